@@ -0,0 +1,124 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/peterh/liner"
+	"github.com/zvchain/zvchain/cmd/gzv/rpc"
+)
+
+const attachDialTimeout = 5 * time.Second
+
+// AttachInit connects to the admin ipc endpoint of the running node and starts the interactive loop
+func AttachInit(ipcPath string) error {
+	if ipcPath == "" {
+		ipcPath = defaultIPCPath()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), attachDialTimeout)
+	defer cancel()
+	client, err := rpc.DialIPC(ctx, ipcPath)
+	if err != nil {
+		return fmt.Errorf("attach to %v error:%v", ipcPath, err)
+	}
+	defer client.Close()
+
+	fmt.Printf("attached to %v, type help for the commands\n", ipcPath)
+	attachLoop(client)
+	return nil
+}
+
+func attachLoop(client *rpc.Client) {
+	line := liner.NewLiner()
+	defer line.Close()
+
+	line.SetCtrlCAborts(true)
+	items := append([]string{"help", "exit"}, adminMethods...)
+	line.SetCompleter(func(line string) (c []string) {
+		for _, n := range items {
+			if strings.HasPrefix(strings.ToLower(n), strings.ToLower(line)) {
+				c = append(c, n)
+			}
+		}
+		return
+	})
+
+	for {
+		input, err := line.Prompt("admin > ")
+		if err != nil {
+			if err == liner.ErrPromptAborted {
+				return
+			}
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		inputArr, err := parseCommandLine(input)
+		if err != nil {
+			fmt.Println(err.Error())
+			continue
+		}
+		line.AppendHistory(input)
+		if len(inputArr) == 0 {
+			continue
+		}
+
+		switch inputArr[0] {
+		case "exit", "quit":
+			return
+		case "help":
+			fmt.Println("Usage: <method> [args...], args are decoded as json if possible, otherwise as string")
+			fmt.Printf("Methods: %v\n", strings.Join(adminMethods, ", "))
+		default:
+			result, err := callAdmin(client, inputArr[0], inputArr[1:])
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			fmt.Println(result)
+		}
+	}
+}
+
+// callAdmin calls the admin method with the arguments and returns the indented json result
+func callAdmin(client *rpc.Client, method string, args []string) (string, error) {
+	params := make([]interface{}, len(args))
+	for i, arg := range args {
+		var v interface{}
+		if err := json.Unmarshal([]byte(arg), &v); err != nil {
+			v = arg
+		}
+		params[i] = v
+	}
+	var raw json.RawMessage
+	if err := client.Call(&raw, "Admin_"+method, params...); err != nil {
+		return "", err
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return string(raw), nil
+	}
+	bs, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
@@ -0,0 +1,87 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/zvchain/zvchain/core"
+)
+
+// exportChain writes the local blocks between the given heights inclusively into the file in the chain archive format.
+// The top of the chain is used if to is 0
+func exportChain(from, to uint64, file string) error {
+	if err := initChainOffline(); err != nil {
+		return err
+	}
+	chain := core.BlockChainImpl
+	defer chain.Close()
+
+	if to == 0 {
+		to = chain.Height()
+	}
+	output(fmt.Sprintf("exporting blocks [%v, %v] to %v", from, to, file))
+	begin := time.Now()
+	count, err := exportChainToFile(chain, from, to, file)
+	if err != nil {
+		return err
+	}
+	output(fmt.Sprintf("exported %v blocks, cost %v", count, time.Since(begin).String()))
+	return nil
+}
+
+// exportChainToFile writes the blocks between the given heights inclusively into the new file in the chain archive
+// format, and returns the count of the blocks written. The file is removed on error
+func exportChainToFile(chain *core.FullBlockChain, from, to uint64, file string) (uint64, error) {
+	f, err := os.OpenFile(filepath.Clean(file), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return 0, err
+	}
+	count, err := chain.ExportChain(f, from, to)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return 0, err
+	}
+	return count, nil
+}
+
+// importChain adds the blocks in the chain archive file onto the local chain, the core and consensus should be inited
+func importChain(file string) error {
+	f, err := os.Open(filepath.Clean(file))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	chain := core.BlockChainImpl
+	output(fmt.Sprintf("importing blocks from %v, local top %v", file, chain.Height()))
+	begin := time.Now()
+	last := begin
+	count, err := chain.ImportChain(f, func(imported uint64) {
+		if time.Since(last) > 10*time.Second {
+			output("blocks imported:", imported, "top:", chain.Height())
+			last = time.Now()
+		}
+	})
+	output(fmt.Sprintf("imported %v blocks, top %v, cost %v", count, chain.Height(), time.Since(begin).String()))
+	return err
+}
@@ -122,6 +122,21 @@ func (ca *RemoteChainOpImpl) nonce(addr string) (uint64, *ErrorResult) {
 	return nonce, nil
 }
 
+func (ca *RemoteChainOpImpl) txChainId() (*uint16, *ErrorResult) {
+	var chainId *uint16
+	res := ca.request("txChainId")
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.Result != nil {
+		err := json.Unmarshal(res.Result, &chainId)
+		if err != nil {
+			return nil, opErrorRes(err)
+		}
+	}
+	return chainId, nil
+}
+
 // Endpoint returns current connected ip and port
 func (ca *RemoteChainOpImpl) Endpoint() string {
 	return ca.base
@@ -158,6 +173,14 @@ func (ca *RemoteChainOpImpl) SendRaw(tx *TxRawData) *RPCResObjCmd {
 		}
 
 	}
+	if tx.ChainId == nil {
+		chainId, errRes := ca.txChainId()
+		if errRes != nil {
+			res.Error = errRes
+			return res
+		}
+		tx.ChainId = chainId
+	}
 	tranx := txRawToTransaction(tx)
 	sign, err := privateKey.Sign(tranx.Hash.Bytes())
 	if err != nil {
@@ -442,6 +465,82 @@ func (ca *RemoteChainOpImpl) TxReceipt(hash string) *RPCResObjCmd {
 	return ca.request("txReceipt", hash)
 }
 
+const (
+	defaultTxPriceBump = 10   // same as the default tx_price_bump, the minimum price bump in percent of the pool for replacing
+	cancelTxGasLimit   = 3000 // gas limit of the zero-value transfer for cancelling
+)
+
+// pendingTx returns the raw data of the transaction waiting in the pool
+func (ca *RemoteChainOpImpl) pendingTx(hash string) (*TxRawData, *ErrorResult) {
+	res := ca.request("pendingTx", hash)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	var tx TxRawData
+	if err := json.Unmarshal(res.Result, &tx); err != nil {
+		return nil, opErrorRes(err)
+	}
+	return &tx, nil
+}
+
+// replaceTx sends the transaction generated from the pending one with the same nonce and a bumped gas price
+// to replace it. The gas price is bumped by bump percent if not given
+func (ca *RemoteChainOpImpl) replaceTx(hash string, gasPrice uint64, bump uint64, gen func(old *TxRawData) *TxRawData) *RPCResObjCmd {
+	res := new(RPCResObjCmd)
+	aci, err := ca.aop.AccountInfo()
+	if err != nil {
+		res.Error = opErrorRes(err)
+		return res
+	}
+	old, errRes := ca.pendingTx(hash)
+	if errRes != nil {
+		res.Error = errRes
+		return res
+	}
+	if old.Source != aci.Address {
+		res.Error = opErrorRes(fmt.Errorf("transaction not sent by the unlocked account %v", aci.Address))
+		return res
+	}
+	if gasPrice == 0 {
+		gasPrice = bumpGasPrice(old.GasPrice, bump)
+	} else if gasPrice <= old.GasPrice {
+		res.Error = opErrorRes(fmt.Errorf("gas price should be higher than %v", old.GasPrice))
+		return res
+	}
+	tx := gen(old)
+	tx.Nonce = old.Nonce
+	tx.GasPrice = gasPrice
+	return ca.SendRaw(tx)
+}
+
+// SpeedUp replaces the pending transaction by the same one with a higher gas price
+func (ca *RemoteChainOpImpl) SpeedUp(hash string, gasPrice uint64, bump uint64) *RPCResObjCmd {
+	return ca.replaceTx(hash, gasPrice, bump, func(old *TxRawData) *TxRawData {
+		return old
+	})
+}
+
+// Cancel replaces the pending transaction by a zero-value transfer to self with a higher gas price
+func (ca *RemoteChainOpImpl) Cancel(hash string, gasPrice uint64, bump uint64) *RPCResObjCmd {
+	return ca.replaceTx(hash, gasPrice, bump, func(old *TxRawData) *TxRawData {
+		return &TxRawData{
+			Target:   old.Source,
+			TxType:   types.TransactionTypeTransfer,
+			GasLimit: cancelTxGasLimit,
+		}
+	})
+}
+
+// bumpGasPrice returns the lowest gas price the pool accepts to replace the given one by bump percent,
+// the bumped price is rounded down as the pool does and must be higher than the given one
+func bumpGasPrice(price uint64, bump uint64) uint64 {
+	bumped := price * (100 + bump) / 100
+	if bumped <= price {
+		bumped = price + 1
+	}
+	return bumped
+}
+
 func (ca *RemoteChainOpImpl) GroupCheck(addr string) *RPCResObjCmd {
 	return ca.request("groupCheck", addr)
 }
@@ -33,4 +33,11 @@ type minerConfig struct {
 	resetHash         string
 	cors              string
 	privateKey        string
+	enableWS          bool
+	wsPort            uint16
+	ipcPath           string
+	disableIPC        bool
+	enableMetrics     bool
+	metricsHost       string
+	metricsPort       uint16
 }
@@ -479,6 +479,49 @@ func (c *gasBaseCmd) initBase() {
 	c.fs.StringVar(&c.gasPriceStr, "gasprice", "500RA", "gas price, default 500RA")
 }
 
+type replaceTxCmd struct {
+	baseCmd
+	hash        string
+	gasPriceStr string
+	gasPrice    uint64
+	bump        uint64
+}
+
+func genReplaceTxCmd(n string, h string) *replaceTxCmd {
+	c := &replaceTxCmd{
+		baseCmd: *genBaseCmd(n, h),
+	}
+	c.fs.StringVar(&c.hash, "hash", "", "the hex hash of the pending transaction")
+	c.fs.StringVar(&c.gasPriceStr, "gasprice", "", "the new gas price, optional. will bump the old one by the bump percent if not specified")
+	c.fs.Uint64Var(&c.bump, "bump", defaultTxPriceBump, "the gas price bump in percent if gasprice not specified, should be at least the tx_price_bump of the node, default 10")
+	return c
+}
+
+func (c *replaceTxCmd) parse(args []string) bool {
+	if err := c.fs.Parse(args); err != nil {
+		output(err.Error())
+		return false
+	}
+	if strings.TrimSpace(c.hash) == "" {
+		output("please input the transaction hash")
+		c.fs.PrintDefaults()
+		return false
+	}
+	if !validateHash(c.hash) {
+		outputJSONErr(opErrorRes(fmt.Errorf("wrong hash format")))
+		return false
+	}
+	if strings.TrimSpace(c.gasPriceStr) != "" {
+		gp, err := common.ParseCoin(c.gasPriceStr)
+		if err != nil {
+			outputJSONErr(opErrorRes(fmt.Errorf("%v:%v, correct example: 100RA,100kRA,1mRA,1ZVC", err, c.gasPriceStr)))
+			return false
+		}
+		c.gasPrice = gp
+	}
+	return true
+}
+
 type sendTxCmd struct {
 	gasBaseCmd
 	to           string
@@ -489,6 +532,7 @@ type sendTxCmd struct {
 	contractPath string
 	txType       int
 	extraData    string
+	expireHeight uint64
 }
 
 func genSendTxCmd() *sendTxCmd {
@@ -504,12 +548,13 @@ func genSendTxCmd() *sendTxCmd {
 	c.fs.StringVar(&c.contractName, "contractname", "", "the name of the contract.")
 	c.fs.StringVar(&c.contractPath, "contractpath", "", "the path to the contract file.")
 	c.fs.IntVar(&c.txType, "type", 0, "transaction type: 0=general tx, 1=contract create, 2=contract call, 4=stake add ,5=miner abort, 6=stake reduce, 7=stake refund")
+	c.fs.Uint64Var(&c.expireHeight, "expire", 0, "the last block height the transaction can be executed at, optional. never expires if not specified")
 	return c
 }
 
 func (c *sendTxCmd) toTxRaw() *TxRawData {
 	value, _ := parseRaFromString(c.value)
-	return &TxRawData{
+	raw := &TxRawData{
 		Target:    c.to,
 		Value:     value,
 		TxType:    c.txType,
@@ -519,6 +564,10 @@ func (c *sendTxCmd) toTxRaw() *TxRawData {
 		Nonce:     c.nonce,
 		ExtraData: []byte(c.extraData),
 	}
+	if c.expireHeight > 0 {
+		raw.ExpireHeight = &c.expireHeight
+	}
+	return raw
 }
 
 func (c *sendTxCmd) parse(args []string) bool {
@@ -960,6 +1009,8 @@ var cmdTx = genTxCmd()
 var cmdReceipt = genReceiptCmd()
 var cmdBlock = genBlockCmd()
 var cmdSendTx = genSendTxCmd()
+var cmdSpeedUp = genReplaceTxCmd("speedup", "replace the pending transaction by the same one with a higher gas price")
+var cmdCancel = genReplaceTxCmd("cancel", "replace the pending transaction by a zero-value transfer to self with a higher gas price")
 var cmdApplyGuardMiner = genApplyGuardMinerCmd()
 var cmdVoteMinerPool = genVoteMinerPoolCmd()
 
@@ -997,6 +1048,8 @@ func init() {
 	list = append(list, &cmdReceipt.baseCmd)
 	list = append(list, &cmdBlock.baseCmd)
 	list = append(list, &cmdSendTx.baseCmd)
+	list = append(list, &cmdSpeedUp.baseCmd)
+	list = append(list, &cmdCancel.baseCmd)
 	list = append(list, &cmdStakeAdd.baseCmd)
 	list = append(list, &cmdMinerAbort.baseCmd)
 	list = append(list, &cmdChangeGuardNode.baseCmd)
@@ -1029,7 +1082,7 @@ func ConsoleInit(keystore, url string, show bool, rpchost string, rpcport int) e
 	}
 
 	if rpcport > 0 {
-		ws := NewWalletServer(rpchost, rpcport, aop)
+		ws := NewWalletServer(rpchost, rpcport, aop, chainop)
 		if err := ws.Start(); err != nil {
 			return err
 		}
@@ -1281,6 +1334,20 @@ func loop(acm accountOp, chainOp chainOp) {
 					return chainOp.SendRaw(cmd.toTxRaw())
 				})
 			}
+		case cmdSpeedUp.name:
+			cmd := genReplaceTxCmd(cmdSpeedUp.name, cmdSpeedUp.help)
+			if cmd.parse(args) {
+				handleCmdForChain(func() *RPCResObjCmd {
+					return chainOp.SpeedUp(cmd.hash, cmd.gasPrice, cmd.bump)
+				})
+			}
+		case cmdCancel.name:
+			cmd := genReplaceTxCmd(cmdCancel.name, cmdCancel.help)
+			if cmd.parse(args) {
+				handleCmdForChain(func() *RPCResObjCmd {
+					return chainOp.Cancel(cmd.hash, cmd.gasPrice, cmd.bump)
+				})
+			}
 		case cmdStakeAdd.name:
 			cmd := genStakeAddCmd()
 			if cmd.parse(args) {
@@ -92,3 +92,21 @@ func TestStringToUint(t *testing.T) {
 		t.Fatal("should be error")
 	}
 }
+
+func TestBumpGasPrice(t *testing.T) {
+	cases := []struct {
+		price, bump, expect uint64
+	}{
+		{500, 10, 550},
+		{501, 10, 551},
+		{509, 10, 559},
+		{1, 10, 2},
+		{0, 10, 1},
+		{500, 0, 501},
+	}
+	for _, c := range cases {
+		if got := bumpGasPrice(c.price, c.bump); got != c.expect {
+			t.Errorf("bump %v by %v%%, expect %v but got %v", c.price, c.bump, c.expect, got)
+		}
+	}
+}
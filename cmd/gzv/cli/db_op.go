@@ -0,0 +1,126 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zvchain/zvchain/core"
+	"github.com/zvchain/zvchain/storage/tasdb"
+)
+
+// convertDB copies the database in src into a new database in dest created by the given storage engine
+func convertDB(src, dest, engine string) error {
+	from := tasdb.DetectEngine(src)
+	if from == "" {
+		return fmt.Errorf("no database found in %v", src)
+	}
+	if from == engine {
+		return fmt.Errorf("database %v is already of the %v engine", src, engine)
+	}
+	output(fmt.Sprintf("converting %v(%v) to %v(%v)", src, from, dest, engine))
+	begin := time.Now()
+	last := begin
+	copied, err := tasdb.Convert(src, dest, engine, func(copied int) {
+		if time.Since(last) > 10*time.Second {
+			output("entries copied:", copied)
+			last = time.Now()
+		}
+	})
+	if err != nil {
+		return err
+	}
+	output(fmt.Sprintf("converted %v entries, cost %v", copied, time.Since(begin).String()))
+	return nil
+}
+
+// migrateDB migrates the database configured to the latest schema version, or only reports
+// the changes of each migration in the dry run
+func migrateDB(dryRun bool) error {
+	begin := time.Now()
+	last := begin
+	version, stats, err := core.MigrateDatabase(dryRun, func(stat *core.MigrationStat) {
+		if time.Since(last) > 10*time.Second {
+			output(fmt.Sprintf("migrating to version %v, scanned %v, changed %v, deleted %v", stat.Version, stat.Scanned, stat.Changed, stat.Deleted))
+			last = time.Now()
+		}
+	})
+	for _, stat := range stats {
+		output(fmt.Sprintf("version %v: %v, scanned %v, changed %v, deleted %v", stat.Version, stat.Name, stat.Scanned, stat.Changed, stat.Deleted))
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(stats) == 0:
+		output(fmt.Sprintf("database is up to date at version %v", version))
+	case dryRun:
+		output(fmt.Sprintf("dry run from version %v, nothing written, cost %v", version, time.Since(begin).String()))
+	default:
+		output(fmt.Sprintf("migrated from version %v to %v, cost %v", version, stats[len(stats)-1].Version, time.Since(begin).String()))
+	}
+	return nil
+}
+
+// checkDB verifies the chain data from the top down, and truncates the chain to the highest fully
+// consistent block if repair
+func checkDB(depth uint64, repair bool) error {
+	checker, err := core.NewChainChecker(!repair)
+	if err != nil {
+		return err
+	}
+	defer checker.Close()
+
+	begin := time.Now()
+	last := begin
+	result, err := checker.Check(depth, func(p *core.ChainProblem) {
+		output(fmt.Sprintf("height %v: %v", p.Height, p.Err))
+	}, func(height uint64) {
+		if time.Since(last) > 10*time.Second {
+			output("checking height", height)
+			last = time.Now()
+		}
+	})
+	if err != nil {
+		return err
+	}
+	output(fmt.Sprintf("checked %v blocks from %v down, %v problems found, cost %v", result.Checked, result.Highest, result.Problems, time.Since(begin).String()))
+	if result.Base > 0 {
+		output(fmt.Sprintf("no blocks stored below %v, the state imported from the snapshot", result.Base))
+	}
+	consistent := result.Consistent
+	if consistent == nil {
+		return fmt.Errorf("no consistent block found")
+	}
+	output(fmt.Sprintf("highest consistent block %v at %v", consistent.Hash.Hex(), consistent.Height))
+	if !repair {
+		if result.Problems > 0 {
+			return fmt.Errorf("%v problems found", result.Problems)
+		}
+		return nil
+	}
+	if result.Problems == 0 && result.Current != nil && result.Current.Hash == consistent.Hash {
+		output("nothing to repair")
+		return nil
+	}
+	removed, err := checker.Repair(consistent)
+	if err != nil {
+		return err
+	}
+	output(fmt.Sprintf("removed %v blocks, the top is %v now", removed, consistent.Height))
+	return nil
+}
@@ -47,6 +47,7 @@ import (
 	time2 "github.com/zvchain/zvchain/middleware/time"
 	"github.com/zvchain/zvchain/middleware/types"
 	"github.com/zvchain/zvchain/monitor"
+	"github.com/zvchain/zvchain/storage/tasdb"
 )
 
 const (
@@ -76,6 +77,14 @@ func (gzv *Gzv) miner(cfg *minerConfig) error {
 	if err != nil {
 		return err
 	}
+	err = gzv.startAdminIPC()
+	if err != nil {
+		return err
+	}
+	err = gzv.startMetrics()
+	if err != nil {
+		return err
+	}
 	ok := mediator.StartMiner()
 
 	fmt.Println("Syncing block and group info from ZV net.Waiting...")
@@ -117,7 +126,7 @@ func (gzv *Gzv) exit(ctrlC <-chan bool, quit chan<- bool) {
 	fmt.Println("exiting...")
 	core.BlockChainImpl.Close()
 	//taslog.Close()
-	mediator.StopMiner()
+	mediator.CloseMiner()
 	if gzv.inited {
 		quit <- true
 	} else {
@@ -163,6 +172,13 @@ func (gzv *Gzv) Run() {
 	disableNotice := mineCmd.Flag("disableNotice", "disable version upgrade notifications .").Default("false").Bool()
 
 	cors := mineCmd.Flag("cors", "set cors host, set 'all' allow any host").Default("").String()
+	ws := mineCmd.Flag("ws", "start websocket rpc server which supports subscriptions, the rpc service level is the same as --rpc").Bool()
+	wsPort := mineCmd.Flag("wsport", "websocket rpc service port").Default("8102").Uint16()
+	ipcPath := mineCmd.Flag("ipcpath", "path of the admin ipc socket, default is gzv.ipc in the database directory").Default("").String()
+	noIPC := mineCmd.Flag("noipc", "disable the admin ipc service").Bool()
+	enableMetrics := mineCmd.Flag("metrics", "start the http server exposing the metrics at /metrics in prometheus format").Bool()
+	metricsHost := mineCmd.Flag("metricshost", "metrics service host").Default("127.0.0.1").IP()
+	metricsPort := mineCmd.Flag("metricsport", "metrics service port").Default("8103").Uint16()
 	super := mineCmd.Flag("super", "start super node").Bool()
 	instanceIndex := mineCmd.Flag("instance", "instance index").Short('i').Default("0").Int()
 	*instanceIndex = 0
@@ -183,6 +199,41 @@ func (gzv *Gzv) Run() {
 	natPort := mineCmd.Flag("natport", "nat server port").Default("3100").Uint16()
 	chainID := mineCmd.Flag("chainid", "chain id").Default("0").Uint16()
 
+	attachCmd := app.Command("attach", "attach to the admin ipc service of the running node")
+	attachPath := attachCmd.Flag("ipcpath", "path of the admin ipc socket, default is gzv.ipc in the database directory").Default("").String()
+
+	rpcSchemaCmd := app.Command("rpc-schema", "dump the OpenRPC document of the rpc apis")
+	schemaLevel := rpcSchemaCmd.Flag("rpc", "rpc service level of the apis included").Default(strconv.FormatInt(int64(rpcLevelDev), 10)).Int()
+	schemaOut := rpcSchemaCmd.Flag("out", "file for output the document, default is stdout").Default("").String()
+
+	dbCmd := app.Command("db", "database maintenance tools")
+	dbConvertCmd := dbCmd.Command("convert", "copy a database directory into a new one created by another storage engine")
+	convertSrc := dbConvertCmd.Flag("src", "directory of the database to convert").Required().String()
+	convertDest := dbConvertCmd.Flag("dest", "directory of the new database, which should not exist").Required().String()
+	convertEngine := dbConvertCmd.Flag("engine", fmt.Sprintf("storage engine of the new database, one of %v", tasdb.EngineNames())).Required().String()
+	dbMigrateCmd := dbCmd.Command("migrate", "migrate the database to the latest schema version")
+	migrateDryRun := dbMigrateCmd.Flag("dry-run", "only report what would change without writing").Bool()
+	dbCheckCmd := dbCmd.Command("check", "verify the consistency of the chain data from the top down")
+	checkDepth := dbCheckCmd.Flag("depth", "number of blocks to check from the top, 0 for all").Default("0").Uint64()
+	dbRepairCmd := dbCmd.Command("repair", "truncate the chain to the highest fully consistent block")
+	repairDepth := dbRepairCmd.Flag("depth", "number of blocks to check from the top, 0 for all").Default("0").Uint64()
+
+	exportCmd := app.Command("export", "export the blocks in a height range into a chain archive file")
+	exportFrom := exportCmd.Flag("from", "lowest height of the blocks to export").Default("0").Uint64()
+	exportTo := exportCmd.Flag("to", "highest height of the blocks to export, default is the top").Default("0").Uint64()
+	exportFile := exportCmd.Flag("out", "archive file for output, which should not exist").Required().String()
+
+	importCmd := app.Command("import", "validate and add the blocks in a chain archive file onto the local chain")
+	importFile := importCmd.Arg("file", "archive file to import").Required().String()
+
+	stateCmd := app.Command("state", "state snapshot tools")
+	stateExportCmd := stateCmd.Command("export", "export the state at a checkpoint height into a snapshot file")
+	exportHeight := stateExportCmd.Flag("height", "height of the state to export, default is the latest checkpoint").Default("0").Uint64()
+	exportOut := stateExportCmd.Flag("out", "snapshot file for output, which should not exist").Required().String()
+	stateImportCmd := stateCmd.Command("import", "rebuild the state from a snapshot file into an empty chain")
+	importIn := stateImportCmd.Flag("in", "snapshot file to import").Required().String()
+	importHash := stateImportCmd.Flag("hash", "hash of the snapshot block got from a trusted source").Required().String()
+
 	clearCmd := app.Command("clear", "Clear the data of blockchain")
 
 	replayCmd := app.Command("replay", "replay the existing blocks")
@@ -250,6 +301,13 @@ func (gzv *Gzv) Run() {
 			resetHash:         *reset,
 			cors:              *cors,
 			privateKey:        *privKey,
+			enableWS:          *ws,
+			wsPort:            *wsPort,
+			ipcPath:           *ipcPath,
+			disableIPC:        *noIPC,
+			enableMetrics:     *enableMetrics,
+			metricsHost:       metricsHost.String(),
+			metricsPort:       *metricsPort,
 		}
 		gzv.config = cfg
 
@@ -274,6 +332,80 @@ func (gzv *Gzv) Run() {
 			"version": common.GzvVersion,
 		}).Info("versionLog")
 		gzv.InitCha <- true
+	case attachCmd.FullCommand():
+		if err := AttachInit(*attachPath); err != nil {
+			fmt.Println(err.Error())
+		}
+	case rpcSchemaCmd.FullCommand():
+		if err := rpcSchema(rpcLevel(*schemaLevel), *schemaOut); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(-1)
+		}
+		os.Exit(0)
+	case dbConvertCmd.FullCommand():
+		if err := convertDB(*convertSrc, *convertDest, *convertEngine); err != nil {
+			output("convert error", err)
+			os.Exit(-1)
+		}
+		os.Exit(0)
+	case dbMigrateCmd.FullCommand():
+		if err := migrateDB(*migrateDryRun); err != nil {
+			output("migrate error", err)
+			os.Exit(-1)
+		}
+		os.Exit(0)
+	case dbCheckCmd.FullCommand():
+		log.Init()
+		if err := checkDB(*checkDepth, false); err != nil {
+			output("check error", err)
+			os.Exit(-1)
+		}
+		os.Exit(0)
+	case dbRepairCmd.FullCommand():
+		log.Init()
+		if err := checkDB(*repairDepth, true); err != nil {
+			output("repair error", err)
+			os.Exit(-1)
+		}
+		os.Exit(0)
+	case exportCmd.FullCommand():
+		if err := exportChain(*exportFrom, *exportTo, *exportFile); err != nil {
+			output("export error", err)
+			os.Exit(-1)
+		}
+		os.Exit(0)
+	case importCmd.FullCommand():
+		log.Init()
+		types.InitMiddleware()
+
+		gzv.config = &minerConfig{
+			keystore:   *keystore,
+			password:   *passWd,
+			privateKey: *privKey,
+		}
+		if err := gzv.coreInit(); err != nil {
+			output("initialize fail:", err)
+			os.Exit(-1)
+		}
+		err := importChain(*importFile)
+		core.BlockChainImpl.Close()
+		if err != nil {
+			output("import error", err)
+			os.Exit(-1)
+		}
+		os.Exit(0)
+	case stateExportCmd.FullCommand():
+		if err := exportState(*exportHeight, *exportOut); err != nil {
+			output("export state error", err)
+			os.Exit(-1)
+		}
+		os.Exit(0)
+	case stateImportCmd.FullCommand():
+		if err := importState(*importIn, *importHash); err != nil {
+			output("import state error", err)
+			os.Exit(-1)
+		}
+		os.Exit(0)
 	case clearCmd.FullCommand():
 		err := ClearBlock()
 		if err != nil {
@@ -16,14 +16,20 @@
 package cli
 
 import (
+	"path/filepath"
+
 	"github.com/zvchain/zvchain/cmd/gzv/rpc"
+	"github.com/zvchain/zvchain/common"
 	"github.com/zvchain/zvchain/consensus/group"
 	"github.com/zvchain/zvchain/core"
 	"github.com/zvchain/zvchain/log"
+	"github.com/zvchain/zvchain/middleware/metrics"
 	"net"
+	"net/http"
 
 	"fmt"
 	"strings"
+	"time"
 )
 
 // rpcLevel indicate the rpc service function
@@ -36,38 +42,46 @@ const (
 	rpcLevelDev                      // Enable all functions including functions for debug or developer use
 )
 
+const ipcFileName = "gzv.ipc"
+
+const (
+	rpcSection            = "rpc"
+	rpcTokenSectionPrefix = "rpc_token_"
+)
+
 // rpcApi defines rpc service instance interface
 type rpcApi interface {
 	Namespace() string
 	Version() string
 }
 
-func (gzv *Gzv) addInstance(inst rpcApi) {
-	gzv.rpcInstances = append(gzv.rpcInstances, inst)
-}
-
 func (gzv *Gzv) initRpcInstances() error {
 	level := gzv.config.rpcLevel
 	if level < rpcLevelMiner || level > rpcLevelDev {
 		return fmt.Errorf("rpc level error:%v", level)
 	}
 	base := &rpcBaseImpl{gr: getGroupReader(), br: core.BlockChainImpl}
-	gzv.rpcInstances = make([]rpcApi, 0)
-	gzv.addInstance(&RpcMinerImpl{base})
+	gzv.rpcInstances = newRpcInstances(level, base, group.GroupRoutine)
+	return nil
+}
+
+// newRpcInstances returns the api instances enabled by the given rpc service level
+func newRpcInstances(level rpcLevel, base *rpcBaseImpl, checker groupRoutineChecker) []rpcApi {
+	instances := []rpcApi{&RpcMinerImpl{base}}
 	if level >= rpcLevelGtas {
-		gzv.addInstance(&RpcGzvImpl{rpcBaseImpl: base, routineChecker: group.GroupRoutine})
+		instances = append(instances, &RpcGzvImpl{rpcBaseImpl: base, routineChecker: checker}, &RpcTxPoolImpl{rpcBaseImpl: base})
 	}
 	if level >= rpcLevelExplorer {
-		gzv.addInstance(&RpcExplorerImpl{rpcBaseImpl: base})
+		instances = append(instances, &RpcExplorerImpl{rpcBaseImpl: base})
 	}
 	if level >= rpcLevelDev {
-		gzv.addInstance(&RpcDevImpl{rpcBaseImpl: base})
+		instances = append(instances, &RpcDevImpl{rpcBaseImpl: base}, &RpcDebugImpl{rpcBaseImpl: base})
 	}
-	return nil
+	return instances
 }
 
 // startHTTP initializes and starts the HTTP RPC endpoint.
-func startHTTP(endpoint string, apis []rpc.API, modules []string, cors []string, vhosts []string) error {
+func startHTTP(endpoint string, apis []rpc.API, modules []string, cors []string, vhosts []string, guard *rpc.HTTPGuardConfig) error {
 	// Short circuit if the HTTP endpoint isn't being exposed
 	if endpoint == "" {
 		return nil
@@ -95,7 +109,99 @@ func startHTTP(endpoint string, apis []rpc.API, modules []string, cors []string,
 	if listener, err = net.Listen("tcp", endpoint); err != nil {
 		return err
 	}
-	go rpc.NewHTTPServer(cors, vhosts, handler).Serve(listener)
+	go rpc.NewHTTPServer(cors, vhosts, handler, guard).Serve(listener)
+	return nil
+}
+
+// startWS initializes and starts the websocket RPC endpoint which supports the subscriptions.
+func startWS(endpoint string, apis []rpc.API, modules []string, wsOrigins []string) error {
+	// Short circuit if the WS endpoint isn't being exposed
+	if endpoint == "" {
+		return nil
+	}
+	isPruneMode := core.BlockChainImpl.IsPruneMode()
+	// Generate the whitelist based on the allowed modules
+	whitelist := make(map[string]bool)
+	for _, module := range modules {
+		whitelist[module] = true
+	}
+	// Register all the APIs exposed by the services
+	handler := rpc.NewServer(isPruneMode)
+	for _, api := range apis {
+		if whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
+			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
+				return err
+			}
+		}
+	}
+	// All APIs registered, start the WS listener
+	var (
+		listener net.Listener
+		err      error
+	)
+	if listener, err = net.Listen("tcp", endpoint); err != nil {
+		return err
+	}
+	go rpc.NewWSServer(wsOrigins, handler).Serve(listener)
+	return nil
+}
+
+// startIPC initializes and starts the IPC RPC endpoint which serves the admin functions only to the local users.
+func startIPC(endpoint string, apis []rpc.API) error {
+	if endpoint == "" {
+		return nil
+	}
+	handler := rpc.NewServer(core.BlockChainImpl.IsPruneMode())
+	for _, api := range apis {
+		if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
+			return err
+		}
+	}
+	listener, err := rpc.CreateIPCListener(endpoint)
+	if err != nil {
+		return err
+	}
+	go handler.ServeListener(listener)
+	return nil
+}
+
+// defaultIPCPath returns the ipc endpoint in the database directory
+func defaultIPCPath() string {
+	return filepath.Join(common.GlobalConf.GetString("chain", "db_blocks", "d_b"), ipcFileName)
+}
+
+// startAdminIPC starts the ipc endpoint serving the admin namespace
+func (gzv *Gzv) startAdminIPC() error {
+	if gzv.config.disableIPC {
+		return nil
+	}
+	if gzv.config.ipcPath == "" {
+		gzv.config.ipcPath = defaultIPCPath()
+	}
+	base := &rpcBaseImpl{gr: getGroupReader(), br: core.BlockChainImpl}
+	admin := &RpcAdminImpl{rpcBaseImpl: base, gzv: gzv}
+	apis := []rpc.API{{Namespace: admin.Namespace(), Version: admin.Version(), Service: admin, Public: false}}
+	if err := startIPC(gzv.config.ipcPath, apis); err != nil {
+		return err
+	}
+	log.DefaultLogger.Infof("IPC serving on %v\n", gzv.config.ipcPath)
+	return nil
+}
+
+// startMetrics starts the http server exposing the metrics of the default registry
+func (gzv *Gzv) startMetrics() error {
+	if !gzv.config.enableMetrics {
+		return nil
+	}
+	endpoint := fmt.Sprintf("%s:%d", gzv.config.metricsHost, gzv.config.metricsPort)
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler(metrics.DefaultRegistry))
+	go http.Serve(listener, mux)
+	log.DefaultLogger.Infof("Metrics serving on %v\n", endpoint)
 	return nil
 }
 
@@ -124,9 +230,25 @@ func (gzv *Gzv) startRPC() error {
 		cors = strings.Split(gzv.config.cors, ",")
 	}
 
+	guard, err := loadHTTPGuardConfig()
+	if err != nil {
+		return err
+	}
+	if gzv.config.enableWS {
+		// The websocket endpoint isn't guarded
+		if guard.RequireAuth {
+			return fmt.Errorf("websocket rpc can't be enabled when require_auth is set")
+		}
+		endpoint := fmt.Sprintf("%s:%d", host, gzv.config.wsPort)
+		if err = startWS(endpoint, apis, []string{}, cors); err != nil {
+			return err
+		}
+		log.DefaultLogger.Infof("WS RPC serving on %v\n", endpoint)
+	}
+
 	for plus := 0; plus < 40; plus++ {
 		endpoint := fmt.Sprintf("%s:%d", host, port+uint16(plus))
-		err = startHTTP(endpoint, apis, []string{}, cors, []string{})
+		err = startHTTP(endpoint, apis, []string{}, cors, []string{}, guard)
 		if err == nil {
 			log.DefaultLogger.Errorf("RPC serving on %v\n", endpoint)
 			return nil
@@ -139,3 +261,71 @@ func (gzv *Gzv) startRPC() error {
 	}
 	return err
 }
+
+// loadHTTPGuardConfig reads the access control of the http rpc service from the rpc section of the config file:
+//
+//	require_auth      reject the requests without the bearer token, the websocket rpc can't be enabled then
+//	allow             namespaces or methods allowed for the requests without the token, all if empty
+//	tokens            names of the static tokens, each configured in the section rpc_token_<name>
+//	                  with the keys token, allow, rate and burst
+//	token_rate        default requests per second of each token
+//	token_burst       default max requests in a burst of each token
+//	jwt_secret        secret of the HS256 signed jwt tokens, jwt disabled if empty
+//	jwt_allow         namespaces or methods allowed for the jwt tokens without the allow claim
+//	jwt_max_lifetime  seconds the jwt tokens can live at most from now, 86400 by default
+//	ip_rate           requests per second of each ip
+//	ip_burst          max requests in a burst of each ip
+//	max_batch         max requests in a batch
+//	max_response_size max bytes of a response
+//
+// Rates and sizes not positive are unlimited
+func loadHTTPGuardConfig() (*rpc.HTTPGuardConfig, error) {
+	conf := common.GlobalConf.GetSectionManager(rpcSection)
+	splitList := func(s string) []string {
+		ret := make([]string, 0)
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				ret = append(ret, item)
+			}
+		}
+		return ret
+	}
+	tokenRate := conf.GetDouble("token_rate", 0)
+	tokenBurst := conf.GetInt("token_burst", 0)
+
+	cfg := &rpc.HTTPGuardConfig{
+		RequireAuth:     conf.GetBool("require_auth", false),
+		Anonymous:       rpc.TokenPolicy{Name: "anonymous", Allow: splitList(conf.GetString("allow", ""))},
+		Tokens:          make([]*rpc.TokenPolicy, 0),
+		JWTSecret:       []byte(conf.GetString("jwt_secret", "")),
+		JWT:             rpc.TokenPolicy{Allow: splitList(conf.GetString("jwt_allow", "")), Rate: tokenRate, Burst: tokenBurst},
+		JWTMaxLifetime:  time.Duration(conf.GetInt("jwt_max_lifetime", 86400)) * time.Second,
+		IPRate:          conf.GetDouble("ip_rate", 0),
+		IPBurst:         conf.GetInt("ip_burst", 0),
+		MaxBatch:        conf.GetInt("max_batch", 0),
+		MaxResponseSize: conf.GetInt("max_response_size", 0),
+	}
+	tokens := make(map[string]string)
+	for _, name := range splitList(conf.GetString("tokens", "")) {
+		tokenConf := common.GlobalConf.GetSectionManager(rpcTokenSectionPrefix + name)
+		token := strings.TrimSpace(tokenConf.GetString("token", ""))
+		if token == "" {
+			return nil, fmt.Errorf("token of rpc token %v not configured", name)
+		}
+		if other, ok := tokens[token]; ok {
+			return nil, fmt.Errorf("rpc token %v duplicates with %v", name, other)
+		}
+		tokens[token] = name
+		cfg.Tokens = append(cfg.Tokens, &rpc.TokenPolicy{
+			Name:  name,
+			Token: token,
+			Allow: splitList(tokenConf.GetString("allow", "")),
+			Rate:  tokenConf.GetDouble("rate", tokenRate),
+			Burst: tokenConf.GetInt("burst", tokenBurst),
+		})
+	}
+	if cfg.RequireAuth && len(cfg.Tokens) == 0 && len(cfg.JWTSecret) == 0 {
+		return nil, fmt.Errorf("rpc auth required but neither token nor jwt secret configured")
+	}
+	return cfg, nil
+}
@@ -30,16 +30,19 @@ var (
 )
 
 type TxRawData struct {
-	Source    string `json:"source"`
-	Target    string `json:"target"`
-	Value     uint64 `json:"value"`
-	GasLimit  uint64 `json:"gas_limit"`
-	GasPrice  uint64 `json:"gas_price"`
-	TxType    int    `json:"type"`
-	Nonce     uint64 `json:"nonce"`
-	Data      []byte `json:"data"`
-	Sign      string `json:"sign"`
-	ExtraData []byte `json:"extra_data"`
+	Source    string  `json:"source"`
+	Target    string  `json:"target"`
+	Value     uint64  `json:"value"`
+	GasLimit  uint64  `json:"gas_limit"`
+	GasPrice  uint64  `json:"gas_price"`
+	TxType    int     `json:"type"`
+	Nonce     uint64  `json:"nonce"`
+	Data      []byte  `json:"data"`
+	Sign      string  `json:"sign"`
+	ExtraData []byte  `json:"extra_data"`
+	ChainId   *uint16 `json:"chain_id,omitempty"`
+
+	ExpireHeight *uint64 `json:"expire_height,omitempty"`
 }
 
 func opErrorRes(err error) *ErrorResult {
@@ -79,6 +82,9 @@ func txRawToTransaction(tx *TxRawData) *types.Transaction {
 		Sign:      sign,
 		ExtraData: tx.ExtraData,
 		Source:    &src,
+		ChainId:   tx.ChainId,
+
+		ExpireHeight: tx.ExpireHeight,
 	}
 	return &types.Transaction{RawTransaction: raw, Hash: raw.GenHash()}
 }
@@ -150,6 +156,12 @@ type chainOp interface {
 	ViewContract(addr string) *RPCResObjCmd
 
 	TxReceipt(hash string) *RPCResObjCmd
+	// SpeedUp replaces the pending transaction by the same one with a higher gas price,
+	// which is bumped by bump percent if not given
+	SpeedUp(hash string, gasPrice uint64, bump uint64) *RPCResObjCmd
+	// Cancel replaces the pending transaction by a zero-value transfer to self with a higher gas price,
+	// which is bumped by bump percent if not given
+	Cancel(hash string, gasPrice uint64, bump uint64) *RPCResObjCmd
 
 	GroupCheck(addr string) *RPCResObjCmd
 }
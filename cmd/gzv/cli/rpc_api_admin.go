@@ -0,0 +1,175 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/consensus/mediator"
+	"github.com/zvchain/zvchain/core"
+	"github.com/zvchain/zvchain/log"
+	"github.com/zvchain/zvchain/network"
+)
+
+// adminMethods are the methods of the admin namespace, used by the completer of gzv attach
+var adminMethods = []string{
+	"addPeer", "removePeer", "peers", "setLogLevel", "resetTop",
+	"startMiner", "stopMiner", "nodeInfo", "exportChain",
+}
+
+// RpcAdminImpl provides the operational functions of the node without restarting it.
+// It's only served on the local ipc endpoint
+type RpcAdminImpl struct {
+	*rpcBaseImpl
+	gzv *Gzv
+}
+
+func (api *RpcAdminImpl) Namespace() string {
+	return "Admin"
+}
+
+func (api *RpcAdminImpl) Version() string {
+	return "1"
+}
+
+func netServer() (*network.Server, error) {
+	s, ok := network.GetNetInstance().(*network.Server)
+	if !ok || s == nil {
+		return nil, fmt.Errorf("network not initialized")
+	}
+	return s, nil
+}
+
+// AddPeer connects to the node with the given id and address
+func (api *RpcAdminImpl) AddPeer(id string, ip string, port int) (bool, error) {
+	s, err := netServer()
+	if err != nil {
+		return false, err
+	}
+	if err := s.AddPeer(strings.TrimSpace(id), strings.TrimSpace(ip), port); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RemovePeer disconnects the peer with the given id
+func (api *RpcAdminImpl) RemovePeer(id string) (bool, error) {
+	s, err := netServer()
+	if err != nil {
+		return false, err
+	}
+	if err := s.RemovePeer(strings.TrimSpace(id)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Peers returns the peers connected
+func (api *RpcAdminImpl) Peers() ([]ConnInfo, error) {
+	s, err := netServer()
+	if err != nil {
+		return nil, err
+	}
+	conns := make([]ConnInfo, 0)
+	for _, n := range s.ConnInfo() {
+		conns = append(conns, ConnInfo{ID: n.ID, IP: n.IP, TCPPort: n.Port})
+	}
+	return conns, nil
+}
+
+// SetLogLevel changes the log level of the module, which is the log file name like core and p2p, or all
+func (api *RpcAdminImpl) SetLogLevel(module string, level string) (bool, error) {
+	if err := log.SetLevel(strings.TrimSpace(module), strings.TrimSpace(level)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ResetTop resets the local top to the block of the given hash, or the nearest block before it with the state
+func (api *RpcAdminImpl) ResetTop(hash string) (*Block, error) {
+	hash = strings.TrimSpace(hash)
+	if !validateHash(hash) {
+		return nil, fmt.Errorf("wrong hash format")
+	}
+	bh := core.BlockChainImpl.QueryBlockHeaderByHash(common.HexToHash(hash))
+	if bh == nil {
+		return nil, fmt.Errorf("block not exists of the hash %v", hash)
+	}
+	resetBh, err := core.BlockChainImpl.ResetNear(bh)
+	if err != nil {
+		return nil, err
+	}
+	b := core.BlockChainImpl.QueryBlockByHash(resetBh.Hash)
+	if b == nil {
+		return nil, fmt.Errorf("block not exists after reset")
+	}
+	return convertBlockHeader(b), nil
+}
+
+// StartMiner starts participating in the consensus
+func (api *RpcAdminImpl) StartMiner() (bool, error) {
+	if mediator.IsMining() {
+		return false, fmt.Errorf("miner already started")
+	}
+	return mediator.StartMiner(), nil
+}
+
+// StopMiner stops participating in the consensus while the node keeps syncing blocks
+func (api *RpcAdminImpl) StopMiner() (bool, error) {
+	if !mediator.IsMining() {
+		return false, fmt.Errorf("miner not started")
+	}
+	mediator.StopMiner()
+	return true, nil
+}
+
+// NodeInfo returns the status of the node
+func (api *RpcAdminImpl) NodeInfo() (*AdminNodeInfo, error) {
+	chain := core.BlockChainImpl
+	top := chain.QueryTopBlock()
+	info := &AdminNodeInfo{
+		ID:              mediator.Proc.GetMinerID().GetAddrString(),
+		Version:         common.GzvVersion,
+		ProtocolVersion: common.ProtocolVersion,
+		BlockHeight:     top.Height,
+		TopHash:         top.Hash,
+		GroupHeight:     api.gr.Height(),
+		TxPoolNum:       int(chain.GetTransactionPool().TxNum()),
+		Mining:          mediator.IsMining(),
+		Syncing:         chain.IsSyncing(),
+		PruneMode:       chain.IsPruneMode(),
+	}
+	if api.gzv != nil && api.gzv.config != nil {
+		info.ChainID = api.gzv.config.chainID
+		info.IPC = api.gzv.config.ipcPath
+	}
+	if s, err := netServer(); err == nil {
+		info.Peers = len(s.ConnInfo())
+	}
+	return info, nil
+}
+
+// ExportChain writes the blocks between the given heights inclusively to the file in the chain archive format,
+// and returns the count of the blocks written. The file must not exist
+func (api *RpcAdminImpl) ExportChain(file string, from, to uint64) (uint64, error) {
+	file = strings.TrimSpace(file)
+	if file == "" {
+		return 0, fmt.Errorf("empty file name")
+	}
+	return exportChainToFile(core.BlockChainImpl, from, to, file)
+}
@@ -0,0 +1,72 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/core"
+)
+
+// RpcDebugImpl provides the functions for inspecting the execution of the transactions and blocks
+type RpcDebugImpl struct {
+	*rpcBaseImpl
+}
+
+func (api *RpcDebugImpl) Namespace() string {
+	return "Debug"
+}
+
+func (api *RpcDebugImpl) Version() string {
+	return "1"
+}
+
+// TraceTransaction re-executes the transaction of the given hash and returns the call tree of the contracts
+func (api *RpcDebugImpl) TraceTransaction(hash string) (*TxTrace, error) {
+	hash = strings.TrimSpace(hash)
+	if !validateHash(hash) {
+		return nil, fmt.Errorf("wrong hash format")
+	}
+	trace, err := core.BlockChainImpl.TraceTransaction(common.HexToHash(hash))
+	if err != nil {
+		return nil, err
+	}
+	return convertTxTrace(trace), nil
+}
+
+// TraceBlock re-executes all transactions of the block at the given height and returns their traces
+func (api *RpcDebugImpl) TraceBlock(height uint64) ([]*TxTrace, error) {
+	traces, err := core.BlockChainImpl.TraceBlock(height)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*TxTrace, 0, len(traces))
+	for _, trace := range traces {
+		ret = append(ret, convertTxTrace(trace))
+	}
+	return ret, nil
+}
+
+// StateDiff returns the balance, nonce, code and storage changed by the block at the given height
+func (api *RpcDebugImpl) StateDiff(height uint64) (*StateDiff, error) {
+	diff, err := core.BlockChainImpl.StateDiff(height)
+	if err != nil {
+		return nil, err
+	}
+	return convertStateDiff(diff), nil
+}
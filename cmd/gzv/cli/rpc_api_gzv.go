@@ -21,6 +21,7 @@ import (
 	"github.com/zvchain/zvchain/common"
 	"github.com/zvchain/zvchain/core"
 	"github.com/zvchain/zvchain/middleware/types"
+	"github.com/zvchain/zvchain/params"
 	"github.com/zvchain/zvchain/tvm"
 	"strings"
 )
@@ -79,10 +80,9 @@ func failErrResult(err string) *ErrorResult {
 	}
 }
 
-// Tx is user transaction interface, used for sending transaction to the node
-func (api *RpcGzvImpl) Tx(txRaw *TxRawData) (string, error) {
+func validateTxRawData(txRaw *TxRawData) error {
 	if !validateTxType(txRaw.TxType) {
-		return "", fmt.Errorf("not supported txType")
+		return fmt.Errorf("not supported txType")
 	}
 
 	// Check the address for the specified tx types
@@ -91,11 +91,19 @@ func (api *RpcGzvImpl) Tx(txRaw *TxRawData) (string, error) {
 		types.TransactionTypeStakeReduce,
 		types.TransactionTypeStakeRefund, types.TransactionTypeVoteMinerPool:
 		if !common.ValidateAddress(strings.TrimSpace(txRaw.Target)) {
-			return "", fmt.Errorf("wrong target address format")
+			return fmt.Errorf("wrong target address format")
 		}
 	}
 	if !common.ValidateAddress(txRaw.Source) {
-		return "", fmt.Errorf("wrong source address")
+		return fmt.Errorf("wrong source address")
+	}
+	return nil
+}
+
+// Tx is user transaction interface, used for sending transaction to the node
+func (api *RpcGzvImpl) Tx(txRaw *TxRawData) (string, error) {
+	if err := validateTxRawData(txRaw); err != nil {
+		return "", err
 	}
 
 	trans := txRawToTransaction(txRaw)
@@ -337,6 +345,25 @@ func (api *RpcGzvImpl) TransDetail(h string) (*Transaction, error) {
 	return nil, nil
 }
 
+// PendingTx returns the raw data of the transaction still waiting in the pool,
+// with which the sender can re-sign a transaction with same nonce to replace it
+func (api *RpcGzvImpl) PendingTx(h string) (*TxRawData, error) {
+	h = strings.TrimSpace(h)
+	if !validateHash(h) {
+		return nil, fmt.Errorf("wrong hash format")
+	}
+	hash := common.HexToHash(h)
+	pool := core.BlockChainImpl.GetTransactionPool()
+	if pool.GetReceipt(hash) != nil {
+		return nil, fmt.Errorf("transaction already executed")
+	}
+	tx := pool.GetTransaction(false, hash)
+	if tx == nil {
+		return nil, fmt.Errorf("transaction not in the pool")
+	}
+	return convertTxRawData(tx), nil
+}
+
 func (api *RpcGzvImpl) Nonce(addr string) (uint64, error) {
 	addr = strings.TrimSpace(addr)
 	if !common.ValidateAddress(addr) {
@@ -348,6 +375,11 @@ func (api *RpcGzvImpl) Nonce(addr string) (uint64, error) {
 	return nonce, nil
 }
 
+// TxChainId returns the chain id that the transaction sent now should carry, which is nil before zip004
+func (api *RpcGzvImpl) TxChainId() (*uint16, error) {
+	return params.GetChainConfig().TxChainId(core.BlockChainImpl.Height() + 1), nil
+}
+
 func (api *RpcGzvImpl) TxReceipt(h string) (*ExecutedTransaction, error) {
 	h = strings.TrimSpace(h)
 	if !validateHash(h) {
@@ -519,3 +551,145 @@ func (api *RpcGzvImpl) CheckPointAt(h uint64) (*types.BlockHeader, error) {
 func (api *RpcGzvImpl) LatestCheckPoint() (*types.BlockHeader, error) {
 	return api.CheckPointAt(api.br.Height())
 }
+
+// Call executes the abi on the contract as the from address over the state at the given height,
+// and the state changes are discarded. The latest state is used if height not given
+func (api *RpcGzvImpl) Call(from string, contract string, abiJSON string, height *uint64) (*ContractCallResult, error) {
+	from = strings.TrimSpace(from)
+	if !common.ValidateAddress(from) {
+		return nil, fmt.Errorf("wrong from address format")
+	}
+	contract = strings.TrimSpace(contract)
+	if !common.ValidateAddress(contract) {
+		return nil, fmt.Errorf("wrong contract address format")
+	}
+	h := api.br.Height()
+	if height != nil {
+		h = *height
+	}
+	ret, err := core.BlockChainImpl.CallContract(common.StringToAddress(from), common.StringToAddress(contract), abiJSON, h)
+	if err != nil {
+		return nil, err
+	}
+	result := &ContractCallResult{
+		Content: ret.Content,
+		Logs:    ret.Logs,
+		GasUsed: ret.GasUsed,
+	}
+	if ret.Err != nil {
+		result.Error = ret.Err.Message
+	}
+	return result, nil
+}
+
+// GetProof returns the merkle proof of the account and the given contract storage keys at the height.
+// The proof is built on the state of the top block if height not given
+func (api *RpcGzvImpl) GetProof(addr string, storageKeys []string, height *uint64) (*AccountProof, error) {
+	addr = strings.TrimSpace(addr)
+	if !common.ValidateAddress(addr) {
+		return nil, fmt.Errorf("wrong account address format")
+	}
+	if len(storageKeys) > maxProofStorageKeys {
+		return nil, fmt.Errorf("storage keys count should not larger than %v", maxProofStorageKeys)
+	}
+	keys := make([][]byte, 0, len(storageKeys))
+	for _, key := range storageKeys {
+		keys = append(keys, []byte(key))
+	}
+	h := api.br.Height()
+	if height != nil {
+		h = *height
+	}
+	bh, proof, err := core.BlockChainImpl.GetProof(common.StringToAddress(addr), keys, h)
+	if err != nil {
+		return nil, err
+	}
+	return convertAccountProof(bh, proof), nil
+}
+
+// TxsByAddress returns the transactions sent from or to the address, including the transfers made inside
+// the contracts. Pass an empty cursor for the first page, and the next_cursor returned for the following pages.
+// Direction is either "asc" or "desc", default to "desc" which returns the latest transactions first
+func (api *RpcGzvImpl) TxsByAddress(addr string, cursor string, limit uint64, direction *string) (*AddressTxs, error) {
+	addr = strings.TrimSpace(addr)
+	if !common.ValidateAddress(addr) {
+		return nil, fmt.Errorf("wrong account address format")
+	}
+	if limit == 0 || limit > maxTxsByAddressLimit {
+		return nil, fmt.Errorf("limit should be in range [1, %v]", maxTxsByAddressLimit)
+	}
+	desc := true
+	if direction != nil {
+		switch strings.ToLower(strings.TrimSpace(*direction)) {
+		case "", "desc":
+		case "asc":
+			desc = false
+		default:
+			return nil, fmt.Errorf("direction should be asc or desc")
+		}
+	}
+	var pos []byte
+	if cursor = strings.TrimSpace(cursor); cursor != "" {
+		pos = common.FromHex(cursor)
+	}
+	txs, next, err := core.BlockChainImpl.TxsByAddress(common.StringToAddress(addr), pos, int(limit), desc)
+	if err != nil {
+		return nil, err
+	}
+	ret := &AddressTxs{Txs: make([]*AddressTx, 0, len(txs))}
+	for _, tx := range txs {
+		ret.Txs = append(ret.Txs, &AddressTx{Height: tx.Height, TxIndex: tx.TxIndex, Hash: tx.TxHash})
+	}
+	if next != nil {
+		ret.NextCursor = common.ToHex(next)
+	}
+	return ret, nil
+}
+
+// EstimateGas returns the minimal gas limit with which the transaction can be executed successfully on the latest state.
+// The nonce and sign of the transaction are ignored, and the gas limit, if given, is used as the upper bound
+func (api *RpcGzvImpl) EstimateGas(txRaw *TxRawData) (uint64, error) {
+	if txRaw == nil {
+		return 0, fmt.Errorf("empty transaction")
+	}
+	if err := validateTxRawData(txRaw); err != nil {
+		return 0, err
+	}
+	return core.BlockChainImpl.EstimateGas(txRawToTransaction(txRaw))
+}
+
+// GasPrice returns the slow, normal and fast gas prices suggested based on the recent blocks and the pending transactions
+func (api *RpcGzvImpl) GasPrice() (*GasPriceSuggestion, error) {
+	s := core.BlockChainImpl.SuggestGasPrice()
+	return &GasPriceSuggestion{
+		Slow:        s.Slow,
+		Normal:      s.Normal,
+		Fast:        s.Fast,
+		Lowest:      s.Lowest,
+		TopHeight:   s.TopHeight,
+		SampleCount: s.SampleCount,
+	}, nil
+}
+
+// FeeHistory returns the gas prices at the given percentiles of each of the latest blocks and the pending transactions.
+// The percentiles should be in ascending order within [0, 100]
+func (api *RpcGzvImpl) FeeHistory(blocks int, percentiles []float64) (*FeeHistory, error) {
+	history, err := core.BlockChainImpl.FeeHistory(blocks, percentiles)
+	if err != nil {
+		return nil, err
+	}
+	return convertFeeHistory(history), nil
+}
+
+// GetLogs returns the logs matching the given query. The height range of the query
+// is limited to maxLogQueryBlockRange and at most maxLogQueryResults logs returned
+func (api *RpcGzvImpl) GetLogs(query *LogQuery) ([]*types.Log, error) {
+	if query == nil {
+		return nil, fmt.Errorf("empty query")
+	}
+	filter, err := parseLogQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return core.BlockChainImpl.GetLogs(filter, maxLogQueryResults)
+}
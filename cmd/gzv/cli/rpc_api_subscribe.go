@@ -0,0 +1,199 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"context"
+	"math"
+	"sync"
+
+	"github.com/zvchain/zvchain/cmd/gzv/rpc"
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/core"
+	"github.com/zvchain/zvchain/log"
+	"github.com/zvchain/zvchain/middleware/notify"
+	"github.com/zvchain/zvchain/middleware/types"
+)
+
+// subscriptionBufferSize is the number of events buffered for each subscription.
+// Events are dropped for the subscription if the client can't keep up
+const subscriptionBufferSize = 128
+
+// eventFeed fans out one kind of event to all of the subscriptions interested in it
+type eventFeed struct {
+	chans map[rpc.ID]chan interface{}
+	lock  sync.RWMutex
+}
+
+func newEventFeed() *eventFeed {
+	return &eventFeed{chans: make(map[rpc.ID]chan interface{})}
+}
+
+func (f *eventFeed) subscribe(id rpc.ID) <-chan interface{} {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	ch := make(chan interface{}, subscriptionBufferSize)
+	f.chans[id] = ch
+	return ch
+}
+
+func (f *eventFeed) unsubscribe(id rpc.ID) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	delete(f.chans, id)
+}
+
+func (f *eventFeed) send(ev interface{}) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	for _, ch := range f.chans {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// chainEvents converts the chain events published on the notify bus to the rpc subscriptions.
+// It subscribes to the bus only once since handlers can't be reliably removed from the bus.
+type chainEvents struct {
+	blocks *eventFeed // *types.Block, both the newly added blocks and the new top after reset
+	txs    *eventFeed // *types.Transaction accepted by the transaction pool
+}
+
+var (
+	chainEventsInstance *chainEvents
+	chainEventsOnce     sync.Once
+)
+
+func getChainEvents() *chainEvents {
+	chainEventsOnce.Do(func() {
+		ce := &chainEvents{
+			blocks: newEventFeed(),
+			txs:    newEventFeed(),
+		}
+		notify.BUS.Subscribe(notify.BlockAddSucc, ce.onBlockAddSuccess)
+		notify.BUS.Subscribe(notify.NewTopBlock, ce.onNewTopBlock)
+		notify.BUS.Subscribe(notify.TransactionAddSucc, ce.onTransactionAdd)
+		chainEventsInstance = ce
+	})
+	return chainEventsInstance
+}
+
+func (ce *chainEvents) onBlockAddSuccess(message notify.Message) error {
+	b := message.GetData().(*types.Block)
+	ce.blocks.send(b)
+	return nil
+}
+
+func (ce *chainEvents) onNewTopBlock(message notify.Message) error {
+	bh := message.GetData().(*types.BlockHeader)
+	b := core.BlockChainImpl.QueryBlockByHash(bh.Hash)
+	if b == nil {
+		return nil
+	}
+	ce.blocks.send(b)
+	return nil
+}
+
+func (ce *chainEvents) onTransactionAdd(message notify.Message) error {
+	tx := message.GetData().(*types.Transaction)
+	ce.txs.send(tx)
+	return nil
+}
+
+// subscribe creates a subscription on the given feed and notifies the client with the value
+// returned by the convert function for each event. Events converted to nil are skipped
+func subscribe(ctx context.Context, feed *eventFeed, convert func(ev interface{}) interface{}) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	sub := notifier.CreateSubscription()
+	ch := feed.subscribe(sub.ID)
+
+	go func() {
+		defer feed.unsubscribe(sub.ID)
+		for {
+			select {
+			case ev := <-ch:
+				if data := convert(ev); data != nil {
+					notifier.Notify(sub.ID, data)
+				}
+			case <-sub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+// NewHeads sends the header of each block added on the chain and each new top block after the chain reset
+func (api *RpcGzvImpl) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	return subscribe(ctx, getChainEvents().blocks, func(ev interface{}) interface{} {
+		return convertBlockHeader(ev.(*types.Block))
+	})
+}
+
+// Logs sends the logs generated by the transactions of each block added on the chain.
+// Only the logs of the given contract addresses will be sent and all logs will be sent if no address given.
+// All the matched logs of a block are sent in one notification regardless of maxLogQueryResults, as they
+// are already bounded by the gas limit of the block
+func (api *RpcGzvImpl) Logs(ctx context.Context, addresses []string) (*rpc.Subscription, error) {
+	filter, err := parseLogQuery(&LogQuery{Addresses: addresses})
+	if err != nil {
+		return nil, err
+	}
+	return subscribe(ctx, getChainEvents().blocks, func(ev interface{}) interface{} {
+		hash := ev.(*types.Block).Header.Hash
+		blockFilter := *filter
+		blockFilter.BlockHash = &hash
+		logs, err := core.BlockChainImpl.GetLogs(&blockFilter, math.MaxInt32)
+		if err != nil {
+			log.DefaultLogger.Errorf("query logs of block %v for subscription error:%v", hash, err)
+			return nil
+		}
+		if len(logs) == 0 {
+			return nil
+		}
+		return logs
+	})
+}
+
+// PendingTransactions sends each transaction accepted by the transaction pool of the node
+func (api *RpcGzvImpl) PendingTransactions(ctx context.Context) (*rpc.Subscription, error) {
+	return subscribe(ctx, getChainEvents().txs, func(ev interface{}) interface{} {
+		return convertTransaction(ev.(*types.Transaction))
+	})
+}
+
+// Checkpoints sends the latest checkpoint whenever it changes after a block added on the chain
+func (api *RpcGzvImpl) Checkpoints(ctx context.Context) (*rpc.Subscription, error) {
+	var last common.Hash
+	return subscribe(ctx, getChainEvents().blocks, func(ev interface{}) interface{} {
+		cp := api.br.CheckPointAt(ev.(*types.Block).Header.Height)
+		if cp == nil || cp.Hash == last {
+			return nil
+		}
+		last = cp.Hash
+		return cp
+	})
+}
@@ -0,0 +1,58 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/zvchain/zvchain/cmd/gzv/rpc"
+)
+
+func TestEventFeed(t *testing.T) {
+	feed := newEventFeed()
+	ch1 := feed.subscribe(rpc.ID("1"))
+	ch2 := feed.subscribe(rpc.ID("2"))
+
+	feed.send(1)
+	if v := <-ch1; v != 1 {
+		t.Errorf("subscription 1 received %v, expect 1", v)
+	}
+	if v := <-ch2; v != 1 {
+		t.Errorf("subscription 2 received %v, expect 1", v)
+	}
+
+	feed.unsubscribe(rpc.ID("2"))
+	feed.send(2)
+	if v := <-ch1; v != 2 {
+		t.Errorf("subscription 1 received %v, expect 2", v)
+	}
+	if len(ch2) != 0 {
+		t.Errorf("unsubscribed subscription shouldn't receive events")
+	}
+}
+
+func TestEventFeedDropWhenFull(t *testing.T) {
+	feed := newEventFeed()
+	ch := feed.subscribe(rpc.ID("1"))
+
+	// Sending must not block even if the subscriber doesn't consume
+	for i := 0; i < subscriptionBufferSize*2; i++ {
+		feed.send(i)
+	}
+	if len(ch) != subscriptionBufferSize {
+		t.Errorf("buffered events %v, expect %v", len(ch), subscriptionBufferSize)
+	}
+}
@@ -0,0 +1,79 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/core"
+)
+
+// RpcTxPoolImpl provides api functions to inspect the transaction pool,
+// mainly for finding out why the transactions are stuck
+type RpcTxPoolImpl struct {
+	*rpcBaseImpl
+}
+
+func (api *RpcTxPoolImpl) Namespace() string {
+	return "Txpool"
+}
+
+func (api *RpcTxPoolImpl) Version() string {
+	return "1"
+}
+
+// Status returns the count of the transactions in the pool
+func (api *RpcTxPoolImpl) Status() (*TxPoolStatus, error) {
+	status := core.BlockChainImpl.TxPoolStatus()
+	return &TxPoolStatus{
+		Pending:  status.Pending,
+		Queued:   status.Queued,
+		Reward:   status.Reward,
+		Accounts: status.Accounts,
+	}, nil
+}
+
+// Content returns the transactions in the pool sent by the given address, with the details
+func (api *RpcTxPoolImpl) Content(addr string) (*PoolAccount, error) {
+	addr = strings.TrimSpace(addr)
+	if !common.ValidateAddress(addr) {
+		return nil, fmt.Errorf("wrong account address format")
+	}
+	address := common.StringToAddress(addr)
+	accounts := core.BlockChainImpl.TxPoolContent(&address)
+	if len(accounts) == 0 {
+		return &PoolAccount{
+			Address:    address.AddrPrefixString(),
+			StateNonce: core.BlockChainImpl.GetNonce(address),
+			NonceGaps:  []uint64{},
+			Pending:    []*PoolTx{},
+			Queued:     []*PoolTx{},
+		}, nil
+	}
+	return convertPoolAccount(accounts[0], true), nil
+}
+
+// Inspect returns the summaries of the transactions in the pool grouped by the sender
+func (api *RpcTxPoolImpl) Inspect() ([]*PoolAccount, error) {
+	accounts := core.BlockChainImpl.TxPoolContent(nil)
+	ret := make([]*PoolAccount, 0, len(accounts))
+	for _, pa := range accounts {
+		ret = append(ret, convertPoolAccount(pa, false))
+	}
+	return ret, nil
+}
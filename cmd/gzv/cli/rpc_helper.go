@@ -27,6 +27,7 @@ import (
 	"github.com/zvchain/zvchain/consensus/mediator"
 	"github.com/zvchain/zvchain/core"
 	"github.com/zvchain/zvchain/middleware/types"
+	"github.com/zvchain/zvchain/storage/account"
 )
 
 func convertTransaction(tx *types.Transaction) *Transaction {
@@ -55,10 +56,40 @@ func convertTransaction(tx *types.Transaction) *Transaction {
 		ExtraData: string(tx.ExtraData),
 		Nonce:     tx.Nonce,
 		Value:     common.RA2TAS(value),
+		ChainId:   tx.ChainId,
+
+		ExpireHeight: tx.ExpireHeight,
 	}
 	return trans
 }
 
+// convertTxRawData converts the transaction to the raw data without the signature
+func convertTxRawData(tx *types.Transaction) *TxRawData {
+	raw := &TxRawData{
+		Source:    tx.Source.AddrPrefixString(),
+		TxType:    int(tx.Type),
+		Nonce:     tx.Nonce,
+		Data:      tx.Data,
+		ExtraData: tx.ExtraData,
+		ChainId:   tx.ChainId,
+
+		ExpireHeight: tx.ExpireHeight,
+	}
+	if tx.Target != nil {
+		raw.Target = tx.Target.AddrPrefixString()
+	}
+	if tx.Value != nil {
+		raw.Value = tx.Value.Uint64()
+	}
+	if tx.GasLimit != nil {
+		raw.GasLimit = tx.GasLimit.Uint64()
+	}
+	if tx.GasPrice != nil {
+		raw.GasPrice = tx.GasPrice.Uint64()
+	}
+	return raw
+}
+
 func convertExecutedTransaction(executed *types.ExecutedTransaction) *ExecutedTransaction {
 	rec := &Receipt{
 		Status:            int(executed.Receipt.Status),
@@ -76,6 +107,51 @@ func convertExecutedTransaction(executed *types.ExecutedTransaction) *ExecutedTr
 
 }
 
+const (
+	maxLogQueryBlockRange = 5000  // max number of blocks can be queried in one logs query
+	maxLogQueryResults    = 10000 // max number of logs can be returned in one logs query
+	maxProofStorageKeys   = 1000  // max number of storage keys can be proven in one proof query
+	maxTxsByAddressLimit  = 1000  // max number of transactions can be returned in one address query
+)
+
+func parseLogQuery(query *LogQuery) (*core.LogFilter, error) {
+	filter := &core.LogFilter{
+		FromHeight: query.FromHeight,
+		ToHeight:   query.ToHeight,
+		Addresses:  make([]common.Address, 0, len(query.Addresses)),
+		Topics:     make([]common.Hash, 0, len(query.Topics)),
+	}
+	if h := strings.TrimSpace(query.BlockHash); h != "" {
+		if !validateHash(h) {
+			return nil, fmt.Errorf("wrong hash format")
+		}
+		hash := common.HexToHash(h)
+		filter.BlockHash = &hash
+	} else {
+		if query.FromHeight > query.ToHeight {
+			return nil, fmt.Errorf("from height is greater than to height")
+		}
+		if query.ToHeight-query.FromHeight >= maxLogQueryBlockRange {
+			return nil, fmt.Errorf("height range exceeds %v blocks", maxLogQueryBlockRange)
+		}
+	}
+	for _, addr := range query.Addresses {
+		addr = strings.TrimSpace(addr)
+		if !common.ValidateAddress(addr) {
+			return nil, fmt.Errorf("wrong address format")
+		}
+		filter.Addresses = append(filter.Addresses, common.StringToAddress(addr))
+	}
+	for _, topic := range query.Topics {
+		topic = strings.TrimSpace(topic)
+		if !validateHash(topic) {
+			return nil, fmt.Errorf("wrong topic format")
+		}
+		filter.Topics = append(filter.Topics, common.HexToHash(topic))
+	}
+	return filter, nil
+}
+
 func convertBlockHeader(b *types.Block) *Block {
 	bh := b.Header
 	block := &Block{
@@ -143,7 +219,7 @@ func sendTransaction(trans *types.Transaction) error {
 	if trans.Sign == nil {
 		return fmt.Errorf("transaction sign is empty")
 	}
-	if ok, err := core.BlockChainImpl.GetTransactionPool().AddTransaction(trans); err != nil || !ok {
+	if ok, err := core.BlockChainImpl.GetTransactionPool().AddLocalTransaction(trans); err != nil || !ok {
 		log.DefaultLogger.Errorf("AddTransaction not ok or error:%s", err.Error())
 		return err
 	}
@@ -236,3 +312,179 @@ func getMorts(p logical.Processor) (string, []MortGage) {
 	}
 	return t, morts
 }
+
+func encodeProof(proof [][]byte) []string {
+	ret := make([]string, 0, len(proof))
+	for _, node := range proof {
+		ret = append(ret, common.ToHex(node))
+	}
+	return ret
+}
+
+func convertAccountProof(bh *types.BlockHeader, proof *account.AccountProof) *AccountProof {
+	ret := &AccountProof{
+		Height:       bh.Height,
+		BlockHash:    bh.Hash,
+		StateRoot:    bh.StateTree,
+		Address:      proof.Address.AddrPrefixString(),
+		AccountProof: encodeProof(proof.AccountProof),
+		Nonce:        proof.Nonce,
+		Balance:      proof.Balance,
+		StorageRoot:  proof.StorageRoot,
+		CodeHash:     proof.CodeHash,
+		StorageProof: make([]*StorageProof, 0, len(proof.StorageProof)),
+	}
+	for _, sp := range proof.StorageProof {
+		storageProof := &StorageProof{
+			Key:   string(sp.Key),
+			Proof: encodeProof(sp.Proof),
+		}
+		// Leave the value empty if the key is proven absent
+		if sp.Value != nil {
+			storageProof.Value = common.ToHex(sp.Value)
+		}
+		ret.StorageProof = append(ret.StorageProof, storageProof)
+	}
+	return ret
+}
+
+func convertPoolAccount(pa *core.PoolAccount, detail bool) *PoolAccount {
+	convert := func(txs []*core.PoolTx) []*PoolTx {
+		ret := make([]*PoolTx, 0, len(txs))
+		for _, tx := range txs {
+			ptx := &PoolTx{
+				Hash:      tx.Hash,
+				Nonce:     tx.Nonce,
+				PriceRank: tx.PriceRank,
+				Age:       uint64(tx.Age.Seconds()),
+			}
+			if tx.GasPrice != nil {
+				ptx.GasPrice = tx.GasPrice.Uint64()
+			}
+			if detail {
+				ptx.Tx = convertTransaction(tx.Transaction)
+			}
+			ret = append(ret, ptx)
+		}
+		return ret
+	}
+	return &PoolAccount{
+		Address:            pa.Address.AddrPrefixString(),
+		StateNonce:         pa.StateNonce,
+		LowestPendingNonce: pa.LowestPendingNonce(),
+		NonceGaps:          pa.NonceGaps,
+		Pending:            convert(pa.Pending),
+		Queued:             convert(pa.Queued),
+	}
+}
+
+func convertFeeHistory(history *core.FeeHistory) *FeeHistory {
+	ret := &FeeHistory{
+		Blocks:  make([]*BlockFeeHistory, 0, len(history.Blocks)),
+		Pending: history.Pending,
+	}
+	for _, b := range history.Blocks {
+		ret.Blocks = append(ret.Blocks, &BlockFeeHistory{
+			Height:      b.Height,
+			Hash:        b.Hash,
+			TxCount:     b.TxCount,
+			Percentiles: b.Percentiles,
+		})
+	}
+	return ret
+}
+
+func convertTxTrace(trace *core.TxTrace) *TxTrace {
+	tx := trace.Tx
+	ret := &TxTrace{
+		Hash:    tx.Hash,
+		Height:  trace.Height,
+		Index:   trace.Index,
+		Type:    tx.Type,
+		Source:  tx.Source,
+		Target:  tx.Target,
+		GasUsed: trace.GasUsed,
+		Status:  int(trace.Status),
+		Call:    convertTraceFrame(trace.Call),
+	}
+	if tx.GasLimit != nil {
+		ret.GasLimit = tx.GasLimit.Uint64()
+	}
+	if trace.Err != nil {
+		ret.Error = trace.Err.Error()
+	}
+	if tx.Type == types.TransactionTypeContractCreate && trace.Err == nil {
+		addr := trace.ContractAddress
+		ret.ContractAddress = &addr
+	}
+	return ret
+}
+
+func convertTraceFrame(frame *tvm.CallFrame) *TraceFrame {
+	if frame == nil {
+		return nil
+	}
+	convertAccess := func(accesses []*tvm.StorageAccess) []*StorageAccess {
+		ret := make([]*StorageAccess, 0, len(accesses))
+		for _, a := range accesses {
+			ret = append(ret, &StorageAccess{Address: a.Address, Key: string(a.Key), Value: tvm.VmDataConvert(a.Value)})
+		}
+		return ret
+	}
+	ret := &TraceFrame{
+		Type:          frame.Type,
+		From:          frame.From,
+		To:            frame.To,
+		Function:      frame.Function,
+		Input:         frame.Input,
+		Gas:           frame.Gas,
+		GasUsed:       frame.GasUsed,
+		Output:        frame.Output,
+		StorageReads:  convertAccess(frame.Reads),
+		StorageWrites: convertAccess(frame.Writes),
+		Transfers:     make([]*TraceTransfer, 0, len(frame.Transfers)),
+		Events:        make([]*TraceEvent, 0, len(frame.Events)),
+		Calls:         make([]*TraceFrame, 0, len(frame.Calls)),
+	}
+	if frame.Error != nil {
+		ret.Error = frame.Error.Message
+		ret.ErrorCode = frame.Error.Code
+	}
+	for _, t := range frame.Transfers {
+		ret.Transfers = append(ret.Transfers, &TraceTransfer{From: t.From, To: t.To, Value: t.Value, Success: t.Success})
+	}
+	for _, e := range frame.Events {
+		ret.Events = append(ret.Events, &TraceEvent{Address: e.Address, Name: e.Name, Data: string(e.Data)})
+	}
+	for _, c := range frame.Calls {
+		ret.Calls = append(ret.Calls, convertTraceFrame(c))
+	}
+	return ret
+}
+
+func convertStateDiff(diff *core.StateDiff) *StateDiff {
+	ret := &StateDiff{
+		Height:   diff.Height,
+		Hash:     diff.Hash,
+		Accounts: make([]*AccountDiff, 0, len(diff.Accounts)),
+	}
+	for _, d := range diff.Accounts {
+		ad := &AccountDiff{
+			Address:        d.Address,
+			Created:        d.Created,
+			Deleted:        d.Deleted,
+			BalanceBefore:  d.Before.Balance,
+			BalanceAfter:   d.After.Balance,
+			NonceBefore:    d.Before.Nonce,
+			NonceAfter:     d.After.Nonce,
+			CodeHashBefore: common.BytesToHash(d.Before.CodeHash),
+			CodeHashAfter:  common.BytesToHash(d.After.CodeHash),
+			Storage:        make([]*StorageDiff, 0, len(d.Storage)),
+		}
+		for _, sd := range d.Storage {
+			ad.Storage = append(ad.Storage, &StorageDiff{Key: string(sd.Key), Before: tvm.VmDataConvert(sd.Before), After: tvm.VmDataConvert(sd.After)})
+		}
+		ret.Accounts = append(ret.Accounts, ad)
+	}
+	return ret
+}
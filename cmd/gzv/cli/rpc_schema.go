@@ -0,0 +1,53 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/zvchain/zvchain/cmd/gzv/rpc"
+)
+
+// rpcSchema writes the OpenRPC document of the apis enabled by the given rpc service level
+// without starting the node, which is the same as the one returned by rpc_discover
+func rpcSchema(level rpcLevel, out string) error {
+	if level < rpcLevelMiner || level > rpcLevelDev {
+		return fmt.Errorf("rpc level error:%v", level)
+	}
+	server := rpc.NewServer(false)
+	// Only the method signatures are needed, so the instances are not bound to the chain
+	for _, inst := range newRpcInstances(level, &rpcBaseImpl{}, nil) {
+		if err := server.RegisterName(inst.Namespace(), inst); err != nil {
+			return err
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(server.OpenRPC())
+}
@@ -0,0 +1,67 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zvchain/zvchain/cmd/gzv/rpc"
+)
+
+func TestRpcSchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpc_schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	methodsOf := func(level rpcLevel) map[string]*rpc.OpenRPCMethod {
+		out := filepath.Join(dir, "schema.json")
+		if err := rpcSchema(level, out); err != nil {
+			t.Fatal(err)
+		}
+		bs, err := ioutil.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc := &rpc.OpenRPCDocument{}
+		if err := json.Unmarshal(bs, doc); err != nil {
+			t.Fatal(err)
+		}
+		methods := make(map[string]*rpc.OpenRPCMethod)
+		for _, m := range doc.Methods {
+			methods[m.Name] = m
+		}
+		return methods
+	}
+
+	methods := methodsOf(rpcLevelGtas)
+	if methods["Gzv_blockHeight"] == nil || methods["Dev_blockDetail"] != nil {
+		t.Errorf("unexpected methods of the gzv level")
+	}
+	methods = methodsOf(rpcLevelDev)
+	m := methods["Gzv_getBlockByHeight"]
+	if m == nil || len(m.Params) != 1 || m.Result.Schema.Ref != "#/components/schemas/Block" {
+		t.Errorf("unexpected method %+v", m)
+	}
+	if rpcSchema(rpcLevelDev+1, "") == nil {
+		t.Errorf("expect error for the invalid level")
+	}
+}
@@ -0,0 +1,100 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/consensus/groupsig"
+	"github.com/zvchain/zvchain/consensus/mediator"
+	"github.com/zvchain/zvchain/core"
+	"github.com/zvchain/zvchain/middleware"
+)
+
+// initChainOffline opens the local chain without starting the network and consensus
+func initChainOffline() error {
+	if err := middleware.InitMiddleware(); err != nil {
+		return err
+	}
+	return core.InitCore(mediator.NewConsensusHelper(groupsig.ID{}), nil)
+}
+
+// exportState writes the state at the given height into the file in the state snapshot format.
+// The latest checkpoint is used if height is 0
+func exportState(height uint64, file string) error {
+	if err := initChainOffline(); err != nil {
+		return err
+	}
+	chain := core.BlockChainImpl
+	defer chain.Close()
+
+	if height == 0 {
+		cp := chain.LatestCheckPoint()
+		if cp == nil {
+			return fmt.Errorf("no checkpoint found")
+		}
+		height = cp.Height
+	}
+	f, err := os.OpenFile(filepath.Clean(file), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	output(fmt.Sprintf("exporting state at %v to %v", height, file))
+	begin := time.Now()
+	stat, err := chain.ExportState(f, height)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	output(fmt.Sprintf("exported state root %v, accounts %v, storage entries %v, codes %v, chunks %v, cost %v",
+		stat.Root.Hex(), stat.Accounts, stat.Storages, stat.Codes, stat.Chunks, time.Since(begin).String()))
+	return nil
+}
+
+// importState rebuilds the state from the snapshot file into the local chain which should be empty,
+// the block of the snapshot becomes the top of the chain
+func importState(file string, hash string) error {
+	if !validateHash(hash) {
+		return fmt.Errorf("invalid block hash %v", hash)
+	}
+	if err := initChainOffline(); err != nil {
+		return err
+	}
+	chain := core.BlockChainImpl
+	defer chain.Close()
+
+	f, err := os.Open(filepath.Clean(file))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	output(fmt.Sprintf("importing state from %v", file))
+	begin := time.Now()
+	stat, err := chain.ImportState(f, common.HexToHash(hash))
+	if err != nil {
+		return err
+	}
+	output(fmt.Sprintf("imported state root %v at %v, accounts %v, storage entries %v, codes %v, cost %v",
+		stat.Root.Hex(), stat.Height, stat.Accounts, stat.Storages, stat.Codes, time.Since(begin).String()))
+	return nil
+}
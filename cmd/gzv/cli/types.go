@@ -266,7 +266,10 @@ type Transaction struct {
 	GasPrice uint64      `json:"gas_price"`
 	Hash     common.Hash `json:"hash"`
 
-	ExtraData string `json:"extra_data"`
+	ExtraData string  `json:"extra_data"`
+	ChainId   *uint16 `json:"chain_id,omitempty"`
+
+	ExpireHeight *uint64 `json:"expire_height,omitempty"`
 }
 
 type Receipt struct {
@@ -344,3 +347,208 @@ type GroupCheckInfo struct {
 	JoinedGroups        []*JoinedGroupInfo   `json:"joined_living_groups"`
 	CurrentGroupRoutine *CurrentEraGroupInfo `json:"current_group_routine"`
 }
+
+// LogQuery is the filter of the logs query. If BlockHash is set, the height range is ignored
+type LogQuery struct {
+	FromHeight uint64   `json:"from_height"`
+	ToHeight   uint64   `json:"to_height"`
+	BlockHash  string   `json:"block_hash"`
+	Addresses  []string `json:"addresses"`
+	Topics     []string `json:"topics"`
+}
+
+// ContractCallResult is the result of a contract call executed without changing the chain state
+type ContractCallResult struct {
+	Content string       `json:"content"`
+	Logs    []*types.Log `json:"logs"`
+	GasUsed uint64       `json:"gas_used"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// StorageProof is the merkle proof of a key in the contract storage, encoded in hex
+type StorageProof struct {
+	Key   string   `json:"key"`
+	Value string   `json:"value,omitempty"`
+	Proof []string `json:"proof"`
+}
+
+// AccountProof is the merkle proof of an account against the state root of the block at the height
+type AccountProof struct {
+	Height       uint64          `json:"height"`
+	BlockHash    common.Hash     `json:"block_hash"`
+	StateRoot    common.Hash     `json:"state_root"`
+	Address      string          `json:"address"`
+	AccountProof []string        `json:"account_proof"`
+	Nonce        uint64          `json:"nonce"`
+	Balance      *big.Int        `json:"balance"`
+	StorageRoot  common.Hash     `json:"storage_root"`
+	CodeHash     common.Hash     `json:"code_hash"`
+	StorageProof []*StorageProof `json:"storage_proof"`
+}
+
+// AddressTx is the position of a transaction involving the address
+type AddressTx struct {
+	Height  uint64      `json:"height"`
+	TxIndex uint16      `json:"tx_index"`
+	Hash    common.Hash `json:"hash"`
+}
+
+// AddressTxs is a page of the transactions involving the address
+type AddressTxs struct {
+	Txs        []*AddressTx `json:"txs"`
+	NextCursor string       `json:"next_cursor,omitempty"` // Empty if no more transactions
+}
+
+// TxPoolStatus is the count of the transactions in the pool
+type TxPoolStatus struct {
+	Pending  int `json:"pending"`
+	Queued   int `json:"queued"`
+	Reward   int `json:"reward"`
+	Accounts int `json:"accounts"`
+}
+
+// PoolTx is the transaction in the pool with the inspection info
+type PoolTx struct {
+	Hash      common.Hash  `json:"hash"`
+	Nonce     uint64       `json:"nonce"`
+	GasPrice  uint64       `json:"gas_price"`
+	PriceRank int          `json:"price_rank"`   // Position of the gas price in the pending transactions, starting from 1
+	Age       uint64       `json:"age"`          // Seconds since the transaction entered the pool
+	Tx        *Transaction `json:"tx,omitempty"` // Transaction details, only returned by txpool content
+}
+
+// PoolAccount is the transactions in the pool of one sender
+type PoolAccount struct {
+	Address            string    `json:"address"`
+	StateNonce         uint64    `json:"state_nonce"`          // Nonce in the latest state, the next nonce expected is state_nonce+1
+	LowestPendingNonce uint64    `json:"lowest_pending_nonce"` // 0 if no pending transaction
+	NonceGaps          []uint64  `json:"nonce_gaps"`           // Missing nonces blocking the transactions behind
+	Pending            []*PoolTx `json:"pending"`
+	Queued             []*PoolTx `json:"queued"`
+}
+
+// GasPriceSuggestion is the gas price suggested based on the recent blocks and the pending transactions
+type GasPriceSuggestion struct {
+	Slow        uint64 `json:"slow"`
+	Normal      uint64 `json:"normal"`
+	Fast        uint64 `json:"fast"`
+	Lowest      uint64 `json:"lowest"` // Lowest price accepted by the node
+	TopHeight   uint64 `json:"top_height"`
+	SampleCount int    `json:"sample_count"`
+}
+
+// BlockFeeHistory is the gas prices at the requested percentiles of the transactions in one block
+type BlockFeeHistory struct {
+	Height      uint64      `json:"height"`
+	Hash        common.Hash `json:"hash"`
+	TxCount     int         `json:"tx_count"`
+	Percentiles []uint64    `json:"percentiles"`
+}
+
+// FeeHistory is the gas prices at the requested percentiles of the recent blocks and the pending transactions
+type FeeHistory struct {
+	Blocks  []*BlockFeeHistory `json:"blocks"`
+	Pending []uint64           `json:"pending"`
+}
+
+// AdminNodeInfo is the running status of the local node
+type AdminNodeInfo struct {
+	ID              string      `json:"id"`
+	Version         string      `json:"version"`
+	ChainID         uint16      `json:"chain_id"`
+	ProtocolVersion int         `json:"protocol_version"`
+	BlockHeight     uint64      `json:"block_height"`
+	TopHash         common.Hash `json:"top_hash"`
+	GroupHeight     uint64      `json:"group_height"`
+	Peers           int         `json:"peers"`
+	TxPoolNum       int         `json:"tx_pool_num"`
+	Mining          bool        `json:"mining"`
+	Syncing         bool        `json:"syncing"`
+	PruneMode       bool        `json:"prune_mode"`
+	IPC             string      `json:"ipc"`
+}
+
+// TxTrace is the result of re-executing a transaction
+type TxTrace struct {
+	Hash            common.Hash     `json:"hash"`
+	Height          uint64          `json:"height"`
+	Index           int             `json:"index"`
+	Type            int8            `json:"type"`
+	Source          *common.Address `json:"source"`
+	Target          *common.Address `json:"target"`
+	GasLimit        uint64          `json:"gas_limit"`
+	GasUsed         uint64          `json:"gas_used"`
+	Status          int             `json:"status"`
+	Error           string          `json:"error,omitempty"`
+	ContractAddress *common.Address `json:"contract_address,omitempty"`
+	Call            *TraceFrame     `json:"call,omitempty"` // Absent if no contract executed
+}
+
+// TraceFrame is one deployment or call of the contract with the nested calls made by it
+type TraceFrame struct {
+	Type          string           `json:"type"`
+	From          common.Address   `json:"from"`
+	To            common.Address   `json:"to"`
+	Function      string           `json:"function,omitempty"`
+	Input         string           `json:"input,omitempty"`
+	Gas           uint64           `json:"gas"`
+	GasUsed       uint64           `json:"gas_used"`
+	Output        string           `json:"output,omitempty"`
+	Error         string           `json:"error,omitempty"`
+	ErrorCode     int              `json:"error_code,omitempty"`
+	StorageReads  []*StorageAccess `json:"storage_reads"`
+	StorageWrites []*StorageAccess `json:"storage_writes"`
+	Transfers     []*TraceTransfer `json:"transfers"`
+	Events        []*TraceEvent    `json:"events"`
+	Calls         []*TraceFrame    `json:"calls"`
+}
+
+// StorageAccess is one read or write of the contract storage, the value is null if not exists or removed
+type StorageAccess struct {
+	Address common.Address `json:"address"`
+	Key     string         `json:"key"`
+	Value   interface{}    `json:"value"`
+}
+
+// TraceTransfer is the transfer made by the contract, the value is in ra
+type TraceTransfer struct {
+	From    common.Address `json:"from"`
+	To      common.Address `json:"to"`
+	Value   *big.Int       `json:"value"`
+	Success bool           `json:"success"`
+}
+
+// TraceEvent is the event emitted by the contract
+type TraceEvent struct {
+	Address common.Address `json:"address"`
+	Name    string         `json:"name"`
+	Data    string         `json:"data"`
+}
+
+// StateDiff is the accounts changed by a block
+type StateDiff struct {
+	Height   uint64         `json:"height"`
+	Hash     common.Hash    `json:"hash"`
+	Accounts []*AccountDiff `json:"accounts"`
+}
+
+// AccountDiff is the change of an account in a block, the balances are in ra
+type AccountDiff struct {
+	Address        common.Address `json:"address"`
+	Created        bool           `json:"created"`
+	Deleted        bool           `json:"deleted"`
+	BalanceBefore  *big.Int       `json:"balance_before"`
+	BalanceAfter   *big.Int       `json:"balance_after"`
+	NonceBefore    uint64         `json:"nonce_before"`
+	NonceAfter     uint64         `json:"nonce_after"`
+	CodeHashBefore common.Hash    `json:"code_hash_before"`
+	CodeHashAfter  common.Hash    `json:"code_hash_after"`
+	Storage        []*StorageDiff `json:"storage"`
+}
+
+// StorageDiff is the change of a storage key, the value is null if not exists or removed
+type StorageDiff struct {
+	Key    string      `json:"key"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
@@ -23,20 +23,36 @@ import (
 )
 
 type WalletServer struct {
-	Host string
-	Port int
-	aop  accountOp
+	Host    string
+	Port    int
+	aop     accountOp
+	chainop *RemoteChainOpImpl
 }
 
-func NewWalletServer(host string, port int, aop accountOp) *WalletServer {
+func NewWalletServer(host string, port int, aop accountOp, chainop *RemoteChainOpImpl) *WalletServer {
 	ws := &WalletServer{
-		Host: host,
-		Port: port,
-		aop:  aop,
+		Host:    host,
+		Port:    port,
+		aop:     aop,
+		chainop: chainop,
 	}
 	return ws
 }
 
+// fillChainId sets the chain id that the transaction should carry for replay protection
+// from the connected node, if not given
+func (ws *WalletServer) fillChainId(txRaw *TxRawData) error {
+	if txRaw.ChainId != nil {
+		return nil
+	}
+	chainId, errRes := ws.chainop.txChainId()
+	if errRes != nil {
+		return fmt.Errorf("get the chain id error:%v, set chain_id or connect to a node", errRes.Message)
+	}
+	txRaw.ChainId = chainId
+	return nil
+}
+
 func (ws *WalletServer) Start() error {
 	if ws.Port <= 0 {
 		return fmt.Errorf("please input the rpcport")
@@ -45,7 +61,7 @@ func (ws *WalletServer) Start() error {
 		{Namespace: "GzvWallet", Version: "1", Service: ws, Public: true},
 	}
 	host := fmt.Sprintf("%s:%d", ws.Host, ws.Port)
-	err := startHTTP(host, apis, []string{}, []string{}, []string{})
+	err := startHTTP(host, apis, []string{}, []string{}, []string{}, nil)
 	if err == nil {
 		fmt.Printf("Wallet RPC serving on http://%s\n", host)
 		return nil
@@ -73,6 +89,9 @@ func (ws *WalletServer) SignTransaction(txRaw *TxRawData, unlockPassword string)
 		return "", fmt.Errorf("address error")
 	}
 
+	if err := ws.fillChainId(txRaw); err != nil {
+		return "", err
+	}
 	tranx := txRawToTransaction(txRaw)
 	sign, err := privateKey.Sign(tranx.Hash.Bytes())
 	if err != nil {
@@ -101,6 +120,9 @@ func (ws *WalletServer) SignData(signer string, data []byte, unlockPassword stri
 }
 
 func (ws *WalletServer) GenHash(txRaw *TxRawData) (string, error) {
+	if err := ws.fillChainId(txRaw); err != nil {
+		return "", err
+	}
 	tranx := txRawToTransaction(txRaw)
 	return tranx.Hash.Hex(), nil
 
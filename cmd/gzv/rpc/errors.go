@@ -63,3 +63,35 @@ type shutdownError struct{}
 func (e *shutdownError) ErrorCode() int { return -32000 }
 
 func (e *shutdownError) Error() string { return "server is shutting down" }
+
+// Request without valid credential
+type unauthorizedError struct{ message string }
+
+func (e *unauthorizedError) ErrorCode() int { return -32001 }
+
+func (e *unauthorizedError) Error() string { return e.message }
+
+// Caller isn't allowed to call the method
+type methodForbiddenError struct{ method string }
+
+func (e *methodForbiddenError) ErrorCode() int { return -32002 }
+
+func (e *methodForbiddenError) Error() string {
+	return fmt.Sprintf("The method %s is not allowed", e.method)
+}
+
+// Request rate of the caller exceeds the limit
+type rateLimitError struct{ message string }
+
+func (e *rateLimitError) ErrorCode() int { return -32005 }
+
+func (e *rateLimitError) Error() string { return e.message }
+
+// Response is larger than the limit
+type responseTooLargeError struct{ limit int }
+
+func (e *responseTooLargeError) ErrorCode() int { return -32006 }
+
+func (e *responseTooLargeError) Error() string {
+	return fmt.Sprintf("response too large, limit %d bytes", e.limit)
+}
@@ -148,9 +148,10 @@ func (t *httpReadWriteNopCloser) Close() error {
 }
 
 // NewHTTPServer creates a new HTTP RPC server around an API provider.
-func NewHTTPServer(cors []string, vhosts []string, srv *Server) *http.Server {
-	// Wrap the CORS-handler within a host-handler
-	handler := newCorsHandler(srv, cors)
+// The requests are checked against the guard config if it's not nil
+func NewHTTPServer(cors []string, vhosts []string, srv *Server, guard *HTTPGuardConfig) *http.Server {
+	// Wrap the guard-handler within a CORS-handler
+	handler := newCorsHandler(newGuardHandler(guard, srv), cors)
 	// handler = newVHostHandler(vhosts, handler)
 	return &http.Server{Handler: handler}
 }
@@ -228,7 +229,7 @@ func validateRequest(r *http.Request) (int, error) {
 	return 0, nil
 }
 
-func newCorsHandler(srv *Server, allowedOrigins []string) http.Handler {
+func newCorsHandler(srv http.Handler, allowedOrigins []string) http.Handler {
 	// disable CORS support if user has not specified a custom CORS configuration
 	if len(allowedOrigins) == 0 {
 		return srv
@@ -0,0 +1,447 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenPolicy is the access policy of the callers with the same credential
+type TokenPolicy struct {
+	Name  string   // Name of the policy, also the key of the rate limit
+	Token string   // Bearer token of the static credential, not used by the jwt policy
+	Allow []string // Allowed namespaces like "Gzv" or methods like "Gzv_balance", all allowed if empty
+	Rate  float64  // Requests per second, unlimited if not positive
+	Burst int      // Max requests in a burst
+}
+
+func (p *TokenPolicy) allowed(method string) bool {
+	if len(p.Allow) == 0 {
+		return true
+	}
+	namespace := strings.SplitN(method, serviceMethodSeparator, 2)[0]
+	for _, a := range p.Allow {
+		if a == "*" || a == method || a == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// HTTPGuardConfig configures the authentication, access control and limits of the HTTP RPC endpoint.
+// Zero values disable the corresponding checks
+type HTTPGuardConfig struct {
+	// RequireAuth rejects the requests without the bearer token, otherwise they are served
+	// with the Anonymous policy
+	RequireAuth bool
+	Anonymous   TokenPolicy
+	Tokens      []*TokenPolicy // Static bearer tokens
+
+	// JWTSecret is the secret of the HS256 signed jwt tokens. The "sub" claim names the caller,
+	// and the "allow" claim overrides the Allow of the JWT policy if given. The "exp" claim is required
+	JWTSecret []byte
+	JWT       TokenPolicy
+	// JWTMaxLifetime rejects the jwt tokens expiring later than it from now, unlimited if not positive
+	JWTMaxLifetime time.Duration
+
+	IPRate  float64 // Requests per second of one ip, unlimited if not positive
+	IPBurst int
+
+	MaxBatch        int // Max requests in a batch
+	MaxResponseSize int // Max bytes of the response body
+}
+
+// guardHandler checks the requests against the config before passing them to the next handler
+type guardHandler struct {
+	cfg    *HTTPGuardConfig
+	tokens map[string]*TokenPolicy
+	next   http.Handler
+
+	ipLimiter    *rateLimiter
+	tokenLimiter *rateLimiter
+}
+
+func newGuardHandler(cfg *HTTPGuardConfig, next http.Handler) http.Handler {
+	if cfg == nil {
+		return next
+	}
+	tokens := make(map[string]*TokenPolicy, len(cfg.Tokens))
+	for _, t := range cfg.Tokens {
+		tokens[t.Token] = t
+	}
+	return &guardHandler{
+		cfg:          cfg,
+		tokens:       tokens,
+		next:         next,
+		ipLimiter:    newRateLimiter(),
+		tokenLimiter: newRateLimiter(),
+	}
+}
+
+// ServeHTTP serves JSON-RPC requests over HTTP, implements http.Handler
+func (h *guardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+	case http.MethodGet:
+		// Static assets only
+		h.next.ServeHTTP(w, r)
+		return
+	case http.MethodOptions:
+		// The server runs the calls in any request with a body, so only the preflights are passed
+		if r.ContentLength == 0 {
+			h.next.ServeHTTP(w, r)
+			return
+		}
+		http.Error(w, "request body not allowed", http.StatusMethodNotAllowed)
+		return
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if code, err := validateRequest(r); err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxHTTPRequestContentLength+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxHTTPRequestContentLength {
+		http.Error(w, fmt.Sprintf("content length too large (>%d)", maxHTTPRequestContentLength), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	batch := isBatch(body)
+	var msgs []json.RawMessage
+	if batch {
+		if err := json.Unmarshal(body, &msgs); err != nil {
+			writeGuardError(w, http.StatusOK, nil, &invalidMessageError{err.Error()})
+			return
+		}
+		if len(msgs) == 0 {
+			writeGuardError(w, http.StatusOK, nil, &invalidRequestError{"empty batch"})
+			return
+		}
+		if h.cfg.MaxBatch > 0 && len(msgs) > h.cfg.MaxBatch {
+			writeGuardError(w, http.StatusOK, nil, &invalidRequestError{fmt.Sprintf("batch too large, limit %d", h.cfg.MaxBatch)})
+			return
+		}
+	} else {
+		msgs = []json.RawMessage{body}
+	}
+	reqs := make([]jsonRequest, len(msgs))
+	for i, msg := range msgs {
+		// Malformed ones are left to the server to respond
+		json.Unmarshal(msg, &reqs[i])
+	}
+	// The id of the response for the rejection of the whole request
+	var id json.RawMessage
+	if !batch {
+		id = reqs[0].ID
+	}
+
+	// Limit the ip before authenticating, so the tokens can't be guessed at full speed
+	if h.cfg.IPRate > 0 && !h.ipLimiter.allow(remoteIP(r), h.cfg.IPRate, h.cfg.IPBurst, len(msgs)) {
+		writeGuardError(w, http.StatusTooManyRequests, id, &rateLimitError{"ip request rate limit exceeded"})
+		return
+	}
+	policy, err := h.authenticate(r)
+	if err != nil {
+		writeGuardError(w, http.StatusUnauthorized, id, &unauthorizedError{err.Error()})
+		return
+	}
+	if policy.Rate > 0 && !h.tokenLimiter.allow(policy.Name, policy.Rate, policy.Burst, len(msgs)) {
+		writeGuardError(w, http.StatusTooManyRequests, id, &rateLimitError{"token request rate limit exceeded"})
+		return
+	}
+
+	// Requests not allowed are responded here, and the others are passed to the server
+	passed := make([]json.RawMessage, 0, len(msgs))
+	rejected := make([]interface{}, 0)
+	for i, req := range reqs {
+		if req.Method == "" || policy.allowed(req.Method) {
+			passed = append(passed, msgs[i])
+			continue
+		}
+		rejected = append(rejected, guardErrorResponse(req.ID, &methodForbiddenError{req.Method}))
+	}
+	if !batch && len(rejected) > 0 {
+		writeGuardResponse(w, http.StatusOK, rejected[0])
+		return
+	}
+	if len(passed) == 0 {
+		writeGuardResponse(w, http.StatusOK, rejected)
+		return
+	}
+	if len(rejected) > 0 {
+		if body, err = json.Marshal(passed); err != nil {
+			writeGuardError(w, http.StatusOK, nil, &callbackError{err.Error()})
+			return
+		}
+	}
+
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	rw := newBufferedResponseWriter(h.cfg.MaxResponseSize)
+	h.next.ServeHTTP(rw, r)
+	if rw.exceeded {
+		writeGuardError(w, http.StatusOK, id, &responseTooLargeError{h.cfg.MaxResponseSize})
+		return
+	}
+	if len(rejected) > 0 {
+		var resps []json.RawMessage
+		if err := json.Unmarshal(rw.buf.Bytes(), &resps); err == nil {
+			for _, resp := range rejected {
+				bs, _ := json.Marshal(resp)
+				resps = append(resps, bs)
+			}
+			writeGuardResponse(w, rw.status, resps)
+			return
+		}
+	}
+	for k, v := range rw.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(rw.status)
+	w.Write(rw.buf.Bytes())
+}
+
+// authenticate returns the policy of the bearer token in the request
+func (h *guardHandler) authenticate(r *http.Request) (*TokenPolicy, error) {
+	auth := strings.TrimSpace(r.Header.Get("Authorization"))
+	if auth == "" {
+		if h.cfg.RequireAuth {
+			return nil, fmt.Errorf("missing bearer token")
+		}
+		return &h.cfg.Anonymous, nil
+	}
+	const prefix = "bearer "
+	if len(auth) <= len(prefix) || strings.ToLower(auth[:len(prefix)]) != prefix {
+		return nil, fmt.Errorf("invalid authorization header")
+	}
+	token := strings.TrimSpace(auth[len(prefix):])
+	for t, p := range h.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return p, nil
+		}
+	}
+	if len(h.cfg.JWTSecret) > 0 && strings.Count(token, ".") == 2 {
+		claims, err := verifyJWT(token, h.cfg.JWTSecret, h.cfg.JWTMaxLifetime, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		policy := h.cfg.JWT
+		policy.Name = "jwt:" + claims.Subject
+		if claims.Allow != nil {
+			policy.Allow = claims.Allow
+		}
+		return &policy, nil
+	}
+	return nil, fmt.Errorf("invalid bearer token")
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func guardErrorResponse(id json.RawMessage, err Error) *jsonErrResponse {
+	resp := &jsonErrResponse{Version: jsonrpcVersion, Error: jsonError{Code: err.ErrorCode(), Message: err.Error()}}
+	if len(id) > 0 {
+		resp.ID = id
+	}
+	return resp
+}
+
+func writeGuardError(w http.ResponseWriter, status int, id json.RawMessage, err Error) {
+	writeGuardResponse(w, status, guardErrorResponse(id, err))
+}
+
+func writeGuardResponse(w http.ResponseWriter, status int, resp interface{}) {
+	w.Header().Set("content-type", contentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// bufferedResponseWriter holds the response in memory until its size is known
+type bufferedResponseWriter struct {
+	header   http.Header
+	status   int
+	buf      bytes.Buffer
+	limit    int
+	exceeded bool
+}
+
+func newBufferedResponseWriter(limit int) *bufferedResponseWriter {
+	return &bufferedResponseWriter{header: make(http.Header), status: http.StatusOK, limit: limit}
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	if w.exceeded {
+		return 0, fmt.Errorf("response too large")
+	}
+	if w.limit > 0 && w.buf.Len()+len(b) > w.limit {
+		w.exceeded = true
+		w.buf.Reset()
+		return 0, fmt.Errorf("response too large")
+	}
+	return w.buf.Write(b)
+}
+
+// jwtClaims are the claims of the jwt token recognized
+type jwtClaims struct {
+	Subject   string   `json:"sub"`
+	Expire    int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	Allow     []string `json:"allow"`
+}
+
+// verifyJWT checks the HS256 signature and the validity period of the jwt token, and returns its claims.
+// The token must expire, and not later than the max lifetime from now if it's positive
+func verifyJWT(token string, secret []byte, maxLifetime time.Duration, now time.Time) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed jwt token")
+	}
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed jwt header")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerBytes, &header); err != nil || header.Alg != "HS256" {
+		return nil, fmt.Errorf("unsupported jwt algorithm")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed jwt signature")
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, fmt.Errorf("invalid jwt signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed jwt payload")
+	}
+	claims := &jwtClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, fmt.Errorf("malformed jwt claims")
+	}
+	if claims.Expire <= 0 {
+		return nil, fmt.Errorf("jwt token without expiration")
+	}
+	if now.Unix() >= claims.Expire {
+		return nil, fmt.Errorf("jwt token expired")
+	}
+	if maxLifetime > 0 && claims.Expire > now.Add(maxLifetime).Unix() {
+		return nil, fmt.Errorf("jwt token lifetime exceeds %v", maxLifetime)
+	}
+	if claims.NotBefore > 0 && now.Unix() < claims.NotBefore {
+		return nil, fmt.Errorf("jwt token not valid yet")
+	}
+	return claims, nil
+}
+
+// rateLimiter limits the request rate of each key with the token bucket
+type rateLimiter struct {
+	lock      sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time // Time when the bucket becomes full again
+}
+
+const limiterPruneInterval = time.Minute
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*tokenBucket), lastPrune: time.Now()}
+}
+
+// allow takes n tokens from the bucket of the key, and returns false if not enough.
+// The bucket refills at the rate per second up to the burst
+func (l *rateLimiter) allow(key string, rate float64, burst int, n int) bool {
+	return l.allowAt(key, rate, burst, n, time.Now())
+}
+
+func (l *rateLimiter) allowAt(key string, rate float64, burst int, n int, now time.Time) bool {
+	capacity := float64(burst)
+	if capacity < rate {
+		capacity = rate
+	}
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if now.Sub(l.lastPrune) > limiterPruneInterval {
+		for k, b := range l.buckets {
+			if now.After(b.full) {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastPrune = now
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > capacity {
+		b.tokens = capacity
+	}
+	b.last = now
+	if b.tokens < float64(n) {
+		return false
+	}
+	b.tokens -= float64(n)
+	b.full = now.Add(time.Duration((capacity - b.tokens) / rate * float64(time.Second)))
+	return true
+}
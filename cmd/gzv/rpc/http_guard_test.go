@@ -0,0 +1,256 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type guardTestResponse struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *jsonError      `json:"error"`
+}
+
+func newGuardTestHandler(t *testing.T, cfg *HTTPGuardConfig) http.Handler {
+	server := NewServer(false)
+	if err := server.RegisterName("test", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	return newGuardHandler(cfg, server)
+}
+
+func guardCall(h http.Handler, token string, body string) (int, string) {
+	req := httptest.NewRequest(http.MethodPost, "http://url.com", strings.NewReader(body))
+	req.Header.Set("content-type", contentType)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+func guardErrorCode(t *testing.T, body string) int {
+	var resp guardTestResponse
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("unmarshal response %v error:%v", body, err)
+	}
+	if resp.Error == nil {
+		return 0
+	}
+	return resp.Error.Code
+}
+
+const (
+	echoRequest = `{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1,{"S":"y"}]}`
+	retsRequest = `{"jsonrpc":"2.0","id":2,"method":"test_rets","params":[]}`
+)
+
+func TestGuardAuth(t *testing.T) {
+	h := newGuardTestHandler(t, &HTTPGuardConfig{
+		RequireAuth: true,
+		Tokens:      []*TokenPolicy{{Name: "echo", Token: "secret", Allow: []string{"test_echo"}}},
+	})
+
+	code, body := guardCall(h, "", echoRequest)
+	if code != http.StatusUnauthorized || guardErrorCode(t, body) != -32001 {
+		t.Errorf("expect unauthorized without token, got %v %v", code, body)
+	}
+	code, body = guardCall(h, "wrong", echoRequest)
+	if code != http.StatusUnauthorized || guardErrorCode(t, body) != -32001 {
+		t.Errorf("expect unauthorized with wrong token, got %v %v", code, body)
+	}
+	if _, body = guardCall(h, "secret", echoRequest); guardErrorCode(t, body) != 0 {
+		t.Errorf("expect echo allowed, got %v", body)
+	}
+	if _, body = guardCall(h, "secret", retsRequest); guardErrorCode(t, body) != -32002 {
+		t.Errorf("expect rets forbidden, got %v", body)
+	}
+
+	// The forbidden one in the batch is rejected alone
+	_, body = guardCall(h, "secret", "["+echoRequest+","+retsRequest+"]")
+	var resps []guardTestResponse
+	if err := json.Unmarshal([]byte(body), &resps); err != nil || len(resps) != 2 {
+		t.Fatalf("unexpected batch response %v", body)
+	}
+	for _, resp := range resps {
+		if (resp.ID == 1) != (resp.Error == nil) {
+			t.Errorf("unexpected batch response %+v", resp)
+		}
+		if resp.ID == 2 && resp.Error.Code != -32002 {
+			t.Errorf("expect rets forbidden in batch, got %+v", resp.Error)
+		}
+	}
+}
+
+func TestGuardMethods(t *testing.T) {
+	h := newGuardTestHandler(t, &HTTPGuardConfig{RequireAuth: true})
+	for _, method := range []string{http.MethodPatch, http.MethodOptions, http.MethodPut, http.MethodDelete} {
+		req := httptest.NewRequest(method, "http://url.com", strings.NewReader(echoRequest))
+		req.Header.Set("content-type", contentType)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusMethodNotAllowed || strings.Contains(rec.Body.String(), "result") {
+			t.Errorf("expect %v with the body refused, got %v %v", method, rec.Code, rec.Body.String())
+		}
+	}
+	// The preflight without body is passed
+	req := httptest.NewRequest(http.MethodOptions, "http://url.com", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code == http.StatusMethodNotAllowed {
+		t.Errorf("expect the preflight passed, got %v", rec.Code)
+	}
+}
+
+func TestGuardNamespaceAllow(t *testing.T) {
+	h := newGuardTestHandler(t, &HTTPGuardConfig{Anonymous: TokenPolicy{Allow: []string{"test"}}})
+	if _, body := guardCall(h, "", retsRequest); guardErrorCode(t, body) != 0 {
+		t.Errorf("expect namespace allowed, got %v", body)
+	}
+	h = newGuardTestHandler(t, &HTTPGuardConfig{Anonymous: TokenPolicy{Allow: []string{"other"}}})
+	if _, body := guardCall(h, "", retsRequest); guardErrorCode(t, body) != -32002 {
+		t.Errorf("expect namespace forbidden, got %v", body)
+	}
+}
+
+func TestGuardLimits(t *testing.T) {
+	h := newGuardTestHandler(t, &HTTPGuardConfig{MaxBatch: 1})
+	if _, body := guardCall(h, "", "["+echoRequest+","+retsRequest+"]"); guardErrorCode(t, body) != -32600 {
+		t.Errorf("expect batch too large, got %v", body)
+	}
+
+	h = newGuardTestHandler(t, &HTTPGuardConfig{MaxResponseSize: 16})
+	if _, body := guardCall(h, "", echoRequest); guardErrorCode(t, body) != -32006 {
+		t.Errorf("expect response too large, got %v", body)
+	}
+
+	h = newGuardTestHandler(t, &HTTPGuardConfig{IPRate: 0.001, IPBurst: 1})
+	if _, body := guardCall(h, "", echoRequest); guardErrorCode(t, body) != 0 {
+		t.Errorf("expect first request served, got %v", body)
+	}
+	code, body := guardCall(h, "", echoRequest)
+	if code != http.StatusTooManyRequests || guardErrorCode(t, body) != -32005 {
+		t.Errorf("expect ip rate limited, got %v %v", code, body)
+	}
+
+	// Failed authentications are limited too
+	h = newGuardTestHandler(t, &HTTPGuardConfig{RequireAuth: true, Tokens: []*TokenPolicy{{Name: "a", Token: "secret"}}, IPRate: 0.001, IPBurst: 1})
+	if code, _ := guardCall(h, "guess1", echoRequest); code != http.StatusUnauthorized {
+		t.Errorf("expect the wrong token unauthorized, got %v", code)
+	}
+	if code, _ := guardCall(h, "guess2", echoRequest); code != http.StatusTooManyRequests {
+		t.Errorf("expect the guesses ip rate limited, got %v", code)
+	}
+
+	h = newGuardTestHandler(t, &HTTPGuardConfig{
+		Tokens: []*TokenPolicy{{Name: "limited", Token: "secret", Rate: 0.001, Burst: 1}},
+	})
+	guardCall(h, "secret", echoRequest)
+	if _, body := guardCall(h, "secret", echoRequest); guardErrorCode(t, body) != -32005 {
+		t.Errorf("expect token rate limited, got %v", body)
+	}
+	if _, body := guardCall(h, "", echoRequest); guardErrorCode(t, body) != 0 {
+		t.Errorf("expect anonymous not limited by the token, got %v", body)
+	}
+}
+
+func signJWT(secret []byte, claims string) string {
+	enc := base64.RawURLEncoding
+	signing := enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + enc.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signing))
+	return signing + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+func TestGuardJWT(t *testing.T) {
+	secret := []byte("jwt-secret")
+	h := newGuardTestHandler(t, &HTTPGuardConfig{
+		RequireAuth: true,
+		JWTSecret:   secret,
+		JWT:         TokenPolicy{Allow: []string{"test_rets"}},
+	})
+	exp := time.Now().Add(time.Hour).Unix()
+
+	token := signJWT(secret, `{"sub":"alice","exp":`+strconv.FormatInt(exp, 10)+`}`)
+	if _, body := guardCall(h, token, retsRequest); guardErrorCode(t, body) != 0 {
+		t.Errorf("expect jwt default allowed, got %v", body)
+	}
+	if _, body := guardCall(h, token, echoRequest); guardErrorCode(t, body) != -32002 {
+		t.Errorf("expect echo forbidden by jwt default, got %v", body)
+	}
+	token = signJWT(secret, `{"sub":"alice","allow":["test_echo"],"exp":`+strconv.FormatInt(exp, 10)+`}`)
+	if _, body := guardCall(h, token, echoRequest); guardErrorCode(t, body) != 0 {
+		t.Errorf("expect echo allowed by the claim, got %v", body)
+	}
+	never := signJWT(secret, `{"sub":"alice"}`)
+	if code, _ := guardCall(h, never, retsRequest); code != http.StatusUnauthorized {
+		t.Errorf("expect token without expiration rejected")
+	}
+
+	expired := signJWT(secret, `{"sub":"alice","exp":1}`)
+	if code, _ := guardCall(h, expired, retsRequest); code != http.StatusUnauthorized {
+		t.Errorf("expect expired token rejected")
+	}
+	forged := signJWT([]byte("other"), `{"sub":"alice","exp":`+strconv.FormatInt(exp, 10)+`}`)
+	if code, _ := guardCall(h, forged, retsRequest); code != http.StatusUnauthorized {
+		t.Errorf("expect forged token rejected")
+	}
+
+	h = newGuardTestHandler(t, &HTTPGuardConfig{RequireAuth: true, JWTSecret: secret, JWTMaxLifetime: time.Minute})
+	if code, _ := guardCall(h, token, echoRequest); code != http.StatusUnauthorized {
+		t.Errorf("expect token living longer than the max lifetime rejected")
+	}
+	short := signJWT(secret, `{"sub":"alice","exp":`+strconv.FormatInt(time.Now().Add(30*time.Second).Unix(), 10)+`}`)
+	if _, body := guardCall(h, short, echoRequest); guardErrorCode(t, body) != 0 {
+		t.Errorf("expect token within the max lifetime allowed, got %v", body)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter()
+	now := time.Now()
+	if !l.allowAt("a", 2, 4, 4, now) {
+		t.Fatalf("expect burst allowed")
+	}
+	if l.allowAt("a", 2, 4, 1, now) {
+		t.Fatalf("expect empty bucket rejected")
+	}
+	if !l.allowAt("b", 2, 4, 1, now) {
+		t.Fatalf("expect other key allowed")
+	}
+	now = now.Add(time.Second)
+	if !l.allowAt("a", 2, 4, 2, now) {
+		t.Fatalf("expect refilled tokens allowed")
+	}
+	if l.allowAt("a", 2, 4, 1, now) {
+		t.Fatalf("expect no more tokens")
+	}
+	// Full buckets are pruned
+	if !l.allowAt("c", 2, 4, 1, now.Add(2*limiterPruneInterval)) || len(l.buckets) != 1 {
+		t.Errorf("expect idle buckets pruned, left %v", len(l.buckets))
+	}
+}
@@ -0,0 +1,283 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/zvchain/zvchain/common"
+)
+
+// OpenRPCVersion is the version of the OpenRPC specification the discover document follows
+const OpenRPCVersion = "1.2.6"
+
+// OpenRPCDocument describes all methods registered on the server, see https://spec.open-rpc.org
+type OpenRPCDocument struct {
+	OpenRPC    string            `json:"openrpc"`
+	Info       OpenRPCInfo       `json:"info"`
+	Methods    []*OpenRPCMethod  `json:"methods"`
+	Components OpenRPCComponents `json:"components"`
+}
+
+type OpenRPCInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type OpenRPCMethod struct {
+	Name           string               `json:"name"`
+	ParamStructure string               `json:"paramStructure"`
+	Params         []*ContentDescriptor `json:"params"`
+	Result         *ContentDescriptor   `json:"result"`
+}
+
+// ContentDescriptor describes a parameter or the result of a method
+type ContentDescriptor struct {
+	Name     string  `json:"name"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type OpenRPCComponents struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Schema is the subset of the JSON schema used to describe the go types
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+// Discover returns the OpenRPC document of the methods registered on the server
+func (s *RPCService) Discover() *OpenRPCDocument {
+	return s.server.OpenRPC()
+}
+
+// OpenRPC builds the OpenRPC document from the signatures of the registered callbacks, with the
+// schemas derived from the go types of the parameters and results. The subscriptions are not included.
+func (s *Server) OpenRPC() *OpenRPCDocument {
+	b := &schemaBuilder{schemas: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+	doc := &OpenRPCDocument{
+		OpenRPC: OpenRPCVersion,
+		Info:    OpenRPCInfo{Title: "ZVChain JSON-RPC API", Version: common.GzvVersion},
+		Methods: make([]*OpenRPCMethod, 0),
+	}
+
+	svcNames := make([]string, 0, len(s.services))
+	for name := range s.services {
+		svcNames = append(svcNames, name)
+	}
+	sort.Strings(svcNames)
+	for _, svcName := range svcNames {
+		svc := s.services[svcName]
+		names := make([]string, 0, len(svc.callbacks))
+		for name := range svc.callbacks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			doc.Methods = append(doc.Methods, b.method(svcName+serviceMethodSeparator+name, svc.callbacks[name]))
+		}
+	}
+	doc.Components.Schemas = b.schemas
+	return doc
+}
+
+type schemaBuilder struct {
+	schemas map[string]*Schema      // component schemas by name
+	names   map[reflect.Type]string // component names of the struct types already visited
+}
+
+func (b *schemaBuilder) method(name string, cb *callback) *OpenRPCMethod {
+	m := &OpenRPCMethod{Name: name, ParamStructure: "by-position", Params: make([]*ContentDescriptor, 0, len(cb.argTypes))}
+	for i, t := range cb.argTypes {
+		// Only the pointer arguments can be omitted, see parsePositionalArguments
+		m.Params = append(m.Params, &ContentDescriptor{
+			Name:     fmt.Sprintf("param%d", i+1),
+			Required: t.Kind() != reflect.Ptr,
+			Schema:   b.schema(t),
+		})
+	}
+	mtype := cb.method.Type
+	if mtype.NumOut() > 0 && cb.errPos != 0 {
+		m.Result = &ContentDescriptor{Name: "result", Schema: b.schema(mtype.Out(0))}
+	} else {
+		m.Result = &ContentDescriptor{Name: "result", Schema: &Schema{Type: "null"}}
+	}
+	return m
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// marshaledKind returns the json type encoded by the custom marshaler of the given type,
+// which is judged by encoding the zero value of the type
+func marshaledKind(t reflect.Type) (kind string, ok bool) {
+	if t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		return "string", true
+	}
+	if !t.Implements(jsonMarshalerType) && !reflect.PtrTo(t).Implements(jsonMarshalerType) {
+		return "", false
+	}
+	defer func() {
+		if recover() != nil {
+			kind, ok = "", true
+		}
+	}()
+	bs, err := json.Marshal(reflect.New(t).Interface())
+	if err != nil || len(bs) == 0 {
+		return "", true
+	}
+	switch bs[0] {
+	case '"':
+		return "string", true
+	case '{':
+		return "object", true
+	case '[':
+		return "array", true
+	case 't', 'f':
+		return "boolean", true
+	case 'n':
+		return "", true
+	}
+	return "number", true
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == bigIntType {
+		return &Schema{Type: "integer"}
+	}
+	if kind, ok := marshaledKind(t); ok {
+		return &Schema{Type: kind}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		// Byte slices are encoded in base64 while byte arrays are encoded as arrays
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string"}
+		}
+		return &Schema{Type: "array", Items: b.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+	case reflect.Struct:
+		return b.structSchema(t)
+	}
+	// Interfaces and others could be of any type
+	return &Schema{}
+}
+
+// structSchema returns the reference to the component schema of the given struct type,
+// the anonymous structs are inlined
+func (b *schemaBuilder) structSchema(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return b.objectSchema(t)
+	}
+	if name, ok := b.names[t]; ok {
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	name := t.Name()
+	if _, ok := b.schemas[name]; ok {
+		// Qualify the name with the package if the name is taken by another type
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+	b.names[t] = name
+	// Register before building so that the recursive types refer to the component
+	b.schemas[name] = &Schema{}
+	*b.schemas[name] = *b.objectSchema(t)
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (b *schemaBuilder) objectSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	b.addFields(s, t)
+	sort.Strings(s.Required)
+	return s
+}
+
+// addFields adds the fields encoded by encoding/json into the object schema, with the fields of
+// the embedded structs promoted unless shadowed by the outer fields
+func (b *schemaBuilder) addFields(s *Schema, t reflect.Type) {
+	embedded := make([]reflect.Type, 0)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			name, opts = tag[:idx], tag[idx+1:]
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			if _, ok := marshaledKind(ft); !ok {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = b.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Ptr {
+			s.Required = append(s.Required, name)
+		}
+	}
+	for _, et := range embedded {
+		inner := &Schema{Properties: make(map[string]*Schema)}
+		b.addFields(inner, et)
+		for name, fs := range inner.Properties {
+			if _, ok := s.Properties[name]; !ok {
+				s.Properties[name] = fs
+			}
+		}
+		for _, name := range inner.Required {
+			if s.Properties[name] == inner.Properties[name] {
+				s.Required = append(s.Required, name)
+			}
+		}
+	}
+}
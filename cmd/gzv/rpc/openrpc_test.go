@@ -0,0 +1,118 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/zvchain/zvchain/common"
+)
+
+type schemaInner struct {
+	Height uint64 `json:"height"`
+	Name   string `json:"name"`
+}
+
+type SchemaResult struct {
+	schemaInner
+	Name    string            `json:"title"`
+	Hash    common.Hash       `json:"hash"`
+	Addr    *common.Address   `json:"addr,omitempty"`
+	Values  []float64         `json:"values"`
+	Data    []byte            `json:"data"`
+	Extra   map[string]uint32 `json:"extra"`
+	Any     interface{}       `json:"any"`
+	Next    *SchemaResult     `json:"next"`
+	Ignored int               `json:"-"`
+	private int
+}
+
+type SchemaService struct{}
+
+func (s *SchemaService) Get(height uint64, filter *string) (*SchemaResult, error) {
+	return nil, nil
+}
+
+func (s *SchemaService) Clear() error {
+	return nil
+}
+
+func TestServer_OpenRPC(t *testing.T) {
+	server := NewServer(false)
+	if err := server.RegisterName("test", new(SchemaService)); err != nil {
+		t.Fatal(err)
+	}
+	doc := server.OpenRPC()
+
+	names := make([]string, 0)
+	for _, m := range doc.Methods {
+		names = append(names, m.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"rpc_discover", "rpc_modules", "test_clear", "test_get"}) {
+		t.Fatalf("unexpected methods %v", names)
+	}
+	clear, get := doc.Methods[2], doc.Methods[3]
+	if len(clear.Params) != 0 || clear.Result.Schema.Type != "null" {
+		t.Errorf("unexpected clear method %+v", clear)
+	}
+	if len(get.Params) != 2 || !get.Params[0].Required || get.Params[0].Schema.Type != "integer" ||
+		get.Params[1].Required || get.Params[1].Schema.Type != "string" {
+		t.Errorf("unexpected params of get")
+	}
+	if get.Result.Schema.Ref != "#/components/schemas/SchemaResult" {
+		t.Errorf("unexpected result of get %+v", get.Result.Schema)
+	}
+
+	s := doc.Components.Schemas["SchemaResult"]
+	if s == nil || s.Type != "object" {
+		t.Fatalf("schema of the result not found")
+	}
+	expect := map[string]string{
+		"height": "integer", "name": "string", "title": "string", "hash": "string", "addr": "string",
+		"values": "array", "data": "string", "extra": "object", "any": "", "next": "",
+	}
+	if len(s.Properties) != len(expect) {
+		t.Errorf("unexpected properties %v", s.Properties)
+	}
+	for name, typ := range expect {
+		if p := s.Properties[name]; p == nil || p.Type != typ {
+			t.Errorf("unexpected property %v: %+v", name, p)
+		}
+	}
+	if s.Properties["next"].Ref != "#/components/schemas/SchemaResult" || s.Properties["values"].Items.Type != "number" ||
+		s.Properties["extra"].AdditionalProperties.Type != "integer" {
+		t.Errorf("unexpected nested schemas")
+	}
+	if !reflect.DeepEqual(s.Required, []string{"any", "data", "extra", "hash", "height", "name", "title", "values"}) {
+		t.Errorf("unexpected required %v", s.Required)
+	}
+
+	// The document is served by rpc_discover
+	client := DialInProc(server)
+	defer client.Close()
+	var served map[string]interface{}
+	if err := client.Call(&served, "rpc_discover"); err != nil {
+		t.Fatal(err)
+	}
+	bs, _ := json.Marshal(doc)
+	var local map[string]interface{}
+	json.Unmarshal(bs, &local)
+	if !reflect.DeepEqual(served, local) {
+		t.Errorf("served document differs from the local one")
+	}
+}
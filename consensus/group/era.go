@@ -104,3 +104,14 @@ func (e *era) String() string {
 func (e *era) end() uint64 {
 	return e.endRange.begin
 }
+
+// stage returns the number of the rounds begun at the given height, 4 if the era ended
+func (e *era) stage(h uint64) int {
+	stage := 0
+	for _, r := range []*rRange{e.encPieceRange, e.mpkRange, e.oriPieceRange, e.endRange} {
+		if h >= r.begin {
+			stage++
+		}
+	}
+	return stage
+}
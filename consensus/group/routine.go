@@ -24,6 +24,7 @@ import (
 	"github.com/zvchain/zvchain/consensus/groupsig"
 	"github.com/zvchain/zvchain/consensus/model"
 	"github.com/zvchain/zvchain/log"
+	"github.com/zvchain/zvchain/middleware/metrics"
 	"github.com/zvchain/zvchain/middleware/notify"
 	"github.com/zvchain/zvchain/middleware/types"
 	"math"
@@ -96,6 +97,7 @@ func InitRoutine(reader minerReader, chain types.BlockChain, provider groupConte
 
 	go GroupRoutine.store.loop()
 	go checker.stat.loop()
+	GroupRoutine.registerMetrics()
 
 	provider.RegisterGroupCreateChecker(checker)
 
@@ -104,6 +106,31 @@ func InitRoutine(reader minerReader, chain types.BlockChain, provider groupConte
 	return GroupRoutine.store
 }
 
+func (routine *createRoutine) registerMetrics() {
+	metrics.NewGaugeFunc("gzv_group_create_seed_height", "Seed height of the current group-create era", func() float64 {
+		routine.lock.RLock()
+		defer routine.lock.RUnlock()
+		return float64(routine.currEra().seedHeight)
+	})
+	metrics.NewGaugeFunc("gzv_group_create_stage", "Stage of the current group-create era: 0 waiting, 1 to 3 in or after the rounds of encrypted piece, mpk and origin piece, 4 ended", func() float64 {
+		top := routine.chain.QueryTopBlock()
+		if top == nil {
+			return 0
+		}
+		routine.lock.RLock()
+		defer routine.lock.RUnlock()
+		return float64(routine.currEra().stage(top.Height))
+	})
+	metrics.NewGaugeFunc("gzv_group_create_selected", "Whether the node is selected as a candidate of the current group-create era", func() float64 {
+		routine.lock.RLock()
+		defer routine.lock.RUnlock()
+		if routine.selected() {
+			return 1
+		}
+		return 0
+	})
+}
+
 func (routine *createRoutine) onNewTopMessage(message notify.Message) error {
 	bh := message.GetData().(*types.BlockHeader)
 	return routine.onNewTopBlock(bh)
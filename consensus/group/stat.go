@@ -18,6 +18,7 @@ package group
 import (
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/metrics"
 	"sync/atomic"
 )
 
@@ -29,6 +30,18 @@ const (
 	createStatusFail
 )
 
+func (s createStatus) String() string {
+	switch s {
+	case createStatusSuccess:
+		return "success"
+	case createStatusFail:
+		return "fail"
+	}
+	return "idle"
+}
+
+var groupCreateTotal = metrics.NewCounterVec("gzv_group_create_total", "Number of the group-create eras by status, the idle ones are counted when the eras begin", "status")
+
 type createStat struct {
 	eraCache *lru.Cache
 	idle     int32
@@ -67,6 +80,9 @@ func (st *createStat) loop() {
 }
 
 func (st *createStat) markStatus(eraSeed uint64, status createStatus) {
+	if v, ok := st.eraCache.Peek(eraSeed); !ok || v.(createStatus) != status {
+		groupCreateTotal.With(status.String()).Inc()
+	}
 	st.eraCache.Add(eraSeed, status)
 }
 
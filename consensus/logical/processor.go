@@ -59,7 +59,8 @@ type Processor struct {
 
 	Ticker *ticker.GlobalTicker // Global timer responsible for some cron tasks
 
-	ready bool // Whether it has been initialized
+	ready   int32 // Whether the miner is started and participating in the consensus, accessed atomically
+	started int32 // Whether the loops started, which keep running after the miner stopped, accessed atomically
 
 	MainChain types.BlockChain // Blockchain access interface
 
@@ -125,7 +126,7 @@ func (p Processor) getMinerInfo() *model.SelfMinerDO {
 
 // Init initialize the process engine
 func (p *Processor) Init(mi model.SelfMinerDO, conf common.ConfManager) bool {
-	p.ready = false
+	atomic.StoreInt32(&p.ready, 0)
 	p.conf = conf
 	p.futureVerifyMsgs = NewFutureMessageHolder()
 	p.rewardHandler = NewRewardHandler(p)
@@ -222,7 +223,7 @@ func (p *Processor) getDefaultSeckeyInfo() model.SecKeyInfo {
 	return model.NewSecKeyInfo(p.GetMinerID(), p.mi.GetDefaultSecKey())
 }
 
-// Start starts miner process
+// Start starts miner process, which can also restart the miner stopped by Stop
 func (p *Processor) Start() bool {
 	p.Ticker.RegisterPeriodicRoutine(p.getCastCheckRoutineName(), p.checkSelfCastRoutine, 1)
 	p.Ticker.RegisterPeriodicRoutine(p.getReleaseRoutineName(), p.releaseRoutine, 2)
@@ -233,18 +234,27 @@ func (p *Processor) Start() bool {
 	p.Ticker.RegisterPeriodicRoutine(p.getUpdateMonitorNodeInfoRoutine(), p.updateMonitorInfo, 3)
 	p.Ticker.StartTickerRoutine(p.getUpdateMonitorNodeInfoRoutine(), false)
 
-	p.triggerCastCheck()
-	go p.chLoop()
-	p.initLivedGroup()
+	if atomic.CompareAndSwapInt32(&p.started, 0, 1) {
+		go p.chLoop()
+		p.initLivedGroup()
+	}
 
-	p.ready = true
+	atomic.StoreInt32(&p.ready, 1)
+	p.triggerCastCheck()
 	return true
 }
 
-// Stop is reserved interface
+// Stop stops casting blocks and handling the consensus messages, and the miner can be started again by Start.
+// The blocks added on chain are still tracked, so the miner resumes on the latest groups and contexts
 func (p *Processor) Stop() {
+	atomic.StoreInt32(&p.ready, 0)
+	p.Ticker.StopTickerRoutine(p.getBroadcastRoutineName())
+}
+
+// Close stops the miner and releases the resources
+func (p *Processor) Close() {
+	p.Stop()
 	p.groupReader.skStore.Close()
-	return
 }
 
 func (p *Processor) initLivedGroup() {
@@ -280,5 +290,5 @@ func (p *Processor) initLivedGroup() {
 
 // Ready check if the processor engine is initialized and ready for message processing
 func (p *Processor) Ready() bool {
-	return p.ready
+	return atomic.LoadInt32(&p.ready) == 1
 }
@@ -16,6 +16,8 @@
 package logical
 
 import (
+	"sync/atomic"
+
 	"github.com/zvchain/zvchain/monitor"
 
 	"github.com/zvchain/zvchain/consensus/groupsig"
@@ -30,8 +32,10 @@ func (p *Processor) chLoop() {
 			go p.verifyCachedMsg(bh.Hash)
 		case b := <-p.blockAddCh:
 			go p.checkSelfCastRoutine()
-			go p.triggerFutureVerifyMsg(b.Header)
-			go p.rewardHandler.TriggerFutureRewardSign(b.Header)
+			if p.Ready() {
+				go p.triggerFutureVerifyMsg(b.Header)
+				go p.rewardHandler.TriggerFutureRewardSign(b.Header)
+			}
 			go p.gNetMgr.updateGroupNetRoutine(b)
 			p.blockContexts.removeProposed(b.Header.Hash)
 		}
@@ -59,7 +63,8 @@ func (p *Processor) triggerFutureVerifyMsg(bh *types.BlockHeader) {
 
 // onBlockAddSuccess handle the event of block add-on-chain
 func (p *Processor) onBlockAddSuccess(message notify.Message) error {
-	if !p.Ready() {
+	// The groups and contexts are still tracked after the miner stopped, only the casting and signing are skipped
+	if atomic.LoadInt32(&p.started) == 0 {
 		return nil
 	}
 	block := message.GetData().(*types.Block)
@@ -76,7 +81,9 @@ func (p *Processor) onBlockAddSuccess(message notify.Message) error {
 			if vctx.isWorking() {
 				vctx.markCastSuccess()
 			}
-			p.rewardHandler.reqRewardTransSign(vctx, bh)
+			if p.Ready() {
+				p.rewardHandler.reqRewardTransSign(vctx, bh)
+			}
 		}
 	}
 
@@ -24,6 +24,7 @@ import (
 
 	"github.com/zvchain/zvchain/consensus/base"
 	"github.com/zvchain/zvchain/consensus/model"
+	"github.com/zvchain/zvchain/middleware/metrics"
 	"github.com/zvchain/zvchain/middleware/time"
 	"github.com/zvchain/zvchain/middleware/types"
 )
@@ -38,11 +39,21 @@ const (
 var max256 *big.Rat
 var rat1 *big.Rat
 
+var vrfProveTotal = metrics.NewCounterVec("gzv_vrf_prove_total", "Number of the vrf proves for the block proposal by result", "result")
+
 func init() {
 	t := new(big.Int)
 	t.SetString("ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", 16)
 	max256 = new(big.Rat).SetInt(t)
 	rat1 = new(big.Rat).SetInt64(1)
+
+	metrics.NewGaugeFunc("gzv_vrf_success_rate", "Rate of the vrf proves satisfying the proposal condition", func() float64 {
+		succ, fail := vrfProveTotal.With("success").Value(), vrfProveTotal.With("fail").Value()
+		if succ+fail == 0 {
+			return 0
+		}
+		return float64(succ) / float64(succ+fail)
+	})
 }
 
 // vrfWorker do some vrf calculations during block proposal to check if the specified miner
@@ -92,12 +103,14 @@ func vrfM(random []byte, h uint64) []byte {
 func (vrf *vrfWorker) Prove(totalStake uint64) (base.VRFProve, uint64, error) {
 	pi, err := base.VRFGenerateProve(vrf.miner.VrfPK, vrf.miner.VrfSK, vrf.m())
 	if err != nil {
+		vrfProveTotal.With("error").Inc()
 		return nil, 0, err
 	}
 	if ok, qn := vrfSatisfy(pi, vrf.miner.Stake, totalStake); ok {
-
+		vrfProveTotal.With("success").Inc()
 		return pi, qn, nil
 	}
+	vrfProveTotal.With("fail").Inc()
 	return nil, 0, errors.New("proof fail")
 }
 
@@ -43,8 +43,18 @@ func StartMiner() bool {
 	return Proc.Start()
 }
 
-// StopMiner ends the miner process and no longer participate in the consensus
+// StopMiner ends the miner process and no longer participate in the consensus until started again
 func StopMiner() {
 	Proc.Stop()
 	return
 }
+
+// IsMining returns whether the miner process is started and participating in the consensus
+func IsMining() bool {
+	return Proc.Ready()
+}
+
+// CloseMiner stops the miner process and releases the resources on exit
+func CloseMiner() {
+	Proc.Close()
+}
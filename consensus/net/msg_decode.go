@@ -143,7 +143,10 @@ func unmarshalResponseProposalBlockMessage(b []byte) (*model.ResponseProposalBlo
 	if e != nil {
 		return nil, fmt.Errorf("unmarshalResponseProposalBlockMessage:%v", e)
 	}
-	transactions := types.PbToTransactions(message.Transactions)
+	transactions, e := types.PbToTransactions(message.Transactions)
+	if e != nil {
+		return nil, fmt.Errorf("unmarshalResponseProposalBlockMessage:%v", e)
+	}
 
 	m := &model.ResponseProposalBlock{
 		Hash:         common.BytesToHash(message.Hash),
@@ -0,0 +1,390 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+	"github.com/zvchain/zvchain/storage/rlp"
+	"github.com/zvchain/zvchain/storage/tasdb"
+)
+
+// The address index keeps three kinds of key value pairs:
+//
+//	entryPrefix + address + height + txIndex -> tx hash, one for each address involved in the transaction
+//	blockPrefix + height -> the block hash and the entries indexed for the block, used for removing them
+//	nextKey -> the next height to index, all blocks below are indexed
+const (
+	addrIndexEntryPrefix = 'a'
+	addrIndexBlockPrefix = 'b'
+
+	addrIndexPosLength = 8 + 2 // height + txIndex
+
+	addrIndexRebuildBatch = 1000 // Number of blocks indexed in one batch when rebuilding
+)
+
+var addrIndexNextKey = []byte("next")
+
+// AddressTx is the position of a transaction involving an address
+type AddressTx struct {
+	Height  uint64
+	TxIndex uint16
+	TxHash  common.Hash
+}
+
+type addrIndexEntry struct {
+	Addr    common.Address
+	TxIndex uint16
+	TxHash  common.Hash
+}
+
+type addrIndexBlock struct {
+	Hash    common.Hash
+	Entries []*addrIndexEntry
+}
+
+// addressIndex maps the addresses to the transactions sent from or to them, including the transfers
+// made inside the contracts
+type addressIndex struct {
+	db *tasdb.PrefixedDatabase
+}
+
+func newAddressIndex(db *tasdb.PrefixedDatabase) *addressIndex {
+	return &addressIndex{db: db}
+}
+
+func addrIndexPos(height uint64, txIndex uint16) []byte {
+	pos := make([]byte, addrIndexPosLength)
+	binary.BigEndian.PutUint64(pos, height)
+	binary.BigEndian.PutUint16(pos[8:], txIndex)
+	return pos
+}
+
+func addrIndexEntryKeyPrefix(addr common.Address) []byte {
+	return append([]byte{addrIndexEntryPrefix}, addr.Bytes()...)
+}
+
+func addrIndexEntryKey(addr common.Address, height uint64, txIndex uint16) []byte {
+	return append(addrIndexEntryKeyPrefix(addr), addrIndexPos(height, txIndex)...)
+}
+
+func addrIndexBlockKey(height uint64) []byte {
+	return append([]byte{addrIndexBlockPrefix}, common.UInt64ToByte(height)...)
+}
+
+// buildAddrIndexEntries returns the index entries of the executed transactions in a block.
+// Receipts and transferees are optional and aligned with the transactions if given
+func buildAddrIndexEntries(txs txSlice, receipts types.Receipts, transferees [][]common.Address) []*addrIndexEntry {
+	entries := make([]*addrIndexEntry, 0)
+	for i, tx := range txs {
+		addrs := make(map[common.Address]struct{})
+		if tx.Source != nil {
+			addrs[*tx.Source] = struct{}{}
+		}
+		if tx.Target != nil {
+			addrs[*tx.Target] = struct{}{}
+		}
+		if i < len(receipts) && receipts[i] != nil && receipts[i].ContractAddress != (common.Address{}) {
+			addrs[receipts[i].ContractAddress] = struct{}{}
+		}
+		if i < len(transferees) {
+			for _, addr := range transferees[i] {
+				addrs[addr] = struct{}{}
+			}
+		}
+		for addr := range addrs {
+			entries = append(entries, &addrIndexEntry{Addr: addr, TxIndex: uint16(i), TxHash: tx.Hash})
+		}
+	}
+	return entries
+}
+
+// next returns the next height to index
+func (idx *addressIndex) next() uint64 {
+	bs, err := idx.db.Get(addrIndexNextKey)
+	if err != nil || len(bs) != 8 {
+		return 0
+	}
+	return common.ByteToUInt64(bs)
+}
+
+func (idx *addressIndex) setNext(batch tasdb.Batch, height uint64) error {
+	return idx.db.AddKv(batch, addrIndexNextKey, common.UInt64ToByte(height))
+}
+
+func (idx *addressIndex) getBlock(height uint64) *addrIndexBlock {
+	bs, err := idx.db.Get(addrIndexBlockKey(height))
+	if err != nil || len(bs) == 0 {
+		return nil
+	}
+	var b addrIndexBlock
+	if err := rlp.DecodeBytes(bs, &b); err != nil {
+		Logger.Errorf("decode address index of height %v error:%v", height, err)
+		return nil
+	}
+	return &b
+}
+
+// deleteBlock deletes the entries indexed for the block at the given height. Only the block
+// with the given hash is deleted if hash not nil
+func (idx *addressIndex) deleteBlock(batch tasdb.Batch, height uint64, hash *common.Hash) error {
+	b := idx.getBlock(height)
+	if b == nil || (hash != nil && b.Hash != *hash) {
+		return nil
+	}
+	for _, e := range b.Entries {
+		if err := idx.db.AddKv(batch, addrIndexEntryKey(e.Addr, height, e.TxIndex), nil); err != nil {
+			return err
+		}
+	}
+	return idx.db.AddKv(batch, addrIndexBlockKey(height), nil)
+}
+
+// putBlock replaces the entries of the given height with the given ones
+func (idx *addressIndex) putBlock(batch tasdb.Batch, bh *types.BlockHeader, entries []*addrIndexEntry) error {
+	if err := idx.deleteBlock(batch, bh.Height, nil); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := idx.db.AddKv(batch, addrIndexEntryKey(e.Addr, bh.Height, e.TxIndex), e.TxHash.Bytes()); err != nil {
+			return err
+		}
+	}
+	bs, err := rlp.EncodeToBytes(&addrIndexBlock{Hash: bh.Hash, Entries: entries})
+	if err != nil {
+		return err
+	}
+	return idx.db.AddKv(batch, addrIndexBlockKey(bh.Height), bs)
+}
+
+// commitBlock indexes the block committed on the chain. Blocks higher than the next height
+// to index are left to the rebuilding, so that the index keeps continuous
+func (idx *addressIndex) commitBlock(batch tasdb.Batch, bh *types.BlockHeader, entries []*addrIndexEntry) error {
+	if idx.next() < bh.Height {
+		return nil
+	}
+	if err := idx.putBlock(batch, bh, entries); err != nil {
+		return err
+	}
+	return idx.setNext(batch, bh.Height+1)
+}
+
+// removeBlock removes the index of the block removed from the chain
+func (idx *addressIndex) removeBlock(batch tasdb.Batch, bh *types.BlockHeader) error {
+	if err := idx.deleteBlock(batch, bh.Height, &bh.Hash); err != nil {
+		return err
+	}
+	if idx.next() > bh.Height {
+		return idx.setNext(batch, bh.Height)
+	}
+	return nil
+}
+
+// clear removes the whole index
+func (idx *addressIndex) clear() error {
+	iter := idx.db.NewIterator()
+	defer iter.Release()
+
+	batch := idx.db.CreateLDBBatch()
+	for iter.Next() {
+		if err := idx.db.AddKv(batch, common.CopyBytes(iter.Key()), nil); err != nil {
+			return err
+		}
+		if batch.ValueSize() > tasdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+// query returns at most limit transactions involving the address after the given position, in
+// ascending order of the position or descending order if desc is true. It also returns the position
+// of the last returned transaction which can be used as the cursor of the next query
+func (idx *addressIndex) query(addr common.Address, cursor []byte, limit int, desc bool) ([]*AddressTx, []byte) {
+	iter := idx.db.NewIteratorWithPrefix(addrIndexEntryKeyPrefix(addr))
+	defer iter.Release()
+
+	var valid bool
+	switch {
+	case cursor == nil && !desc:
+		valid = iter.First()
+	case cursor == nil && desc:
+		valid = iter.Last()
+	case !desc:
+		valid = iter.Seek(cursor)
+		if valid && bytes.Equal(iter.Key(), cursor) {
+			valid = iter.Next()
+		}
+	default:
+		if iter.Seek(cursor) {
+			valid = iter.Prev()
+		} else {
+			valid = iter.Last()
+		}
+	}
+
+	step := iter.Next
+	if desc {
+		step = iter.Prev
+	}
+	txs := make([]*AddressTx, 0)
+	var last []byte
+	for ; valid && len(txs) < limit; valid = step() {
+		pos := iter.Key()
+		if len(pos) != addrIndexPosLength {
+			continue
+		}
+		txs = append(txs, &AddressTx{
+			Height:  binary.BigEndian.Uint64(pos),
+			TxIndex: binary.BigEndian.Uint16(pos[8:]),
+			TxHash:  common.BytesToHash(iter.Value()),
+		})
+		last = common.CopyBytes(pos)
+	}
+	// No more transactions
+	if !valid {
+		last = nil
+	}
+	return txs, last
+}
+
+// startAddressIndexRebuild starts indexing the blocks not indexed yet in background.
+// The existing index is removed first if full is true
+func (chain *FullBlockChain) startAddressIndexRebuild(full bool) error {
+	if full {
+		Logger.Infof("clear the address index for rebuilding")
+		if err := chain.addrIndex.clear(); err != nil {
+			return err
+		}
+	}
+	go func() {
+		if err := chain.rebuildAddressIndex(); err != nil {
+			Logger.Errorf("rebuild address index error:%v", err)
+		}
+	}()
+	return nil
+}
+
+// rebuildAddressIndex indexes the blocks from the next height to index to the top.
+// The transfers inside the contracts are kept for the blocks indexed before, but lost for the
+// blocks committed while the index disabled since the transactions are not re-executed
+func (chain *FullBlockChain) rebuildAddressIndex() error {
+	idx := chain.addrIndex
+	begin := idx.next()
+	Logger.Infof("rebuild address index from height %v", begin)
+
+	for {
+		indexed, err := chain.indexBlocksFrom(idx.next())
+		if err != nil {
+			return err
+		}
+		if !indexed {
+			break
+		}
+		if atomic.LoadInt32(&chain.shutdowning) == 1 {
+			return fmt.Errorf("in shutdown hook")
+		}
+	}
+	Logger.Infof("rebuild address index finished, from %v to %v", begin, idx.next())
+	return nil
+}
+
+// indexBlocksFrom indexes a batch of blocks from the given height, and returns false if nothing indexed
+func (chain *FullBlockChain) indexBlocksFrom(from uint64) (bool, error) {
+	// Holds the read lock to avoid the chain changing during indexing
+	chain.rwLock.RLock()
+	defer chain.rwLock.RUnlock()
+
+	idx := chain.addrIndex
+	top := chain.latestBlock
+	if top == nil || from > top.Height {
+		// Remove the index of the blocks removed from the chain while the index disabled
+		batch := idx.db.CreateLDBBatch()
+		iter := idx.db.NewIteratorWithPrefix([]byte{addrIndexBlockPrefix})
+		defer iter.Release()
+		for iter.Next() {
+			height := common.ByteToUInt64(iter.Key())
+			if top == nil || height > top.Height {
+				if err := idx.deleteBlock(batch, height, nil); err != nil {
+					return false, err
+				}
+			}
+		}
+		return false, batch.Write()
+	}
+
+	batch := idx.db.CreateLDBBatch()
+	height := from
+	for ; height <= top.Height && height < from+addrIndexRebuildBatch; height++ {
+		bh := chain.queryBlockHeaderByHeight(height)
+		if bh == nil {
+			continue
+		}
+		rawTxs := chain.queryBlockTransactionsAll(bh.Hash)
+		txs := make(txSlice, 0, len(rawTxs))
+		receipts := make(types.Receipts, 0, len(rawTxs))
+		for _, raw := range rawTxs {
+			tx := types.NewTransaction(raw, raw.GenHash())
+			txs = append(txs, tx)
+			receipts = append(receipts, chain.transactionPool.GetReceipt(tx.Hash))
+		}
+		// Keep the transfers inside the contracts indexed before
+		var transferees [][]common.Address
+		if b := idx.getBlock(height); b != nil && b.Hash == bh.Hash {
+			transferees = make([][]common.Address, len(txs))
+			for _, e := range b.Entries {
+				if int(e.TxIndex) < len(transferees) {
+					transferees[e.TxIndex] = append(transferees[e.TxIndex], e.Addr)
+				}
+			}
+		}
+		if err := idx.putBlock(batch, bh, buildAddrIndexEntries(txs, receipts, transferees)); err != nil {
+			return false, err
+		}
+	}
+	if err := idx.setNext(batch, height); err != nil {
+		return false, err
+	}
+	return true, batch.Write()
+}
+
+// TxsByAddress returns at most limit transactions sent from or to the address, including the transfers
+// made inside the contracts, after the cursor position in ascending or descending order. The returned cursor
+// is used for querying the next page and is nil if no more transactions
+func (chain *FullBlockChain) TxsByAddress(addr common.Address, cursor []byte, limit int, desc bool) ([]*AddressTx, []byte, error) {
+	if chain.addrIndex == nil {
+		return nil, nil, fmt.Errorf("address index not enabled")
+	}
+	if cursor != nil && len(cursor) != addrIndexPosLength {
+		return nil, nil, fmt.Errorf("invalid cursor")
+	}
+	chain.rwLock.RLock()
+	defer chain.rwLock.RUnlock()
+
+	txs, next := chain.addrIndex.query(addr, cursor, limit, desc)
+	return txs, next, nil
+}
@@ -0,0 +1,115 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"os"
+	"testing"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+	"github.com/zvchain/zvchain/storage/tasdb"
+)
+
+func newTestAddressIndex(t *testing.T) (*addressIndex, func()) {
+	ds, err := tasdb.NewDataSource("test_addr_index", nil)
+	if err != nil {
+		t.Fatalf("new datasource error:%v", err)
+	}
+	db, err := ds.NewPrefixDatabase("ai")
+	if err != nil {
+		t.Fatalf("new prefix db error:%v", err)
+	}
+	return newAddressIndex(db), func() {
+		db.Close()
+		os.RemoveAll("test_addr_index")
+	}
+}
+
+func commitTestAddrIndexBlock(t *testing.T, idx *addressIndex, bh *types.BlockHeader, txs txSlice, transferees [][]common.Address) {
+	batch := idx.db.CreateLDBBatch()
+	if err := idx.commitBlock(batch, bh, buildAddrIndexEntries(txs, nil, transferees)); err != nil {
+		t.Fatalf("commit block error:%v", err)
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("write batch error:%v", err)
+	}
+}
+
+func TestAddressIndex(t *testing.T) {
+	idx, clean := newTestAddressIndex(t)
+	defer clean()
+
+	a := common.BytesToAddress([]byte("a"))
+	b := common.BytesToAddress([]byte("b"))
+	c := common.BytesToAddress([]byte("c"))
+	newTx := func(source, target common.Address, nonce uint64) *types.Transaction {
+		return types.NewTransaction(&types.RawTransaction{Source: &source, Target: &target, Nonce: nonce}, common.BytesToHash(common.Uint64ToByte(nonce)))
+	}
+
+	// Height 0: a -> b, b -> c with an inner transfer to a
+	commitTestAddrIndexBlock(t, idx, &types.BlockHeader{Height: 0, Hash: common.BytesToHash([]byte("0"))},
+		txSlice{newTx(a, b, 1), newTx(b, c, 2)}, [][]common.Address{nil, {a}})
+	// Height 1: c -> b
+	bh1 := &types.BlockHeader{Height: 1, Hash: common.BytesToHash([]byte("1"))}
+	commitTestAddrIndexBlock(t, idx, bh1, txSlice{newTx(c, b, 3)}, nil)
+	// Height 3 is skipped since height 2 not indexed
+	commitTestAddrIndexBlock(t, idx, &types.BlockHeader{Height: 3, Hash: common.BytesToHash([]byte("3"))}, txSlice{newTx(a, b, 4)}, nil)
+	if idx.next() != 2 {
+		t.Fatalf("next height should be 2, got %v", idx.next())
+	}
+
+	txs, cursor := idx.query(a, nil, 10, false)
+	if len(txs) != 2 || cursor != nil {
+		t.Fatalf("address a should have 2 txs, got %v", len(txs))
+	}
+	if txs[0].TxIndex != 0 || txs[1].TxIndex != 1 {
+		t.Fatalf("wrong order of txs")
+	}
+
+	// Page through b in descending order
+	txs, cursor = idx.query(b, nil, 2, true)
+	if len(txs) != 2 || cursor == nil || txs[0].Height != 1 || txs[1].TxIndex != 1 {
+		t.Fatalf("wrong first page of b: %v", len(txs))
+	}
+	txs, cursor = idx.query(b, cursor, 2, true)
+	if len(txs) != 1 || cursor != nil || txs[0].Height != 0 || txs[0].TxIndex != 0 {
+		t.Fatalf("wrong second page of b: %v", len(txs))
+	}
+
+	// Remove height 1
+	batch := idx.db.CreateLDBBatch()
+	if err := idx.removeBlock(batch, bh1); err != nil {
+		t.Fatalf("remove block error:%v", err)
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("write batch error:%v", err)
+	}
+	if idx.next() != 1 {
+		t.Fatalf("next height should be 1 after removing, got %v", idx.next())
+	}
+	txs, _ = idx.query(c, nil, 10, false)
+	if len(txs) != 1 || txs[0].Height != 0 {
+		t.Fatalf("txs of the removed block should be removed")
+	}
+
+	if err := idx.clear(); err != nil {
+		t.Fatalf("clear error:%v", err)
+	}
+	if txs, _ = idx.query(a, nil, 10, false); len(txs) != 0 || idx.next() != 0 {
+		t.Fatalf("index should be empty after clear")
+	}
+}
@@ -0,0 +1,624 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vmihailenco/msgpack"
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+	"github.com/zvchain/zvchain/storage/tasdb"
+)
+
+// The ancient store keeps the blocks far behind the latest checkpoint in append-only flat files
+// and removes them from the hot database. Each table of the store consists of the files:
+//
+//	<table>.idx:              end offset(8) of the item of each height in its data file, unchanged for heights without block
+//	<table>.<segment>.dat:    items of the heights in the segment, the old segments may be removed if expired
+//
+// There are three tables: the marshaled headers, the encoded transactions and the msgpack encoded receipts.
+// The hot database keeps the lookups of the frozen blocks and transactions:
+//
+//	blockPrefix + block hash -> height
+//	txPrefix + tx hash -> height + txIndex
+//	frozenKey -> the next height to freeze, all heights below are frozen
+const (
+	ancientHeaderTable  = "headers"
+	ancientBodyTable    = "bodies"
+	ancientReceiptTable = "receipts"
+
+	ancientBlockPrefix = 'b'
+	ancientTxPrefix    = 't'
+
+	ancientSegmentHeights = 100000 // Number of heights stored in one data file
+	ancientFreezeBatch    = 1000   // Number of heights frozen in one batch
+	ancientFreezeInterval = time.Minute
+)
+
+var ancientFrozenKey = []byte("frozen")
+
+// ancientTable is an append-only table of the items indexed by height
+type ancientTable struct {
+	dir     string
+	name    string
+	segment uint64 // Number of heights stored in one data file
+
+	index    *os.File
+	head     *os.File // Data file of the segment being appended
+	headSeg  uint64
+	headSize uint64
+	items    uint64 // Number of heights stored
+	tail     uint64 // The first segment not expired
+	lock     sync.RWMutex
+}
+
+func openAncientTable(dir, name string, segment uint64) (*ancientTable, error) {
+	index, err := os.OpenFile(filepath.Join(dir, name+".idx"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := index.Stat()
+	if err != nil {
+		index.Close()
+		return nil, err
+	}
+	t := &ancientTable{dir: dir, name: name, segment: segment, index: index, items: uint64(stat.Size()) / 8}
+	// Remove the partially written index entry
+	if err := index.Truncate(int64(t.items * 8)); err != nil {
+		index.Close()
+		return nil, err
+	}
+	segments, err := t.segments()
+	if err != nil {
+		index.Close()
+		return nil, err
+	}
+	if len(segments) > 0 {
+		t.tail = segments[0]
+	} else {
+		t.tail = t.items / segment
+	}
+	if err := t.openHead(); err != nil {
+		index.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *ancientTable) dataFile(segment uint64) string {
+	return filepath.Join(t.dir, fmt.Sprintf("%s.%04d.dat", t.name, segment))
+}
+
+// segments returns the segments of the data files existing in ascending order
+func (t *ancientTable) segments() ([]uint64, error) {
+	files, err := ioutil.ReadDir(t.dir)
+	if err != nil {
+		return nil, err
+	}
+	segments := make([]uint64, 0)
+	for _, f := range files {
+		name := f.Name()
+		if !strings.HasPrefix(name, t.name+".") || !strings.HasSuffix(name, ".dat") {
+			continue
+		}
+		seg, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, t.name+"."), ".dat"), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i] < segments[j]
+	})
+	return segments, nil
+}
+
+// openHead opens the data file of the segment for the next item and truncates the data not indexed
+func (t *ancientTable) openHead() error {
+	if t.head != nil {
+		t.head.Close()
+		t.head = nil
+	}
+	seg := t.items / t.segment
+	size := uint64(0)
+	if t.items%t.segment != 0 {
+		end, err := t.readIndex(t.items - 1)
+		if err != nil {
+			return err
+		}
+		size = end
+	}
+	head, err := os.OpenFile(t.dataFile(seg), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if err := head.Truncate(int64(size)); err != nil {
+		head.Close()
+		return err
+	}
+	t.head, t.headSeg, t.headSize = head, seg, size
+	return nil
+}
+
+func (t *ancientTable) readIndex(item uint64) (uint64, error) {
+	buf := make([]byte, 8)
+	if _, err := t.index.ReadAt(buf, int64(item*8)); err != nil {
+		return 0, err
+	}
+	return common.ByteToUInt64(buf), nil
+}
+
+// append stores the item of the next height, empty data for the height without block
+func (t *ancientTable) append(data []byte) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.items/t.segment != t.headSeg {
+		if err := t.openHead(); err != nil {
+			return err
+		}
+	}
+	if len(data) > 0 {
+		if _, err := t.head.WriteAt(data, int64(t.headSize)); err != nil {
+			return err
+		}
+	}
+	end := t.headSize + uint64(len(data))
+	if _, err := t.index.WriteAt(common.UInt64ToByte(end), int64(t.items*8)); err != nil {
+		return err
+	}
+	t.headSize = end
+	t.items++
+	return nil
+}
+
+// get returns the item of the given height, nil if not stored or expired
+func (t *ancientTable) get(height uint64) ([]byte, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	seg := height / t.segment
+	if height >= t.items || seg < t.tail {
+		return nil, nil
+	}
+	start := uint64(0)
+	if height%t.segment != 0 {
+		var err error
+		if start, err = t.readIndex(height - 1); err != nil {
+			return nil, err
+		}
+	}
+	end, err := t.readIndex(height)
+	if err != nil {
+		return nil, err
+	}
+	if end <= start {
+		return nil, nil
+	}
+	data := make([]byte, end-start)
+	if seg == t.headSeg {
+		_, err = t.head.ReadAt(data, int64(start))
+		return data, err
+	}
+	f, err := os.Open(t.dataFile(seg))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	_, err = f.ReadAt(data, int64(start))
+	return data, err
+}
+
+// truncate removes the items of the heights not lower than the given one
+func (t *ancientTable) truncate(items uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if items >= t.items {
+		return nil
+	}
+	if err := t.index.Truncate(int64(items * 8)); err != nil {
+		return err
+	}
+	t.items = items
+	segments, err := t.segments()
+	if err != nil {
+		return err
+	}
+	if t.head != nil {
+		t.head.Close()
+		t.head = nil
+	}
+	for _, seg := range segments {
+		if seg > items/t.segment {
+			if err := os.Remove(t.dataFile(seg)); err != nil {
+				return err
+			}
+		}
+	}
+	return t.openHead()
+}
+
+// expire removes the data files of the segments lower than the given one, except the one being appended
+func (t *ancientTable) expire(segment uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if segment > t.headSeg {
+		segment = t.headSeg
+	}
+	for ; t.tail < segment; t.tail++ {
+		if err := os.Remove(t.dataFile(t.tail)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *ancientTable) sync() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if err := t.head.Sync(); err != nil {
+		return err
+	}
+	return t.index.Sync()
+}
+
+func (t *ancientTable) close() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.head != nil {
+		t.head.Close()
+	}
+	t.index.Close()
+}
+
+// ancientStore stores the headers, transactions and receipts of the frozen blocks
+type ancientStore struct {
+	headers  *ancientTable
+	bodies   *ancientTable
+	receipts *ancientTable
+	db       *tasdb.PrefixedDatabase // Lookups of the frozen blocks and transactions
+	frozen   uint64                  // The next height to freeze, accessed atomically
+	quit     chan struct{}
+
+	distance   uint64 // Blocks lower than the latest checkpoint by the distance are frozen
+	bodyExpiry uint64 // Bodies lower than the top by the expiry are removed, 0 if kept forever
+}
+
+func newAncientStore(dir string, db *tasdb.PrefixedDatabase, segment uint64) (*ancientStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	as := &ancientStore{db: db, quit: make(chan struct{})}
+	if bs, err := db.Get(ancientFrozenKey); err == nil && len(bs) == 8 {
+		as.frozen = common.ByteToUInt64(bs)
+	}
+
+	tables := make([]*ancientTable, 0, 3)
+	for _, name := range []string{ancientHeaderTable, ancientBodyTable, ancientReceiptTable} {
+		t, err := openAncientTable(dir, name, segment)
+		if err == nil && t.items < as.frozen {
+			err = fmt.Errorf("ancient table %v has %v heights, less than the frozen %v", name, t.items, as.frozen)
+		}
+		// The heights stored but not committed to the hot database are frozen again
+		if err == nil {
+			err = t.truncate(as.frozen)
+		}
+		if err != nil {
+			for _, opened := range tables {
+				opened.close()
+			}
+			return nil, fmt.Errorf("open ancient table %v error:%v", name, err)
+		}
+		tables = append(tables, t)
+	}
+	as.headers, as.bodies, as.receipts = tables[0], tables[1], tables[2]
+	return as, nil
+}
+
+func ancientBlockKey(hash common.Hash) []byte {
+	return append([]byte{ancientBlockPrefix}, hash.Bytes()...)
+}
+
+func ancientTxKey(hash common.Hash) []byte {
+	return append([]byte{ancientTxPrefix}, hash.Bytes()...)
+}
+
+// next returns the next height to freeze
+func (as *ancientStore) next() uint64 {
+	return atomic.LoadUint64(&as.frozen)
+}
+
+func (as *ancientStore) blockHeight(hash common.Hash) (uint64, bool) {
+	bs, err := as.db.Get(ancientBlockKey(hash))
+	if err != nil || len(bs) != 8 {
+		return 0, false
+	}
+	return common.ByteToUInt64(bs), true
+}
+
+func (as *ancientStore) hasBlock(hash common.Hash) bool {
+	ok, _ := as.db.Has(ancientBlockKey(hash))
+	return ok
+}
+
+func (as *ancientStore) hasTx(hash common.Hash) bool {
+	ok, _ := as.db.Has(ancientTxKey(hash))
+	return ok
+}
+
+func (as *ancientStore) headerBytes(hash common.Hash) []byte {
+	height, ok := as.blockHeight(hash)
+	if !ok {
+		return nil
+	}
+	bs, err := as.headers.get(height)
+	if err != nil {
+		Logger.Errorf("get ancient header at %v error:%v", height, err)
+	}
+	return bs
+}
+
+func (as *ancientStore) bodyBytes(hash common.Hash) []byte {
+	height, ok := as.blockHeight(hash)
+	if !ok {
+		return nil
+	}
+	bs, err := as.bodies.get(height)
+	if err != nil {
+		Logger.Errorf("get ancient body at %v error:%v", height, err)
+	}
+	return bs
+}
+
+func (as *ancientStore) receipt(hash common.Hash) *types.Receipt {
+	pos, err := as.db.Get(ancientTxKey(hash))
+	if err != nil || len(pos) != addrIndexPosLength {
+		return nil
+	}
+	height, txIndex := common.ByteToUInt64(pos[:8]), common.ByteToUInt16(pos[8:])
+	bs, err := as.receipts.get(height)
+	if err != nil {
+		Logger.Errorf("get ancient receipts at %v error:%v", height, err)
+		return nil
+	}
+	if len(bs) == 0 {
+		return nil
+	}
+	var receipts []*types.Receipt
+	if err := msgpack.Unmarshal(bs, &receipts); err != nil {
+		Logger.Errorf("decode ancient receipts at %v error:%v", height, err)
+		return nil
+	}
+	if int(txIndex) < len(receipts) {
+		return receipts[txIndex]
+	}
+	return nil
+}
+
+func (as *ancientStore) close() {
+	close(as.quit)
+	as.headers.close()
+	as.bodies.close()
+	as.receipts.close()
+}
+
+// startAncientFreezer moves the blocks far behind the latest checkpoint into the ancient store periodically
+func (chain *FullBlockChain) startAncientFreezer() {
+	quit := chain.ancients.quit
+	go func() {
+		tc := time.NewTicker(ancientFreezeInterval)
+		defer tc.Stop()
+		for {
+			select {
+			case <-tc.C:
+				if err := chain.freezeAncients(); err != nil {
+					Logger.Errorf("freeze ancient blocks error:%v", err)
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
+// freezeAncients freezes the blocks lower than the latest checkpoint by the distance configured,
+// and removes the expired bodies
+func (chain *FullBlockChain) freezeAncients() error {
+	as := chain.ancients
+	cp := chain.LatestCheckPoint()
+	if cp == nil || cp.Height <= as.distance {
+		return nil
+	}
+	if _, err := chain.freezeBlocks(cp.Height - as.distance); err != nil {
+		return err
+	}
+	if top := chain.Height(); as.bodyExpiry > 0 && top > as.bodyExpiry {
+		return chain.expireAncientBodies(top - as.bodyExpiry)
+	}
+	return nil
+}
+
+// freezeBlocks moves the blocks lower than the given height into the ancient store, and returns the
+// count of heights frozen
+func (chain *FullBlockChain) freezeBlocks(limit uint64) (uint64, error) {
+	as := chain.ancients
+	begin := as.next()
+	for from := begin; from < limit; from = as.next() {
+		to := from + ancientFreezeBatch
+		if to > limit {
+			to = limit
+		}
+		if err := chain.freezeRange(from, to); err != nil {
+			return from - begin, err
+		}
+		if atomic.LoadInt32(&chain.shutdowning) == 1 {
+			return to - begin, fmt.Errorf("in shutdown hook")
+		}
+	}
+	if limit > begin {
+		Logger.Infof("ancient blocks frozen from %v to %v", begin, as.next())
+		return as.next() - begin, nil
+	}
+	return 0, nil
+}
+
+// freezeRange appends the blocks of the heights in [from, to) to the ancient store, and removes them from
+// the hot database with the lookups added in one batch
+func (chain *FullBlockChain) freezeRange(from, to uint64) error {
+	// Holds the read lock to avoid the chain changing during freezing
+	chain.rwLock.RLock()
+	defer chain.rwLock.RUnlock()
+
+	as := chain.ancients
+	pool, ok := chain.transactionPool.(*txPool)
+	if !ok {
+		return fmt.Errorf("unexpected transaction pool")
+	}
+	// Remove the items appended by the failed freezing if any
+	for _, t := range []*ancientTable{as.headers, as.bodies, as.receipts} {
+		if err := t.truncate(from); err != nil {
+			return err
+		}
+	}
+	batch := as.db.CreateLDBBatch()
+	for h := from; h < to; h++ {
+		hash := chain.queryBlockHash(h)
+		if hash == nil {
+			for _, t := range []*ancientTable{as.headers, as.bodies, as.receipts} {
+				if err := t.append(nil); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		header, err := chain.blocks.Get(hash.Bytes())
+		if err != nil {
+			return fmt.Errorf("get header at %v error:%v", h, err)
+		}
+		body, err := chain.txDb.Get(hash.Bytes())
+		if err != nil {
+			return fmt.Errorf("get body at %v error:%v", h, err)
+		}
+		txs, err := decodeBlockTransactions(body)
+		if err != nil {
+			return fmt.Errorf("decode body at %v error:%v", h, err)
+		}
+		receipts := make([]*types.Receipt, len(txs))
+		for i, tx := range txs {
+			txHash := tx.GenHash()
+			receipts[i] = pool.loadReceipt(txHash)
+			if err := as.db.AddKv(batch, ancientTxKey(txHash), addrIndexPos(h, uint16(i))); err != nil {
+				return err
+			}
+			if err := pool.receiptDb.AddKv(batch, txHash.Bytes(), nil); err != nil {
+				return err
+			}
+		}
+		receiptBytes, err := msgpack.Marshal(receipts)
+		if err != nil {
+			return err
+		}
+		if err := as.headers.append(header); err != nil {
+			return err
+		}
+		if err := as.bodies.append(body); err != nil {
+			return err
+		}
+		if err := as.receipts.append(receiptBytes); err != nil {
+			return err
+		}
+		if err := as.db.AddKv(batch, ancientBlockKey(*hash), common.UInt64ToByte(h)); err != nil {
+			return err
+		}
+		if err := chain.blocks.AddKv(batch, hash.Bytes(), nil); err != nil {
+			return err
+		}
+		if err := chain.txDb.AddKv(batch, hash.Bytes(), nil); err != nil {
+			return err
+		}
+	}
+	// The items must be persisted before removed from the hot database
+	for _, t := range []*ancientTable{as.headers, as.bodies, as.receipts} {
+		if err := t.sync(); err != nil {
+			return err
+		}
+	}
+	if err := as.db.AddKv(batch, ancientFrozenKey, common.UInt64ToByte(to)); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	atomic.StoreUint64(&as.frozen, to)
+	return nil
+}
+
+// expireAncientBodies removes the transactions and receipts of the frozen blocks lower than the given height,
+// only the whole segments are removed
+func (chain *FullBlockChain) expireAncientBodies(below uint64) error {
+	as := chain.ancients
+	if frozen := as.next(); below > frozen {
+		below = frozen
+	}
+	segment := below / as.bodies.segment
+	for seg := as.bodies.tail; seg < segment && seg < as.bodies.headSeg; seg++ {
+		// Remove the lookups of the transactions in the segment
+		batch := as.db.CreateLDBBatch()
+		for h := seg * as.bodies.segment; h < (seg+1)*as.bodies.segment; h++ {
+			body, err := as.bodies.get(h)
+			if err != nil {
+				return err
+			}
+			if len(body) == 0 {
+				continue
+			}
+			txs, err := decodeBlockTransactions(body)
+			if err != nil {
+				return fmt.Errorf("decode ancient body at %v error:%v", h, err)
+			}
+			for _, tx := range txs {
+				if err := as.db.AddKv(batch, ancientTxKey(tx.GenHash()), nil); err != nil {
+					return err
+				}
+			}
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		if err := as.bodies.expire(seg + 1); err != nil {
+			return err
+		}
+		if err := as.receipts.expire(seg + 1); err != nil {
+			return err
+		}
+		Logger.Infof("ancient bodies expired below %v", (seg+1)*as.bodies.segment)
+	}
+	return nil
+}
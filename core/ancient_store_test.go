@@ -0,0 +1,199 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+)
+
+func TestAncientTable(t *testing.T) {
+	dir := "test_ancient_table"
+	os.RemoveAll(dir)
+	os.MkdirAll(dir, 0755)
+	defer os.RemoveAll(dir)
+
+	table, err := openAncientTable(dir, "items", 4)
+	if err != nil {
+		t.Fatalf("open table error:%v", err)
+	}
+	items := make([][]byte, 10)
+	for i := range items {
+		// Every third height has no block
+		if i%3 != 2 {
+			items[i] = bytes.Repeat([]byte{byte(i)}, i+1)
+		}
+		if err := table.append(items[i]); err != nil {
+			t.Fatalf("append error:%v", err)
+		}
+	}
+	check := func(table *ancientTable, from, to int) {
+		for i := from; i < to; i++ {
+			data, err := table.get(uint64(i))
+			if err != nil {
+				t.Fatalf("get %v error:%v", i, err)
+			}
+			if !bytes.Equal(data, items[i]) {
+				t.Errorf("unexpected item at %v: %x", i, data)
+			}
+		}
+	}
+	check(table, 0, len(items))
+	if data, _ := table.get(uint64(len(items))); data != nil {
+		t.Errorf("expect nil for the height not stored")
+	}
+	table.close()
+
+	// Reopen and truncate
+	if table, err = openAncientTable(dir, "items", 4); err != nil {
+		t.Fatalf("reopen table error:%v", err)
+	}
+	if table.items != 10 {
+		t.Fatalf("expect 10 items after reopen, got %v", table.items)
+	}
+	check(table, 0, len(items))
+	if err := table.truncate(6); err != nil {
+		t.Fatalf("truncate error:%v", err)
+	}
+	if data, _ := table.get(7); data != nil {
+		t.Errorf("expect nil for the height truncated")
+	}
+	if _, err := os.Stat(table.dataFile(2)); !os.IsNotExist(err) {
+		t.Errorf("expect the data file truncated removed")
+	}
+	items = items[:6]
+	if err := table.append([]byte("again")); err != nil {
+		t.Fatalf("append error:%v", err)
+	}
+	items = append(items, []byte("again"))
+	check(table, 0, len(items))
+
+	// Expire the first segment
+	if err := table.expire(1); err != nil {
+		t.Fatalf("expire error:%v", err)
+	}
+	if data, _ := table.get(1); data != nil {
+		t.Errorf("expect nil for the height expired")
+	}
+	check(table, 4, len(items))
+	table.close()
+
+	if table, err = openAncientTable(dir, "items", 4); err != nil {
+		t.Fatalf("reopen table error:%v", err)
+	}
+	defer table.close()
+	if table.tail != 1 || table.items != uint64(len(items)) {
+		t.Errorf("unexpected table after reopen, tail %v, items %v", table.tail, table.items)
+	}
+	check(table, 4, len(items))
+}
+
+func TestBlockChain_AncientStore(t *testing.T) {
+	common.InitConf("../tas_config_all.ini")
+	dir := testOutPut + "/" + t.Name() + "_ancient"
+	common.GlobalConf.SetBool(configSec, "ancient_store", true)
+	common.GlobalConf.SetString(configSec, "ancient_db", dir)
+	defer func() {
+		common.GlobalConf.SetBool(configSec, "ancient_store", false)
+		os.RemoveAll(dir)
+	}()
+
+	err := initContext4Test(t)
+	defer clearSelf(t)
+	if err != nil {
+		t.Fatalf("failed to initContext4Test")
+	}
+	if BlockChainImpl.ancients == nil {
+		t.Fatalf("ancient store not enabled")
+	}
+	// Use the small segments for testing the expiry
+	pool := BlockChainImpl.transactionPool.(*txPool)
+	BlockChainImpl.ancients.close()
+	as, err := newAncientStore(dir, BlockChainImpl.ancients.db, 1)
+	if err != nil {
+		t.Fatalf("new ancient store error:%v", err)
+	}
+	BlockChainImpl.ancients, pool.ancients = as, as
+
+	initBalance()
+	tx := genTestTx(500, "100", 1, 1)
+	if _, err := pool.AddTransaction(tx); err != nil {
+		t.Fatalf("fail to AddTransaction %v", err)
+	}
+	blocks := make([]*types.Block, 0)
+	for h := uint64(1); h <= 3; h++ {
+		block := BlockChainImpl.CastBlock(h, common.Hex2Bytes("12"), 0, nil, common.Hash{})
+		if block == nil {
+			t.Fatalf("fail to cast new block")
+		}
+		if types.AddBlockSucc != BlockChainImpl.AddBlockOnChain(source, block) {
+			t.Fatalf("fail to add block")
+		}
+		blocks = append(blocks, block)
+	}
+	if len(blocks[0].Transactions) != 1 {
+		t.Fatalf("expect the transaction in the first block")
+	}
+
+	frozen, err := BlockChainImpl.freezeBlocks(3)
+	if err != nil || frozen != 3 {
+		t.Fatalf("freeze blocks error:%v, frozen %v", err, frozen)
+	}
+	if ok, _ := BlockChainImpl.blocks.Has(blocks[0].Header.Hash.Bytes()); ok {
+		t.Errorf("expect the frozen header removed from the hot database")
+	}
+	if ok, _ := pool.receiptDb.Has(tx.Hash.Bytes()); ok {
+		t.Errorf("expect the frozen receipt removed from the hot database")
+	}
+	// Query from the store directly since the latest blocks are cached
+	for _, b := range blocks {
+		local := BlockChainImpl.queryBlockByHash(*BlockChainImpl.queryBlockHash(b.Header.Height))
+		if local == nil || local.Header.Hash != b.Header.Hash || len(local.Transactions) != len(b.Transactions) {
+			t.Errorf("unexpected block at %v", b.Header.Height)
+		}
+		if !BlockChainImpl.HasBlock(b.Header.Hash) {
+			t.Errorf("expect block at %v existing", b.Header.Height)
+		}
+	}
+	if got := BlockChainImpl.GetTransactionByHash(false, tx.Hash); got == nil || got.Hash != tx.Hash || got.Nonce != tx.Nonce {
+		t.Errorf("unexpected frozen transaction %+v", got)
+	}
+	if rc := pool.GetReceipt(tx.Hash); rc == nil || rc.Height != 1 || rc.TxHash != tx.Hash {
+		t.Errorf("unexpected frozen receipt %+v", rc)
+	}
+	if exists, _ := pool.IsTransactionExisted(tx.Hash); !exists {
+		t.Errorf("expect the frozen transaction existing")
+	}
+
+	// Bodies of the heights below 2 are removed, the headers are kept
+	if err := BlockChainImpl.expireAncientBodies(2); err != nil {
+		t.Fatalf("expire bodies error:%v", err)
+	}
+	local := BlockChainImpl.queryBlockByHash(blocks[0].Header.Hash)
+	if local == nil || local.Header.Hash != blocks[0].Header.Hash || len(local.Transactions) != 0 {
+		t.Errorf("expect the header kept and the body removed at 1")
+	}
+	if BlockChainImpl.GetTransactionByHash(false, tx.Hash) != nil || pool.GetReceipt(tx.Hash) != nil {
+		t.Errorf("expect the expired transaction removed")
+	}
+	if local := BlockChainImpl.queryBlockByHash(blocks[1].Header.Hash); local == nil || local.Header.Hash != blocks[1].Header.Hash {
+		t.Errorf("unexpected block at 2 after expiry")
+	}
+}
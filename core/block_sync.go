@@ -711,7 +711,10 @@ func unMarshalBlockMsgResponse(b []byte) (*blockResponseMessage, error) {
 	}
 	blocks := make([]*types.Block, 0)
 	for _, pb := range message.Blocks {
-		b := types.PbToBlock(pb)
+		b, e := types.PbToBlock(pb)
+		if e != nil {
+			return nil, e
+		}
 		blocks = append(blocks, b)
 	}
 	bmr := blockResponseMessage{Blocks: blocks}
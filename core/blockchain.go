@@ -20,13 +20,10 @@ import (
 	"fmt"
 	"github.com/zvchain/zvchain/common/prque"
 	"github.com/zvchain/zvchain/storage/trie"
-	"os"
 	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
-	"github.com/syndtr/goleveldb/leveldb/filter"
-	"github.com/syndtr/goleveldb/leveldb/opt"
 	"github.com/zvchain/zvchain/core/group"
 	"github.com/zvchain/zvchain/log"
 
@@ -68,6 +65,29 @@ type BlockChainConfig struct {
 	reward      string
 	tx          string
 	receipt     string
+	bloom       string
+	addrIndex   string
+	stateDiff   string
+	ancient     string
+	flatState   string
+	// Whether indexing the transactions by address
+	addrIndexEnabled bool
+	// Number of the latest blocks whose state diffs are persisted, 0 if not persisted
+	stateDiffBlocks uint64
+	// Whether moving the blocks far behind the latest checkpoint into the ancient store
+	ancientEnabled bool
+	// Directory of the ancient store files
+	ancientDir string
+	// Blocks lower than the latest checkpoint by the distance are moved into the ancient store
+	ancientDistance uint64
+	// Transactions and receipts of the blocks lower than the top by the expiry are removed in pruning mode, 0 if kept
+	ancientBodyExpiry uint64
+	// Whether keeping the flat state for reading the accounts and storage
+	flatStateEnabled bool
+	// Whether verifying the flat state against the state trie periodically
+	flatStateVerify bool
+	// Interval of verifying the flat state
+	flatStateVerifyInterval time.Duration
 	// Whether running node in pruning mode
 	pruneMode bool
 	// pruning mode config
@@ -89,6 +109,12 @@ type FullBlockChain struct {
 	blockHeight     *tasdb.PrefixedDatabase
 	txDb            *tasdb.PrefixedDatabase
 	stateDb         *tasdb.PrefixedDatabase
+	bloomDb         *tasdb.PrefixedDatabase
+	addrIndex       *addressIndex     // Nil if the address index disabled
+	stateDiffs      *stateDiffStore   // Nil if the state diffs not persisted
+	ancients        *ancientStore     // Nil if the ancient store disabled
+	flats           *account.FlatTree // Nil if the flat state disabled
+	flatQuit        chan struct{}     // Closed to stop the routine checking the flat state
 	smallStateDb    *smallStateStore
 	cacheDb         *tasdb.PrefixedDatabase
 	batch           tasdb.Batch
@@ -96,6 +122,7 @@ type FullBlockChain struct {
 	stateCache      account.AccountDatabase
 	shutdowning     int32 // shutdowning must be called atomically
 	transactionPool types.TransactionPool
+	gasOracle       *gasPriceOracle
 
 	latestBlock   *types.BlockHeader // Latest block on chain
 	latestStateDB *account.AccountDB
@@ -185,8 +212,25 @@ func getBlockChainConfig() *BlockChainConfig {
 		reward:      "nu",
 		tx:          "tx",
 		receipt:     "rc",
+		bloom:       "bm",
+		addrIndex:   "ai",
+		stateDiff:   "sd",
+		ancient:     "an",
+		flatState:   "fs",
 		pruneConfig: getPruneConfig(pruneMode),
 		pruneMode:   pruneMode,
+
+		addrIndexEnabled: common.GlobalConf.GetBool(configSec, "address_index", false),
+		stateDiffBlocks:  uint64(common.GlobalConf.GetInt(configSec, "state_diff_blocks", 0)),
+
+		ancientEnabled:    common.GlobalConf.GetBool(configSec, "ancient_store", false),
+		ancientDir:        common.GlobalConf.GetString(configSec, "ancient_db", "d_ancient"),
+		ancientDistance:   uint64(common.GlobalConf.GetInt(configSec, "ancient_distance", 100000)),
+		ancientBodyExpiry: uint64(common.GlobalConf.GetInt(configSec, "ancient_body_expiry", 0)),
+
+		flatStateEnabled:        common.GlobalConf.GetBool(configSec, "flat_state", false),
+		flatStateVerify:         common.GlobalConf.GetBool(configSec, "flat_state_verify", true),
+		flatStateVerifyInterval: time.Duration(common.GlobalConf.GetInt(configSec, "flat_state_verify_interval", 21600)) * time.Second,
 	}
 }
 
@@ -222,11 +266,11 @@ func initBlockChain(helper types.ConsensusHelper, minerAccount types.Account) er
 	writeBufferSize := common.GlobalConf.GetInt(configSec, "db_write_cache", getDefaultBigDbWriteCache(chain.config.pruneMode))
 	stateCacheSize := common.GlobalConf.GetInt(configSec, "db_state_cache", 256)
 
-	options := &opt.Options{
+	options := &tasdb.Options{
 		OpenFilesCacheCapacity: fileCacheSize,
-		BlockCacheCapacity:     blockCacheSize * opt.MiB,
-		WriteBuffer:            writeBufferSize * opt.MiB, // Two of these are used internally
-		Filter:                 filter.NewBloomFilter(10),
+		BlockCacheCapacity:     blockCacheSize * tasdb.MiB,
+		WriteBuffer:            writeBufferSize * tasdb.MiB, // Two of these are used internally
+		BloomFilterBits:        10,
 	}
 
 	ds, err := tasdb.NewDataSource(chain.config.dbfile, options)
@@ -256,6 +300,55 @@ func initBlockChain(helper types.ConsensusHelper, minerAccount types.Account) er
 		Logger.Errorf("Init block chain error! Error:%s", err.Error())
 		return err
 	}
+	chain.bloomDb, err = ds.NewPrefixDatabase(chain.config.bloom)
+	if err != nil {
+		Logger.Errorf("Init block chain error! Error:%s", err.Error())
+		return err
+	}
+	if chain.config.addrIndexEnabled {
+		addrIndexDb, err := ds.NewPrefixDatabase(chain.config.addrIndex)
+		if err != nil {
+			Logger.Errorf("Init block chain error! Error:%s", err.Error())
+			return err
+		}
+		chain.addrIndex = newAddressIndex(addrIndexDb)
+	}
+	if chain.config.stateDiffBlocks > 0 {
+		stateDiffDb, err := ds.NewPrefixDatabase(chain.config.stateDiff)
+		if err != nil {
+			Logger.Errorf("Init block chain error! Error:%s", err.Error())
+			return err
+		}
+		chain.stateDiffs = newStateDiffStore(stateDiffDb, chain.config.stateDiffBlocks)
+	}
+
+	if chain.config.ancientEnabled {
+		ancientDb, err := ds.NewPrefixDatabase(chain.config.ancient)
+		if err != nil {
+			Logger.Errorf("Init block chain error! Error:%s", err.Error())
+			return err
+		}
+		chain.ancients, err = newAncientStore(chain.config.ancientDir, ancientDb, ancientSegmentHeights)
+		if err != nil {
+			Logger.Errorf("Init ancient store error:%v", err)
+			return err
+		}
+		chain.ancients.distance = chain.config.ancientDistance
+		// Archive nodes keep all the bodies
+		if chain.config.pruneMode {
+			chain.ancients.bodyExpiry = chain.config.ancientBodyExpiry
+		}
+	}
+
+	if chain.config.flatStateEnabled {
+		flatDb, err := ds.NewPrefixDatabase(chain.config.flatState)
+		if err != nil {
+			Logger.Errorf("Init block chain error! Error:%s", err.Error())
+			return err
+		}
+		chain.flats = account.NewFlatTree(flatDb)
+		chain.flatQuit = make(chan struct{})
+	}
 
 	receiptdb, err := ds.NewPrefixDatabase(chain.config.receipt)
 	if err != nil {
@@ -263,12 +356,12 @@ func initBlockChain(helper types.ConsensusHelper, minerAccount types.Account) er
 		return err
 	}
 
-	var sdbOptions *opt.Options
+	var sdbOptions *tasdb.Options
 	if chain.config.pruneMode {
 		writeBufferSize := common.GlobalConf.GetInt(prune, "sdb_write_cache", 64)
-		sdbOptions = &opt.Options{
-			WriteBuffer: writeBufferSize * opt.MiB, // Two of these are used internally
-			BlockSize:   512 * opt.KiB,             // The maximum value is close to 512k
+		sdbOptions = &tasdb.Options{
+			WriteBuffer: writeBufferSize * tasdb.MiB, // Two of these are used internally
+			BlockSize:   512 * tasdb.KiB,             // The maximum value is close to 512k
 		}
 	}
 
@@ -282,10 +375,19 @@ func initBlockChain(helper types.ConsensusHelper, minerAccount types.Account) er
 		Logger.Errorf("new small state db error:%v", err)
 		return err
 	}
+	if err := chain.versionValidate(); err != nil {
+		Logger.Errorf("validate data version error:%v", err)
+		return err
+	}
+	if err := chain.migrateOnStartup(receiptdb, smallStateDb); err != nil {
+		Logger.Errorf("migrate database error:%v", err)
+		return err
+	}
 	chain.smallStateDb = initSmallStore(smallStateDb)
 	chain.rewardManager = NewRewardManager()
 	chain.batch = chain.blocks.CreateLDBBatch()
 	chain.transactionPool = newTransactionPool(chain, receiptdb)
+	chain.gasOracle = newGasPriceOracle(chain)
 
 	chain.txBatch = newTxBatchAdder(chain.transactionPool)
 
@@ -309,11 +411,7 @@ func initBlockChain(helper types.ConsensusHelper, minerAccount types.Account) er
 	}
 	latestBH = chain.latestBlock
 	if nil != latestBH {
-		if !chain.versionValidate() {
-			fmt.Println("Illegal data version! Please delete the directory d0 and restart the program!")
-			os.Exit(0)
-		}
-		state, err := account.NewAccountDB(common.BytesToHash(latestBH.StateTree.Bytes()), chain.stateCache)
+		state, err := chain.newAccountDB(common.BytesToHash(latestBH.StateTree.Bytes()))
 		if nil == err {
 			chain.updateLatestBlock(state, latestBH)
 			chain.buildCache(10)
@@ -329,6 +427,7 @@ func initBlockChain(helper types.ConsensusHelper, minerAccount types.Account) er
 	}
 
 	chain.forkProcessor = initForkProcessor(chain, helper)
+	chain.registerMetrics()
 
 	BlockChainImpl = chain
 
@@ -358,6 +457,24 @@ func initBlockChain(helper types.ConsensusHelper, minerAccount types.Account) er
 
 	initStakeGetter(MinerManagerImpl, chain)
 
+	if chain.addrIndex != nil {
+		// Index the blocks committed while the index disabled, or rebuild the whole index if required
+		if err = chain.startAddressIndexRebuild(common.GlobalConf.GetBool(configSec, "address_index_rebuild", false)); err != nil {
+			Logger.Errorf("rebuild address index error:%v", err)
+			return err
+		}
+	}
+
+	if pool, ok := chain.transactionPool.(*txPool); ok {
+		pool.startJournal()
+	}
+	if chain.ancients != nil {
+		chain.startAncientFreezer()
+	}
+	if chain.flats != nil {
+		chain.startFlatState()
+	}
+
 	chain.LogDbStats()
 	return nil
 }
@@ -454,16 +571,22 @@ func (chain *FullBlockChain) Clear() error {
 	return nil
 }
 
-func (chain *FullBlockChain) versionValidate() bool {
+// versionValidate checks the data version recorded in the genesis. The data of the older versions is
+// brought up to date by the migrations run after, and the newer one can't be read by the program.
+// The chain without genesis, either empty or imported from a state snapshot, has nothing to check
+func (chain *FullBlockChain) versionValidate() error {
 	genesisHeader := chain.queryBlockHeaderByHeight(uint64(0))
 	if genesisHeader == nil {
-		return false
+		return nil
 	}
 	version := genesisHeader.Nonce
-	if version != common.ChainDataVersion {
-		return false
+	if version > common.ChainDataVersion {
+		return fmt.Errorf("data version %v is newer than %v supported, please upgrade the program", version, common.ChainDataVersion)
+	}
+	if version < common.ChainDataVersion {
+		Logger.Infof("data version %v is older than %v, migrating the database", version, common.ChainDataVersion)
 	}
-	return true
+	return nil
 }
 
 func (chain *FullBlockChain) compareChainWeight(bh2 *types.BlockHeader) int {
@@ -484,6 +607,12 @@ func (chain *FullBlockChain) Close() {
 		chain.stateCache.TrieDB().SaveCache()
 	}
 	chain.PersistentState()
+	if chain.flats != nil {
+		chain.closeFlatState()
+	}
+	if pool, ok := chain.transactionPool.(*txPool); ok {
+		pool.close()
+	}
 	if chain.blocks != nil {
 		chain.blocks.Close()
 	}
@@ -500,6 +629,9 @@ func (chain *FullBlockChain) Close() {
 	if chain.smallStateDb != nil {
 		chain.smallStateDb.Close()
 	}
+	if chain.ancients != nil {
+		chain.ancients.close()
+	}
 }
 
 // GetRewardManager returns the reward manager
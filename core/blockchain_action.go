@@ -37,11 +37,12 @@ import (
 type batchAddBlockCallback func(b *types.Block, ret types.AddBlockResult) bool
 
 type executePostState struct {
-	state      *account.AccountDB
-	receipts   types.Receipts
-	evictedTxs []common.Hash
-	txs        txSlice
-	ts         *common.TimeStatCtx
+	state       *account.AccountDB
+	receipts    types.Receipts
+	evictedTxs  []common.Hash
+	txs         txSlice
+	transferees [][]common.Address // Recipients of the transfers inside the contracts, indexed by tx
+	ts          *common.TimeStatCtx
 }
 
 // CastBlock cast a block, current casters synchronization operation in the group
@@ -86,7 +87,7 @@ func (chain *FullBlockChain) CastBlock(height uint64, proveValue []byte, qn uint
 
 	preRoot := common.BytesToHash(latestBlock.StateTree.Bytes())
 
-	state, err := account.NewAccountDB(preRoot, chain.stateCache)
+	state, err := chain.newAccountDB(preRoot)
 	if err != nil {
 		var buffer bytes.Buffer
 		buffer.WriteString("fail to new stateDb, lateset height: ")
@@ -98,6 +99,9 @@ func (chain *FullBlockChain) CastBlock(height uint64, proveValue []byte, qn uint
 		Logger.Error(buffer.String())
 		return nil
 	}
+	if chain.recordDiff() {
+		state.RecordDiff()
+	}
 
 	Logger.Debugf("casting block height=%v,preHash=%v", height, preRoot)
 	//taslog.Flush()
@@ -112,7 +116,7 @@ func (chain *FullBlockChain) CastBlock(height uint64, proveValue []byte, qn uint
 	exeTraceLog.SetParent("CastBlock")
 	defer exeTraceLog.Log("pack=true")
 	block.Header.CurTime = chain.ts.Now()
-	stateRoot, evictHashs, txSlice, receipts, gasFee, err := chain.stateProc.process(state, block.Header, txs, true, nil)
+	stateRoot, evictHashs, txSlice, receipts, transferees, gasFee, err := chain.stateProc.process(state, block.Header, txs, true, nil)
 	exeTraceLog.SetEnd()
 
 	block.Transactions = txSlice.txsToRaw()
@@ -141,10 +145,11 @@ func (chain *FullBlockChain) CastBlock(height uint64, proveValue []byte, qn uint
 
 	// Blocks that you cast yourself do not need to be verified
 	chain.verifiedBlocks.Add(block.Header.Hash, &executePostState{
-		state:      state,
-		receipts:   receipts,
-		evictedTxs: evictHashs,
-		txs:        txSlice,
+		state:       state,
+		receipts:    receipts,
+		evictedTxs:  evictHashs,
+		txs:         txSlice,
+		transferees: transferees,
 	})
 	return block
 }
@@ -174,6 +179,7 @@ func (chain *FullBlockChain) verifyTxs(block *types.Block) (txs txSlice, ok bool
 func (chain *FullBlockChain) AddBlockOnChain(source string, b *types.Block) types.AddBlockResult {
 	begin := time.Now()
 	ret, _ := chain.addBlockOnChain(source, b)
+	observeAddBlock(ret, begin)
 	if ret == types.AddBlockSucc {
 		log.ELKLogger.WithFields(logrus.Fields{
 			"blockHash":      b.Header.Hash.Hex(),
@@ -579,13 +585,16 @@ func (chain *FullBlockChain) executeTransaction(block *types.Block, slice txSlic
 
 	preRoot := preBlock.StateTree
 
-	state, err := account.NewAccountDB(preRoot, chain.stateCache)
+	state, err := chain.newAccountDB(preRoot)
 	if err != nil {
 		Logger.Errorf("Fail to new stateDb, error:%s", err)
 		return false, nil
 	}
+	if chain.recordDiff() {
+		state.RecordDiff()
+	}
 
-	stateTree, evictTxs, executedSlice, receipts, gasFee, err := chain.stateProc.process(state, block.Header, slice, false, nil)
+	stateTree, evictTxs, executedSlice, receipts, transferees, gasFee, err := chain.stateProc.process(state, block.Header, slice, false, nil)
 	txTree := executedSlice.calcTxTree()
 	if txTree != block.Header.TxTree {
 		Logger.Errorf("Fail to verify txTree, hash1:%s hash2:%s", txTree, block.Header.TxTree)
@@ -612,7 +621,7 @@ func (chain *FullBlockChain) executeTransaction(block *types.Block, slice txSlic
 	Logger.Infof("executeTransactions block height=%v,preHash=%v", block.Header.Height, preRoot)
 	//taslog.Flush()
 
-	eps := &executePostState{state: state, receipts: receipts, evictedTxs: evictTxs, txs: executedSlice}
+	eps := &executePostState{state: state, receipts: receipts, evictedTxs: evictTxs, txs: executedSlice, transferees: transferees}
 	chain.verifiedBlocks.Add(block.Header.Hash, eps)
 	return true, eps
 }
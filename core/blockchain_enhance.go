@@ -17,8 +17,6 @@ package core
 
 import (
 	"fmt"
-	"github.com/syndtr/goleveldb/leveldb/filter"
-	"github.com/syndtr/goleveldb/leveldb/opt"
 	"github.com/zvchain/zvchain/common"
 	"github.com/zvchain/zvchain/middleware/types"
 	"github.com/zvchain/zvchain/storage/account"
@@ -77,9 +75,9 @@ func NewLocalBlockProvider(dir string) (BlockProvider, error) {
 		topRawBlocks:    common.MustNewLRUCache(20),
 	}
 
-	options := &opt.Options{
-		BlockCacheCapacity: 64 * opt.MiB,
-		Filter:             filter.NewBloomFilter(10),
+	options := &tasdb.Options{
+		BlockCacheCapacity: 64 * tasdb.MiB,
+		BloomFilterBits:    10,
 		ReadOnly:           true,
 	}
 
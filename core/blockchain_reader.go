@@ -19,7 +19,6 @@ import (
 	"fmt"
 	"github.com/zvchain/zvchain/common"
 	"github.com/zvchain/zvchain/middleware/types"
-	"github.com/zvchain/zvchain/storage/account"
 	"math"
 	"math/big"
 )
@@ -72,7 +71,7 @@ func (chain *FullBlockChain) LatestAccountDB() (types.AccountDB, error) {
 	defer chain.rwLock.RUnlock()
 	lastBlockHeader := chain.QueryTopBlock()
 	preRoot := common.BytesToHash(lastBlockHeader.StateTree.Bytes())
-	state, err := account.NewAccountDB(preRoot, chain.stateCache)
+	state, err := chain.newAccountDB(preRoot)
 	return state, err
 }
 
@@ -245,7 +244,7 @@ func (chain *FullBlockChain) getAccountDBByHash(hash common.Hash) (types.Account
 			return nil, fmt.Errorf("no data of hash %v", hash)
 		}
 	}
-	return account.NewAccountDB(header.StateTree, chain.stateCache)
+	return chain.newAccountDB(header.StateTree)
 }
 
 // GetAccountDBByHash returns account database with specified block hash
@@ -264,7 +263,7 @@ func (chain *FullBlockChain) accountDBAt(height uint64) (types.AccountDB, error)
 			return nil, fmt.Errorf("no data at height %v", height)
 		}
 	}
-	return account.NewAccountDB(header.StateTree, chain.stateCache)
+	return chain.newAccountDB(header.StateTree)
 }
 
 // AccountDBAt returns account database with specified block height
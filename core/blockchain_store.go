@@ -218,6 +218,10 @@ func (chain *FullBlockChain) saveBlockTxs(blockHash common.Hash, dataBytes []byt
 	return chain.txDb.AddKv(chain.batch, blockHash.Bytes(), dataBytes)
 }
 
+func (chain *FullBlockChain) saveBlockBloom(blockHash common.Hash, dataBytes []byte) error {
+	return chain.bloomDb.AddKv(chain.batch, blockHash.Bytes(), dataBytes)
+}
+
 // commitBlock persist a block in a batch
 func (chain *FullBlockChain) commitBlock(block *types.Block, ps *executePostState) (ok bool, err error) {
 	traceLog := monitor.NewPerformTraceLogger("commitBlock", block.Header.Hash, block.Header.Height)
@@ -274,6 +278,34 @@ func (chain *FullBlockChain) commitBlock(block *types.Block, ps *executePostStat
 	if err = chain.transactionPool.SaveReceipts(bh.Hash, ps.receipts); err != nil {
 		return
 	}
+	// Save hash to the bloom of all logs in the block
+	bloom := types.CreateBloom(ps.receipts)
+	if err = chain.saveBlockBloom(bh.Hash, bloom.Bytes()); err != nil {
+		return
+	}
+	// Index the transactions by the addresses involved
+	if chain.addrIndex != nil {
+		txs := ps.txs
+		if txs == nil && len(block.Transactions) > 0 {
+			txs = make(txSlice, 0, len(block.Transactions))
+			for _, raw := range block.Transactions {
+				txs = append(txs, types.NewTransaction(raw, raw.GenHash()))
+			}
+		}
+		entries := buildAddrIndexEntries(txs, ps.receipts, ps.transferees)
+		if err = chain.addrIndex.commitBlock(chain.batch, bh, entries); err != nil {
+			return
+		}
+	}
+	// Save the accounts changed by the block if recorded when executing
+	diff := ps.state.Diff()
+	if chain.stateDiffs != nil {
+		if diff != nil {
+			if err = chain.stateDiffs.commitBlock(chain.batch, bh, diff); err != nil {
+				return
+			}
+		}
+	}
 	// Save current block
 	if err = chain.saveCurrentBlock(bh.Hash); err != nil {
 		return
@@ -285,6 +317,9 @@ func (chain *FullBlockChain) commitBlock(block *types.Block, ps *executePostStat
 	//ps.ts.AddStat("batch.Write", time.Since(b))
 
 	chain.updateLatestBlock(ps.state, bh)
+	if chain.flats != nil {
+		chain.commitFlatState(bh, diff)
+	}
 
 	rmTxLog := monitor.NewPerformTraceLogger("RemoveFromPool", block.Header.Hash, block.Header.Height)
 	rmTxLog.SetParent("commitBlock")
@@ -357,6 +392,21 @@ func (chain *FullBlockChain) resetTop(block *types.BlockHeader) error {
 		if err = chain.saveBlockTxs(curr.Hash, nil); err != nil {
 			return err
 		}
+		// Delete the old block's bloom
+		if err = chain.saveBlockBloom(curr.Hash, nil); err != nil {
+			return err
+		}
+		// Delete the old block's address index
+		if chain.addrIndex != nil {
+			if err = chain.addrIndex.removeBlock(chain.batch, curr); err != nil {
+				return err
+			}
+		}
+		if chain.stateDiffs != nil {
+			if err = chain.stateDiffs.removeBlock(chain.batch, curr); err != nil {
+				return err
+			}
+		}
 		rawTxs := chain.queryBlockTransactionsAll(curr.Hash)
 		for _, rawTx := range rawTxs {
 			tHash := rawTx.GenHash()
@@ -380,7 +430,7 @@ func (chain *FullBlockChain) resetTop(block *types.BlockHeader) error {
 	if err = chain.saveCurrentBlock(block.Hash); err != nil {
 		return err
 	}
-	state, err := account.NewAccountDB(block.StateTree, chain.stateCache)
+	state, err := chain.newAccountDB(block.StateTree)
 	if err != nil {
 		return err
 	}
@@ -444,6 +494,19 @@ func (chain *FullBlockChain) removeOrphan(block *types.Block) error {
 	if err = chain.saveBlockTxs(hash, nil); err != nil {
 		return err
 	}
+	if err = chain.saveBlockBloom(hash, nil); err != nil {
+		return err
+	}
+	if chain.addrIndex != nil {
+		if err = chain.addrIndex.removeBlock(chain.batch, block.Header); err != nil {
+			return err
+		}
+	}
+	if chain.stateDiffs != nil {
+		if err = chain.stateDiffs.removeBlock(chain.batch, block.Header); err != nil {
+			return err
+		}
+	}
 	txs := chain.queryBlockTransactionsAll(hash)
 	if txs != nil {
 		txHashs := make([]common.Hash, len(txs))
@@ -475,6 +538,9 @@ func (chain *FullBlockChain) hasBlock(hash common.Hash) bool {
 	if ok, _ := chain.blocks.Has(hash.Bytes()); ok {
 		return ok
 	}
+	if chain.ancients != nil {
+		return chain.ancients.hasBlock(hash)
+	}
 	return false
 	//pre := gchain.queryBlockHeaderByHash(bh.PreHash)
 	//return pre != nil
@@ -543,12 +609,25 @@ func (chain *FullBlockChain) queryBlockHeaderByHeightFloor(height uint64) *types
 func (chain *FullBlockChain) queryBlockBodyBytes(hash common.Hash) []byte {
 	bs, err := chain.txDb.Get(hash.Bytes())
 	if err != nil {
+		// The body of the frozen block is in the ancient store, or removed if expired
+		if chain.ancients != nil && chain.ancients.hasBlock(hash) {
+			return chain.ancients.bodyBytes(hash)
+		}
 		Logger.Errorf("get txDb err:%v, key:%v", err.Error(), hash.Hex())
 		return nil
 	}
 	return bs
 }
 
+// queryBlockBloom returns the logs bloom of the given block, and false if the bloom not stored
+func (chain *FullBlockChain) queryBlockBloom(hash common.Hash) (types.Bloom, bool) {
+	bs, err := chain.bloomDb.Get(hash.Bytes())
+	if err != nil || len(bs) != types.BloomByteLength {
+		return types.Bloom{}, false
+	}
+	return types.BytesToBloom(bs), true
+}
+
 func (chain *FullBlockChain) queryBlockTransactionsAll(hash common.Hash) []*types.RawTransaction {
 	bs := chain.queryBlockBodyBytes(hash)
 	if bs == nil {
@@ -657,6 +736,9 @@ func (chain *FullBlockChain) queryBlockByHash(hash common.Hash) *types.Block {
 
 func (chain *FullBlockChain) queryBlockHeaderBytes(hash common.Hash) []byte {
 	result, _ := chain.blocks.Get(hash.Bytes())
+	if result == nil && chain.ancients != nil {
+		return chain.ancients.headerBytes(hash)
+	}
 	return result
 }
 
@@ -706,12 +788,11 @@ func (chain *FullBlockChain) queryBlockTransactionsOptional(txIdx int, height ui
 	if bh == nil {
 		return nil
 	}
-	bs, err := chain.txDb.Get(bh.Hash.Bytes())
-	if err != nil {
-		Logger.Errorf("queryBlockTransactionsOptional get txDb err:%v, key:%v", err.Error(), bh.Hash.Hex())
+	bs := chain.queryBlockBodyBytes(bh.Hash)
+	if bs == nil {
 		return nil
 	}
-	tx, err := decodeTransaction(txIdx, bs)
+	tx, _ := decodeTransaction(txIdx, bs)
 	if tx != nil {
 		return tx
 	}
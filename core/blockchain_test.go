@@ -18,8 +18,6 @@ package core
 import (
 	"fmt"
 	"github.com/sirupsen/logrus"
-	"github.com/syndtr/goleveldb/leveldb/filter"
-	"github.com/syndtr/goleveldb/leveldb/opt"
 	"github.com/zvchain/zvchain/consensus/base"
 	"github.com/zvchain/zvchain/middleware/ticker"
 	"github.com/zvchain/zvchain/params"
@@ -868,11 +866,11 @@ func newBlockChainByDB(db string, readonly bool) (*FullBlockChain, error) {
 		newBlockMessages: common.MustNewLRUCache(100),
 	}
 
-	options := &opt.Options{
+	options := &tasdb.Options{
 		OpenFilesCacheCapacity: 5000,
-		BlockCacheCapacity:     128 * opt.MiB,
-		WriteBuffer:            16 * opt.MiB, // Two of these are used internally
-		Filter:                 filter.NewBloomFilter(10),
+		BlockCacheCapacity:     128 * tasdb.MiB,
+		WriteBuffer:            16 * tasdb.MiB, // Two of these are used internally
+		BloomFilterBits:        10,
 		ReadOnly:               readonly,
 	}
 
@@ -0,0 +1,230 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+)
+
+// The chain archive stores the blocks in a height range independent of the database layout.
+// It starts with the magic, the version and the height range, followed by the block entries
+// and ends with the end entry:
+//
+//	block entry: 0x01 | header length(4) | marshaled header | body length(4) | encoded transactions | crc32(4)
+//	end entry:   0x00 | count of blocks(8)
+//
+// The crc32 covers the header and body of the entry, and all integers are big-endian
+const (
+	archiveMagic   = "ZVBA"
+	archiveVersion = uint16(1)
+
+	archiveEntryEnd   = byte(0)
+	archiveEntryBlock = byte(1)
+
+	// archiveImportBatch is the count of blocks added to the chain in one batch when importing
+	archiveImportBatch = 100
+)
+
+// ArchiveHeader is the head of the chain archive
+type ArchiveHeader struct {
+	Version uint16
+	From    uint64 // The lowest height of the range
+	To      uint64 // The highest height of the range
+}
+
+// ExportChain writes the blocks on the chain between the given heights inclusively to the writer
+// in the archive format, and returns the count of the blocks written
+func (chain *FullBlockChain) ExportChain(w io.Writer, from, to uint64) (uint64, error) {
+	if from > to {
+		return 0, fmt.Errorf("from %v higher than to %v", from, to)
+	}
+	if top := chain.Height(); to > top {
+		return 0, fmt.Errorf("to %v higher than the top %v", to, top)
+	}
+	bw := bufio.NewWriter(w)
+	head := bytes.NewBuffer(nil)
+	head.WriteString(archiveMagic)
+	head.Write(common.UInt16ToByte(archiveVersion))
+	head.Write(common.UInt64ToByte(from))
+	head.Write(common.UInt64ToByte(to))
+	if _, err := bw.Write(head.Bytes()); err != nil {
+		return 0, err
+	}
+
+	count := uint64(0)
+	for h := from; h <= to; h++ {
+		// Heights without block are skipped
+		b := chain.QueryBlockByHeight(h)
+		if b != nil {
+			if err := writeArchiveBlock(bw, b); err != nil {
+				return count, fmt.Errorf("write block at %v error:%v", h, err)
+			}
+			count++
+		}
+		if h == to {
+			break
+		}
+	}
+	if err := bw.WriteByte(archiveEntryEnd); err != nil {
+		return count, err
+	}
+	if _, err := bw.Write(common.UInt64ToByte(count)); err != nil {
+		return count, err
+	}
+	return count, bw.Flush()
+}
+
+func writeArchiveBlock(w io.Writer, b *types.Block) error {
+	if _, err := w.Write([]byte{archiveEntryBlock}); err != nil {
+		return err
+	}
+	return writeBlockEntry(w, b)
+}
+
+// ImportChain adds the blocks in the chain archive onto the chain with full validation, the blocks already on
+// the chain are skipped. It returns the count of blocks added, and the progress is called after each batch added
+func (chain *FullBlockChain) ImportChain(r io.Reader, progress func(imported uint64)) (uint64, error) {
+	reader, err := newChainArchiveReader(r)
+	if err != nil {
+		return 0, err
+	}
+	imported := uint64(0)
+	blocks := make([]*types.Block, 0, archiveImportBatch)
+	for {
+		b, err := reader.next()
+		if err != nil && err != io.EOF {
+			return imported, err
+		}
+		if b != nil {
+			blocks = append(blocks, b)
+		}
+		if len(blocks) == archiveImportBatch || (err == io.EOF && len(blocks) > 0) {
+			added, addErr := chain.importBlocks(blocks)
+			imported += added
+			if addErr != nil {
+				return imported, addErr
+			}
+			if progress != nil {
+				progress(imported)
+			}
+			blocks = blocks[:0]
+		}
+		if err == io.EOF {
+			return imported, nil
+		}
+	}
+}
+
+// importBlocks adds the chained blocks onto the top of the chain, and returns the count of blocks added
+func (chain *FullBlockChain) importBlocks(blocks []*types.Block) (uint64, error) {
+	for len(blocks) > 0 && chain.HasBlock(blocks[0].Header.Hash) {
+		blocks = blocks[1:]
+	}
+	if len(blocks) == 0 {
+		return 0, nil
+	}
+	// Archive blocks never fork the local chain
+	first := blocks[0].Header
+	if top := chain.QueryTopBlock(); first.PreHash != top.Hash {
+		return 0, fmt.Errorf("block %v at %v not linked to the local top %v at %v", first.Hash, first.Height, top.Hash, top.Height)
+	}
+
+	added := uint64(0)
+	var addErr error
+	err := chain.batchAddBlockOnChain("archive", false, blocks, func(b *types.Block, ret types.AddBlockResult) bool {
+		if ret == types.AddBlockSucc {
+			added++
+			return true
+		}
+		if ret == types.AddBlockExisted {
+			return true
+		}
+		addErr = fmt.Errorf("add block %v at %v fail, result %v", b.Header.Hash, b.Header.Height, ret)
+		return false
+	})
+	if err != nil {
+		return added, err
+	}
+	return added, addErr
+}
+
+// chainArchiveReader reads the blocks from the chain archive one by one
+type chainArchiveReader struct {
+	r      *bufio.Reader
+	header *ArchiveHeader
+	count  uint64
+}
+
+func newChainArchiveReader(r io.Reader) (*chainArchiveReader, error) {
+	br := bufio.NewReader(r)
+	head := make([]byte, len(archiveMagic)+2+8+8)
+	if _, err := io.ReadFull(br, head); err != nil {
+		return nil, fmt.Errorf("read archive header error:%v", err)
+	}
+	if string(head[:len(archiveMagic)]) != archiveMagic {
+		return nil, fmt.Errorf("not a chain archive")
+	}
+	head = head[len(archiveMagic):]
+	header := &ArchiveHeader{
+		Version: common.ByteToUInt16(head[:2]),
+		From:    common.ByteToUInt64(head[2:10]),
+		To:      common.ByteToUInt64(head[10:18]),
+	}
+	if header.Version != archiveVersion {
+		return nil, fmt.Errorf("unsupported archive version %v", header.Version)
+	}
+	return &chainArchiveReader{r: br, header: header}, nil
+}
+
+// next returns the next block in the archive, and io.EOF after the end entry read.
+// The archive without the end entry is considered truncated
+func (ar *chainArchiveReader) next() (*types.Block, error) {
+	flag, err := ar.r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	switch flag {
+	case archiveEntryEnd:
+		countBytes := make([]byte, 8)
+		if _, err := io.ReadFull(ar.r, countBytes); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if count := common.ByteToUInt64(countBytes); count != ar.count {
+			return nil, fmt.Errorf("block count mismatch, expect %v but read %v", count, ar.count)
+		}
+		return nil, io.EOF
+	case archiveEntryBlock:
+	default:
+		return nil, fmt.Errorf("unknown archive entry %v", flag)
+	}
+
+	b, err := readBlockEntry(ar.r)
+	if err != nil {
+		return nil, err
+	}
+	bh := b.Header
+	if bh.Height < ar.header.From || bh.Height > ar.header.To {
+		return nil, fmt.Errorf("block height %v out of the archive range [%v, %v]", bh.Height, ar.header.From, ar.header.To)
+	}
+	ar.count++
+	return b, nil
+}
@@ -0,0 +1,160 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+)
+
+func readArchive(data []byte) ([]*types.Block, error) {
+	reader, err := newChainArchiveReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	blocks := make([]*types.Block, 0)
+	for {
+		b, err := reader.next()
+		if err == io.EOF {
+			return blocks, nil
+		}
+		if err != nil {
+			return blocks, err
+		}
+		blocks = append(blocks, b)
+	}
+}
+
+func TestChainArchive(t *testing.T) {
+	err := initContext4Test(t)
+	defer clearSelf(t)
+	if err != nil {
+		t.Fatalf("failed to initContext4Test")
+	}
+
+	top := BlockChainImpl.Height()
+	buf := bytes.NewBuffer(nil)
+	count, err := BlockChainImpl.ExportChain(buf, 0, top)
+	if err != nil {
+		t.Fatalf("export error:%v", err)
+	}
+	if count == 0 {
+		t.Fatalf("no block exported")
+	}
+	data := buf.Bytes()
+
+	blocks, err := readArchive(data)
+	if err != nil {
+		t.Fatalf("read archive error:%v", err)
+	}
+	if uint64(len(blocks)) != count {
+		t.Fatalf("expect %v blocks but got %v", count, len(blocks))
+	}
+	for _, b := range blocks {
+		local := BlockChainImpl.QueryBlockByHeight(b.Header.Height)
+		if local == nil || local.Header.Hash != b.Header.Hash || local.Header.Hash != b.Header.GenHash() {
+			t.Errorf("block mismatch at %v", b.Header.Height)
+		}
+		if len(local.Transactions) != len(b.Transactions) {
+			t.Errorf("transactions mismatch at %v", b.Header.Height)
+		}
+	}
+
+	if _, err := BlockChainImpl.ExportChain(bytes.NewBuffer(nil), 0, top+1); err == nil {
+		t.Errorf("expect error for range above the top")
+	}
+
+	// Truncated archive
+	if _, err := readArchive(data[:len(data)-1]); err != io.ErrUnexpectedEOF {
+		t.Errorf("expect unexpected eof for truncated archive, got %v", err)
+	}
+	// Corrupted block entry
+	corrupted := append([]byte{}, data...)
+	corrupted[len(archiveMagic)+18+10] ^= 0xff
+	if _, err := readArchive(corrupted); err == nil {
+		t.Errorf("expect error for corrupted archive")
+	}
+	// Wrong magic
+	if _, err := readArchive([]byte("ZVBX0000000000000000000000")); err == nil {
+		t.Errorf("expect error for wrong magic")
+	}
+}
+
+func TestChainArchive_Import(t *testing.T) {
+	err := initContext4Test(t)
+	defer clearSelf(t)
+	if err != nil {
+		t.Fatalf("failed to initContext4Test")
+	}
+
+	castor := common.BytesToAddress(genHash("castor"))
+	for h := uint64(1); h <= 3; h++ {
+		block := BlockChainImpl.CastBlock(h, common.Hex2Bytes("12"), 0, castor.Bytes(), common.Hash{})
+		if block == nil {
+			t.Fatalf("fail to cast new block")
+		}
+		if types.AddBlockSucc != BlockChainImpl.AddBlockOnChain("", block) {
+			t.Fatalf("fail to add block")
+		}
+	}
+	top := BlockChainImpl.QueryTopBlock()
+	buf := bytes.NewBuffer(nil)
+	if _, err := BlockChainImpl.ExportChain(buf, 0, top.Height); err != nil {
+		t.Fatalf("export error:%v", err)
+	}
+	data := buf.Bytes()
+	partial := bytes.NewBuffer(nil)
+	if _, err := BlockChainImpl.ExportChain(partial, 2, top.Height); err != nil {
+		t.Fatalf("export error:%v", err)
+	}
+	clearSelf(t)
+
+	t.Run("import", func(t *testing.T) {
+		err := initContext4Test(t)
+		defer clearSelf(t)
+		if err != nil {
+			t.Fatalf("failed to initContext4Test")
+		}
+		// Blocks not linked to the top
+		if _, err := BlockChainImpl.ImportChain(bytes.NewReader(partial.Bytes()), nil); err == nil {
+			t.Errorf("expect error importing blocks not linked")
+		}
+
+		progressed := uint64(0)
+		imported, err := BlockChainImpl.ImportChain(bytes.NewReader(data), func(n uint64) {
+			progressed = n
+		})
+		if err != nil {
+			t.Fatalf("import error:%v", err)
+		}
+		if imported != top.Height || progressed != imported {
+			t.Errorf("unexpected imported count %v, progress %v", imported, progressed)
+		}
+		if local := BlockChainImpl.QueryTopBlock(); local.Hash != top.Hash || local.StateTree != top.StateTree {
+			t.Fatalf("unexpected top after import %v %v", local.Height, local.Hash)
+		}
+
+		// Existing blocks are skipped
+		imported, err = BlockChainImpl.ImportChain(bytes.NewReader(data), nil)
+		if err != nil || imported != 0 {
+			t.Errorf("unexpected reimport result %v %v", imported, err)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+	"github.com/zvchain/zvchain/storage/account"
+	"github.com/zvchain/zvchain/tvm"
+)
+
+// CallResult is the result of executing a contract call without changing the chain state
+type CallResult struct {
+	Content string
+	Logs    []*types.Log
+	GasUsed uint64
+	Err     *types.TransactionError // Nil if executed successfully
+}
+
+// stateAt returns the block header and the state after the block at the given height.
+// The top block is used if the given height is higher than the top
+func (chain *FullBlockChain) stateAt(height uint64) (*types.BlockHeader, *account.AccountDB, error) {
+	chain.rwLock.RLock()
+	defer chain.rwLock.RUnlock()
+
+	header := chain.latestBlock
+	if header == nil || height < header.Height {
+		header = chain.queryBlockHeaderByHeightFloor(height)
+		if header == nil {
+			return nil, nil, fmt.Errorf("no data at height %v", height)
+		}
+	}
+	state, err := chain.newAccountDB(header.StateTree)
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, state, nil
+}
+
+// CallContract executes the abi on the contract over the state at the given height as the source address.
+// The state changes made by the execution are discarded.
+func (chain *FullBlockChain) CallContract(source, contractAddr common.Address, abiJSON string, height uint64) (*CallResult, error) {
+	header, state, err := chain.stateAt(height)
+	if err != nil {
+		return nil, err
+	}
+	if len(abiJSON) > txMaxSize {
+		return nil, fmt.Errorf("abi size(%v) should not larger than %v", len(abiJSON), txMaxSize)
+	}
+	tx := types.NewTransaction(&types.RawTransaction{
+		Source:   &source,
+		Target:   &contractAddr,
+		Type:     types.TransactionTypeContractCall,
+		Data:     []byte(abiJSON),
+		Value:    types.NewBigInt(0),
+		GasLimit: types.NewBigInt(gasLimitPerTransaction),
+		GasPrice: types.NewBigInt(0),
+	}, common.Hash{})
+	intrinsicGasUsed := intrinsicGas(tx).Uint64()
+
+	// The tvm controller is shared with the block executing, so lock the chain
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+
+	controller := tvm.NewController(state, chain, header, tx, intrinsicGasUsed, MinerManagerImpl)
+	contract := tvm.LoadContract(contractAddr)
+	if contract.Code == "" {
+		return nil, fmt.Errorf("no code at the given address %v", contractAddr.AddrPrefixString())
+	}
+	executeResult, logs, txErr := controller.ExecuteAbiEval(&source, contract, abiJSON)
+	ret := &CallResult{
+		Logs:    logs,
+		GasUsed: gasLimitPerTransaction - controller.GetGasLeft(),
+		Err:     txErr,
+	}
+	if executeResult != nil {
+		ret.Content = executeResult.Content
+	}
+	return ret, nil
+}
@@ -0,0 +1,147 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+	"github.com/zvchain/zvchain/storage/account"
+	"github.com/zvchain/zvchain/tvm"
+)
+
+const counterContract = `
+class Counter(object):
+    def __init__(self):
+        self.count = 0
+
+    @register.public()
+    def increase(self):
+        self.count = self.count + 1
+
+    @register.public()
+    def get_count(self):
+        return self.count
+`
+
+func genContractTestTx(txType int8, target *common.Address, nonce uint64, data []byte) *types.Transaction {
+	raw := &types.RawTransaction{
+		GasPrice: types.NewBigInt(500),
+		GasLimit: types.NewBigInt(gasLimitPerTransaction),
+		Target:   target,
+		Nonce:    nonce,
+		Value:    types.NewBigInt(0),
+		Type:     txType,
+		Data:     data,
+	}
+	sk := common.HexToSecKey(privateKey)
+	source := sk.GetPubKey().GetAddress()
+	raw.Source = &source
+	tx := types.NewTransaction(raw, raw.GenHash())
+	sign, _ := sk.Sign(tx.Hash.Bytes())
+	tx.Sign = sign.Bytes()
+	return tx
+}
+
+func TestCallContractNoCode(t *testing.T) {
+	err := initContext4Test(t)
+	if err != nil {
+		t.Fatalf("init fail:%v", err)
+	}
+	defer clearSelf(t)
+
+	source := common.BytesToAddress([]byte{1})
+	target := common.BytesToAddress([]byte{2})
+	_, err = BlockChainImpl.CallContract(source, target, `{"func_name":"balance_of","args":[]}`, BlockChainImpl.Height())
+	if err == nil {
+		t.Fatalf("call on the address without code should fail")
+	}
+}
+
+func TestCallContractAtHeight(t *testing.T) {
+	err := initContext4Test(t)
+	if err != nil {
+		t.Fatalf("init fail:%v", err)
+	}
+	defer clearSelf(t)
+	chain := BlockChainImpl
+	initBalance()
+
+	code, _ := json.Marshal(&tvm.Contract{Code: counterContract, ContractName: "Counter"})
+	deploy := genContractTestTx(types.TransactionTypeContractCreate, nil, 1, code)
+	contractAddr := common.BytesToAddress(common.Sha256(common.BytesCombine(deploy.Source[:], common.Uint64ToByte(deploy.Nonce))))
+	increase := genContractTestTx(types.TransactionTypeContractCall, &contractAddr, 2, []byte(`{"func_name":"increase","args":[]}`))
+
+	// Deployed at 2 and increased at 3
+	for i, tx := range []*types.Transaction{deploy, increase} {
+		h := uint64(i + 2)
+		if _, err := chain.GetTransactionPool().AddTransaction(tx); err != nil {
+			t.Fatalf("fail to AddTransaction %v", err)
+		}
+		block := chain.CastBlock(h, common.Hex2Bytes("12"), 0, nil, common.Hash{})
+		if block == nil || types.AddBlockSucc != chain.AddBlockOnChain(source, block) {
+			t.Fatalf("fail to add block at %v", h)
+		}
+		if len(block.Transactions) != 1 {
+			t.Fatalf("expect the tx packed at %v", h)
+		}
+	}
+	top := chain.QueryTopBlock()
+
+	getCount := func(height uint64) string {
+		ret, err := chain.CallContract(*deploy.Source, contractAddr, `{"func_name":"get_count","args":[]}`, height)
+		if err != nil || ret.Err != nil {
+			t.Fatalf("call at %v error:%v %v", height, err, ret)
+		}
+		return strings.Trim(ret.Content, `"`)
+	}
+	if c := getCount(2); c != "0" {
+		t.Errorf("expect count 0 at 2, got %v", c)
+	}
+	if c := getCount(3); c != "1" {
+		t.Errorf("expect count 1 at 3, got %v", c)
+	}
+	if _, err := chain.CallContract(*deploy.Source, contractAddr, `{"func_name":"get_count","args":[]}`, 1); err == nil {
+		t.Errorf("expect error before the contract deployed")
+	}
+
+	// The changes made by the calls are discarded
+	for _, h := range []uint64{2, 3} {
+		ret, err := chain.CallContract(*deploy.Source, contractAddr, `{"func_name":"increase","args":[]}`, h)
+		if err != nil || ret.Err != nil {
+			t.Fatalf("call increase at %v error:%v %v", h, err, ret)
+		}
+	}
+	if c := getCount(2); c != "0" {
+		t.Errorf("expect count 0 at 2 after the calls, got %v", c)
+	}
+	if c := getCount(3); c != "1" {
+		t.Errorf("expect count 1 at 3 after the calls, got %v", c)
+	}
+	state, err := chain.LatestAccountDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root := state.(*account.AccountDB).IntermediateRoot(true); root != top.StateTree {
+		t.Errorf("expect the latest state unchanged")
+	}
+	if chain.QueryTopBlock().Hash != top.Hash {
+		t.Errorf("expect the top unchanged")
+	}
+}
@@ -0,0 +1,379 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/core/group"
+	"github.com/zvchain/zvchain/log"
+	"github.com/zvchain/zvchain/middleware/types"
+	"github.com/zvchain/zvchain/storage/account"
+	"github.com/zvchain/zvchain/storage/tasdb"
+)
+
+// ChainProblem is an inconsistency found in the chain data
+type ChainProblem struct {
+	Height uint64
+	Err    error
+}
+
+// ChainCheckResult is the result of checking the chain data
+type ChainCheckResult struct {
+	Highest    uint64             // Highest height stored
+	Base       uint64             // Lowest height checked, the blocks below are missing if the state imported from a snapshot
+	Current    *types.BlockHeader // The current top block, nil if missing
+	Checked    uint64             // Number of blocks checked
+	Problems   uint64             // Number of problems found
+	Consistent *types.BlockHeader // The highest fully consistent block in the heights checked, nil if not found
+}
+
+// ChainChecker checks the consistency of the chain data offline, and truncates the chain to the highest
+// fully consistent block for repairing. A block is fully consistent if all the blocks checked not above it
+// are stored completely and chained by the hashes, and its state trie can be fully resolved. As the states of
+// the old blocks may be pruned, the states are only checked from the top down until one is resolved.
+type ChainChecker struct {
+	chain    *FullBlockChain
+	receipts *tasdb.PrefixedDatabase
+	tailor   *OfflineTailor // Verifies the state tries
+	readOnly bool
+	highest  uint64 // Highest height with the block stored
+	base     uint64 // Height of the snapshot block the chain bootstrapped from, or 0 if no blocks missing
+}
+
+// NewChainChecker opens the chain databases configured, which must not be used by a running node
+func NewChainChecker(readOnly bool) (*ChainChecker, error) {
+	Logger = log.CoreLogger
+	config := getBlockChainConfig()
+	if tasdb.DetectEngine(config.dbfile) == "" {
+		return nil, fmt.Errorf("no database found in %v", config.dbfile)
+	}
+	chain := &FullBlockChain{
+		config:       config,
+		init:         true,
+		isAdjusting:  false,
+		topRawBlocks: common.MustNewLRUCache(20),
+	}
+	options := &tasdb.Options{
+		BloomFilterBits: 10,
+		ReadOnly:        readOnly,
+	}
+	ds, err := tasdb.NewDataSource(config.dbfile, options)
+	if err != nil {
+		return nil, err
+	}
+	var receipts *tasdb.PrefixedDatabase
+	for prefix, db := range map[string]**tasdb.PrefixedDatabase{
+		config.block:       &chain.blocks,
+		config.blockHeight: &chain.blockHeight,
+		config.tx:          &chain.txDb,
+		config.state:       &chain.stateDb,
+		config.bloom:       &chain.bloomDb,
+		config.receipt:     &receipts,
+	} {
+		if *db, err = ds.NewPrefixDatabase(prefix); err != nil {
+			return nil, err
+		}
+	}
+	if config.addrIndexEnabled {
+		addrIndexDb, err := ds.NewPrefixDatabase(config.addrIndex)
+		if err != nil {
+			return nil, err
+		}
+		chain.addrIndex = newAddressIndex(addrIndexDb)
+	}
+	if config.stateDiffBlocks > 0 {
+		stateDiffDb, err := ds.NewPrefixDatabase(config.stateDiff)
+		if err != nil {
+			return nil, err
+		}
+		chain.stateDiffs = newStateDiffStore(stateDiffDb, config.stateDiffBlocks)
+	}
+	if config.ancientEnabled {
+		ancientDb, err := ds.NewPrefixDatabase(config.ancient)
+		if err != nil {
+			return nil, err
+		}
+		if chain.ancients, err = newAncientStore(config.ancientDir, ancientDb, ancientSegmentHeights); err != nil {
+			return nil, err
+		}
+	}
+	smallDir := common.GlobalConf.GetString(configSec, "small_db", "d_small")
+	if tasdb.DetectEngine(smallDir) != "" {
+		smallStateDs, err := tasdb.NewDataSource(smallDir, &tasdb.Options{ReadOnly: readOnly})
+		if err != nil {
+			return nil, err
+		}
+		smallStateDb, err := smallStateDs.NewPrefixDatabase("")
+		if err != nil {
+			return nil, err
+		}
+		chain.smallStateDb = initSmallStore(smallStateDb)
+	}
+	chain.stateCache = account.NewDatabaseWithCache(chain.stateDb, false, common.GlobalConf.GetInt(configSec, "db_state_cache", 256), "")
+	return newChainChecker(chain, receipts, readOnly), nil
+}
+
+func newChainChecker(chain *FullBlockChain, receipts *tasdb.PrefixedDatabase, readOnly bool) *ChainChecker {
+	c := &ChainChecker{chain: chain, receipts: receipts, readOnly: readOnly}
+	groupManager := group.NewManager(chain, nil)
+	c.tailor = &OfflineTailor{
+		chain:       chain,
+		out:         os.Stdout,
+		usedNodes:   make(map[common.Hash]struct{}),
+		groupSeeds:  make(map[common.Address]struct{}),
+		onlyVerify:  true,
+		groupReader: groupManager,
+		groupKeys:   [][]byte{groupManager.GroupKey()},
+	}
+
+	iter := chain.blockHeight.NewIterator()
+	if iter.Last() {
+		c.highest = common.ByteToUInt64(iter.Key())
+	}
+	// The chain bootstrapped by importing the state snapshot has no blocks between the genesis and the snapshot,
+	// so the lowest block above the genesis doesn't link to a stored one
+	if iter.Seek(common.UInt64ToByte(1)) {
+		low := common.ByteToUInt64(iter.Key())
+		if bh := chain.queryBlockHeaderByHash(common.BytesToHash(iter.Value())); bh != nil && !chain.hasBlock(bh.PreHash) {
+			c.base = low
+		}
+	}
+	iter.Release()
+	return c
+}
+
+// Close closes the databases
+func (c *ChainChecker) Close() {
+	if c.chain.ancients != nil {
+		c.chain.ancients.close()
+	}
+	if c.chain.smallStateDb != nil {
+		c.chain.smallStateDb.Close()
+	}
+	c.chain.blocks.Close()
+}
+
+// Check walks the blocks from the highest down to the base to verify them, and the given number of blocks are
+// checked if depth is not 0. The problems found are reported one by one, and the progress reported with the
+// height of each block checked
+func (c *ChainChecker) Check(depth uint64, report func(p *ChainProblem), progress func(height uint64)) (*ChainCheckResult, error) {
+	chain := c.chain
+	result := &ChainCheckResult{Highest: c.highest, Base: c.base, Current: chain.loadCurrentBlock()}
+	problem := func(h uint64, err error) {
+		result.Problems++
+		result.Consistent = nil
+		report(&ChainProblem{Height: h, Err: err})
+	}
+	if cur := result.Current; cur == nil {
+		result.Problems++
+		report(&ChainProblem{Height: c.highest, Err: fmt.Errorf("current block missing")})
+	} else if hash := chain.queryBlockHash(cur.Height); hash == nil || *hash != cur.Hash {
+		result.Problems++
+		report(&ChainProblem{Height: cur.Height, Err: fmt.Errorf("current block %v not on the chain", cur.Hash.Hex())})
+	}
+	if !chain.hasHeight(c.highest) {
+		return result, nil
+	}
+
+	var next *types.BlockHeader // The block checked above
+	for h := c.highest; ; h-- {
+		if chain.hasHeight(h) {
+			if depth > 0 && result.Checked >= depth {
+				break
+			}
+			// The receipts of the snapshot block are not imported
+			bh, err := c.checkBlock(h, c.base == 0 || h != c.base)
+			if bh != nil && next != nil && next.PreHash != bh.Hash {
+				problem(next.Height, fmt.Errorf("pre hash %v not found at %v", next.PreHash.Hex(), h))
+			}
+			if err == nil && result.Consistent == nil {
+				err = c.checkState(bh)
+			}
+			if err != nil {
+				problem(h, err)
+			} else if result.Consistent == nil {
+				result.Consistent = bh
+			}
+			result.Checked++
+			next = bh
+			if progress != nil {
+				progress(h)
+			}
+		}
+		if h == c.base {
+			break
+		}
+	}
+	return result, nil
+}
+
+// checkBlock verifies the header, transactions and receipts of the block at the given height. It returns
+// the header if stored correctly, even with the transactions or receipts broken
+func (c *ChainChecker) checkBlock(h uint64, receipts bool) (*types.BlockHeader, error) {
+	chain := c.chain
+	hash := chain.queryBlockHash(h)
+	bh := chain.queryBlockHeaderByHash(*hash)
+	if bh == nil {
+		return nil, fmt.Errorf("header %v missing", hash.Hex())
+	}
+	if bh.Height != h || bh.Hash != *hash || bh.GenHash() != *hash {
+		return nil, fmt.Errorf("header %v mismatch", hash.Hex())
+	}
+	body := chain.queryBlockBodyBytes(bh.Hash)
+	if body == nil {
+		// Removed from the ancient store if expired
+		if chain.ancients != nil && chain.ancients.hasBlock(bh.Hash) {
+			return bh, nil
+		}
+		return bh, fmt.Errorf("transactions missing")
+	}
+	rawTxs, err := decodeBlockTransactions(body)
+	if err != nil {
+		return bh, fmt.Errorf("decode transactions error:%v", err)
+	}
+	txs := make(txSlice, 0, len(rawTxs))
+	for _, raw := range rawTxs {
+		txs = append(txs, types.NewTransaction(raw, raw.GenHash()))
+	}
+	if txs.calcTxTree() != bh.TxTree {
+		return bh, fmt.Errorf("tx tree mismatch")
+	}
+	for _, tx := range txs {
+		if receipts && !c.hasReceipt(tx.Hash) {
+			return bh, fmt.Errorf("receipt of %v missing", tx.Hash.Hex())
+		}
+	}
+	return bh, nil
+}
+
+func (c *ChainChecker) hasReceipt(hash common.Hash) bool {
+	if ok, _ := c.receipts.Has(hash.Bytes()); ok {
+		return true
+	}
+	return c.chain.ancients != nil && c.chain.ancients.hasTx(hash)
+}
+
+// checkState verifies the state trie of the block can be fully resolved
+func (c *ChainChecker) checkState(bh *types.BlockHeader) error {
+	// The state data in the small db is merged into the state database on startup
+	if c.chain.smallStateDb != nil && c.chain.smallStateDb.hasHeight(bh.Height) {
+		return nil
+	}
+	state, err := account.NewAccountDB(bh.StateTree, c.chain.stateCache)
+	if err != nil {
+		return fmt.Errorf("state root %v missing", bh.StateTree.Hex())
+	}
+	if err := c.tailor.loadAllGroupSeeds(bh.Height); err != nil {
+		return fmt.Errorf("load group seeds error:%v", err)
+	}
+	if _, err := state.Traverse(c.tailor.verifyConfig()); err != nil {
+		return fmt.Errorf("resolve state %v error:%v", bh.StateTree.Hex(), err)
+	}
+	return nil
+}
+
+// Repair truncates the chain to the given block by removing the blocks above it with their transactions,
+// receipts, blooms and indexes. It returns the number of blocks removed. The chain can't be truncated below
+// the base, as the blocks below are missing
+func (c *ChainChecker) Repair(to *types.BlockHeader) (uint64, error) {
+	chain := c.chain
+	if c.readOnly {
+		return 0, fmt.Errorf("database opened read only")
+	}
+	if to.Height < c.base {
+		return 0, fmt.Errorf("can't truncate below the base height %v", c.base)
+	}
+	if chain.ancients != nil && to.Height+1 < chain.ancients.next() {
+		return 0, fmt.Errorf("can't truncate below the frozen height %v", chain.ancients.next())
+	}
+	chain.batch = chain.blocks.CreateLDBBatch()
+	// Switch to the block first, so the chain is usable if interrupted, and the blocks left above are
+	// removed by repairing again
+	if err := chain.saveCurrentBlock(to.Hash); err != nil {
+		return 0, err
+	}
+	if err := chain.batch.Write(); err != nil {
+		return 0, err
+	}
+	chain.batch.Reset()
+
+	heights := make([]uint64, 0)
+	for h := c.highest; h > to.Height; h-- {
+		hash := chain.queryBlockHash(h)
+		if hash == nil {
+			continue
+		}
+		if err := c.removeBlock(h, *hash); err != nil {
+			return uint64(len(heights)), err
+		}
+		heights = append(heights, h)
+		if chain.batch.ValueSize() >= tasdb.IdealBatchSize {
+			if err := chain.batch.Write(); err != nil {
+				return uint64(len(heights)), err
+			}
+			chain.batch.Reset()
+		}
+	}
+	if err := chain.batch.Write(); err != nil {
+		return uint64(len(heights)), err
+	}
+	if chain.smallStateDb != nil {
+		if err := chain.smallStateDb.DeleteHeights(heights); err != nil {
+			return uint64(len(heights)), err
+		}
+	}
+	c.highest = to.Height
+	return uint64(len(heights)), nil
+}
+
+// removeBlock adds the deletion of the block into the batch
+func (c *ChainChecker) removeBlock(h uint64, hash common.Hash) error {
+	chain := c.chain
+	if err := chain.saveBlockHeight(h, nil); err != nil {
+		return err
+	}
+	if bh := chain.queryBlockHeaderByHash(hash); bh != nil && bh.Height == h {
+		if chain.addrIndex != nil {
+			if err := chain.addrIndex.removeBlock(chain.batch, bh); err != nil {
+				return err
+			}
+		}
+		if chain.stateDiffs != nil {
+			if err := chain.stateDiffs.removeBlock(chain.batch, bh); err != nil {
+				return err
+			}
+		}
+	}
+	if body, err := chain.txDb.Get(hash.Bytes()); err == nil {
+		if txs, err := decodeBlockTransactions(body); err == nil {
+			for _, tx := range txs {
+				if err := c.receipts.AddKv(chain.batch, tx.GenHash().Bytes(), nil); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if err := chain.saveBlockHeader(hash, nil); err != nil {
+		return err
+	}
+	if err := chain.saveBlockTxs(hash, nil); err != nil {
+		return err
+	}
+	return chain.saveBlockBloom(hash, nil)
+}
@@ -0,0 +1,157 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+)
+
+func TestChainChecker(t *testing.T) {
+	err := initContext4Test(t)
+	defer clearSelf(t)
+	if err != nil {
+		t.Fatalf("failed to initContext4Test")
+	}
+	chain := BlockChainImpl
+	initBalance()
+	pool := chain.transactionPool.(*txPool)
+	txs := make(map[uint64]*types.Transaction)
+	for h := uint64(2); h <= 4; h++ {
+		tx := genTestTx(500, "100", h-1, 1)
+		if _, err := pool.AddTransaction(tx); err != nil {
+			t.Fatalf("fail to AddTransaction %v", err)
+		}
+		block := chain.CastBlock(h, common.Hex2Bytes("12"), 0, nil, common.Hash{})
+		if block == nil {
+			t.Fatalf("fail to cast new block")
+		}
+		if types.AddBlockSucc != chain.AddBlockOnChain(source, block) {
+			t.Fatalf("fail to add block")
+		}
+		txs[h] = tx
+	}
+	top := chain.QueryTopBlock()
+
+	checker := newChainChecker(chain, pool.receiptDb, false)
+	problems := make([]*ChainProblem, 0)
+	check := func(depth uint64) *ChainCheckResult {
+		problems = problems[:0]
+		result, err := checker.Check(depth, func(p *ChainProblem) {
+			problems = append(problems, p)
+		}, nil)
+		if err != nil {
+			t.Fatalf("check error:%v", err)
+		}
+		return result
+	}
+	result := check(0)
+	if len(problems) != 0 || result.Problems != 0 {
+		t.Fatalf("expect no problems, got %v", problems)
+	}
+	if result.Consistent == nil || result.Consistent.Hash != top.Hash {
+		t.Fatalf("expect the top consistent")
+	}
+	if result := check(2); result.Checked != 2 {
+		t.Errorf("expect 2 blocks checked, got %v", result.Checked)
+	}
+
+	// Lose the receipt of the block at 3
+	pool.receiptDb.Delete(txs[3].Hash.Bytes())
+	result = check(0)
+	if len(problems) != 1 || problems[0].Height != 3 {
+		t.Fatalf("expect the problem at 3, got %v", problems)
+	}
+	if result.Consistent == nil || result.Consistent.Height != 2 {
+		t.Fatalf("expect the block at 2 consistent")
+	}
+
+	removed, err := checker.Repair(result.Consistent)
+	if err != nil {
+		t.Fatalf("repair error:%v", err)
+	}
+	if removed != 2 || chain.hasHeight(3) || chain.hasHeight(4) || chain.hasBlock(top.Hash) {
+		t.Errorf("expect the blocks above 2 removed")
+	}
+	if pool.hasReceipt(txs[4].Hash) {
+		t.Errorf("expect the receipts above 2 removed")
+	}
+	if cur := chain.loadCurrentBlock(); cur == nil || cur.Height != 2 {
+		t.Errorf("expect the current block at 2")
+	}
+	result = check(0)
+	if len(problems) != 0 || result.Consistent == nil || result.Consistent.Height != 2 {
+		t.Errorf("expect the chain consistent after repairing, got %v", problems)
+	}
+}
+
+func TestChainChecker_SnapshotBase(t *testing.T) {
+	err := initContext4Test(t)
+	defer clearSelf(t)
+	if err != nil {
+		t.Fatalf("failed to initContext4Test")
+	}
+	chain := BlockChainImpl
+	initBalance()
+	pool := chain.transactionPool.(*txPool)
+	txs := make(map[uint64]*types.Transaction)
+	for h := uint64(2); h <= 4; h++ {
+		tx := genTestTx(500, "100", h-1, 1)
+		if _, err := pool.AddTransaction(tx); err != nil {
+			t.Fatalf("fail to AddTransaction %v", err)
+		}
+		block := chain.CastBlock(h, common.Hex2Bytes("12"), 0, nil, common.Hash{})
+		if block == nil {
+			t.Fatalf("fail to cast new block")
+		}
+		if types.AddBlockSucc != chain.AddBlockOnChain(source, block) {
+			t.Fatalf("fail to add block")
+		}
+		txs[h] = tx
+	}
+	top := chain.QueryTopBlock()
+
+	// Bootstrapped from the snapshot block at 3 without the blocks below and its receipts
+	chain.blocks.Delete(chain.QueryBlockHeaderByHeight(2).Hash.Bytes())
+	chain.blockHeight.Delete(common.UInt64ToByte(2))
+	pool.receiptDb.Delete(txs[3].Hash.Bytes())
+
+	checker := newChainChecker(chain, pool.receiptDb, false)
+	problems := make([]*ChainProblem, 0)
+	result, err := checker.Check(0, func(p *ChainProblem) {
+		problems = append(problems, p)
+	}, nil)
+	if err != nil {
+		t.Fatalf("check error:%v", err)
+	}
+	if len(problems) != 0 {
+		t.Fatalf("expect no problems above the snapshot block, got %v", problems)
+	}
+	if result.Base != 3 || result.Checked != 2 {
+		t.Errorf("expect 2 blocks checked down to the base 3, got %v %v", result.Checked, result.Base)
+	}
+	if result.Consistent == nil || result.Consistent.Hash != top.Hash {
+		t.Errorf("expect the top consistent")
+	}
+	if _, err := checker.Repair(chain.QueryBlockHeaderByHeight(0)); err == nil {
+		t.Errorf("expect error for repairing below the base")
+	}
+	if !chain.hasBlock(top.Hash) {
+		t.Errorf("expect the chain untouched")
+	}
+}
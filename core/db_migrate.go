@@ -0,0 +1,375 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/vmihailenco/msgpack"
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+	"github.com/zvchain/zvchain/storage/tasdb"
+)
+
+// The schema version of the database is stored in the blocks database. The databases created before the
+// versioning are of version 0. On startup, the migrations of the versions higher than the stored one are
+// run in order, and the version is saved after each migration finished.
+//
+// A migration walks the logical databases step by step, and the progress is saved periodically as:
+//
+//	migrationProgressKey -> version(4) | step(4) | the last key migrated of the step
+//
+// so an interrupted migration resumes from where it stopped. The entries after the saved key may be
+// migrated again, so the migrations must be idempotent.
+const (
+	schemaVersionKey     = "schema_version"
+	migrationProgressKey = "migrating"
+
+	migrationSaveItems      = 10000 // Number of entries scanned between the progress saved
+	migrationReportInterval = 10 * time.Second
+)
+
+// MigrationStat is the statistics of a migration
+type MigrationStat struct {
+	Version uint32
+	Name    string
+	Scanned uint64 // Number of entries scanned
+	Changed uint64 // Number of entries written
+	Deleted uint64 // Number of entries deleted
+}
+
+// migrationDBs is the logical databases the migrations work on
+type migrationDBs struct {
+	blocks      *tasdb.PrefixedDatabase
+	blockHeight *tasdb.PrefixedDatabase
+	txs         *tasdb.PrefixedDatabase
+	receipts    *tasdb.PrefixedDatabase
+	state       *tasdb.PrefixedDatabase
+	bloom       *tasdb.PrefixedDatabase
+	small       *tasdb.PrefixedDatabase
+}
+
+type migration struct {
+	version uint32
+	name    string
+	run     func(m *migrator) error
+}
+
+// migrations is the registry of the migrations in the ascending order of the version
+var migrations []*migration
+
+func registerMigration(version uint32, name string, run func(m *migrator) error) {
+	if version != latestSchemaVersion()+1 {
+		panic(fmt.Sprintf("migration %v registered out of order", version))
+	}
+	migrations = append(migrations, &migration{version: version, name: name, run: run})
+}
+
+func init() {
+	registerMigration(1, "build the log blooms of the blocks stored before the bloom index", migrateLogBlooms)
+}
+
+// latestSchemaVersion returns the schema version of the database created by the current program
+func latestSchemaVersion() uint32 {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].version
+}
+
+// migrationWriter buffers the changes of a migration, nothing is written in the dry run
+type migrationWriter struct {
+	dryRun  bool
+	stat    *MigrationStat
+	batches map[*tasdb.PrefixedDatabase]tasdb.Batch
+	size    int
+}
+
+// put writes the entry into the database, nil value to delete it
+func (w *migrationWriter) put(db *tasdb.PrefixedDatabase, key, value []byte) error {
+	if value == nil {
+		w.stat.Deleted++
+	} else {
+		w.stat.Changed++
+	}
+	if w.dryRun {
+		return nil
+	}
+	batch, ok := w.batches[db]
+	if !ok {
+		batch = db.CreateLDBBatch()
+		w.batches[db] = batch
+	}
+	w.size += len(key) + len(value)
+	return db.AddKv(batch, key, value)
+}
+
+func (w *migrationWriter) flush() error {
+	for _, batch := range w.batches {
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		batch.Reset()
+	}
+	w.size = 0
+	return nil
+}
+
+// migrator runs a migration over the logical databases
+type migrator struct {
+	dbs      *migrationDBs
+	version  uint32
+	writer   *migrationWriter
+	progress func(stat *MigrationStat)
+
+	step       uint32 // Index of the current step
+	resumeStep uint32
+	resumeKey  []byte
+	lastReport time.Time
+}
+
+// iterate calls fn with each entry of the database in the key order as one step of the migration,
+// the changes should be made by the writer given
+func (m *migrator) iterate(db *tasdb.PrefixedDatabase, fn func(key, value []byte, w *migrationWriter) error) error {
+	step := m.step
+	m.step++
+	// Finished before interrupted
+	if step < m.resumeStep {
+		return nil
+	}
+	iter := db.NewIterator()
+	defer iter.Release()
+
+	valid := false
+	if step == m.resumeStep && m.resumeKey != nil {
+		valid = iter.Seek(m.resumeKey)
+		if valid && bytes.Equal(iter.Key(), m.resumeKey) {
+			valid = iter.Next()
+		}
+	} else {
+		valid = iter.Next()
+	}
+	scanned := 0
+	for ; valid; valid = iter.Next() {
+		key := common.CopyBytes(iter.Key())
+		if err := fn(key, common.CopyBytes(iter.Value()), m.writer); err != nil {
+			return err
+		}
+		m.writer.stat.Scanned++
+		scanned++
+		if scanned%migrationSaveItems == 0 || m.writer.size >= tasdb.IdealBatchSize {
+			if err := m.save(step, key); err != nil {
+				return err
+			}
+		}
+		if m.progress != nil && time.Since(m.lastReport) >= migrationReportInterval {
+			m.progress(m.writer.stat)
+			m.lastReport = time.Now()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return m.save(step+1, nil)
+}
+
+// save writes the changes buffered, then the progress of the migration
+func (m *migrator) save(step uint32, key []byte) error {
+	if m.writer.dryRun {
+		return nil
+	}
+	if err := m.writer.flush(); err != nil {
+		return err
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, 8+len(key)))
+	buf.Write(common.UInt32ToByte(m.version))
+	buf.Write(common.UInt32ToByte(step))
+	buf.Write(key)
+	return m.dbs.blocks.Put([]byte(migrationProgressKey), buf.Bytes())
+}
+
+func loadSchemaVersion(blocks *tasdb.PrefixedDatabase) (uint32, bool) {
+	bs, err := blocks.Get([]byte(schemaVersionKey))
+	if err != nil || len(bs) != 4 {
+		return 0, false
+	}
+	return common.ByteToUInt32(bs), true
+}
+
+// migrateDatabase runs the migrations needed for the databases to the latest schema version, and returns
+// the statistics of the migrations run. In the dry run, nothing is written and the changes of each migration
+// are counted against the current data. The databases never used are marked as the latest version directly
+func migrateDatabase(dbs *migrationDBs, dryRun bool, progress func(stat *MigrationStat)) ([]*MigrationStat, error) {
+	latest := latestSchemaVersion()
+	version, ok := loadSchemaVersion(dbs.blocks)
+	if !ok {
+		if used, _ := dbs.blocks.Has([]byte(blockStatusKey)); !used {
+			if dryRun {
+				return nil, nil
+			}
+			return nil, dbs.blocks.Put([]byte(schemaVersionKey), common.UInt32ToByte(latest))
+		}
+	}
+	if version > latest {
+		return nil, fmt.Errorf("database schema version %v is newer than the supported %v", version, latest)
+	}
+	var resumeStep uint32
+	var resumeKey []byte
+	if bs, err := dbs.blocks.Get([]byte(migrationProgressKey)); err == nil && len(bs) >= 8 && common.ByteToUInt32(bs[:4]) == version+1 {
+		resumeStep, resumeKey = common.ByteToUInt32(bs[4:8]), bs[8:]
+		if len(resumeKey) == 0 {
+			resumeKey = nil
+		}
+	}
+
+	stats := make([]*MigrationStat, 0)
+	for _, mg := range migrations {
+		if mg.version <= version {
+			continue
+		}
+		stat := &MigrationStat{Version: mg.version, Name: mg.name}
+		m := &migrator{
+			dbs:        dbs,
+			version:    mg.version,
+			writer:     &migrationWriter{dryRun: dryRun, stat: stat, batches: make(map[*tasdb.PrefixedDatabase]tasdb.Batch)},
+			progress:   progress,
+			lastReport: time.Now(),
+		}
+		if !dryRun && mg.version == version+1 {
+			m.resumeStep, m.resumeKey = resumeStep, resumeKey
+		}
+		if err := mg.run(m); err != nil {
+			return stats, fmt.Errorf("migration %v(%v) error:%v", mg.version, mg.name, err)
+		}
+		stats = append(stats, stat)
+		if dryRun {
+			continue
+		}
+		if err := m.writer.flush(); err != nil {
+			return stats, err
+		}
+		batch := dbs.blocks.CreateLDBBatch()
+		if err := dbs.blocks.AddKv(batch, []byte(schemaVersionKey), common.UInt32ToByte(mg.version)); err != nil {
+			return stats, err
+		}
+		if err := dbs.blocks.AddKv(batch, []byte(migrationProgressKey), nil); err != nil {
+			return stats, err
+		}
+		if err := batch.Write(); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// MigrateDatabase opens the databases configured and migrates them to the latest schema version. The chain
+// must not be running. It returns the version before migrating and the statistics of the migrations run
+func MigrateDatabase(dryRun bool, progress func(stat *MigrationStat)) (uint32, []*MigrationStat, error) {
+	config := getBlockChainConfig()
+	if tasdb.DetectEngine(config.dbfile) == "" {
+		return 0, nil, fmt.Errorf("no database found in %v", config.dbfile)
+	}
+	ds, err := tasdb.NewDataSource(config.dbfile, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	smallDs, err := tasdb.NewDataSource(common.GlobalConf.GetString(configSec, "small_db", "d_small"), nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	dbs := &migrationDBs{}
+	for prefix, db := range map[string]**tasdb.PrefixedDatabase{
+		config.block:       &dbs.blocks,
+		config.blockHeight: &dbs.blockHeight,
+		config.tx:          &dbs.txs,
+		config.receipt:     &dbs.receipts,
+		config.state:       &dbs.state,
+		config.bloom:       &dbs.bloom,
+	} {
+		if *db, err = ds.NewPrefixDatabase(prefix); err != nil {
+			return 0, nil, err
+		}
+	}
+	if dbs.small, err = smallDs.NewPrefixDatabase(""); err != nil {
+		return 0, nil, err
+	}
+	defer dbs.small.Close()
+	defer dbs.blocks.Close()
+
+	version, _ := loadSchemaVersion(dbs.blocks)
+	stats, err := migrateDatabase(dbs, dryRun, progress)
+	return version, stats, err
+}
+
+// migrateOnStartup migrates the databases opened by the chain to the latest schema version
+func (chain *FullBlockChain) migrateOnStartup(receipts, small *tasdb.PrefixedDatabase) error {
+	dbs := &migrationDBs{
+		blocks:      chain.blocks,
+		blockHeight: chain.blockHeight,
+		txs:         chain.txDb,
+		receipts:    receipts,
+		state:       chain.stateDb,
+		bloom:       chain.bloomDb,
+		small:       small,
+	}
+	begin := time.Now()
+	stats, err := migrateDatabase(dbs, false, func(stat *MigrationStat) {
+		Logger.Infof("migrating database to version %v, scanned %v, changed %v, deleted %v", stat.Version, stat.Scanned, stat.Changed, stat.Deleted)
+	})
+	for _, stat := range stats {
+		Logger.Infof("database migrated to version %v(%v), scanned %v, changed %v, deleted %v", stat.Version, stat.Name, stat.Scanned, stat.Changed, stat.Deleted)
+	}
+	if len(stats) > 0 {
+		fmt.Printf("database migrated to version %v, cost %v\n", stats[len(stats)-1].Version, time.Since(begin))
+	}
+	return err
+}
+
+// migrateLogBlooms saves the log bloom of each block on the chain if not exists
+func migrateLogBlooms(m *migrator) error {
+	return m.iterate(m.dbs.blockHeight, func(key, value []byte, w *migrationWriter) error {
+		if len(key) != 8 || len(value) != common.HashLength {
+			return nil
+		}
+		if ok, _ := m.dbs.bloom.Has(value); ok {
+			return nil
+		}
+		body, err := m.dbs.txs.Get(value)
+		if err != nil {
+			// Moved into the ancient store
+			return nil
+		}
+		txs, err := decodeBlockTransactions(body)
+		if err != nil {
+			return fmt.Errorf("decode transactions at %v error:%v", common.ByteToUInt64(key), err)
+		}
+		receipts := make(types.Receipts, 0, len(txs))
+		for _, tx := range txs {
+			bs, err := m.dbs.receipts.Get(tx.GenHash().Bytes())
+			if err != nil {
+				continue
+			}
+			var receipt types.Receipt
+			if err := msgpack.Unmarshal(bs, &receipt); err != nil {
+				return fmt.Errorf("decode receipt at %v error:%v", common.ByteToUInt64(key), err)
+			}
+			receipts = append(receipts, &receipt)
+		}
+		return w.put(m.dbs.bloom, value, types.CreateBloom(receipts).Bytes())
+	})
+}
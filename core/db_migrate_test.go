@@ -0,0 +1,120 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+)
+
+func TestMigrateDatabase(t *testing.T) {
+	err := initContext4Test(t)
+	defer clearSelf(t)
+	if err != nil {
+		t.Fatalf("failed to initContext4Test")
+	}
+	chain := BlockChainImpl
+	if v, ok := loadSchemaVersion(chain.blocks); !ok || v != latestSchemaVersion() {
+		t.Fatalf("expect the new database at the latest version, got %v", v)
+	}
+
+	initBalance()
+	pool := chain.transactionPool.(*txPool)
+	for h := uint64(2); h <= 4; h++ {
+		if _, err := pool.AddTransaction(genTestTx(500, "100", h-1, 1)); err != nil {
+			t.Fatalf("fail to AddTransaction %v", err)
+		}
+		block := chain.CastBlock(h, common.Hex2Bytes("12"), 0, nil, common.Hash{})
+		if block == nil {
+			t.Fatalf("fail to cast new block")
+		}
+		if types.AddBlockSucc != chain.AddBlockOnChain(source, block) {
+			t.Fatalf("fail to add block")
+		}
+	}
+	blooms := make(map[uint64][]byte)
+	for h := uint64(0); h <= 4; h++ {
+		bh := chain.QueryBlockHeaderByHeight(h)
+		if bh == nil {
+			continue
+		}
+		if bs, _ := chain.bloomDb.Get(bh.Hash.Bytes()); bs != nil {
+			blooms[h] = bs
+		}
+	}
+
+	// Roll back to the database without the blooms
+	reset := func() {
+		for h := range blooms {
+			chain.bloomDb.Delete(chain.QueryBlockHeaderByHeight(h).Hash.Bytes())
+		}
+		chain.blocks.Delete([]byte(schemaVersionKey))
+	}
+	dbs := &migrationDBs{
+		blocks:      chain.blocks,
+		blockHeight: chain.blockHeight,
+		txs:         chain.txDb,
+		receipts:    chain.transactionPool.(*txPool).receiptDb,
+		state:       chain.stateDb,
+		bloom:       chain.bloomDb,
+	}
+	reset()
+
+	stats, err := migrateDatabase(dbs, true, nil)
+	if err != nil || len(stats) != 1 {
+		t.Fatalf("dry run error:%v", err)
+	}
+	if stats[0].Changed != uint64(len(blooms)) {
+		t.Errorf("expect %v blooms reported, got %v", len(blooms), stats[0].Changed)
+	}
+	if v, ok := loadSchemaVersion(chain.blocks); ok {
+		t.Errorf("expect nothing written in the dry run, got version %v", v)
+	}
+
+	// Resume after the block at height 2
+	chain.blocks.Put([]byte(migrationProgressKey), append(append(common.UInt32ToByte(1), common.UInt32ToByte(0)...), common.UInt64ToByte(2)...))
+	stats, err = migrateDatabase(dbs, false, nil)
+	if err != nil || len(stats) != 1 {
+		t.Fatalf("migrate error:%v", err)
+	}
+	for h, bloom := range blooms {
+		bs, _ := chain.bloomDb.Get(chain.QueryBlockHeaderByHeight(h).Hash.Bytes())
+		if h <= 2 && bs != nil {
+			t.Errorf("expect the block at %v skipped on resuming", h)
+		}
+		if h > 2 && !bytes.Equal(bs, bloom) {
+			t.Errorf("unexpected bloom at %v", h)
+		}
+	}
+	if v, _ := loadSchemaVersion(chain.blocks); v != latestSchemaVersion() {
+		t.Errorf("expect the latest version after migrating, got %v", v)
+	}
+	if ok, _ := chain.blocks.Has([]byte(migrationProgressKey)); ok {
+		t.Errorf("expect the progress removed after migrating")
+	}
+
+	// Nothing to do at the latest version
+	if stats, err := migrateDatabase(dbs, false, nil); err != nil || len(stats) != 0 {
+		t.Errorf("expect no migration, got %v %v", stats, err)
+	}
+	chain.blocks.Put([]byte(schemaVersionKey), common.UInt32ToByte(latestSchemaVersion()+1))
+	if _, err := migrateDatabase(dbs, false, nil); err == nil {
+		t.Errorf("expect error for the newer version")
+	}
+}
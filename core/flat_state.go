@@ -0,0 +1,167 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"time"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+	"github.com/zvchain/zvchain/storage/account"
+)
+
+// flatStateCheckInterval is the interval of checking whether the flat state needs to be regenerated or verified
+const flatStateCheckInterval = 10 * time.Minute
+
+// newAccountDB opens the account db of the state root, which reads from the flat state if available
+func (chain *FullBlockChain) newAccountDB(root common.Hash) (*account.AccountDB, error) {
+	state, err := account.NewAccountDB(root, chain.stateCache)
+	if err != nil {
+		return nil, err
+	}
+	if chain.flats != nil {
+		if reader := chain.flats.Reader(root); reader != nil {
+			state.UseFlat(reader)
+		}
+	}
+	return state, nil
+}
+
+// recordDiff returns whether recording the account changes when executing blocks
+func (chain *FullBlockChain) recordDiff() bool {
+	return chain.stateDiffs != nil || chain.flats != nil
+}
+
+// commitFlatState adds the diff layer of the block committed, and flattens the layers more than
+// TriesInMemory blocks below into the disk layer. The flat state is regenerated if the diff can't be applied,
+// or by the checking routine if it's broken by a failed generation
+func (chain *FullBlockChain) commitFlatState(bh *types.BlockHeader, diff []*account.AccountDiff) {
+	// The genesis state is generated on startup
+	if bh.Height == 0 {
+		return
+	}
+	pre := chain.queryBlockHeaderByHash(bh.PreHash)
+	if pre == nil || diff == nil {
+		Logger.Warnf("no diff of the flat state at %v, regenerate", bh.Height)
+		chain.regenerateFlatState(bh.StateTree)
+		return
+	}
+	if err := chain.flats.Update(bh.StateTree, pre.StateTree, diff); err == account.ErrFlatUnavailable {
+		return
+	} else if err != nil {
+		Logger.Warnf("update flat state at %v error:%v, regenerate", bh.Height, err)
+		chain.regenerateFlatState(bh.StateTree)
+		return
+	}
+	if err := chain.flats.Cap(bh.StateTree, int(TriesInMemory)); err != nil {
+		Logger.Errorf("flatten flat state at %v error:%v", bh.Height, err)
+	}
+}
+
+// regenerateFlatState rebuilds the flat state from the state trie of the root in background
+func (chain *FullBlockChain) regenerateFlatState(root common.Hash) {
+	go func() {
+		begin := time.Now()
+		if err := chain.flats.Generate(root, chain.stateCache); err != nil {
+			Logger.Errorf("generate flat state at root %v error:%v", root.Hex(), err)
+			return
+		}
+		Logger.Infof("flat state generated at root %v, cost %v", root.Hex(), time.Since(begin))
+	}()
+}
+
+// startFlatState starts the routine which regenerates the flat state if it's broken or doesn't match the
+// top block, and verifies it against the state trie periodically if configured
+func (chain *FullBlockChain) startFlatState() {
+	top := chain.getLatestBlock()
+	if root, ready := chain.flats.DiskRoot(); !ready || root != top.StateTree {
+		chain.regenerateFlatState(top.StateTree)
+	}
+	quit := chain.flatQuit
+	go func() {
+		tc := time.NewTicker(flatStateCheckInterval)
+		defer tc.Stop()
+		var lastVerify time.Time
+		for {
+			if chain.config.flatStateVerify && time.Since(lastVerify) >= chain.config.flatStateVerifyInterval {
+				lastVerify = time.Now()
+				chain.verifyFlatState()
+			}
+			select {
+			case <-tc.C:
+				chain.checkFlatState()
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
+// checkFlatState regenerates the flat state at the top if it's broken and no generation running
+func (chain *FullBlockChain) checkFlatState() {
+	if _, ready := chain.flats.DiskRoot(); ready || chain.flats.Generating() {
+		return
+	}
+	root := chain.getLatestBlock().StateTree
+	Logger.Warnf("flat state broken, regenerate at root %v", root.Hex())
+	begin := time.Now()
+	if err := chain.flats.Generate(root, chain.stateCache); err != nil {
+		Logger.Errorf("generate flat state at root %v error:%v", root.Hex(), err)
+		return
+	}
+	Logger.Infof("flat state generated at root %v, cost %v", root.Hex(), time.Since(begin))
+}
+
+// verifyFlatState verifies the disk layer of the flat state against the state trie, and regenerates
+// the flat state if mismatched
+func (chain *FullBlockChain) verifyFlatState() {
+	root, ready := chain.flats.DiskRoot()
+	if !ready {
+		return
+	}
+	begin := time.Now()
+	err := chain.flats.Verify(root, chain.stateCache)
+	if err == account.ErrFlatUnavailable {
+		Logger.Infof("flat state at root %v flattened during verifying, skipped", root.Hex())
+		return
+	}
+	if err != nil {
+		top := chain.getLatestBlock().StateTree
+		Logger.Errorf("verify flat state at root %v error:%v, regenerate at %v", root.Hex(), err, top.Hex())
+		if err := chain.flats.Generate(top, chain.stateCache); err != nil {
+			Logger.Errorf("generate flat state at root %v error:%v", top.Hex(), err)
+		}
+		return
+	}
+	Logger.Infof("flat state verified at root %v, cost %v", root.Hex(), time.Since(begin))
+}
+
+// closeFlatState flattens all the diff layers below the top into the disk layer, so the flat state
+// is usable on the next startup
+func (chain *FullBlockChain) closeFlatState() {
+	if chain.flatQuit != nil {
+		close(chain.flatQuit)
+		chain.flatQuit = nil
+	}
+	chain.flats.Close()
+	top := chain.getLatestBlock()
+	if top == nil {
+		return
+	}
+	if err := chain.flats.Cap(top.StateTree, 0); err != nil {
+		Logger.Errorf("flatten flat state on close error:%v", err)
+	}
+}
@@ -0,0 +1,99 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+	"github.com/zvchain/zvchain/storage/account"
+)
+
+func TestBlockChain_FlatState(t *testing.T) {
+	common.InitConf("../tas_config_all.ini")
+	common.GlobalConf.SetBool(configSec, "flat_state", true)
+	defer common.GlobalConf.SetBool(configSec, "flat_state", false)
+
+	err := initContext4Test(t)
+	defer clearSelf(t)
+	if err != nil {
+		t.Fatalf("failed to initContext4Test")
+	}
+	if BlockChainImpl.flats == nil {
+		t.Fatalf("flat state not enabled")
+	}
+	// The state committed without the diff is regenerated in background
+	initBalance()
+	waitReady := func(root common.Hash) {
+		for i := 0; i < 100; i++ {
+			if disk, ready := BlockChainImpl.flats.DiskRoot(); ready && disk == root {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		t.Fatalf("flat state not generated at %v", root.Hex())
+	}
+	base := BlockChainImpl.QueryTopBlock().StateTree
+	waitReady(base)
+
+	pool := BlockChainImpl.transactionPool.(*txPool)
+	tx := genTestTx(500, "100", 1, 1)
+	if _, err := pool.AddTransaction(tx); err != nil {
+		t.Fatalf("fail to AddTransaction %v", err)
+	}
+	for h := uint64(2); h <= 4; h++ {
+		block := BlockChainImpl.CastBlock(h, common.Hex2Bytes("12"), 0, nil, common.Hash{})
+		if block == nil {
+			t.Fatalf("fail to cast new block")
+		}
+		if types.AddBlockSucc != BlockChainImpl.AddBlockOnChain(source, block) {
+			t.Fatalf("fail to add block")
+		}
+	}
+	top := BlockChainImpl.QueryTopBlock()
+	if BlockChainImpl.flats.Reader(top.StateTree) == nil {
+		t.Fatalf("expect the diff layer of the top block")
+	}
+	if disk, _ := BlockChainImpl.flats.DiskRoot(); disk != base {
+		t.Errorf("expect the latest blocks kept in the diff layers")
+	}
+	if err := BlockChainImpl.flats.Verify(top.StateTree, BlockChainImpl.stateCache); err != nil {
+		t.Fatalf("verify the diff layers error:%v", err)
+	}
+
+	state, err := BlockChainImpl.LatestAccountDB()
+	if err != nil {
+		t.Fatalf("latest account db error:%v", err)
+	}
+	target := common.BytesToAddress(genHash("100"))
+	trieState, _ := account.NewAccountDB(top.StateTree, BlockChainImpl.stateCache)
+	if state.GetBalance(target).Cmp(trieState.GetBalance(target)) != 0 || state.GetNonce(*tx.Source) != trieState.GetNonce(*tx.Source) {
+		t.Errorf("unexpected account read from the flat state")
+	}
+
+	// Flatten all into the disk layer
+	if err := BlockChainImpl.flats.Cap(top.StateTree, 0); err != nil {
+		t.Fatalf("cap error:%v", err)
+	}
+	if disk, ready := BlockChainImpl.flats.DiskRoot(); !ready || disk != top.StateTree {
+		t.Fatalf("expect the disk layer at the top")
+	}
+	if err := BlockChainImpl.flats.Verify(top.StateTree, BlockChainImpl.stateCache); err != nil {
+		t.Fatalf("verify the disk layer error:%v", err)
+	}
+}
@@ -682,8 +682,12 @@ func unmarshalFindAncestorBlockResponseMsg(b []byte) (*findAncestorBlockResponse
 	}
 	topHeader := types.PbToBlockHeader(message.TopHeader)
 	blocks := make([]*types.Block, 0)
-	for _, b := range message.Blocks {
-		blocks = append(blocks, types.PbToBlock(b))
+	for _, pb := range message.Blocks {
+		b, e := types.PbToBlock(pb)
+		if e != nil {
+			return nil, e
+		}
+		blocks = append(blocks, b)
 	}
 	cpb := findAncestorBlockResponse{TopHeader: topHeader, Blocks: blocks, FindAncestor: *message.FindAncestor}
 	return &cpb, nil
@@ -0,0 +1,133 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/zvchain/zvchain/middleware/types"
+	"github.com/zvchain/zvchain/params"
+)
+
+// ExecutionError is returned when the transaction fails regardless of the gas limit
+type ExecutionError struct {
+	Status types.ReceiptStatus
+	Err    error
+}
+
+func (e *ExecutionError) Error() string {
+	return fmt.Sprintf("execution failed with receipt status %v: %v", e.Status, e.Err)
+}
+
+// EstimateGas returns the minimal gas limit with which the transaction can be executed successfully
+// on the latest state. The gas limit of the transaction, if set, is used as the upper bound of the search.
+// The nonce of the transaction is ignored and the gas price is set to the lowest accepted one if not given.
+// The given transaction is left unchanged.
+func (chain *FullBlockChain) EstimateGas(tx *types.Transaction) (uint64, error) {
+	if tx.Source == nil {
+		return 0, fmt.Errorf("source is nil")
+	}
+	// Work on a copy, the fields are filled and the gas limit is changed while searching
+	raw := *tx.RawTransaction
+	tx = types.NewTransaction(&raw, tx.Hash)
+	if tx.Value == nil {
+		tx.Value = types.NewBigInt(0)
+	}
+	top, state, err := chain.stateAt(chain.Height())
+	if err != nil {
+		return 0, err
+	}
+	// Execute on the block next to the top
+	header := &types.BlockHeader{
+		Height:  top.Height + 1,
+		PreHash: top.Hash,
+		CurTime: top.CurTime,
+		Castor:  top.Castor,
+	}
+	if tx.GasPrice == nil || tx.GasPrice.Sign() == 0 {
+		tx.GasPrice = types.NewBigInt(minGasPrice(header.Height))
+	}
+	tx.Nonce = state.GetNonce(*tx.Source) + 1
+	if tx.ChainId == nil {
+		tx.ChainId = params.GetChainConfig().TxChainId(header.Height)
+	}
+
+	lo := intrinsicGas(tx).Uint64() - 1
+	hi := uint64(gasLimitPerTransaction)
+	if tx.GasLimit != nil && tx.GasLimit.IsUint64() && tx.GasLimit.Uint64() > lo && tx.GasLimit.Uint64() < hi {
+		hi = tx.GasLimit.Uint64()
+	}
+	if lo >= hi {
+		return 0, fmt.Errorf("intrinsic gas %v exceeds the gas limit %v", lo+1, hi)
+	}
+	// Cap the upper bound to the gas the source can afford
+	if tx.GasPrice.Sign() > 0 {
+		available := new(big.Int).Set(state.GetBalance(*tx.Source))
+		if tx.Value.Sign() > 0 {
+			available.Sub(available, tx.Value.Value())
+		}
+		allowance := available.Div(available, tx.GasPrice.Value())
+		if allowance.IsUint64() && allowance.Uint64() < hi {
+			hi = allowance.Uint64()
+		}
+		if hi <= lo {
+			return 0, fmt.Errorf("balance not enough for paying gas and value, %v", tx.Source.AddrPrefixString())
+		}
+	}
+
+	// The tvm controller is shared with the block executing, so lock the chain
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+
+	// executable tries to execute the transaction with the given gas limit
+	executable := func(gas uint64) (*result, error) {
+		tx.GasLimit = types.NewBigInt(gas)
+		db, err := chain.newAccountDB(top.StateTree)
+		if err != nil {
+			return nil, err
+		}
+		return applyStateTransition(db, tx, header)
+	}
+
+	// Make sure the transaction can succeed with the upper bound
+	ret, err := executable(hi)
+	if err != nil {
+		return 0, err
+	}
+	if ret.err != nil {
+		if ret.transitionStatus == types.RSGasNotEnoughError {
+			return 0, fmt.Errorf("gas required exceeds allowance %v", hi)
+		}
+		return 0, &ExecutionError{Status: ret.transitionStatus, Err: ret.err}
+	}
+
+	// Binary search the minimal gas limit that makes the transaction succeed.
+	// Only the failed execution means the gas too low, other errors are returned
+	for lo+1 < hi {
+		mid := (lo + hi) / 2
+		ret, err := executable(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ret.err == nil {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return hi, nil
+}
@@ -0,0 +1,64 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+)
+
+func TestEstimateGasTransfer(t *testing.T) {
+	err := initContext4Test(t)
+	if err != nil {
+		t.Fatalf("init fail:%v", err)
+	}
+	initBalance()
+	defer clearSelf(t)
+
+	tx := genTestTx(500, "1", 1, 1)
+	gasLimit := tx.GasLimit.Uint64()
+	gas, err := BlockChainImpl.EstimateGas(tx)
+	if err != nil {
+		t.Fatalf("estimate gas error:%v", err)
+	}
+	if tx.GasLimit.Uint64() != gasLimit {
+		t.Errorf("gas limit of the transaction changed to %v, expect %v", tx.GasLimit.Uint64(), gasLimit)
+	}
+	if gas != intrinsicGas(tx).Uint64() {
+		t.Errorf("estimated gas %v, expect the intrinsic gas %v", gas, intrinsicGas(tx).Uint64())
+	}
+}
+
+func TestEstimateGasBalanceNotEnough(t *testing.T) {
+	err := initContext4Test(t)
+	if err != nil {
+		t.Fatalf("init fail:%v", err)
+	}
+	defer clearSelf(t)
+
+	source := common.BytesToAddress([]byte{1})
+	target := common.BytesToAddress([]byte{2})
+	tx := types.NewTransaction(&types.RawTransaction{
+		Source: &source,
+		Target: &target,
+		Value:  types.NewBigInt(1),
+	}, common.Hash{})
+	if _, err := BlockChainImpl.EstimateGas(tx); err == nil {
+		t.Fatalf("estimate gas should fail when balance not enough")
+	}
+}
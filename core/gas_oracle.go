@@ -0,0 +1,241 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+)
+
+const (
+	defaultOracleBlocks      = 20   // default count of the recent blocks sampled for suggesting the gas price
+	maxFeeHistoryBlocks      = 1024 // max count of the blocks can be queried in one fee history request
+	maxFeeHistoryPercentiles = 100  // max count of the percentiles in one fee history request
+
+	slowPercentile   = 30
+	normalPercentile = 60
+	fastPercentile   = 90
+)
+
+// GasPriceSuggestion is the gas price suggested for the transactions according to the recent blocks and the pool
+type GasPriceSuggestion struct {
+	Slow        uint64 // Price likely to be packed in a while
+	Normal      uint64 // Price likely to be packed in the next few blocks
+	Fast        uint64 // Price likely to be packed in the next block
+	Lowest      uint64 // Lowest price accepted by the pool and the chain
+	TopHeight   uint64 // Height of the top block the suggestion is based on
+	SampleCount int    // Count of the gas prices sampled
+}
+
+// BlockFeeHistory is the gas price distribution of the transactions in one block
+type BlockFeeHistory struct {
+	Height      uint64
+	Hash        common.Hash
+	TxCount     int      // Count of the non-reward transactions
+	Percentiles []uint64 // Gas prices at the requested percentiles, all zero if no transaction
+}
+
+// FeeHistory is the gas price distribution of the recent blocks and the pending transactions in the pool
+type FeeHistory struct {
+	Blocks  []*BlockFeeHistory // Ordered by height ascending
+	Pending []uint64           // Gas prices of the pending transactions at the requested percentiles
+}
+
+// gasPriceOracle samples the gas prices of the transactions in the recent blocks and the pending ones in the pool.
+// The suggestion is cached until the top block changes
+type gasPriceOracle struct {
+	chain  *FullBlockChain
+	blocks int
+
+	blockPrices *lru.Cache // Sorted gas prices of the block keyed by the block hash
+
+	lock       sync.Mutex
+	lastTop    common.Hash
+	suggestion *GasPriceSuggestion
+}
+
+func newGasPriceOracle(chain *FullBlockChain) *gasPriceOracle {
+	blocks := common.GlobalConf.GetInt(configSec, "gas_oracle_blocks", defaultOracleBlocks)
+	if blocks <= 0 {
+		blocks = defaultOracleBlocks
+	}
+	return &gasPriceOracle{
+		chain:       chain,
+		blocks:      blocks,
+		blockPrices: common.MustNewLRUCache(maxFeeHistoryBlocks),
+	}
+}
+
+// SuggestGasPrice returns the suggested gas prices based on the recent blocks and the pending transactions
+func (chain *FullBlockChain) SuggestGasPrice() *GasPriceSuggestion {
+	return chain.gasOracle.suggest()
+}
+
+// FeeHistory returns the gas prices at the given percentiles of the latest blocks and the pending transactions
+func (chain *FullBlockChain) FeeHistory(blocks int, percentiles []float64) (*FeeHistory, error) {
+	return chain.gasOracle.feeHistory(blocks, percentiles)
+}
+
+func (o *gasPriceOracle) suggest() *GasPriceSuggestion {
+	top := o.chain.QueryTopBlock()
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.suggestion != nil && o.lastTop == top.Hash {
+		return o.suggestion
+	}
+
+	prices := make([]uint64, 0)
+	for h, count := top.Height, 0; count < o.blocks; count++ {
+		bh := o.chain.QueryBlockHeaderByHeight(h)
+		if bh != nil {
+			prices = append(prices, o.pricesOf(bh)...)
+		}
+		if h == 0 {
+			break
+		}
+		h--
+	}
+	prices = append(prices, o.chain.transactionPool.(*txPool).received.pendingPrices()...)
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i] < prices[j]
+	})
+
+	lowest := minGasPrice(top.Height + 1)
+	if bound := o.chain.transactionPool.(*txPool).gasPriceLowerBound.Uint64(); bound > lowest {
+		lowest = bound
+	}
+	atLeast := func(price uint64) uint64 {
+		if price < lowest {
+			return lowest
+		}
+		return price
+	}
+	s := &GasPriceSuggestion{
+		Slow:        atLeast(percentile(prices, slowPercentile)),
+		Normal:      atLeast(percentile(prices, normalPercentile)),
+		Fast:        atLeast(percentile(prices, fastPercentile)),
+		Lowest:      lowest,
+		TopHeight:   top.Height,
+		SampleCount: len(prices),
+	}
+	o.lastTop = top.Hash
+	o.suggestion = s
+	return s
+}
+
+func (o *gasPriceOracle) feeHistory(blocks int, percentiles []float64) (*FeeHistory, error) {
+	if blocks <= 0 || blocks > maxFeeHistoryBlocks {
+		return nil, fmt.Errorf("block count should be in range [1, %v]", maxFeeHistoryBlocks)
+	}
+	if len(percentiles) > maxFeeHistoryPercentiles {
+		return nil, fmt.Errorf("at most %v percentiles allowed", maxFeeHistoryPercentiles)
+	}
+	for i, p := range percentiles {
+		if p < 0 || p > 100 {
+			return nil, fmt.Errorf("percentile %v out of range [0, 100]", p)
+		}
+		if i > 0 && p < percentiles[i-1] {
+			return nil, fmt.Errorf("percentiles should be in ascending order")
+		}
+	}
+	top := o.chain.QueryTopBlock()
+	history := &FeeHistory{Blocks: make([]*BlockFeeHistory, 0, blocks)}
+	for h, count := top.Height, 0; count < blocks; count++ {
+		// Heights without block are skipped
+		if bh := o.chain.QueryBlockHeaderByHeight(h); bh != nil {
+			prices := o.pricesOf(bh)
+			history.Blocks = append(history.Blocks, &BlockFeeHistory{
+				Height:      bh.Height,
+				Hash:        bh.Hash,
+				TxCount:     len(prices),
+				Percentiles: percentiles2Prices(prices, percentiles),
+			})
+		}
+		if h == 0 {
+			break
+		}
+		h--
+	}
+	for i, j := 0, len(history.Blocks)-1; i < j; i, j = i+1, j-1 {
+		history.Blocks[i], history.Blocks[j] = history.Blocks[j], history.Blocks[i]
+	}
+	pending := o.chain.transactionPool.(*txPool).received.pendingPrices()
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i] < pending[j]
+	})
+	history.Pending = percentiles2Prices(pending, percentiles)
+	return history, nil
+}
+
+// pricesOf returns the sorted gas prices of the non-reward transactions in the block
+func (o *gasPriceOracle) pricesOf(bh *types.BlockHeader) []uint64 {
+	if v, ok := o.blockPrices.Get(bh.Hash); ok {
+		return v.([]uint64)
+	}
+	o.chain.rwLock.RLock()
+	txs := o.chain.queryBlockTransactionsAll(bh.Hash)
+	o.chain.rwLock.RUnlock()
+
+	prices := make([]uint64, 0)
+	for _, tx := range txs {
+		if tx.IsReward() || tx.GasPrice == nil {
+			continue
+		}
+		prices = append(prices, tx.GasPrice.Uint64())
+	}
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i] < prices[j]
+	})
+	o.blockPrices.Add(bh.Hash, prices)
+	return prices
+}
+
+// pendingPrices returns the gas prices of the pending transactions
+func (c *simpleContainer) pendingPrices() []uint64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	prices := make([]uint64, 0, c.pending.size)
+	for _, list := range c.pending.waitingMap {
+		for iter := list.IterAtPosition(0); iter.Next(); {
+			prices = append(prices, iter.Value().(*orderByNonceTx).item.GasPrice.Uint64())
+		}
+	}
+	return prices
+}
+
+// percentile returns the value at the given percentile of the sorted values, 0 if empty
+func percentile(sorted []uint64, p float64) uint64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(float64(len(sorted)-1) * p / 100)
+	return sorted[idx]
+}
+
+func percentiles2Prices(sorted []uint64, percentiles []float64) []uint64 {
+	ret := make([]uint64, len(percentiles))
+	for i, p := range percentiles {
+		ret[i] = percentile(sorted, p)
+	}
+	return ret
+}
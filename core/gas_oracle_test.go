@@ -0,0 +1,91 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/zvchain/zvchain/middleware/types"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+	cases := map[float64]uint64{0: 1, 30: 4, 50: 6, 90: 10, 100: 11}
+	for p, expect := range cases {
+		if got := percentile(sorted, p); got != expect {
+			t.Errorf("percentile %v expect %v but got %v", p, expect, got)
+		}
+	}
+	if percentile(nil, 50) != 0 {
+		t.Errorf("percentile of empty should be 0")
+	}
+}
+
+func TestGasPriceOracle(t *testing.T) {
+	err := initContext4Test(t)
+	defer clearSelf(t)
+	if err != nil {
+		t.Fatalf("failed to initContext4Test")
+	}
+
+	lowest := minGasPrice(BlockChainImpl.Height() + 1)
+	s := BlockChainImpl.SuggestGasPrice()
+	if s.SampleCount != 0 || s.Slow != lowest || s.Fast != lowest {
+		t.Errorf("expect the lowest price suggested without samples, got %+v", s)
+	}
+
+	pool := BlockChainImpl.transactionPool.(*txPool)
+	prices := []uint64{lowest * 10, lowest * 20, lowest * 30, lowest * 40, lowest * 50}
+	for i, base := range []*types.Transaction{tx1, tx3, tx4, tx8, tx13} {
+		tx := genTx4Test(base.Hash.Hex(), 1, types.NewBigInt(prices[i]), gasLimit, base.Source)
+		if err := pool.received.push(tx); err != nil {
+			t.Fatalf("push error:%v", err)
+		}
+	}
+	// Cached until the top block changes
+	if cached := BlockChainImpl.SuggestGasPrice(); cached != s {
+		t.Errorf("suggestion should be cached on the same top block")
+	}
+	BlockChainImpl.gasOracle.suggestion = nil
+	s = BlockChainImpl.SuggestGasPrice()
+	if s.SampleCount != 5 || s.Slow != prices[1] || s.Normal != prices[2] || s.Fast != prices[3] {
+		t.Errorf("unexpected suggestion %+v", s)
+	}
+
+	history, err := BlockChainImpl.FeeHistory(10, []float64{0, 50, 100})
+	if err != nil {
+		t.Fatalf("fee history error:%v", err)
+	}
+	if len(history.Blocks) != int(BlockChainImpl.Height()+1) || history.Blocks[0].Height != 0 {
+		t.Errorf("unexpected blocks %v", len(history.Blocks))
+	}
+	if history.Pending[0] != prices[0] || history.Pending[1] != prices[2] || history.Pending[2] != prices[4] {
+		t.Errorf("unexpected pending percentiles %v", history.Pending)
+	}
+
+	if _, err := BlockChainImpl.FeeHistory(0, nil); err == nil {
+		t.Errorf("expect error for zero block count")
+	}
+	if _, err := BlockChainImpl.FeeHistory(1, []float64{50, 10}); err == nil {
+		t.Errorf("expect error for unordered percentiles")
+	}
+	if _, err := BlockChainImpl.FeeHistory(1, []float64{101}); err == nil {
+		t.Errorf("expect error for percentile out of range")
+	}
+	if _, err := BlockChainImpl.FeeHistory(1, make([]float64, maxFeeHistoryPercentiles+1)); err == nil {
+		t.Errorf("expect error for too many percentiles")
+	}
+}
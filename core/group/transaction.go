@@ -23,6 +23,7 @@ import (
 	"github.com/vmihailenco/msgpack"
 	"github.com/zvchain/zvchain/common"
 	"github.com/zvchain/zvchain/middleware/types"
+	"github.com/zvchain/zvchain/params"
 )
 
 type PacketSender struct {
@@ -130,6 +131,7 @@ func (p *PacketSender) toTx(source common.Address, data []byte, txType int8) (*t
 	raw.GasLimit = p.baseGasLimit
 	raw.Source = &source
 	raw.Nonce = db.GetNonce(source) + 1
+	raw.ChainId = params.GetChainConfig().TxChainId(p.chain.Height() + 1)
 	tx := types.NewTransaction(raw, raw.GenHash())
 	sk := common.HexToSecKey(p.chain.MinerSk())
 	if sk == nil {
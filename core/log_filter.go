@@ -0,0 +1,154 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+)
+
+// LogFilter defines the conditions of the logs query.
+// If BlockHash is set, only the logs in the block are queried and the height range is ignored.
+// Empty Addresses or Topics matches any address or topic
+type LogFilter struct {
+	BlockHash  *common.Hash
+	FromHeight uint64
+	ToHeight   uint64
+	Addresses  []common.Address
+	Topics     []common.Hash
+}
+
+// bloomMatch checks whether the logs matching the filter may be contained in the given bloom
+func (f *LogFilter) bloomMatch(bloom types.Bloom) bool {
+	if len(f.Addresses) > 0 {
+		included := false
+		for _, addr := range f.Addresses {
+			if types.BloomLookup(bloom, addr) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+	if len(f.Topics) > 0 {
+		included := false
+		for _, topic := range f.Topics {
+			if types.BloomLookup(bloom, topic) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+	return true
+}
+
+// match checks whether the given log matches the filter
+func (f *LogFilter) match(log *types.Log) bool {
+	if len(f.Addresses) > 0 {
+		included := false
+		for _, addr := range f.Addresses {
+			if log.Address == addr {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+	if len(f.Topics) > 0 {
+		included := false
+		for _, topic := range f.Topics {
+			if log.Topic == topic {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+	return true
+}
+
+// GetLogs returns the logs on the main chain matching the given filter.
+// The per-block bloom is used to skip the blocks without any matched log.
+// Error returned if more than limit logs matched
+func (chain *FullBlockChain) GetLogs(filter *LogFilter, limit int) ([]*types.Log, error) {
+	logs := make([]*types.Log, 0)
+	if filter.BlockHash != nil {
+		matched, err := chain.filterBlockLogs(*filter.BlockHash, filter, limit)
+		if err != nil {
+			return nil, err
+		}
+		return append(logs, matched...), nil
+	}
+	if filter.FromHeight > filter.ToHeight {
+		return nil, fmt.Errorf("from height %v is greater than to height %v", filter.FromHeight, filter.ToHeight)
+	}
+	for h := filter.FromHeight; h <= filter.ToHeight; h++ {
+		hash := chain.queryBlockHash(h)
+		if hash == nil {
+			continue
+		}
+		matched, err := chain.filterBlockLogs(*hash, filter, limit-len(logs))
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, matched...)
+		// Avoid overflow when ToHeight is the max uint64
+		if h == filter.ToHeight {
+			break
+		}
+	}
+	return logs, nil
+}
+
+// filterBlockLogs returns the logs of the given block matching the filter.
+// Blocks committed before the bloom stored are checked through the receipts directly
+func (chain *FullBlockChain) filterBlockLogs(hash common.Hash, filter *LogFilter, limit int) ([]*types.Log, error) {
+	if bloom, ok := chain.queryBlockBloom(hash); ok && !filter.bloomMatch(bloom) {
+		return nil, nil
+	}
+	chain.rwLock.RLock()
+	txs := chain.queryBlockTransactionsAll(hash)
+	chain.rwLock.RUnlock()
+
+	logs := make([]*types.Log, 0)
+	for _, tx := range txs {
+		receipt := chain.transactionPool.GetReceipt(tx.GenHash())
+		if receipt == nil {
+			continue
+		}
+		for _, log := range receipt.Logs {
+			if !filter.match(log) {
+				continue
+			}
+			if len(logs) >= limit {
+				return nil, fmt.Errorf("query returns more than %v logs", limit)
+			}
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
@@ -0,0 +1,55 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+)
+
+func TestLogFilterMatch(t *testing.T) {
+	addr1 := common.BytesToAddress([]byte{1})
+	addr2 := common.BytesToAddress([]byte{2})
+	topic1 := common.BytesToHash([]byte{1})
+	topic2 := common.BytesToHash([]byte{2})
+
+	log := &types.Log{Address: addr1, Topic: topic1}
+	bloom := types.CreateBloom(types.Receipts{&types.Receipt{Logs: []*types.Log{log}}})
+
+	cases := []struct {
+		filter *LogFilter
+		expect bool
+	}{
+		{&LogFilter{}, true},
+		{&LogFilter{Addresses: []common.Address{addr1}}, true},
+		{&LogFilter{Addresses: []common.Address{addr2}}, false},
+		{&LogFilter{Addresses: []common.Address{addr2, addr1}}, true},
+		{&LogFilter{Topics: []common.Hash{topic1}}, true},
+		{&LogFilter{Topics: []common.Hash{topic2}}, false},
+		{&LogFilter{Addresses: []common.Address{addr1}, Topics: []common.Hash{topic2}}, false},
+		{&LogFilter{Addresses: []common.Address{addr1}, Topics: []common.Hash{topic1}}, true},
+	}
+	for i, c := range cases {
+		if c.filter.match(log) != c.expect {
+			t.Errorf("case %v: log match expect %v", i, c.expect)
+		}
+		if c.filter.bloomMatch(bloom) != c.expect {
+			t.Errorf("case %v: bloom match expect %v", i, c.expect)
+		}
+	}
+}
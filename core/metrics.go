@@ -0,0 +1,126 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"time"
+
+	"github.com/zvchain/zvchain/middleware/metrics"
+	"github.com/zvchain/zvchain/middleware/types"
+)
+
+var (
+	blockAddSeconds = metrics.NewHistogram("gzv_chain_block_add_seconds", "Latency of adding a block on chain", nil)
+	blockAddTotal   = metrics.NewCounterVec("gzv_chain_block_add_total", "Number of the blocks tried to add on chain by result", "result")
+)
+
+func addBlockResultName(ret types.AddBlockResult) string {
+	switch ret {
+	case types.AddBlockSucc:
+		return "success"
+	case types.AddBlockExisted:
+		return "existed"
+	case types.AddBlockLessWeightThanLocal:
+		return "less_weight"
+	case types.AddBlockConsensusFailed:
+		return "consensus_failed"
+	}
+	return "failed"
+}
+
+func observeAddBlock(ret types.AddBlockResult, begin time.Time) {
+	blockAddTotal.With(addBlockResultName(ret)).Inc()
+	if ret == types.AddBlockSucc {
+		blockAddSeconds.Observe(time.Since(begin).Seconds())
+	}
+}
+
+// registerMetrics registers the metrics read from the chain when collected
+func (chain *FullBlockChain) registerMetrics() {
+	metrics.NewGaugeFunc("gzv_chain_height", "Height of the latest block", func() float64 {
+		return float64(chain.Height())
+	})
+	metrics.NewGaugeFunc("gzv_chain_total_qn", "Total qn of the latest block", func() float64 {
+		return float64(chain.TotalQN())
+	})
+	metrics.NewGaugeFunc("gzv_txpool_pending", "Number of the executable transactions in the pool", func() float64 {
+		return float64(chain.transactionPool.TxNum())
+	})
+	metrics.NewGaugeFunc("gzv_txpool_queued", "Number of the transactions waiting for the nonce gaps in the pool", func() float64 {
+		return float64(chain.transactionPool.TxQueueNum())
+	})
+	metrics.NewGaugeFunc("gzv_fork_processing", "Whether the fork processor is processing a fork", func() float64 {
+		if chain.forkProcessor.processing() {
+			return 1
+		}
+		return 0
+	})
+	metrics.NewGaugeFunc("gzv_fork_target_height", "Height of the top block of the fork being processed, 0 if not processing", func() float64 {
+		return float64(chain.forkProcessor.targetHeight())
+	})
+	metrics.NewGaugeFunc("gzv_sync_syncing", "Whether the node is syncing blocks from the neighbors", func() float64 {
+		if blockSync != nil && blockSync.isSyncing() {
+			return 1
+		}
+		return 0
+	})
+	metrics.NewGaugeFunc("gzv_sync_candidates", "Number of the neighbors with the top blocks known", func() float64 {
+		if blockSync == nil {
+			return 0
+		}
+		return float64(blockSync.candidateCount())
+	})
+	metrics.NewGaugeFunc("gzv_sync_best_height", "Highest top block of the neighbors", func() float64 {
+		if blockSync == nil {
+			return 0
+		}
+		return float64(blockSync.bestCandidateHeight())
+	})
+	chain.stateDb.RegisterMetrics("gzv_db_chain")
+}
+
+func (fp *forkProcessor) processing() bool {
+	fp.lock.RLock()
+	defer fp.lock.RUnlock()
+	return fp.syncCtx != nil
+}
+
+func (fp *forkProcessor) targetHeight() uint64 {
+	fp.lock.RLock()
+	defer fp.lock.RUnlock()
+	if fp.syncCtx == nil || fp.syncCtx.targetTop == nil {
+		return 0
+	}
+	return fp.syncCtx.targetTop.Height
+}
+
+func (bs *blockSyncer) candidateCount() int {
+	bs.lock.RLock()
+	defer bs.lock.RUnlock()
+	return len(bs.candidatePool)
+}
+
+func (bs *blockSyncer) bestCandidateHeight() uint64 {
+	bs.lock.RLock()
+	defer bs.lock.RUnlock()
+	best := uint64(0)
+	for _, c := range bs.candidatePool {
+		if c.BH != nil && c.BH.Height > best {
+			best = c.BH.Height
+		}
+	}
+	return best
+}
@@ -19,6 +19,7 @@ import (
 	"bytes"
 	"container/heap"
 	"fmt"
+	"math/big"
 	"sort"
 	"sync"
 	"time"
@@ -30,7 +31,10 @@ import (
 	"github.com/zvchain/zvchain/middleware/types"
 )
 
-const maxSyncCountPreSource = 50 // max count of tx with same source to sync to neighbour node
+const (
+	maxSyncCountPreSource = 50 // max count of tx with same source to sync to neighbour node
+	defaultPriceBump      = 10 // default minimum gas price bump in percent for replacing the tx with same nonce
+)
 
 type simpleContainer struct {
 	txsMap     map[common.Hash]*TransactionWithTime
@@ -91,9 +95,22 @@ func (h *priceHeap) Pop() interface{} {
 	return x
 }
 
+// priceBumped checks whether the new gas price is high enough to replace the transaction with the old one,
+// which requires the new price higher than the old one and not lower than the old one bumped by bump percent
+// and rounded down. The cli bumps the price the same way
+func priceBumped(oldPrice, newPrice *types.BigInt, bump uint64) bool {
+	if newPrice.Cmp(oldPrice.Value()) <= 0 {
+		return false
+	}
+	threshold := new(big.Int).Mul(oldPrice.Value(), new(big.Int).SetUint64(100+bump))
+	threshold.Div(threshold, big.NewInt(100))
+	return newPrice.Cmp(threshold) >= 0
+}
+
 type pendingContainer struct {
-	limit int
-	size  int
+	limit     int
+	size      int
+	priceBump uint64 // minimum gas price bump in percent for replacing
 
 	waitingMap map[common.Address]*skip.SkipList //*orderByNonceTx. Map of transactions group by source for waiting
 }
@@ -105,7 +122,7 @@ func (s *pendingContainer) push(tx *types.Transaction, stateNonce uint64) (added
 		existSource := s.waitingMap[*tx.Source].Get(newTxNode)[0]
 
 		if existSource != nil {
-			if existSource.(*orderByNonceTx).item.GasPrice.Cmp(tx.GasPrice.Value()) < 0 {
+			if priceBumped(existSource.(*orderByNonceTx).item.GasPrice, tx.GasPrice, s.priceBump) {
 				//replace the existing one
 				deleted := s.waitingMap[*tx.Source].Delete(existSource)
 				s.size = s.size - len(deleted)
@@ -251,10 +268,11 @@ func newOrderByNonceTx(tx *types.Transaction) *orderByNonceTx {
 	return s
 }
 
-func newPendingContainer(limit int) *pendingContainer {
+func newPendingContainer(limit int, priceBump uint64) *pendingContainer {
 	s := &pendingContainer{
 		limit:      limit,
 		size:       0,
+		priceBump:  priceBump,
 		waitingMap: make(map[common.Address]*skip.SkipList),
 	}
 	return s
@@ -264,12 +282,17 @@ func newSimpleContainer(pendingLimit int, queueLimit int, chain types.BlockChain
 	//timeOutDuration is the max time of a tx can keeped in tx pool, default value is 30 minutes
 	timeOutDuration := common.GlobalConf.GetInt(configSec, "tx_timeout_duration", 60*30)
 	timeout := time.Second * time.Duration(timeOutDuration)
+	// priceBump is the minimum gas price bump in percent for a tx to replace the one with same source and nonce
+	priceBump := common.GlobalConf.GetInt(configSec, "tx_price_bump", defaultPriceBump)
+	if priceBump < 0 {
+		priceBump = 0
+	}
 
 	c := &simpleContainer{
 		lock:       sync.RWMutex{},
 		chain:      chain.(*FullBlockChain),
 		txsMap:     make(map[common.Hash]*TransactionWithTime),
-		pending:    newPendingContainer(pendingLimit),
+		pending:    newPendingContainer(pendingLimit, uint64(priceBump)),
 		queue:      make(map[common.Hash]*types.Transaction),
 		queueLimit: queueLimit,
 		txTimeout:  timeout,
@@ -354,9 +377,11 @@ func (c *simpleContainer) push(tx *types.Transaction) (err error) {
 	}
 	c.txsMap[tx.Hash] = warpTransaction(tx)
 	if evicted != nil {
-		Logger.Debugf("Tx %v replaced by %v as higher gas price when push()", evicted.Hash, tx.Hash)
 		if evicted.Hash == tx.Hash {
-			err = fmt.Errorf("existing a transaction with same nonce: %v", conflicted.Hash.Hex())
+			Logger.Debugf("Tx %v discarded as the gas price not bumped enough to replace %v", tx.Hash, conflicted.Hash)
+			err = ErrReplaceUnderpriced
+		} else {
+			Logger.Debugf("Tx %v replaced by %v as higher gas price when push()", evicted.Hash, tx.Hash)
 		}
 		delete(c.txsMap, evicted.Hash)
 	}
@@ -370,7 +395,7 @@ func (c *simpleContainer) addToQueue(tx *types.Transaction) (evicted *types.Tran
 	}
 	for _, old := range c.queue {
 		if old.Nonce == tx.Nonce && bytes.Equal(old.Source.Bytes(), tx.Source.Bytes()) {
-			if old.GasPrice.Cmp(tx.GasPrice.Value()) >= 0 {
+			if !priceBumped(old.GasPrice, tx.GasPrice, c.pending.priceBump) {
 				evicted = tx
 				conflicted = old
 				return
@@ -479,6 +504,43 @@ func (c *simpleContainer) evictPending() {
 	}
 }
 
+// evictExpired removes the transactions which can't be executed at the given height any more.
+// The pending transactions behind the expired one of the same source are moved back to the queue
+// as the nonce is no longer continuous
+func (c *simpleContainer) evictExpired(height uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, tx := range c.queue {
+		if tx.ExpireHeight != nil && *tx.ExpireHeight < height {
+			Logger.Debugf("Tx %v evicted from queue as expired at %v", tx.Hash, *tx.ExpireHeight)
+			c.removeWithoutLock(tx)
+		}
+	}
+	demotes := make([]*types.Transaction, 0)
+	for _, list := range c.pending.waitingMap {
+		expired := false
+		for iter := list.IterAtPosition(0); iter.Next(); {
+			tx := iter.Value().(*orderByNonceTx).item
+			if tx.ExpireHeight != nil && *tx.ExpireHeight < height {
+				expired = true
+			}
+			if expired {
+				demotes = append(demotes, tx)
+			}
+		}
+	}
+	for _, tx := range demotes {
+		c.pending.remove(tx)
+		if tx.ExpireHeight != nil && *tx.ExpireHeight < height {
+			Logger.Debugf("Tx %v evicted from pending as expired at %v", tx.Hash, *tx.ExpireHeight)
+			delete(c.txsMap, tx.Hash)
+		} else {
+			c.queue[tx.Hash] = tx
+		}
+	}
+}
+
 func (c *simpleContainer) evictTimeout() {
 	c.lock.Lock()
 	defer c.lock.Unlock()
@@ -93,7 +93,7 @@ func execute(t *testing.T, tx types.Transaction) {
 }
 
 func Test_push(t *testing.T) {
-	t1 := genTx4Test("d3b14a7bab3c68e9369d0e433e5be9a514e843593f0f149cb0906e7bc085d881", 1, types.NewBigInt(20000), gasLimit, &addr1)
+	t1 := genTx4Test("d3b14a7bab3c68e9369d0e433e5be9a514e843593f0f149cb0906e7bc085d881", 1, types.NewBigInt(22000), gasLimit, &addr1)
 	t2 := genTx4Test("d3b14a7bab3c68e9369d0e433e5be9a514e843593f0f149cb0906e7bc085d882", 1, types.NewBigInt(19999), gasLimit, &addr1)
 	t3 := genTx4Test("d3b14a7bab3c68e9369d0e433e5be9a514e843593f0f149cb0906e7bc085d883", 2, types.NewBigInt(20000), gasLimit, &addr1)
 
@@ -263,6 +263,98 @@ func TestEvicted(t *testing.T) {
 	checkPendingSize(t)
 }
 
+func TestEvictExpired(t *testing.T) {
+	err := initContext4Test(t)
+	defer clearSelf(t)
+	if err != nil {
+		t.Fatalf("failed to initContext4Test")
+	}
+
+	container = newSimpleContainer(200, 80, BlockChainImpl)
+	txs := make([]*types.Transaction, 0)
+	for i := 1; i <= 5; i++ {
+		tx := genTx4Test("ab454fdea57373b25b150497e016fcfdc06b55a66518e3756305e46f3dda7ee"+strconv.Itoa(i), uint64(i), types.NewBigInt(20000), gasLimit, &addr1)
+		txs = append(txs, tx)
+	}
+	expire := uint64(10)
+	txs[2].ExpireHeight = &expire
+	// Nonce gap, pushed to the queue
+	queued := genTx4Test("ab454fdea57373b25b150497e016fcfdc06b55a66518e3756305e46f3dda7dd1", 3, types.NewBigInt(20000), gasLimit, &addr2)
+	queued.ExpireHeight = &expire
+	for _, tx := range append(txs, queued) {
+		if err := container.push(tx); err != nil {
+			t.Fatalf("push error:%v", err)
+		}
+	}
+
+	container.evictExpired(expire)
+	if container.Len() != 6 {
+		t.Fatalf("no tx should be evicted before expired, got %v", container.Len())
+	}
+
+	container.evictExpired(expire + 1)
+	checkPendingSize(t)
+	if container.get(txs[2].Hash) != nil || container.get(queued.Hash) != nil {
+		t.Errorf("expired txs should be evicted")
+	}
+	if container.pending.size != 2 {
+		t.Errorf("pending size expect 2 but got %v", container.pending.size)
+	}
+	// The txs behind the expired one should wait in the queue
+	if len(container.queue) != 2 || container.queue[txs[3].Hash] == nil || container.queue[txs[4].Hash] == nil {
+		t.Errorf("txs behind the expired one should be moved to the queue")
+	}
+
+	// Fill the gap and the queued ones can be promoted again
+	if err := container.push(genTx4Test("ab454fdea57373b25b150497e016fcfdc06b55a66518e3756305e46f3dda7ff3", 3, types.NewBigInt(20000), gasLimit, &addr1)); err != nil {
+		t.Fatalf("push error:%v", err)
+	}
+	container.promoteQueueToPending()
+	if container.pending.size != 5 || len(container.queue) != 0 {
+		t.Errorf("promote error, pending size %v, queue size %v", container.pending.size, len(container.queue))
+	}
+	checkPendingSize(t)
+}
+
+func TestReplaceByPriceBump(t *testing.T) {
+	err := initContext4Test(t)
+	defer clearSelf(t)
+	if err != nil {
+		t.Fatalf("failed to initContext4Test")
+	}
+
+	container = newSimpleContainer(200, 80, BlockChainImpl)
+	pending := genTx4Test("ab454fdea57373b25b150497e016fcfdc06b55a66518e3756305e46f3dda7e01", 1, types.NewBigInt(20000), gasLimit, &addr1)
+	queued := genTx4Test("ab454fdea57373b25b150497e016fcfdc06b55a66518e3756305e46f3dda7e03", 3, types.NewBigInt(20000), gasLimit, &addr1)
+	for _, tx := range []*types.Transaction{pending, queued} {
+		if err := container.push(tx); err != nil {
+			t.Fatalf("push error:%v", err)
+		}
+	}
+
+	for i, old := range []*types.Transaction{pending, queued} {
+		prefix := "ab454fdea57373b25b150497e016fcfdc06b55a66518e3756305e46f3dda7f" + strconv.Itoa(i)
+		underpriced := genTx4Test(prefix+"1", old.Nonce, types.NewBigInt(21999), gasLimit, &addr1)
+		if err := container.push(underpriced); err != ErrReplaceUnderpriced {
+			t.Errorf("expect replacement underpriced, got %v", err)
+		}
+		if container.get(underpriced.Hash) != nil || container.get(old.Hash) == nil {
+			t.Errorf("the old tx should be kept")
+		}
+		bumped := genTx4Test(prefix+"2", old.Nonce, types.NewBigInt(22000), gasLimit, &addr1)
+		if err := container.push(bumped); err != nil {
+			t.Errorf("push error:%v", err)
+		}
+		if container.get(bumped.Hash) == nil || container.get(old.Hash) != nil {
+			t.Errorf("the old tx should be replaced")
+		}
+	}
+	if container.pending.size != 1 || len(container.queue) != 1 {
+		t.Errorf("pending size %v, queue size %v", container.pending.size, len(container.queue))
+	}
+	checkPendingSize(t)
+}
+
 func checkPendingSize(t *testing.T) {
 	if container == nil {
 		return
@@ -0,0 +1,138 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+	"github.com/zvchain/zvchain/storage/account"
+	"github.com/zvchain/zvchain/storage/rlp"
+	"github.com/zvchain/zvchain/storage/tasdb"
+)
+
+// StateDiff is the accounts changed by a block, including the rewards
+type StateDiff struct {
+	Height   uint64
+	Hash     common.Hash
+	Accounts []*account.AccountDiff
+}
+
+// stateDiffStore keeps the state diffs of the latest blocks, keyed by the height
+type stateDiffStore struct {
+	db   *tasdb.PrefixedDatabase
+	keep uint64 // Number of the latest heights kept, heights without block included
+}
+
+func newStateDiffStore(db *tasdb.PrefixedDatabase, keep uint64) *stateDiffStore {
+	return &stateDiffStore{db: db, keep: keep}
+}
+
+func (s *stateDiffStore) get(height uint64) *StateDiff {
+	bs, err := s.db.Get(common.UInt64ToByte(height))
+	if err != nil || len(bs) == 0 {
+		return nil
+	}
+	var diff StateDiff
+	if err := rlp.DecodeBytes(bs, &diff); err != nil {
+		Logger.Errorf("decode state diff of height %v error:%v", height, err)
+		return nil
+	}
+	return &diff
+}
+
+// commitBlock saves the diff of the block committed and removes the ones out of the kept range
+func (s *stateDiffStore) commitBlock(batch tasdb.Batch, bh *types.BlockHeader, accounts []*account.AccountDiff) error {
+	bs, err := rlp.EncodeToBytes(&StateDiff{Height: bh.Height, Hash: bh.Hash, Accounts: accounts})
+	if err != nil {
+		return err
+	}
+	if err = s.db.AddKv(batch, common.UInt64ToByte(bh.Height), bs); err != nil {
+		return err
+	}
+	if bh.Height >= s.keep {
+		return s.prune(batch, bh.Height-s.keep)
+	}
+	return nil
+}
+
+// prune removes the diffs at the heights not higher than the given one. The heights are skipped
+// by the chain, so all the lower ones are iterated rather than the one dropped out of the range
+func (s *stateDiffStore) prune(batch tasdb.Batch, height uint64) error {
+	iter := s.db.NewIterator()
+	defer iter.Release()
+	for iter.Next() {
+		if common.ByteToUInt64(iter.Key()) > height {
+			break
+		}
+		if err := s.db.AddKv(batch, common.CopyBytes(iter.Key()), nil); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+// removeBlock removes the diff of the block removed from the chain
+func (s *stateDiffStore) removeBlock(batch tasdb.Batch, bh *types.BlockHeader) error {
+	if diff := s.get(bh.Height); diff != nil && diff.Hash == bh.Hash {
+		return s.db.AddKv(batch, common.UInt64ToByte(bh.Height), nil)
+	}
+	return nil
+}
+
+// StateDiff returns the accounts changed by the block at the given height. The diff is read from
+// the store if persisted, otherwise the block is re-executed on the state of its parent
+func (chain *FullBlockChain) StateDiff(height uint64) (*StateDiff, error) {
+	b := chain.QueryBlockByHeight(height)
+	if b == nil {
+		return nil, fmt.Errorf("block not exists at %v", height)
+	}
+	bh := b.Header
+	if chain.stateDiffs != nil {
+		if diff := chain.stateDiffs.get(height); diff != nil && diff.Hash == bh.Hash {
+			return diff, nil
+		}
+	}
+	if height == 0 {
+		return nil, fmt.Errorf("genesis block has no state diff")
+	}
+	pre := chain.QueryBlockHeaderByHash(bh.PreHash)
+	if pre == nil {
+		return nil, fmt.Errorf("parent block not exists")
+	}
+	db, err := account.NewAccountDB(pre.StateTree, chain.stateCache)
+	if err != nil {
+		return nil, err
+	}
+	db.RecordDiff()
+
+	txs := make(txSlice, 0, len(b.Transactions))
+	for _, raw := range b.Transactions {
+		txs = append(txs, types.NewTransaction(raw, raw.GenHash()))
+	}
+	// The tvm controller is shared with the block executing, so lock the chain
+	chain.mu.Lock()
+	root, _, executed, _, _, _, err := chain.stateProc.process(db, bh, txs, false, nil)
+	chain.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if len(executed) != len(txs) || root != bh.StateTree {
+		return nil, fmt.Errorf("re-execution of the block at %v mismatches the state", height)
+	}
+	return &StateDiff{Height: height, Hash: bh.Hash, Accounts: db.Diff()}, nil
+}
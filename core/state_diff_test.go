@@ -0,0 +1,129 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+	"github.com/zvchain/zvchain/storage/account"
+	"github.com/zvchain/zvchain/storage/tasdb"
+)
+
+func TestStateDiffStore(t *testing.T) {
+	ds, err := tasdb.NewDataSource("test_state_diff", nil)
+	if err != nil {
+		t.Fatalf("new datasource error:%v", err)
+	}
+	db, err := ds.NewPrefixDatabase("sd")
+	if err != nil {
+		t.Fatalf("new prefix db error:%v", err)
+	}
+	defer func() {
+		db.Close()
+		os.RemoveAll("test_state_diff")
+	}()
+	store := newStateDiffStore(db, 2)
+
+	headers := make([]*types.BlockHeader, 0)
+	for h := uint64(1); h <= 3; h++ {
+		bh := &types.BlockHeader{Height: h, Hash: common.BytesToHash(common.UInt64ToByte(h))}
+		headers = append(headers, bh)
+		diff := []*account.AccountDiff{{
+			Address: common.BytesToAddress([]byte{byte(h)}),
+			Created: true,
+			Before:  account.Account{Balance: new(big.Int)},
+			After:   account.Account{Balance: new(big.Int).SetUint64(h)},
+			Storage: []*account.StorageDiff{{Key: []byte("k"), After: []byte("v")}},
+		}}
+		batch := db.CreateLDBBatch()
+		if err := store.commitBlock(batch, bh, diff); err != nil {
+			t.Fatalf("commit block error:%v", err)
+		}
+		if err := batch.Write(); err != nil {
+			t.Fatalf("write batch error:%v", err)
+		}
+	}
+
+	if store.get(1) != nil {
+		t.Errorf("expect the diff out of the kept range removed")
+	}
+	diff := store.get(3)
+	if diff == nil || diff.Hash != headers[2].Hash || len(diff.Accounts) != 1 {
+		t.Fatalf("unexpected diff %+v", diff)
+	}
+	if a := diff.Accounts[0]; !a.Created || a.After.Balance.Uint64() != 3 || string(a.Storage[0].After) != "v" {
+		t.Errorf("unexpected account diff %+v", a)
+	}
+
+	// Only the diff of the same block is removed
+	batch := db.CreateLDBBatch()
+	store.removeBlock(batch, &types.BlockHeader{Height: 2, Hash: common.BytesToHash([]byte("other"))})
+	store.removeBlock(batch, headers[2])
+	batch.Write()
+	if store.get(2) == nil || store.get(3) != nil {
+		t.Errorf("unexpected diffs after removing")
+	}
+
+	// The diffs at all the heights out of the range are removed when heights skipped
+	batch = db.CreateLDBBatch()
+	store.commitBlock(batch, &types.BlockHeader{Height: 6, Hash: common.BytesToHash([]byte{6})}, nil)
+	batch.Write()
+	if store.get(2) != nil || store.get(6) == nil {
+		t.Errorf("expect the diffs up to height 4 removed")
+	}
+}
+
+func TestBlockChain_StateDiff(t *testing.T) {
+	err := initContext4Test(t)
+	defer clearSelf(t)
+	if err != nil {
+		t.Fatalf("failed to initContext4Test")
+	}
+
+	castor := common.BytesToAddress(genHash("castor"))
+	block := BlockChainImpl.CastBlock(1, common.Hex2Bytes("12"), 0, castor.Bytes(), common.Hash{})
+	if block == nil {
+		t.Fatalf("fail to cast new block")
+	}
+	if types.AddBlockSucc != BlockChainImpl.AddBlockOnChain("", block) {
+		t.Fatalf("fail to add block")
+	}
+
+	if _, err := BlockChainImpl.StateDiff(0); err == nil {
+		t.Errorf("expect no diff of the genesis")
+	}
+	diff, err := BlockChainImpl.StateDiff(1)
+	if err != nil {
+		t.Fatalf("state diff error:%v", err)
+	}
+	if diff.Hash != block.Header.Hash {
+		t.Fatalf("unexpected block hash %v", diff.Hash)
+	}
+	// The block has no transaction, only the castor and the node rewards changed
+	var castorDiff *account.AccountDiff
+	for _, a := range diff.Accounts {
+		if a.Address == castor {
+			castorDiff = a
+		}
+	}
+	if castorDiff == nil || !castorDiff.Created || castorDiff.After.Balance.Cmp(BlockChainImpl.GetBalance(castor)) != 0 {
+		t.Errorf("unexpected diff of the castor %+v", castorDiff)
+	}
+}
@@ -118,8 +118,9 @@ type result struct {
 	cumulativeGasUsed *big.Int
 	transitionStatus  types.ReceiptStatus
 	err               error
-	logs              []*types.Log   // Generated when calls contract
-	contractAddress   common.Address // Generated when creates contract
+	logs              []*types.Log     // Generated when calls contract
+	contractAddress   common.Address   // Generated when creates contract
+	transferees       []common.Address // Recipients of the transfers made inside the contract
 }
 
 func newResult() *result {
@@ -139,6 +140,12 @@ func newTransitionContext(db types.AccountDB, tx types.TxMessage, bh *types.Bloc
 }
 
 func checkState(db types.AccountDB, tx *types.Transaction, height uint64) error {
+	if err := chainIdValidate(tx, height); err != nil {
+		return err
+	}
+	if err := expireValidate(tx, height); err != nil {
+		return err
+	}
 	if !validateNonce(db, tx) {
 		return errNonceError
 	}
@@ -255,6 +262,7 @@ func (ss *contractCreator) Transition() *result {
 					ret.setError(fmt.Errorf(err.Message), types.RSTvmError)
 				}
 			} else {
+				ret.transferees = controller.Transferees()
 				Logger.Debugf("Contract create success! Tx hash:%s, contract addr:%s", ss.msg.GetHash().Hex(), contractAddress.AddrPrefixString())
 			}
 		}
@@ -296,6 +304,7 @@ func (ss *contractCaller) Transition() *result {
 					ret.setError(fmt.Errorf(err.Message), types.RSTvmError)
 				}
 			} else {
+				ret.transferees = controller.Transferees()
 				Logger.Debugf("Contract call success! contract addr:%s，abi is %s", contract.ContractAddress.AddrPrefixString(), string(ss.msg.Payload()))
 			}
 		}
@@ -432,10 +441,12 @@ func applyStateTransition(accountDB types.AccountDB, tx *types.Transaction, bh *
 	return ret, nil
 }
 
-// process executes all types transactions and returns the receipts
-func (executor *stateProcessor) process(accountDB *account.AccountDB, bh *types.BlockHeader, txs []*types.Transaction, pack bool, ts *common.TimeStatCtx) (state common.Hash, evits []common.Hash, executed txSlice, recps []*types.Receipt, gasFee uint64, err error) {
+// process executes all types transactions and returns the receipts, together with the
+// recipients of the transfers made inside the contracts by each executed transaction
+func (executor *stateProcessor) process(accountDB *account.AccountDB, bh *types.BlockHeader, txs []*types.Transaction, pack bool, ts *common.TimeStatCtx) (state common.Hash, evits []common.Hash, executed txSlice, recps []*types.Receipt, transferees [][]common.Address, gasFee uint64, err error) {
 	beginTime := time.Now()
 	receipts := make([]*types.Receipt, 0)
+	transferees = make([][]common.Address, 0)
 	transactions := make(txSlice, 0)
 	evictedTxs := make([]common.Hash, 0)
 	castor := common.BytesToAddress(bh.Castor)
@@ -500,6 +511,7 @@ func (executor *stateProcessor) process(accountDB *account.AccountDB, bh *types.
 		receipt.TxIndex = uint16(idx)
 		receipt.Height = bh.Height
 		receipts = append(receipts, receipt)
+		transferees = append(transferees, ret.transferees)
 		//errs[i] = err
 
 	}
@@ -523,7 +535,7 @@ func (executor *stateProcessor) process(accountDB *account.AccountDB, bh *types.
 
 	state = accountDB.IntermediateRoot(true)
 	//Logger.Debugf("castor reward at %v, %v %v %v %v", bh.Height, castorTotalRewards, gasFee, rm.daemonNodesRewards(bh.Height), rm.userNodesRewards(bh.Height))
-	return state, evictedTxs, transactions, receipts, gasFee, nil
+	return state, evictedTxs, transactions, receipts, transferees, gasFee, nil
 }
 
 func validateNonce(accountDB types.AccountDB, transaction *types.Transaction) bool {
@@ -550,12 +562,17 @@ func createContract(accountDB types.AccountDB, transaction types.TxMessage) (com
 	return contractAddr, nil
 }
 
-func validGasPrice(gasPrice *big.Int, height uint64) bool {
+// minGasPrice returns the lowest gas price accepted at the given height
+func minGasPrice(height uint64) uint64 {
 	times := height / adjustGasPricePeriod
 	if times > adjustGasPriceTimes {
 		times = adjustGasPriceTimes
 	}
-	if gasPrice.Cmp(big.NewInt(0).SetUint64(initialMinGasPrice<<times)) < 0 {
+	return initialMinGasPrice << times
+}
+
+func validGasPrice(gasPrice *big.Int, height uint64) bool {
+	if gasPrice.Cmp(big.NewInt(0).SetUint64(minGasPrice(height))) < 0 {
 		return false
 	}
 	return true
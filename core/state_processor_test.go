@@ -16,8 +16,6 @@ import (
 	"github.com/zvchain/zvchain/storage/account"
 	"github.com/zvchain/zvchain/storage/tasdb"
 
-	"github.com/syndtr/goleveldb/leveldb/filter"
-	"github.com/syndtr/goleveldb/leveldb/opt"
 )
 
 var (
@@ -40,15 +38,15 @@ func initExecutor() {
 			rewardManager:   NewRewardManager(),
 		},
 	}
-	options := &opt.Options{
+	options := &tasdb.Options{
 		OpenFilesCacheCapacity:        100,
-		BlockCacheCapacity:            16 * opt.MiB,
-		WriteBuffer:                   32 * opt.MiB, // Two of these are used internally
-		Filter:                        filter.NewBloomFilter(10),
-		CompactionTableSize:           4 * opt.MiB,
+		BlockCacheCapacity:            16 * tasdb.MiB,
+		WriteBuffer:                   32 * tasdb.MiB, // Two of these are used internally
+		BloomFilterBits:               10,
+		CompactionTableSize:           4 * tasdb.MiB,
 		CompactionTableSizeMultiplier: 2,
-		CompactionTotalSize:           16 * opt.MiB,
-		BlockSize:                     2 * opt.MiB,
+		CompactionTotalSize:           16 * tasdb.MiB,
+		BlockSize:                     2 * tasdb.MiB,
 	}
 	ds, err := tasdb.NewDataSource("test_db", options)
 	if err != nil {
@@ -124,7 +122,7 @@ func TestStateProcessor_process(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	stateHash, evts, executed, receptes, _, err := executor.process(adb, &types.BlockHeader{}, txs, false, nil)
+	stateHash, evts, executed, receptes, _, _, err := executor.process(adb, &types.BlockHeader{}, txs, false, nil)
 	if err != nil {
 		t.Fatalf("execute error :%v", err)
 	}
@@ -0,0 +1,37 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+	"github.com/zvchain/zvchain/storage/account"
+)
+
+// GetProof returns the merkle proof of the account and its storage keys on the state after the block
+// at the given height, together with the header whose StateTree the proof should be verified against.
+// The top block is used if the given height is higher than the top
+func (chain *FullBlockChain) GetProof(addr common.Address, storageKeys [][]byte, height uint64) (*types.BlockHeader, *account.AccountProof, error) {
+	header, state, err := chain.stateAt(height)
+	if err != nil {
+		return nil, nil, err
+	}
+	proof, err := state.GetProof(addr, storageKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, proof, nil
+}
@@ -0,0 +1,43 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/storage/trie"
+)
+
+func TestGetProofOnGenesis(t *testing.T) {
+	err := initContext4Test(t)
+	if err != nil {
+		t.Fatalf("init fail:%v", err)
+	}
+	defer clearSelf(t)
+
+	addr := common.BytesToAddress([]byte{1})
+	header, proof, err := BlockChainImpl.GetProof(addr, nil, 0)
+	if err != nil {
+		t.Fatalf("get proof error:%v", err)
+	}
+	if header.Height != 0 {
+		t.Fatalf("proof should be at the genesis, got height %v", header.Height)
+	}
+	if _, err := trie.VerifyProof(header.StateTree, addr[:], proof.AccountProof); err != nil {
+		t.Fatalf("verify proof error:%v", err)
+	}
+}
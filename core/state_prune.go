@@ -17,9 +17,6 @@ package core
 
 import (
 	"fmt"
-	"github.com/syndtr/goleveldb/leveldb/filter"
-	"github.com/syndtr/goleveldb/leveldb/opt"
-	"github.com/syndtr/goleveldb/leveldb/util"
 	"github.com/zvchain/zvchain/common"
 	"github.com/zvchain/zvchain/core/group"
 	"github.com/zvchain/zvchain/log"
@@ -81,14 +78,14 @@ func NewOfflineTailor(genesisGroup *types.GenesisInfo, dbDir string, sdbDir stri
 		topRawBlocks: common.MustNewLRUCache(20),
 	}
 
-	options := &opt.Options{
-		Filter:                 filter.NewBloomFilter(10),
+	options := &tasdb.Options{
+		BloomFilterBits:        10,
 		OpenFilesCacheCapacity: maxOpenFiles,
 	}
 	if onlyVerify {
 		options.ReadOnly = true
 	} else {
-		options.WriteBuffer = 128 * opt.MiB
+		options.WriteBuffer = 128 * tasdb.MiB
 	}
 	ds, err := tasdb.NewDataSource(chain.config.dbfile, options)
 	if err != nil {
@@ -238,6 +235,16 @@ func (t *OfflineTailor) subTreeConcernedKeys(address common.Address) [][]byte {
 	return nil
 }
 
+// verifyConfig returns the config for traversing the state tries to verify, in which only the concerned
+// keys of the group accounts loaded are traversed
+func (t *OfflineTailor) verifyConfig() *account.TraverseConfig {
+	return &account.TraverseConfig{
+		CheckHash:           false,
+		VisitedRoots:        make(map[common.Hash]struct{}),
+		SubTreeKeysProvider: t.subTreeConcernedKeys,
+	}
+}
+
 func (t *OfflineTailor) collectUsedNodes() error {
 	const noPruneBlock = TriesInMemory
 
@@ -355,13 +362,11 @@ func (t *OfflineTailor) eraseNodes() {
 
 func (t *OfflineTailor) Compaction() {
 	begin := time.Now()
-	rangeStart := []byte(t.chain.config.state)
-	rangeEnd := make([]byte, len(rangeStart))
-	copy(rangeEnd, rangeStart)
-	rangeEnd[len(rangeEnd)-1] = rangeEnd[len(rangeEnd)-1] + 1
-
-	t.info("start compaction range %v-%v", rangeStart, rangeEnd)
-	t.chain.blocks.GetDB().CompactRange(util.Range{Start: rangeStart, Limit: rangeEnd})
+	t.info("start compaction range of prefix %v", []byte(t.chain.config.state))
+	if err := t.chain.stateDb.Compact(nil, nil); err != nil {
+		t.info("compaction error %v", err)
+		return
+	}
 	t.info("compaction finished, cost %v", time.Since(begin).String())
 }
 
@@ -400,11 +405,7 @@ func (t *OfflineTailor) Verify() error {
 	firstHeight := verifyBlockHeights[len(verifyBlockHeights)-1]
 	t.loadAllGroupSeeds(firstHeight)
 
-	traverseConfig := &account.TraverseConfig{
-		CheckHash:           false,
-		VisitedRoots:        make(map[common.Hash]struct{}),
-		SubTreeKeysProvider: t.subTreeConcernedKeys,
-	}
+	traverseConfig := t.verifyConfig()
 
 	t.info("all blocks need to verify: %v(%v-%v)", len(verifyBlockHeights), firstHeight, verifyBlockHeights[0])
 	begin := time.Now()
@@ -0,0 +1,548 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sync"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+	"github.com/zvchain/zvchain/storage/account"
+	"github.com/zvchain/zvchain/storage/rlp"
+	"github.com/zvchain/zvchain/storage/sha3"
+	"github.com/zvchain/zvchain/storage/trie"
+)
+
+// The state snapshot stores the whole state at a height together with the block at that height.
+// It starts with the magic, the version and the height, followed by the block entry, the chunk
+// entries of the state and ends with the end entry:
+//
+//	block entry: header length(4) | marshaled header | body length(4) | encoded transactions | crc32(4)
+//	chunk entry: 0x01 | data length(4) | rlp encoded items | crc32(4)
+//	end entry:   0x00 | count of accounts(8) | count of storage entries(8) | count of codes(8) | count of chunks(8)
+//
+// The crc32 of the block entry covers its header and body. The storage entries of an account always
+// come before the account item, and the code comes right after the account. Items of different accounts
+// may be interleaved.
+const (
+	snapshotMagic   = "ZVSS"
+	snapshotVersion = uint16(1)
+
+	snapshotEntryEnd   = byte(0)
+	snapshotEntryChunk = byte(1)
+
+	snapshotChunkSize = 1024 * 1024
+	maxEntrySize      = 64 * 1024 * 1024
+)
+
+const (
+	snapshotItemAccount = byte(iota + 1)
+	snapshotItemStorage
+	snapshotItemCode
+)
+
+type snapshotItem struct {
+	Kind  byte
+	Addr  []byte // Address of the account and storage items
+	Key   []byte // Storage key of the storage item, or the code hash of the code item
+	Value []byte // The rlp encoded account, the storage value or the code
+}
+
+// SnapshotStat is the statistics of a state snapshot
+type SnapshotStat struct {
+	Height   uint64
+	Root     common.Hash
+	Accounts uint64
+	Storages uint64
+	Codes    uint64
+	Chunks   uint64
+}
+
+// snapshotWriter packs the items into chunks, it's safe for concurrent use
+type snapshotWriter struct {
+	w     *bufio.Writer
+	lock  sync.Mutex
+	items []*snapshotItem
+	size  int
+	stat  *SnapshotStat
+	err   error
+}
+
+func (sw *snapshotWriter) add(item *snapshotItem) error {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+	if sw.err != nil {
+		return sw.err
+	}
+	sw.items = append(sw.items, item)
+	sw.size += len(item.Addr) + len(item.Key) + len(item.Value)
+	switch item.Kind {
+	case snapshotItemAccount:
+		sw.stat.Accounts++
+	case snapshotItemStorage:
+		sw.stat.Storages++
+	case snapshotItemCode:
+		sw.stat.Codes++
+	}
+	if sw.size >= snapshotChunkSize {
+		sw.err = sw.flush()
+	}
+	return sw.err
+}
+
+func (sw *snapshotWriter) flush() error {
+	if len(sw.items) == 0 {
+		return nil
+	}
+	data, err := rlp.EncodeToBytes(sw.items)
+	if err != nil {
+		return err
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, len(data)+9))
+	buf.WriteByte(snapshotEntryChunk)
+	buf.Write(common.UInt32ToByte(uint32(len(data))))
+	buf.Write(data)
+	buf.Write(common.UInt32ToByte(crc32.ChecksumIEEE(data)))
+	if _, err := sw.w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	sw.stat.Chunks++
+	sw.items, sw.size = sw.items[:0], 0
+	return nil
+}
+
+// ExportState writes the state at the given height to the writer in the snapshot format
+func (chain *FullBlockChain) ExportState(w io.Writer, height uint64) (*SnapshotStat, error) {
+	b := chain.QueryBlockByHeight(height)
+	if b == nil {
+		return nil, fmt.Errorf("no block at height %v", height)
+	}
+	state, err := account.NewAccountDB(b.Header.StateTree, chain.stateCache)
+	if err != nil {
+		return nil, err
+	}
+
+	sw := &snapshotWriter{w: bufio.NewWriter(w), stat: &SnapshotStat{Height: height, Root: b.Header.StateTree}}
+	head := bytes.NewBuffer(nil)
+	head.WriteString(snapshotMagic)
+	head.Write(common.UInt16ToByte(snapshotVersion))
+	head.Write(common.UInt64ToByte(height))
+	if _, err := sw.w.Write(head.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := writeBlockEntry(sw.w, b); err != nil {
+		return nil, err
+	}
+
+	config := &account.TraverseConfig{
+		CheckHash: true,
+		VisitAccountCb: func(stat *account.TraverseStat) {
+			data, err := rlp.EncodeToBytes(&stat.Account)
+			if err != nil {
+				sw.lock.Lock()
+				sw.err = err
+				sw.lock.Unlock()
+				return
+			}
+			if sw.add(&snapshotItem{Kind: snapshotItemAccount, Addr: stat.Addr.Bytes(), Value: data}) != nil {
+				return
+			}
+			if stat.Code != nil {
+				sw.add(&snapshotItem{Kind: snapshotItemCode, Key: stat.Account.CodeHash, Value: stat.Code})
+			}
+		},
+		VisitStorageCb: func(addr common.Address, key []byte, value []byte) error {
+			return sw.add(&snapshotItem{Kind: snapshotItemStorage, Addr: addr.Bytes(), Key: common.CopyBytes(key), Value: common.CopyBytes(value)})
+		},
+	}
+	if ok, err := state.Traverse(config); !ok {
+		return nil, fmt.Errorf("traverse state error:%v", err)
+	}
+	if sw.err != nil {
+		return nil, sw.err
+	}
+	if err := sw.flush(); err != nil {
+		return nil, err
+	}
+	end := bytes.NewBuffer(nil)
+	end.WriteByte(snapshotEntryEnd)
+	end.Write(common.UInt64ToByte(sw.stat.Accounts))
+	end.Write(common.UInt64ToByte(sw.stat.Storages))
+	end.Write(common.UInt64ToByte(sw.stat.Codes))
+	end.Write(common.UInt64ToByte(sw.stat.Chunks))
+	if _, err := sw.w.Write(end.Bytes()); err != nil {
+		return nil, err
+	}
+	return sw.stat, sw.w.Flush()
+}
+
+// snapshotReader reads the chunks from the state snapshot one by one
+type snapshotReader struct {
+	r     *bufio.Reader
+	block *types.Block
+	stat  *SnapshotStat
+}
+
+func newSnapshotReader(r io.Reader) (*snapshotReader, error) {
+	br := bufio.NewReader(r)
+	head := make([]byte, len(snapshotMagic)+2+8)
+	if _, err := io.ReadFull(br, head); err != nil {
+		return nil, fmt.Errorf("read snapshot header error:%v", err)
+	}
+	if string(head[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, fmt.Errorf("not a state snapshot")
+	}
+	head = head[len(snapshotMagic):]
+	if version := common.ByteToUInt16(head[:2]); version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %v", version)
+	}
+	height := common.ByteToUInt64(head[2:10])
+
+	b, err := readBlockEntry(br)
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot block error:%v", err)
+	}
+	if b.Header.Height != height {
+		return nil, fmt.Errorf("snapshot block height %v mismatch, expect %v", b.Header.Height, height)
+	}
+	return &snapshotReader{r: br, block: b, stat: &SnapshotStat{Height: height, Root: b.Header.StateTree}}, nil
+}
+
+// writeBlockEntry writes the block as a block entry: header length(4) | marshaled header |
+// body length(4) | encoded transactions | crc32(4)
+func writeBlockEntry(w io.Writer, b *types.Block) error {
+	header, err := types.MarshalBlockHeader(b.Header)
+	if err != nil {
+		return err
+	}
+	body, err := encodeBlockTransactions(b)
+	if err != nil {
+		return err
+	}
+	crc := crc32.NewIEEE()
+	crc.Write(header)
+	crc.Write(body)
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(header)+len(body)+12))
+	buf.Write(common.UInt32ToByte(uint32(len(header))))
+	buf.Write(header)
+	buf.Write(common.UInt32ToByte(uint32(len(body))))
+	buf.Write(body)
+	buf.Write(common.UInt32ToByte(crc.Sum32()))
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// readBlockEntry reads the block entry written by writeBlockEntry
+func readBlockEntry(r *bufio.Reader) (*types.Block, error) {
+	header, err := readEntrySection(r)
+	if err != nil {
+		return nil, err
+	}
+	body, err := readEntrySection(r)
+	if err != nil {
+		return nil, err
+	}
+	crcBytes := make([]byte, 4)
+	if _, err := io.ReadFull(r, crcBytes); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	crc := crc32.NewIEEE()
+	crc.Write(header)
+	crc.Write(body)
+	if crc.Sum32() != common.ByteToUInt32(crcBytes) {
+		return nil, fmt.Errorf("checksum mismatch of the block entry")
+	}
+
+	bh, err := types.UnMarshalBlockHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	txs, err := decodeBlockTransactions(body)
+	if err != nil {
+		return nil, err
+	}
+	return &types.Block{Header: bh, Transactions: txs}, nil
+}
+
+func readEntrySection(r *bufio.Reader) ([]byte, error) {
+	lenBytes := make([]byte, 4)
+	if _, err := io.ReadFull(r, lenBytes); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	size := common.ByteToUInt32(lenBytes)
+	if size > maxEntrySize {
+		return nil, fmt.Errorf("entry size %v too large", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return data, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// next returns the items of the next chunk, and io.EOF after the end entry read
+func (sr *snapshotReader) next() ([]*snapshotItem, error) {
+	flag, err := sr.r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	switch flag {
+	case snapshotEntryEnd:
+		counts := make([]byte, 32)
+		if _, err := io.ReadFull(sr.r, counts); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		expect := &SnapshotStat{
+			Height:   sr.stat.Height,
+			Root:     sr.stat.Root,
+			Accounts: common.ByteToUInt64(counts[:8]),
+			Storages: common.ByteToUInt64(counts[8:16]),
+			Codes:    common.ByteToUInt64(counts[16:24]),
+			Chunks:   common.ByteToUInt64(counts[24:]),
+		}
+		if *expect != *sr.stat {
+			return nil, fmt.Errorf("snapshot count mismatch, expect %+v but read %+v", expect, sr.stat)
+		}
+		return nil, io.EOF
+	case snapshotEntryChunk:
+	default:
+		return nil, fmt.Errorf("unknown snapshot entry %v", flag)
+	}
+
+	lenBytes := make([]byte, 4)
+	if _, err := io.ReadFull(sr.r, lenBytes); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	size := common.ByteToUInt32(lenBytes)
+	if size > maxEntrySize {
+		return nil, fmt.Errorf("chunk size %v too large", size)
+	}
+	data := make([]byte, size+4)
+	if _, err := io.ReadFull(sr.r, data); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if crc32.ChecksumIEEE(data[:size]) != common.ByteToUInt32(data[size:]) {
+		return nil, fmt.Errorf("checksum mismatch of the chunk %v", sr.stat.Chunks)
+	}
+	var items []*snapshotItem
+	if err := rlp.DecodeBytes(data[:size], &items); err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		switch item.Kind {
+		case snapshotItemAccount:
+			sr.stat.Accounts++
+		case snapshotItemStorage:
+			sr.stat.Storages++
+		case snapshotItemCode:
+			sr.stat.Codes++
+		default:
+			return nil, fmt.Errorf("unknown snapshot item %v", item.Kind)
+		}
+	}
+	sr.stat.Chunks++
+	return items, nil
+}
+
+// stateRebuilder rebuilds the tries from the snapshot items
+type stateRebuilder struct {
+	height   uint64
+	triedb   *trie.NodeDatabase
+	accounts *trie.Trie
+	storages map[common.Address]*trie.Trie
+}
+
+func (sb *stateRebuilder) apply(item *snapshotItem) error {
+	switch item.Kind {
+	case snapshotItemStorage:
+		addr := common.BytesToAddress(item.Addr)
+		st, ok := sb.storages[addr]
+		if !ok {
+			var err error
+			if st, err = trie.NewTrie(common.Hash{}, sb.triedb); err != nil {
+				return err
+			}
+			sb.storages[addr] = st
+		}
+		return st.TryUpdate(item.Key, item.Value)
+	case snapshotItemAccount:
+		addr := common.BytesToAddress(item.Addr)
+		var acc account.Account
+		if err := rlp.DecodeBytes(item.Value, &acc); err != nil {
+			return err
+		}
+		st, ok := sb.storages[addr]
+		if ok {
+			root, err := st.Commit(nil)
+			if err != nil {
+				return err
+			}
+			if root != acc.Root {
+				return fmt.Errorf("storage root mismatch of %v, expect %v but rebuilt %v", addr.AddrPrefixString(), acc.Root.Hex(), root.Hex())
+			}
+			if err := sb.triedb.Commit(sb.height, root, false); err != nil {
+				return err
+			}
+			delete(sb.storages, addr)
+		} else if acc.Root != emptyStorageRoot && acc.Root != emptyTrieRoot {
+			return fmt.Errorf("storage of %v missing", addr.AddrPrefixString())
+		}
+		return sb.accounts.TryUpdate(addr.Bytes(), item.Value)
+	case snapshotItemCode:
+		hash := common.BytesToHash(item.Key)
+		if common.Hash(sha3.Sum256(item.Value)) != hash {
+			return fmt.Errorf("code hash mismatch %v", hash.Hex())
+		}
+		sb.triedb.InsertBlob(hash, item.Value)
+		return sb.triedb.Commit(sb.height, hash, false)
+	}
+	return nil
+}
+
+// commit writes the account trie built so far to the database
+func (sb *stateRebuilder) commit() (common.Hash, error) {
+	root, err := sb.accounts.Commit(nil)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return root, sb.triedb.Commit(sb.height, root, false)
+}
+
+var (
+	// emptyStorageRoot is the storage root of the accounts never having storage
+	emptyStorageRoot = common.Hash(sha3.Sum256(nil))
+	// emptyTrieRoot is the root hash of an empty trie, which is the storage root after all entries removed
+	emptyTrieRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+)
+
+// ImportState rebuilds the state from the snapshot and verifies the root against the state root of the block
+// in the snapshot, then writes the block as the top of the chain so that the node can sync from there.
+// The snapshot comes from an untrusted source, so the block is authenticated by the expected hash got from
+// a trusted one. The chain should have only the genesis block.
+func (chain *FullBlockChain) ImportState(r io.Reader, expected common.Hash) (*SnapshotStat, error) {
+	if chain.Height() != 0 {
+		return nil, fmt.Errorf("state can only be imported into the chain with only the genesis block, but the top is %v", chain.Height())
+	}
+	sr, err := newSnapshotReader(r)
+	if err != nil {
+		return nil, err
+	}
+	b := sr.block
+	if b.Header.Hash != b.Header.GenHash() {
+		return nil, fmt.Errorf("block hash mismatch")
+	}
+	if b.Header.Hash != expected {
+		return nil, fmt.Errorf("block hash %v of the snapshot is not the expected %v", b.Header.Hash.Hex(), expected.Hex())
+	}
+	txs := make(txSlice, 0, len(b.Transactions))
+	for _, raw := range b.Transactions {
+		txs = append(txs, types.NewTransaction(raw, raw.GenHash()))
+	}
+	if txs.calcTxTree() != b.Header.TxTree {
+		return nil, fmt.Errorf("tx tree mismatch")
+	}
+
+	triedb := chain.stateCache.TrieDB()
+	accounts, err := trie.NewTrie(common.Hash{}, triedb)
+	if err != nil {
+		return nil, err
+	}
+	sb := &stateRebuilder{height: b.Header.Height, triedb: triedb, accounts: accounts, storages: make(map[common.Address]*trie.Trie)}
+	for {
+		items, err := sr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			if err := sb.apply(item); err != nil {
+				return nil, err
+			}
+		}
+		// Flush the nodes to the database chunk by chunk to limit the memory used
+		if _, err := sb.commit(); err != nil {
+			return nil, err
+		}
+	}
+	if len(sb.storages) > 0 {
+		return nil, fmt.Errorf("storage of %v accounts without the account", len(sb.storages))
+	}
+	root, err := sb.commit()
+	if err != nil {
+		return nil, err
+	}
+	if root != b.Header.StateTree {
+		return nil, fmt.Errorf("state root mismatch, expect %v but rebuilt %v", b.Header.StateTree.Hex(), root.Hex())
+	}
+	if err := chain.saveSnapshotBlock(b); err != nil {
+		return nil, err
+	}
+	return sr.stat, nil
+}
+
+// saveSnapshotBlock writes the block whose state is imported as the top of the chain
+func (chain *FullBlockChain) saveSnapshotBlock(b *types.Block) error {
+	bh := b.Header
+	headerBytes, err := types.MarshalBlockHeader(bh)
+	if err != nil {
+		return err
+	}
+	bodyBytes, err := encodeBlockTransactions(b)
+	if err != nil {
+		return err
+	}
+	state, err := account.NewAccountDB(bh.StateTree, chain.stateCache)
+	if err != nil {
+		return err
+	}
+
+	chain.rwLock.Lock()
+	defer chain.rwLock.Unlock()
+	defer chain.batch.Reset()
+
+	if err := chain.saveBlockHeader(bh.Hash, headerBytes); err != nil {
+		return err
+	}
+	if err := chain.saveBlockHeight(bh.Height, bh.Hash.Bytes()); err != nil {
+		return err
+	}
+	if err := chain.saveBlockTxs(bh.Hash, bodyBytes); err != nil {
+		return err
+	}
+	if err := chain.saveCurrentBlock(bh.Hash); err != nil {
+		return err
+	}
+	if err := chain.batch.Write(); err != nil {
+		return err
+	}
+	chain.updateLatestBlock(state, bh)
+	return nil
+}
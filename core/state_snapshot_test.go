@@ -0,0 +1,128 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+)
+
+func readSnapshot(data []byte) (*snapshotReader, error) {
+	reader, err := newSnapshotReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	for {
+		_, err := reader.next()
+		if err == io.EOF {
+			return reader, nil
+		}
+		if err != nil {
+			return reader, err
+		}
+	}
+}
+
+func TestStateSnapshot(t *testing.T) {
+	err := initContext4Test(t)
+	defer clearSelf(t)
+	if err != nil {
+		t.Fatalf("failed to initContext4Test")
+	}
+
+	castor := common.BytesToAddress(genHash("castor"))
+	block := BlockChainImpl.CastBlock(1, common.Hex2Bytes("12"), 0, castor.Bytes(), common.Hash{})
+	if block == nil {
+		t.Fatalf("fail to cast new block")
+	}
+	if types.AddBlockSucc != BlockChainImpl.AddBlockOnChain("", block) {
+		t.Fatalf("fail to add block")
+	}
+	balance := BlockChainImpl.GetBalance(castor)
+
+	buf := bytes.NewBuffer(nil)
+	stat, err := BlockChainImpl.ExportState(buf, 1)
+	if err != nil {
+		t.Fatalf("export state error:%v", err)
+	}
+	if stat.Accounts == 0 || stat.Storages == 0 || stat.Codes == 0 || stat.Chunks == 0 || stat.Root != block.Header.StateTree {
+		t.Fatalf("unexpected export stat %+v", stat)
+	}
+	data := buf.Bytes()
+
+	reader, err := readSnapshot(data)
+	if err != nil {
+		t.Fatalf("read snapshot error:%v", err)
+	}
+	if *reader.stat != *stat || reader.block.Header.Hash != block.Header.Hash {
+		t.Fatalf("unexpected snapshot read %+v", reader.stat)
+	}
+	// Truncated snapshot
+	if _, err := readSnapshot(data[:len(data)-1]); err != io.ErrUnexpectedEOF {
+		t.Errorf("expect unexpected eof for truncated snapshot, got %v", err)
+	}
+	// Corrupted chunk, the last byte of the chunk data is before the checksum and the end entry
+	corrupted := append([]byte{}, data...)
+	corrupted[len(corrupted)-33-4-1] ^= 0xff
+	if _, err := readSnapshot(corrupted); err == nil {
+		t.Errorf("expect error for corrupted snapshot")
+	}
+	clearSelf(t)
+
+	// Import into a fresh chain
+	t.Run("import", func(t *testing.T) {
+		err := initContext4Test(t)
+		defer clearSelf(t)
+		if err != nil {
+			t.Fatalf("failed to initContext4Test")
+		}
+		if _, err := BlockChainImpl.ImportState(bytes.NewReader(corrupted), block.Header.Hash); err == nil {
+			t.Fatalf("expect error importing corrupted snapshot")
+		}
+		if BlockChainImpl.Height() != 0 {
+			t.Fatalf("chain changed by failed import")
+		}
+
+		if _, err := BlockChainImpl.ImportState(bytes.NewReader(data), common.Hash{1}); err == nil {
+			t.Fatalf("expect error importing the snapshot of an unexpected block")
+		}
+		if BlockChainImpl.Height() != 0 {
+			t.Fatalf("chain changed by failed import")
+		}
+
+		imported, err := BlockChainImpl.ImportState(bytes.NewReader(data), block.Header.Hash)
+		if err != nil {
+			t.Fatalf("import state error:%v", err)
+		}
+		if *imported != *stat {
+			t.Errorf("unexpected import stat %+v", imported)
+		}
+		top := BlockChainImpl.QueryTopBlock()
+		if top.Height != 1 || top.Hash != block.Header.Hash {
+			t.Fatalf("unexpected top after import %+v", top)
+		}
+		if BlockChainImpl.GetBalance(castor).Cmp(balance) != 0 {
+			t.Errorf("balance mismatch after import")
+		}
+		if _, err := BlockChainImpl.ImportState(bytes.NewReader(data), block.Header.Hash); err == nil {
+			t.Errorf("expect error importing into a chain not empty")
+		}
+	})
+}
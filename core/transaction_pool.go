@@ -18,13 +18,16 @@ package core
 import (
 	"errors"
 	"fmt"
+	"path/filepath"
 	"sync"
+	"time"
 
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/zvchain/zvchain/common/secp256k1"
 	"github.com/zvchain/zvchain/network"
 
 	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/notify"
 	"github.com/zvchain/zvchain/middleware/types"
 	"github.com/zvchain/zvchain/storage/tasdb"
 )
@@ -58,6 +61,9 @@ var (
 	ErrSign            = errors.New("sign error")
 	ErrNonce           = errors.New("nonce error")
 	ErrDataSizeTooLong = errors.New("data size too long")
+	ErrExpired         = errors.New("transaction expired")
+
+	ErrReplaceUnderpriced = errors.New("replacement transaction underpriced")
 )
 
 type txPool struct {
@@ -67,9 +73,12 @@ type txPool struct {
 	// when add block on chain, does not participate in the broadcast
 
 	receiptDb          *tasdb.PrefixedDatabase
+	ancients           *ancientStore // Receipts of the frozen blocks, nil if the ancient store disabled
 	batch              tasdb.Batch
 	chain              types.BlockChain
 	gasPriceLowerBound *types.BigInt
+	journal            *txJournal    // Journal of the local transactions, nil if disabled
+	journalQuit        chan struct{} // Closed to stop rotating the journal
 	lock               sync.RWMutex
 }
 
@@ -77,12 +86,19 @@ type txPool struct {
 func newTransactionPool(chain *FullBlockChain, receiptDb *tasdb.PrefixedDatabase) types.TransactionPool {
 	pool := &txPool{
 		receiptDb:          receiptDb,
+		ancients:           chain.ancients,
 		batch:              chain.batch,
 		asyncAdds:          common.MustNewLRUCache(txCountPerBlock * maxReqBlockCount),
 		chain:              chain,
 		gasPriceLowerBound: types.NewBigInt(uint64(common.GlobalConf.GetInt("chain", "gasprice_lower_bound", 1))),
 	}
 	pool.received = newSimpleContainer(maxPendingSize, maxQueueSize, chain)
+	// The journal is kept in the data directory by default
+	defaultJournal := filepath.Join(chain.config.dbfile, "txs.journal")
+	if path := common.GlobalConf.GetString(configSec, "tx_journal", defaultJournal); path != "" {
+		pool.journal = newTxJournal(path)
+		pool.journalQuit = make(chan struct{})
+	}
 	pool.bonPool = newRewardPool(chain.rewardManager, rewardTxMaxSize)
 	initTxSyncer(chain, pool, network.GetNetInstance())
 
@@ -147,6 +163,67 @@ func (pool *txPool) AddTransaction(tx *types.Transaction) (bool, error) {
 	return pool.tryAddTransaction(tx)
 }
 
+// AddLocalTransaction try to add a transaction submitted locally into the tool.
+// The transaction is also journaled and will be added again after the node restarts
+func (pool *txPool) AddLocalTransaction(tx *types.Transaction) (bool, error) {
+	ok, err := pool.tryAddTransaction(tx)
+	if ok && pool.journal != nil {
+		if err := pool.journal.insert(tx); err != nil {
+			Logger.Warnf("journal local tx %v error:%v", tx.Hash.Hex(), err)
+		}
+	}
+	return ok, err
+}
+
+// startJournal replays the journaled transactions into the pool, and rotates the journal periodically.
+// It should be called after the chain is initialized as the transactions are validated with the latest state
+func (pool *txPool) startJournal() {
+	if pool.journal == nil {
+		return
+	}
+	err := pool.journal.load(func(tx *types.Transaction) error {
+		_, err := pool.tryAddTransaction(tx)
+		return err
+	})
+	if err != nil {
+		Logger.Errorf("load tx journal error:%v", err)
+	}
+	pool.rotateJournal()
+
+	interval := common.GlobalConf.GetInt(configSec, "tx_journal_rotate", 3600)
+	if interval <= 0 {
+		return
+	}
+	quit := pool.journalQuit
+	go func() {
+		tc := time.NewTicker(time.Duration(interval) * time.Second)
+		defer tc.Stop()
+		for {
+			select {
+			case <-tc.C:
+				pool.rotateJournal()
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
+// rotateJournal drops the transactions no longer in the pool from the journal
+func (pool *txPool) rotateJournal() {
+	if err := pool.journal.rotate(pool.received.contains); err != nil {
+		Logger.Errorf("rotate tx journal error:%v", err)
+	}
+}
+
+// close releases the resources held by the pool
+func (pool *txPool) close() {
+	if pool.journal != nil {
+		close(pool.journalQuit)
+		pool.journal.close()
+	}
+}
+
 // AddTransaction try to add a list of transactions into the tool asynchronously
 func (pool *txPool) AsyncAddTransaction(tx *types.Transaction) error {
 	if tx.IsReward() {
@@ -240,6 +317,9 @@ func (pool *txPool) tryAdd(tx *types.Transaction) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	if !tx.IsReward() {
+		notify.BUS.Publish(notify.TransactionAddSucc, &notify.TransactionAddSuccMessage{Tx: tx})
+	}
 
 	return true, nil
 }
@@ -323,7 +403,10 @@ func (pool *txPool) RemoveFromPool(txs []common.Hash) {
 	for _, tx := range txs {
 		pool.remove(tx)
 	}
-	go pool.received.promoteQueueToPending()
+	go func() {
+		pool.received.evictExpired(pool.chain.Height() + 1)
+		pool.received.promoteQueueToPending()
+	}()
 }
 
 // BackToPool will put the transactions back to pool
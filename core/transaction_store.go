@@ -58,6 +58,9 @@ func (pool *txPool) DeleteReceipts(txs []common.Hash) error {
 func (pool *txPool) loadReceipt(hash common.Hash) *types.Receipt {
 	txBytes, _ := pool.receiptDb.Get(hash.Bytes())
 	if txBytes == nil {
+		if pool.ancients != nil {
+			return pool.ancients.receipt(hash)
+		}
 		return nil
 	}
 
@@ -71,6 +74,9 @@ func (pool *txPool) loadReceipt(hash common.Hash) *types.Receipt {
 
 func (pool *txPool) hasReceipt(hash common.Hash) bool {
 	ok, _ := pool.receiptDb.Has(hash.Bytes())
+	if !ok && pool.ancients != nil {
+		return pool.ancients.hasTx(hash)
+	}
 	return ok
 }
 
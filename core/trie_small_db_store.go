@@ -146,6 +146,12 @@ func (store *smallStateStore) generateDataKey(heightBytes []byte) []byte {
 	return bytesBuffer.Bytes()
 }
 
+// hasHeight returns whether the state data of the given height is stored
+func (store *smallStateStore) hasHeight(height uint64) bool {
+	ok, _ := store.db.Has(store.generateDataKey(common.Uint64ToByte(height)))
+	return ok
+}
+
 func (store *smallStateStore) Close() {
 	if store.db != nil {
 		store.db.Close()
@@ -0,0 +1,221 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+)
+
+// txJournal is a rotating log of the transactions submitted locally, which are replayed
+// into the pool after the node restarts.
+// Each entry is stored as the length with 4 bytes big-endian followed by the msgpack encoded raw transaction
+type txJournal struct {
+	path   string
+	writer *os.File
+	locals map[common.Hash]*types.Transaction // Transactions journaled and not rotated out yet
+	mu     sync.Mutex
+}
+
+func newTxJournal(path string) *txJournal {
+	return &txJournal{
+		path:   path,
+		locals: make(map[common.Hash]*types.Transaction),
+	}
+}
+
+// load reads the transactions from the journal and replays them by the given function.
+// The transactions failed to replay are dropped and a truncated tail of the journal is ignored
+func (j *txJournal) load(add func(tx *types.Transaction) error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var (
+		reader  = bufio.NewReader(f)
+		total   = 0
+		dropped = 0
+	)
+	for {
+		raw, err := readJournalEntry(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			Logger.Warnf("tx journal %v broken after %v transactions: %v", j.path, total, err)
+			break
+		}
+		total++
+		tx := types.NewTransaction(raw, raw.GenHash())
+		if err := add(tx); err != nil {
+			Logger.Debugf("drop journaled tx %v: %v", tx.Hash.Hex(), err)
+			dropped++
+			continue
+		}
+		j.locals[tx.Hash] = tx
+	}
+	Logger.Infof("loaded %v transactions from tx journal, %v dropped", total, dropped)
+	return nil
+}
+
+// insert appends the transaction to the journal
+func (j *txJournal) insert(tx *types.Transaction) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.writer == nil {
+		f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		j.writer = f
+	}
+	if err := writeJournalEntry(j.writer, tx.RawTransaction); err != nil {
+		return err
+	}
+	j.locals[tx.Hash] = tx
+	return nil
+}
+
+// rotate regenerates the journal with the transactions still alive, so that the mined or invalid ones are dropped
+func (j *txJournal) rotate(alive func(hash common.Hash) bool) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.writer != nil {
+		if err := j.writer.Close(); err != nil {
+			return err
+		}
+		j.writer = nil
+	}
+	for hash := range j.locals {
+		if !alive(hash) {
+			delete(j.locals, hash)
+		}
+	}
+	if len(j.locals) == 0 {
+		if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	tmp := j.path + ".new"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, tx := range j.sortedLocals() {
+		if err := writeJournalEntry(w, tx.RawTransaction); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return err
+	}
+	Logger.Debugf("tx journal rotated, %v transactions kept", len(j.locals))
+	return nil
+}
+
+// sortedLocals returns the journaled transactions ordered by the source and the nonce,
+// so that they are replayed in the order the pool accepts them
+func (j *txJournal) sortedLocals() []*types.Transaction {
+	txs := make([]*types.Transaction, 0, len(j.locals))
+	for _, tx := range j.locals {
+		txs = append(txs, tx)
+	}
+	source := func(tx *types.Transaction) []byte {
+		if tx.Source == nil {
+			return nil
+		}
+		return tx.Source.Bytes()
+	}
+	sort.Slice(txs, func(i, k int) bool {
+		if c := bytes.Compare(source(txs[i]), source(txs[k])); c != 0 {
+			return c < 0
+		}
+		return txs[i].Nonce < txs[k].Nonce
+	})
+	return txs
+}
+
+// close flushes and closes the journal file
+func (j *txJournal) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.writer == nil {
+		return nil
+	}
+	err := j.writer.Close()
+	j.writer = nil
+	return err
+}
+
+func writeJournalEntry(w io.Writer, tx *types.RawTransaction) error {
+	data, err := marshalTx(tx)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(common.UInt32ToByte(uint32(len(data)))); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func readJournalEntry(r io.Reader) (*types.RawTransaction, error) {
+	lenBytes := make([]byte, 4)
+	if _, err := io.ReadFull(r, lenBytes); err != nil {
+		return nil, err
+	}
+	size := common.ByteToUInt32(lenBytes)
+	if size > txMaxSize*2 {
+		return nil, fmt.Errorf("entry size %v too large", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return unmarshalTx(data)
+}
@@ -0,0 +1,165 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+)
+
+func TestTxJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "txjournal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "txs.journal")
+
+	journal := newTxJournal(path)
+	txs := make([]*types.Transaction, 0)
+	for i := 1; i <= 3; i++ {
+		tx := genTestTx(500, "1", uint64(i), 1)
+		txs = append(txs, tx)
+		if err := journal.insert(tx); err != nil {
+			t.Fatalf("insert error:%v", err)
+		}
+	}
+	if err := journal.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replay and reject the second one
+	journal = newTxJournal(path)
+	loaded := make([]*types.Transaction, 0)
+	err = journal.load(func(tx *types.Transaction) error {
+		if tx.Hash == txs[1].Hash {
+			return fmt.Errorf("rejected")
+		}
+		loaded = append(loaded, tx)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("load error:%v", err)
+	}
+	if len(loaded) != 2 || len(journal.locals) != 2 {
+		t.Fatalf("loaded %v txs, %v kept", len(loaded), len(journal.locals))
+	}
+	if loaded[0].Hash != txs[0].Hash || !bytes.Equal(loaded[0].Sign, txs[0].Sign) || *loaded[0].Source != *txs[0].Source {
+		t.Errorf("tx changed after replay")
+	}
+
+	// Drop the first one as mined
+	err = journal.rotate(func(hash common.Hash) bool {
+		return hash != txs[0].Hash
+	})
+	if err != nil {
+		t.Fatalf("rotate error:%v", err)
+	}
+	journal = newTxJournal(path)
+	if err := journal.load(func(tx *types.Transaction) error { return nil }); err != nil {
+		t.Fatalf("load error:%v", err)
+	}
+	if len(journal.locals) != 1 || journal.locals[txs[2].Hash] == nil {
+		t.Errorf("journal should only keep the alive tx after rotation, got %v", len(journal.locals))
+	}
+
+	// Rotating out all removes the journal
+	if err := journal.rotate(func(hash common.Hash) bool { return false }); err != nil {
+		t.Fatalf("rotate error:%v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("empty journal should be removed")
+	}
+}
+
+func TestTxJournalTruncated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "txjournal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "txs.journal")
+
+	journal := newTxJournal(path)
+	for i := 1; i <= 2; i++ {
+		if err := journal.insert(genTestTx(500, "1", uint64(i), 1)); err != nil {
+			t.Fatalf("insert error:%v", err)
+		}
+	}
+	journal.close()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, info.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+
+	journal = newTxJournal(path)
+	count := 0
+	if err := journal.load(func(tx *types.Transaction) error {
+		count++
+		return nil
+	}); err != nil {
+		t.Fatalf("load error:%v", err)
+	}
+	if count != 1 {
+		t.Errorf("expect the complete entry loaded only, got %v", count)
+	}
+}
+
+func TestTxJournalRotateOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "txjournal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "txs.journal")
+
+	journal := newTxJournal(path)
+	for i := 20; i >= 1; i-- {
+		if err := journal.insert(genTestTx(500, "1", uint64(i), 1)); err != nil {
+			t.Fatalf("insert error:%v", err)
+		}
+	}
+	if err := journal.rotate(func(hash common.Hash) bool { return true }); err != nil {
+		t.Fatalf("rotate error:%v", err)
+	}
+
+	journal = newTxJournal(path)
+	nonces := make([]uint64, 0)
+	if err := journal.load(func(tx *types.Transaction) error {
+		nonces = append(nonces, tx.Nonce)
+		return nil
+	}); err != nil {
+		t.Fatalf("load error:%v", err)
+	}
+	if len(nonces) != 20 {
+		t.Fatalf("expect 20 txs loaded, got %v", len(nonces))
+	}
+	for i, nonce := range nonces {
+		if nonce != uint64(i+1) {
+			t.Fatalf("expect txs replayed in the nonce order, got %v", nonces)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+)
+
+// TxPoolStatus is the count of the transactions in the pool
+type TxPoolStatus struct {
+	Pending  int // Transactions ready for packing
+	Queued   int // Transactions waiting for the previous nonce
+	Reward   int // Reward transactions
+	Accounts int // Senders of the pending and queued transactions
+}
+
+// PoolTx is the transaction in the pool together with its inspection info
+type PoolTx struct {
+	*types.Transaction
+	// PriceRank is the position of the gas price in the pending transactions ordered by the price heap,
+	// starting from 1. Transactions with the same gas price share the same rank
+	PriceRank int
+	Age       time.Duration // Time since the transaction entered the pool
+}
+
+// PoolAccount contains the transactions in the pool of one sender
+type PoolAccount struct {
+	Address    common.Address
+	StateNonce uint64    // Nonce of the sender in the latest state
+	Pending    []*PoolTx // Pending transactions ordered by nonce
+	Queued     []*PoolTx // Queued transactions ordered by nonce
+	// NonceGaps are the missing nonces between the state nonce and the highest nonce in the pool,
+	// which block the transactions behind from being packed
+	NonceGaps []uint64
+}
+
+// LowestPendingNonce returns the lowest nonce of the pending transactions, 0 if no pending transaction
+func (pa *PoolAccount) LowestPendingNonce() uint64 {
+	if len(pa.Pending) == 0 {
+		return 0
+	}
+	return pa.Pending[0].Nonce
+}
+
+// TxPoolStatus returns the count of the transactions in the pool
+func (chain *FullBlockChain) TxPoolStatus() *TxPoolStatus {
+	pool := chain.transactionPool.(*txPool)
+	status := pool.received.status()
+	status.Reward = pool.bonPool.len()
+	return status
+}
+
+// TxPoolContent returns the transactions in the pool grouped by the sender and ordered by the address.
+// Only the transactions of the given address are returned if it's not nil
+func (chain *FullBlockChain) TxPoolContent(addr *common.Address) []*PoolAccount {
+	return chain.transactionPool.(*txPool).received.inspect(addr)
+}
+
+func (c *simpleContainer) status() *TxPoolStatus {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	accounts := make(map[common.Address]struct{}, len(c.pending.waitingMap))
+	for addr := range c.pending.waitingMap {
+		accounts[addr] = struct{}{}
+	}
+	for _, tx := range c.queue {
+		accounts[*tx.Source] = struct{}{}
+	}
+	return &TxPoolStatus{
+		Pending:  c.pending.size,
+		Queued:   len(c.queue),
+		Accounts: len(accounts),
+	}
+}
+
+// inspect walks the pending lists and the queue, and groups the transactions by the sender
+func (c *simpleContainer) inspect(addr *common.Address) []*PoolAccount {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	// Order all pending transactions as the price heap does to rank the gas price
+	prices := make(priceHeap, 0, c.pending.size)
+	for _, list := range c.pending.waitingMap {
+		for iter := list.IterAtPosition(0); iter.Next(); {
+			prices = append(prices, iter.Value().(*orderByNonceTx).item)
+		}
+	}
+	sort.Sort(prices)
+	rank := func(tx *types.Transaction) int {
+		// Count of the pending transactions with higher price
+		return sort.Search(len(prices), func(i int) bool {
+			return prices[i].GasPrice.Cmp(tx.GasPrice.Value()) <= 0
+		}) + 1
+	}
+	now := time.Now()
+	wrap := func(tx *types.Transaction) *PoolTx {
+		ptx := &PoolTx{Transaction: tx, PriceRank: rank(tx)}
+		if wt := c.txsMap[tx.Hash]; wt != nil {
+			ptx.Age = now.Sub(wt.begin)
+		}
+		return ptx
+	}
+
+	accounts := make(map[common.Address]*PoolAccount)
+	getAccount := func(source common.Address) *PoolAccount {
+		if pa, ok := accounts[source]; ok {
+			return pa
+		}
+		pa := &PoolAccount{
+			Address:    source,
+			StateNonce: c.chain.latestStateDB.GetNonce(source),
+			Pending:    make([]*PoolTx, 0),
+			Queued:     make([]*PoolTx, 0),
+		}
+		accounts[source] = pa
+		return pa
+	}
+	for source, list := range c.pending.waitingMap {
+		if addr != nil && source != *addr {
+			continue
+		}
+		pa := getAccount(source)
+		for iter := list.IterAtPosition(0); iter.Next(); {
+			pa.Pending = append(pa.Pending, wrap(iter.Value().(*orderByNonceTx).item))
+		}
+	}
+	for _, tx := range c.queue {
+		if addr != nil && *tx.Source != *addr {
+			continue
+		}
+		pa := getAccount(*tx.Source)
+		pa.Queued = append(pa.Queued, wrap(tx))
+	}
+
+	result := make([]*PoolAccount, 0, len(accounts))
+	for _, pa := range accounts {
+		sort.Slice(pa.Queued, func(i, j int) bool {
+			return pa.Queued[i].Nonce < pa.Queued[j].Nonce
+		})
+		pa.NonceGaps = nonceGaps(pa)
+		result = append(result, pa)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return bytes.Compare(result[i].Address.Bytes(), result[j].Address.Bytes()) < 0
+	})
+	return result
+}
+
+// nonceGaps finds the missing nonces between the state nonce and the highest nonce of the account's transactions
+func nonceGaps(pa *PoolAccount) []uint64 {
+	exists := make(map[uint64]struct{}, len(pa.Pending)+len(pa.Queued))
+	highest := pa.StateNonce
+	for _, txs := range [][]*PoolTx{pa.Pending, pa.Queued} {
+		for _, tx := range txs {
+			exists[tx.Nonce] = struct{}{}
+			if tx.Nonce > highest {
+				highest = tx.Nonce
+			}
+		}
+	}
+	gaps := make([]uint64, 0)
+	for n := pa.StateNonce + 1; n < highest; n++ {
+		if _, ok := exists[n]; !ok {
+			gaps = append(gaps, n)
+		}
+	}
+	return gaps
+}
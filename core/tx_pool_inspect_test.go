@@ -0,0 +1,73 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/zvchain/zvchain/middleware/types"
+)
+
+func TestSimpleContainerInspect(t *testing.T) {
+	err := initContext4Test(t)
+	defer clearSelf(t)
+	if err != nil {
+		t.Fatalf("failed to initContext4Test")
+	}
+
+	container = newSimpleContainer(200, 80, BlockChainImpl)
+	pushes := []*types.Transaction{
+		genTx4Test("ab454fdea57373b25b150497e016fcfdc06b55a66518e3756305e46f3dda7c01", 1, types.NewBigInt(300), gasLimit, &addr1),
+		genTx4Test("ab454fdea57373b25b150497e016fcfdc06b55a66518e3756305e46f3dda7c02", 2, types.NewBigInt(100), gasLimit, &addr1),
+		// Nonce 3 and 5 missing
+		genTx4Test("ab454fdea57373b25b150497e016fcfdc06b55a66518e3756305e46f3dda7c04", 4, types.NewBigInt(500), gasLimit, &addr1),
+		genTx4Test("ab454fdea57373b25b150497e016fcfdc06b55a66518e3756305e46f3dda7c06", 6, types.NewBigInt(500), gasLimit, &addr1),
+		genTx4Test("ab454fdea57373b25b150497e016fcfdc06b55a66518e3756305e46f3dda7c11", 1, types.NewBigInt(300), gasLimit, &addr2),
+	}
+	for _, tx := range pushes {
+		if err := container.push(tx); err != nil {
+			t.Fatalf("push error:%v", err)
+		}
+	}
+
+	status := container.status()
+	if status.Pending != 3 || status.Queued != 2 || status.Accounts != 2 {
+		t.Fatalf("status error:%+v", status)
+	}
+
+	accounts := container.inspect(&addr1)
+	if len(accounts) != 1 {
+		t.Fatalf("expect 1 account but got %v", len(accounts))
+	}
+	pa := accounts[0]
+	if pa.StateNonce != 0 || pa.LowestPendingNonce() != 1 {
+		t.Errorf("nonce error: state %v, lowest pending %v", pa.StateNonce, pa.LowestPendingNonce())
+	}
+	if len(pa.Pending) != 2 || len(pa.Queued) != 2 || pa.Queued[0].Nonce != 4 || pa.Queued[1].Nonce != 6 {
+		t.Fatalf("pending or queue error")
+	}
+	if len(pa.NonceGaps) != 2 || pa.NonceGaps[0] != 3 || pa.NonceGaps[1] != 5 {
+		t.Errorf("nonce gaps error:%v", pa.NonceGaps)
+	}
+	// Pending prices are 300, 300 and 100
+	if pa.Pending[0].PriceRank != 1 || pa.Pending[1].PriceRank != 3 || pa.Queued[0].PriceRank != 1 {
+		t.Errorf("price rank error: %v %v %v", pa.Pending[0].PriceRank, pa.Pending[1].PriceRank, pa.Queued[0].PriceRank)
+	}
+
+	if accounts := container.inspect(nil); len(accounts) != 2 {
+		t.Errorf("expect 2 accounts but got %v", len(accounts))
+	}
+}
@@ -434,7 +434,9 @@ func (ts *txSyncer) onTxResponse(msg notify.Message) error {
 		txx := types.NewTransaction(tx, tx.GenHash())
 		_, err := ts.pool.AddTransaction(txx)
 		if err != nil {
-			if err == ErrNonce {
+			// The tx replaced by a higher priced one or failed to replace the existing one
+			// should not be requested again
+			if err == ErrNonce || err == ErrReplaceUnderpriced {
 				ts.logger.Debugf("add tx to nonce error cache %s", txx.Hash)
 				ts.nonceErrTxs.ContainsOrAdd(txx.Hash, 1)
 				continue
@@ -0,0 +1,129 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+	"github.com/zvchain/zvchain/tvm"
+)
+
+// TxTrace is the result of re-executing a transaction on the state of its block
+type TxTrace struct {
+	Tx              *types.Transaction
+	Height          uint64
+	Index           int
+	GasUsed         uint64
+	Status          types.ReceiptStatus
+	Err             error          // Error of the execution, nil if succeeded
+	ContractAddress common.Address // Address of the contract created
+	Call            *tvm.CallFrame // Call tree of the contract execution, nil if no contract executed
+}
+
+// TraceTransaction re-executes the transaction of the given hash on the state before it in the block,
+// and records the contract executions
+func (chain *FullBlockChain) TraceTransaction(hash common.Hash) (*TxTrace, error) {
+	rc := chain.transactionPool.GetReceipt(hash)
+	if rc == nil {
+		return nil, fmt.Errorf("transaction not executed")
+	}
+	b := chain.QueryBlockByHeight(rc.Height)
+	if b == nil {
+		return nil, fmt.Errorf("block not exists at %v", rc.Height)
+	}
+	if int(rc.TxIndex) >= len(b.Transactions) || b.Transactions[rc.TxIndex].GenHash() != hash {
+		return nil, fmt.Errorf("transaction not found in the block at %v", rc.Height)
+	}
+	traces, err := chain.traceBlock(b, int(rc.TxIndex))
+	if err != nil {
+		return nil, err
+	}
+	return traces[0], nil
+}
+
+// TraceBlock re-executes all transactions of the block at the given height on the state of its parent,
+// and records the contract executions
+func (chain *FullBlockChain) TraceBlock(height uint64) ([]*TxTrace, error) {
+	b := chain.QueryBlockByHeight(height)
+	if b == nil {
+		return nil, fmt.Errorf("block not exists at %v", height)
+	}
+	return chain.traceBlock(b, -1)
+}
+
+// traceBlock re-executes the transactions of the block in order as the state processor does.
+// Only the transaction at the given index is traced and returned, or all if the index is negative
+func (chain *FullBlockChain) traceBlock(b *types.Block, index int) ([]*TxTrace, error) {
+	bh := b.Header
+	if bh.Height == 0 {
+		return nil, fmt.Errorf("genesis block can't be traced")
+	}
+	pre := chain.QueryBlockHeaderByHash(bh.PreHash)
+	if pre == nil {
+		return nil, fmt.Errorf("parent block not exists")
+	}
+	db, err := chain.AccountDBAt(pre.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	// The tvm controller is shared with the block executing, so lock the chain
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	defer tvm.SetTracer(nil)
+
+	traces := make([]*TxTrace, 0)
+	for i, raw := range b.Transactions {
+		tx := types.NewTransaction(raw, raw.GenHash())
+		traced := index < 0 || i == index
+		var tracer *tvm.CallTracer
+		if traced {
+			tracer = tvm.NewCallTracer()
+			tvm.SetTracer(tracer)
+		} else {
+			tvm.SetTracer(nil)
+		}
+		ret, err := applyStateTransition(db, tx, bh)
+		if err != nil {
+			return nil, fmt.Errorf("apply transaction %v error:%v", tx.Hash.Hex(), err)
+		}
+		if tx.Source != nil {
+			db.SetNonce(*tx.Source, tx.Nonce)
+		}
+		if !traced {
+			continue
+		}
+		trace := &TxTrace{
+			Tx:              tx,
+			Height:          bh.Height,
+			Index:           i,
+			Status:          ret.transitionStatus,
+			Err:             ret.err,
+			ContractAddress: ret.contractAddress,
+			Call:            tracer.Root(),
+		}
+		if ret.cumulativeGasUsed != nil {
+			trace.GasUsed = ret.cumulativeGasUsed.Uint64()
+		}
+		traces = append(traces, trace)
+		if i == index {
+			break
+		}
+	}
+	return traces, nil
+}
@@ -35,8 +35,9 @@ func intrinsicGas(transaction *types.Transaction) *big.Int {
 	return gasBig
 }
 
-// commonValidate performs the validations on all of transactions
-func commonValidate(tx *types.Transaction) error {
+// commonValidate performs the validations on all of transactions.
+// The height is the one the transaction expected to be executed at, 0 if unknown and the height related validations are skipped
+func commonValidate(tx *types.Transaction, height uint64) error {
 	size := 0
 	if tx.Data != nil {
 		size += len(tx.Data)
@@ -50,6 +51,26 @@ func commonValidate(tx *types.Transaction) error {
 	if tx.Sign == nil {
 		return fmt.Errorf("tx sign nil")
 	}
+	if height > 0 {
+		if err := expireValidate(tx, height); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expireValidate checks whether the transaction can be executed at the given height according to its expire height
+func expireValidate(tx *types.Transaction, height uint64) error {
+	if tx.ExpireHeight == nil {
+		return nil
+	}
+	cfg := params.GetChainConfig()
+	if !cfg.IsZIP005(height) {
+		return fmt.Errorf("expire height not supported before height %v", cfg.ZIP005)
+	}
+	if *tx.ExpireHeight < height {
+		return ErrExpired
+	}
 	return nil
 }
 
@@ -90,7 +111,15 @@ func valueValidate(tx *types.Transaction) error {
 	return nil
 }
 
-func sourceRecover(tx *types.Transaction) error {
+// sourceRecover recovers the source from the sign and checks whether it equals to the given one.
+// The chain id, covered by the signed hash, is checked first for the transactions executed at the given height,
+// and skipped if the height is 0
+func sourceRecover(tx *types.Transaction, height uint64) error {
+	if height > 0 {
+		if err := chainIdValidate(tx, height); err != nil {
+			return err
+		}
+	}
 	var sign = common.BytesToSign(tx.Sign)
 	if sign == nil {
 		return fmt.Errorf("BytesToSign fail, sign=%v", tx.Sign)
@@ -107,6 +136,26 @@ func sourceRecover(tx *types.Transaction) error {
 	return nil
 }
 
+// chainIdValidate checks the chain id of the transaction executed at the given height.
+// The chain id is required since zip004 for replay protection, and not allowed before so that
+// the transaction hash is compatible with the nodes before zip004
+func chainIdValidate(tx *types.Transaction, height uint64) error {
+	cfg := params.GetChainConfig()
+	if !cfg.IsZIP004(height) {
+		if tx.ChainId != nil {
+			return fmt.Errorf("chain id not supported before height %v", cfg.ZIP004)
+		}
+		return nil
+	}
+	if tx.ChainId == nil {
+		return fmt.Errorf("chain id required")
+	}
+	if *tx.ChainId != cfg.ChainId {
+		return fmt.Errorf("chain id mismatch, expect %v but got %v", cfg.ChainId, *tx.ChainId)
+	}
+	return nil
+}
+
 func senderValidate(sender common.Address, db types.AccountDB, height uint64) error {
 	if params.GetChainConfig().IsZIP003(height) && governInstance.isBlack(db, sender) {
 		return fmt.Errorf("sender cannot launch the transaction")
@@ -336,8 +385,15 @@ func blackUpdateValidate(tx *types.Transaction, validateState bool) error {
 func getValidator(tx *types.Transaction, validateState bool) validator {
 	return func() error {
 		var err error
+		// The height related validations are only done with the state validations, as the transactions
+		// of the blocks being verified may be executed at the height other than the next one.
+		// They will be checked again when executing anyway
+		height := uint64(0)
+		if validateState {
+			height = BlockChainImpl.Height() + 1
+		}
 		// Common validations
-		if err = commonValidate(tx); err != nil {
+		if err = commonValidate(tx, height); err != nil {
 			return err
 		}
 		// Reward tx type
@@ -393,7 +449,7 @@ func getValidator(tx *types.Transaction, validateState bool) validator {
 				return err
 			}
 			// Recover source at last for performance concern
-			if err := sourceRecover(tx); err != nil {
+			if err := sourceRecover(tx, height); err != nil {
 				return err
 			}
 		}
@@ -0,0 +1,83 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/zvchain/zvchain/params"
+)
+
+func TestChainIdValidate(t *testing.T) {
+	cfg := params.GetChainConfig()
+	old := cfg.ZIP004
+	cfg.ZIP004 = 100
+	defer func() {
+		cfg.ZIP004 = old
+	}()
+
+	tx := genTestTx(500, "1", 1, 1)
+	if err := chainIdValidate(tx, 99); err != nil {
+		t.Errorf("transaction without chain id should pass before zip004:%v", err)
+	}
+	if err := chainIdValidate(tx, 100); err == nil {
+		t.Errorf("transaction without chain id should fail since zip004")
+	}
+
+	tx.ChainId = cfg.TxChainId(100)
+	if err := chainIdValidate(tx, 99); err == nil {
+		t.Errorf("transaction with chain id should fail before zip004")
+	}
+	if err := chainIdValidate(tx, 100); err != nil {
+		t.Errorf("transaction with the right chain id should pass since zip004:%v", err)
+	}
+	wrong := cfg.ChainId + 1
+	tx.ChainId = &wrong
+	if err := chainIdValidate(tx, 100); err == nil {
+		t.Errorf("transaction with the wrong chain id should fail")
+	}
+}
+
+func TestExpireValidate(t *testing.T) {
+	cfg := params.GetChainConfig()
+	old := cfg.ZIP005
+	cfg.ZIP005 = 100
+	defer func() {
+		cfg.ZIP005 = old
+	}()
+
+	tx := genTestTx(500, "1", 1, 1)
+	if err := expireValidate(tx, 200); err != nil {
+		t.Errorf("transaction without expire height should pass:%v", err)
+	}
+	expire := uint64(150)
+	tx.ExpireHeight = &expire
+	if err := expireValidate(tx, 99); err == nil {
+		t.Errorf("transaction with expire height should fail before zip005")
+	}
+	if err := expireValidate(tx, 150); err != nil {
+		t.Errorf("transaction should pass at the expire height:%v", err)
+	}
+	if err := expireValidate(tx, 151); err != ErrExpired {
+		t.Errorf("transaction should be expired after the expire height, got %v", err)
+	}
+	if err := commonValidate(tx, 151); err != ErrExpired {
+		t.Errorf("common validate should reject the expired transaction, got %v", err)
+	}
+	if err := commonValidate(tx, 0); err != nil {
+		t.Errorf("common validate should skip the height related validations without height:%v", err)
+	}
+}
@@ -2,8 +2,11 @@ package log
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"time"
 
@@ -178,3 +181,41 @@ func (lrs *Logrusplus) Logger(fileName string, maxSize int64, maxFiles int, leve
 
 	return logger
 }
+
+// SetLevel changes the level of the logger of the module, which is the log file name without the directory
+// like "core" and "p2p". All the loggers are changed if the module is "all"
+func (lrs *Logrusplus) SetLevel(module string, level logrus.Level) error {
+	found := false
+	for fileName, logger := range lrs.loggers {
+		if module == "all" || filepath.Base(fileName) == module {
+			logger.SetLevel(level)
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("unknown log module: %v", module)
+	}
+	return nil
+}
+
+// Modules returns the module names of the loggers
+func (lrs *Logrusplus) Modules() []string {
+	modules := make([]string, 0, len(lrs.loggers))
+	for fileName := range lrs.loggers {
+		modules = append(modules, filepath.Base(fileName))
+	}
+	sort.Strings(modules)
+	return modules
+}
+
+// SetLevel changes the level of the logger of the module at runtime
+func SetLevel(module string, level string) error {
+	if RusPlus == nil {
+		return fmt.Errorf("log not initialized")
+	}
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	return RusPlus.SetLevel(module, lvl)
+}
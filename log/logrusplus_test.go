@@ -1,9 +1,13 @@
 package log
 
 import (
-	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 func Test_Main(t *testing.T) {
@@ -61,3 +65,34 @@ func clearLogLevel() {
 	GroupLogger.SetLevel(logrus.PanicLevel)
 
 }
+
+func TestLogrusplus_SetLevel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lrs := New()
+	core := lrs.Logger(filepath.Join(dir, "core"), 1024, 1, logrus.InfoLevel)
+	p2p := lrs.Logger(filepath.Join(dir, "p2p"), 1024, 1, logrus.InfoLevel)
+
+	if err := lrs.SetLevel("core", logrus.DebugLevel); err != nil {
+		t.Fatalf("set level error:%v", err)
+	}
+	if core.GetLevel() != logrus.DebugLevel || p2p.GetLevel() != logrus.InfoLevel {
+		t.Errorf("only the core logger should be changed")
+	}
+	if err := lrs.SetLevel("all", logrus.WarnLevel); err != nil {
+		t.Fatalf("set level error:%v", err)
+	}
+	if core.GetLevel() != logrus.WarnLevel || p2p.GetLevel() != logrus.WarnLevel {
+		t.Errorf("all loggers should be changed")
+	}
+	if err := lrs.SetLevel("unknown", logrus.WarnLevel); err == nil {
+		t.Errorf("expect error for unknown module")
+	}
+	if modules := lrs.Modules(); len(modules) != 2 || modules[0] != "core" || modules[1] != "p2p" {
+		t.Errorf("unexpected modules %v", modules)
+	}
+}
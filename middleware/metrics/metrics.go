@@ -0,0 +1,397 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package metrics provides the registry of the node metrics, which can be exposed
+// in the prometheus text format or read by the other reporters
+package metrics
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Type is the type of a metric
+type Type string
+
+// Types of the metrics, named as prometheus does
+const (
+	TypeCounter   Type = "counter"
+	TypeGauge     Type = "gauge"
+	TypeHistogram Type = "histogram"
+)
+
+// Label is a name value pair identifying a sample of the metric
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is one value of a metric
+type Sample struct {
+	Name   string // Name of the sample, the histogram ones have the suffixes _bucket, _sum or _count
+	Labels []Label
+	Value  float64
+}
+
+type metric interface {
+	collect() []*Sample
+}
+
+type entry struct {
+	name   string
+	help   string
+	typ    Type
+	metric metric
+}
+
+// Registry holds the metrics by name
+type Registry struct {
+	lock    sync.RWMutex
+	entries map[string]*entry
+}
+
+// DefaultRegistry is the registry used by the package level functions
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{entries: make(map[string]*entry)}
+}
+
+// getOrRegister returns the metric registered with the name if of the same type, otherwise
+// registers the one created. So the modules initialized more than once share the same metrics
+func (r *Registry) getOrRegister(name, help string, typ Type, create func() metric) metric {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	m := create()
+	if e, ok := r.entries[name]; ok && e.typ == typ && reflect.TypeOf(e.metric) == reflect.TypeOf(m) {
+		return e.metric
+	}
+	r.entries[name] = &entry{name: name, help: help, typ: typ, metric: m}
+	return m
+}
+
+// register registers the metric, replacing the one with the same name
+func (r *Registry) register(name, help string, typ Type, m metric) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.entries[name] = &entry{name: name, help: help, typ: typ, metric: m}
+}
+
+// Unregister removes the metric with the name
+func (r *Registry) Unregister(name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.entries, name)
+}
+
+func (r *Registry) sortedEntries() []*entry {
+	r.lock.RLock()
+	entries := make([]*entry, 0, len(r.entries))
+	for _, e := range r.entries {
+		entries = append(entries, e)
+	}
+	r.lock.RUnlock()
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].name < entries[j].name
+	})
+	return entries
+}
+
+// Samples returns the current values of all metrics sorted by name
+func (r *Registry) Samples() []*Sample {
+	samples := make([]*Sample, 0)
+	for _, e := range r.sortedEntries() {
+		samples = append(samples, e.metric.collect()...)
+	}
+	return samples
+}
+
+// Counter is a value which only increases
+type Counter struct {
+	name   string
+	labels []Label
+	value  uint64
+}
+
+// Inc increases the counter by 1
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.value, 1)
+}
+
+// Add increases the counter by n
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.value, n)
+}
+
+// Value returns the current value of the counter
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+func (c *Counter) collect() []*Sample {
+	return []*Sample{{Name: c.name, Labels: c.labels, Value: float64(c.Value())}}
+}
+
+// Gauge is a value which can go up and down
+type Gauge struct {
+	name   string
+	labels []Label
+	bits   uint64
+}
+
+// Set sets the value of the gauge
+func (g *Gauge) Set(v float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+}
+
+// Add adds the delta, which may be negative, to the gauge
+func (g *Gauge) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&g.bits)
+		v := math.Float64frombits(old) + delta
+		if atomic.CompareAndSwapUint64(&g.bits, old, math.Float64bits(v)) {
+			return
+		}
+	}
+}
+
+// Value returns the current value of the gauge
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+func (g *Gauge) collect() []*Sample {
+	return []*Sample{{Name: g.name, Labels: g.labels, Value: g.Value()}}
+}
+
+// DefaultBuckets are the upper bounds of the histogram buckets in seconds, suitable for the latencies
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Histogram counts the observations in the buckets
+type Histogram struct {
+	name    string
+	buckets []float64 // Upper bounds in ascending order
+	lock    sync.Mutex
+	counts  []uint64 // Counts of the observations in each bucket, not cumulative
+	sum     float64
+	count   uint64
+}
+
+// Observe records an observation
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if i < len(h.buckets) {
+		h.counts[i]++
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *Histogram) collect() []*Sample {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	samples := make([]*Sample, 0, len(h.buckets)+3)
+	cumulative := uint64(0)
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i]
+		samples = append(samples, &Sample{Name: h.name + "_bucket", Labels: []Label{{"le", formatFloat(bound)}}, Value: float64(cumulative)})
+	}
+	samples = append(samples,
+		&Sample{Name: h.name + "_bucket", Labels: []Label{{"le", "+Inf"}}, Value: float64(h.count)},
+		&Sample{Name: h.name + "_sum", Value: h.sum},
+		&Sample{Name: h.name + "_count", Value: float64(h.count)},
+	)
+	return samples
+}
+
+// vec holds the children of a metric by the label values
+type vec struct {
+	name       string
+	labelNames []string
+	lock       sync.RWMutex
+	children   map[string]metric
+	create     func(labels []Label) metric
+}
+
+func (v *vec) with(values []string) metric {
+	if len(values) != len(v.labelNames) {
+		panic("metrics: inconsistent label cardinality of " + v.name)
+	}
+	key := strings.Join(values, "\xff")
+	v.lock.RLock()
+	m, ok := v.children[key]
+	v.lock.RUnlock()
+	if ok {
+		return m
+	}
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if m, ok = v.children[key]; ok {
+		return m
+	}
+	labels := make([]Label, len(values))
+	for i, value := range values {
+		labels[i] = Label{Name: v.labelNames[i], Value: value}
+	}
+	m = v.create(labels)
+	v.children[key] = m
+	return m
+}
+
+func (v *vec) collect() []*Sample {
+	v.lock.RLock()
+	keys := make([]string, 0, len(v.children))
+	for key := range v.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	samples := make([]*Sample, 0, len(keys))
+	for _, key := range keys {
+		samples = append(samples, v.children[key].collect()...)
+	}
+	v.lock.RUnlock()
+	return samples
+}
+
+// CounterVec is a set of counters with the same name and different label values
+type CounterVec struct {
+	vec
+}
+
+// With returns the counter of the label values, in the order of the label names
+func (cv *CounterVec) With(values ...string) *Counter {
+	return cv.with(values).(*Counter)
+}
+
+// GaugeVec is a set of gauges with the same name and different label values
+type GaugeVec struct {
+	vec
+}
+
+// With returns the gauge of the label values, in the order of the label names
+func (gv *GaugeVec) With(values ...string) *Gauge {
+	return gv.with(values).(*Gauge)
+}
+
+// funcMetric reads the samples from the function when collected
+type funcMetric struct {
+	fn func() []*Sample
+}
+
+func (f *funcMetric) collect() []*Sample {
+	return f.fn()
+}
+
+// NewCounter returns the counter registered with the name, registers a new one if not exists
+func (r *Registry) NewCounter(name, help string) *Counter {
+	return r.getOrRegister(name, help, TypeCounter, func() metric {
+		return &Counter{name: name}
+	}).(*Counter)
+}
+
+// NewGauge returns the gauge registered with the name, registers a new one if not exists
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	return r.getOrRegister(name, help, TypeGauge, func() metric {
+		return &Gauge{name: name}
+	}).(*Gauge)
+}
+
+// NewHistogram returns the histogram registered with the name, registers a new one with
+// the bucket upper bounds if not exists. DefaultBuckets are used if buckets not given
+func (r *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	return r.getOrRegister(name, help, TypeHistogram, func() metric {
+		bs := append([]float64{}, buckets...)
+		sort.Float64s(bs)
+		return &Histogram{name: name, buckets: bs, counts: make([]uint64, len(bs))}
+	}).(*Histogram)
+}
+
+// NewCounterVec returns the counter vector registered with the name, registers a new one if not exists
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return r.getOrRegister(name, help, TypeCounter, func() metric {
+		return &CounterVec{vec{name: name, labelNames: labelNames, children: make(map[string]metric), create: func(labels []Label) metric {
+			return &Counter{name: name, labels: labels}
+		}}}
+	}).(*CounterVec)
+}
+
+// NewGaugeVec returns the gauge vector registered with the name, registers a new one if not exists
+func (r *Registry) NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return r.getOrRegister(name, help, TypeGauge, func() metric {
+		return &GaugeVec{vec{name: name, labelNames: labelNames, children: make(map[string]metric), create: func(labels []Label) metric {
+			return &Gauge{name: name, labels: labels}
+		}}}
+	}).(*GaugeVec)
+}
+
+// NewGaugeFunc registers the gauge whose value is read from the function when collected.
+// It replaces the one registered with the same name
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(name, help, TypeGauge, &funcMetric{fn: func() []*Sample {
+		return []*Sample{{Name: name, Value: fn()}}
+	}})
+}
+
+// NewCollector registers the metric whose samples are read from the function when collected,
+// which is used for the values with labels maintained elsewhere. It replaces the one registered
+// with the same name
+func (r *Registry) NewCollector(name, help string, typ Type, fn func() []*Sample) {
+	r.register(name, help, typ, &funcMetric{fn: fn})
+}
+
+// NewCounter returns the counter in the default registry
+func NewCounter(name, help string) *Counter {
+	return DefaultRegistry.NewCounter(name, help)
+}
+
+// NewGauge returns the gauge in the default registry
+func NewGauge(name, help string) *Gauge {
+	return DefaultRegistry.NewGauge(name, help)
+}
+
+// NewHistogram returns the histogram in the default registry
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	return DefaultRegistry.NewHistogram(name, help, buckets)
+}
+
+// NewCounterVec returns the counter vector in the default registry
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return DefaultRegistry.NewCounterVec(name, help, labelNames...)
+}
+
+// NewGaugeVec returns the gauge vector in the default registry
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return DefaultRegistry.NewGaugeVec(name, help, labelNames...)
+}
+
+// NewGaugeFunc registers the gauge function in the default registry
+func NewGaugeFunc(name, help string, fn func() float64) {
+	DefaultRegistry.NewGaugeFunc(name, help, fn)
+}
+
+// NewCollector registers the collector function in the default registry
+func NewCollector(name, help string, typ Type, fn func() []*Sample) {
+	DefaultRegistry.NewCollector(name, help, typ, fn)
+}
@@ -0,0 +1,100 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("test_requests_total", "Number of requests")
+	c.Inc()
+	c.Add(2)
+	if r.NewCounter("test_requests_total", "") != c {
+		t.Fatalf("expect the registered counter returned")
+	}
+	r.NewGauge("test_height", "").Set(12.5)
+	cv := r.NewCounterVec("test_bytes_total", "Bytes by code", "code", "dir")
+	cv.With("2", "send").Add(10)
+	cv.With("1", "recv").Add(5)
+	cv.With("2", "send").Add(1)
+	r.NewGaugeFunc("test_func", "", func() float64 { return 7 })
+	h := r.NewHistogram("test_latency_seconds", "", []float64{1, 0.1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(3)
+	r.NewCollector("test_collected", "", TypeGauge, func() []*Sample {
+		return []*Sample{{Name: "test_collected", Labels: []Label{{"name", "a\"b"}}, Value: 1}}
+	})
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expect := `# HELP test_bytes_total Bytes by code
+# TYPE test_bytes_total counter
+test_bytes_total{code="1",dir="recv"} 5
+test_bytes_total{code="2",dir="send"} 11
+# TYPE test_collected gauge
+test_collected{name="a\"b"} 1
+# TYPE test_func gauge
+test_func 7
+# TYPE test_height gauge
+test_height 12.5
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{le="0.1"} 1
+test_latency_seconds_bucket{le="1"} 2
+test_latency_seconds_bucket{le="+Inf"} 3
+test_latency_seconds_sum 3.55
+test_latency_seconds_count 3
+# HELP test_requests_total Number of requests
+# TYPE test_requests_total counter
+test_requests_total 3
+`
+	if buf.String() != expect {
+		t.Errorf("unexpected text:\n%v", buf.String())
+	}
+
+	// Function metrics are replaced, and a metric of another type replaces the old one
+	r.NewGaugeFunc("test_func", "", func() float64 { return 8 })
+	r.NewGauge("test_requests_total", "").Set(1)
+	for _, s := range r.Samples() {
+		if s.Name == "test_func" && s.Value != 8 || s.Name == "test_requests_total" && s.Value != 1 {
+			t.Errorf("unexpected sample %+v", s)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "").Inc()
+
+	rec := httptest.NewRecorder()
+	Handler(r).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Header().Get("Content-Type") != ContentType || !strings.Contains(rec.Body.String(), "test_total 1\n") {
+		t.Errorf("unexpected response %v %v", rec.Header(), rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	Handler(r).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expect post rejected, got %v", rec.Code)
+	}
+}
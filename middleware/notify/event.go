@@ -35,4 +35,6 @@ const (
 	TxSyncNotify   = "tx_sync_notify"
 	TxSyncReq      = "tx_sync_req"
 	TxSyncResponse = "tx_sync_response"
+
+	TransactionAddSucc = "transaction_add_succ"
 )
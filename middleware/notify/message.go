@@ -30,6 +30,18 @@ func (m *BlockOnChainSuccMessage) GetData() interface{} {
 	return m.Block
 }
 
+// TransactionAddSuccMessage is published when a transaction is accepted by the transaction pool
+type TransactionAddSuccMessage struct {
+	Tx *types.Transaction
+}
+
+func (m *TransactionAddSuccMessage) GetRaw() []byte {
+	return []byte{}
+}
+func (m *TransactionAddSuccMessage) GetData() interface{} {
+	return m.Tx
+}
+
 type GroupOnChainSuccMessage struct {
 	Group types.GroupI
 }
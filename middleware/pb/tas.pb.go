@@ -96,6 +96,8 @@ type RawTransaction struct {
 	ExtraData            []byte   `protobuf:"bytes,8,opt,name=ExtraData" json:"ExtraData,omitempty"`
 	Type                 *int32   `protobuf:"varint,9,req,name=Type" json:"Type,omitempty"`
 	Sign                 []byte   `protobuf:"bytes,10,opt,name=Sign" json:"Sign,omitempty"`
+	ChainId              *uint32  `protobuf:"varint,11,opt,name=ChainId" json:"ChainId,omitempty"`
+	ExpireHeight         *uint64  `protobuf:"varint,12,opt,name=ExpireHeight" json:"ExpireHeight,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -204,6 +206,20 @@ func (m *RawTransaction) GetSign() []byte {
 	return nil
 }
 
+func (m *RawTransaction) GetChainId() uint32 {
+	if m != nil && m.ChainId != nil {
+		return *m.ChainId
+	}
+	return 0
+}
+
+func (m *RawTransaction) GetExpireHeight() uint64 {
+	if m != nil && m.ExpireHeight != nil {
+		return *m.ExpireHeight
+	}
+	return 0
+}
+
 type TransactionRequestMessage struct {
 	TransactionHashes    [][]byte `protobuf:"bytes,1,rep,name=TransactionHashes" json:"TransactionHashes,omitempty"`
 	CurrentBlockHash     []byte   `protobuf:"bytes,2,req,name=CurrentBlockHash" json:"CurrentBlockHash,omitempty"`
@@ -1745,6 +1761,16 @@ func (m *RawTransaction) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintTas(dAtA, i, uint64(len(m.Sign)))
 		i += copy(dAtA[i:], m.Sign)
 	}
+	if m.ChainId != nil {
+		dAtA[i] = 0x58
+		i++
+		i = encodeVarintTas(dAtA, i, uint64(*m.ChainId))
+	}
+	if m.ExpireHeight != nil {
+		dAtA[i] = 0x60
+		i++
+		i = encodeVarintTas(dAtA, i, uint64(*m.ExpireHeight))
+	}
 	if m.XXX_unrecognized != nil {
 		i += copy(dAtA[i:], m.XXX_unrecognized)
 	}
@@ -2780,6 +2806,12 @@ func (m *RawTransaction) Size() (n int) {
 		l = len(m.Sign)
 		n += 1 + l + sovTas(uint64(l))
 	}
+	if m.ChainId != nil {
+		n += 1 + sovTas(uint64(*m.ChainId))
+	}
+	if m.ExpireHeight != nil {
+		n += 1 + sovTas(uint64(*m.ExpireHeight))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -3799,6 +3831,46 @@ func (m *RawTransaction) Unmarshal(dAtA []byte) error {
 				m.Sign = []byte{}
 			}
 			iNdEx = postIndex
+		case 11:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ChainId", wireType)
+			}
+			var v uint32
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTas
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.ChainId = &v
+		case 12:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ExpireHeight", wireType)
+			}
+			var v uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTas
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.ExpireHeight = &v
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTas(dAtA[iNdEx:])
@@ -0,0 +1,70 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package statistics
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/zvchain/zvchain/middleware/metrics"
+)
+
+// metricsReportCode is the type header of the posts carrying the metrics
+const metricsReportCode = "metrics"
+
+type metricSample struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+// encodeMetrics encodes the samples of the registry in json
+func encodeMetrics(r *metrics.Registry) (*bytes.Buffer, error) {
+	samples := r.Samples()
+	objs := make([]*metricSample, 0, len(samples))
+	for _, s := range samples {
+		obj := &metricSample{Name: s.Name, Value: s.Value}
+		if len(s.Labels) > 0 {
+			obj.Labels = make(map[string]string, len(s.Labels))
+			for _, l := range s.Labels {
+				obj.Labels[l.Name] = l.Value
+			}
+		}
+		objs = append(objs, obj)
+	}
+	bs, err := json.Marshal(objs)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(bs), nil
+}
+
+// reportMetrics posts the metrics of the default registry to the collector periodically
+func reportMetrics(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for range t.C {
+		buf, err := encodeMetrics(metrics.DefaultRegistry)
+		if err != nil {
+			if logger != nil {
+				logger.Errorf("encode metrics error:%v", err)
+			}
+			continue
+		}
+		SendPost(buf, metricsReportCode)
+	}
+}
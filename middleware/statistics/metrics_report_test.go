@@ -0,0 +1,39 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package statistics
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/zvchain/zvchain/middleware/metrics"
+)
+
+func TestEncodeMetrics(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.NewCounterVec("test_total", "", "code").With("1").Add(3)
+	buf, err := encodeMetrics(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var samples []*metricSample
+	if err := json.Unmarshal(buf.Bytes(), &samples); err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 1 || samples[0].Name != "test_total" || samples[0].Labels["code"] != "1" || samples[0].Value != 3 {
+		t.Errorf("unexpected samples %+v", samples)
+	}
+}
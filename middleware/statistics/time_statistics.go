@@ -144,6 +144,10 @@ func InitStatistics(config common.ConfManager) {
 		}
 	}()
 	initCount(config)
+	// The metrics are posted to the same collector if enabled
+	if config.GetBool("statistics", "metrics_report", false) {
+		go reportMetrics(time.Duration(config.GetInt("statistics", "metrics_report_interval", 60)) * time.Second)
+	}
 }
 
 func HasInit() bool {
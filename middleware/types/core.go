@@ -103,6 +103,9 @@ type RawTransaction struct {
 	ExtraData []byte          `msgpack:"ed"`
 	Sign      []byte          `msgpack:"si"`            // The Sign of the sender
 	Source    *common.Address `msgpack:"src,omitempty"` // Sender address, recovered from sign
+	ChainId   *uint16         `msgpack:"ci,omitempty"`  // Chain id for replay protection, required since zip004 and nil before
+
+	ExpireHeight *uint64 `msgpack:"eh,omitempty"` // The last block height the transaction can be executed at, nil if never expires
 }
 
 // Transaction denotes one transaction infos
@@ -134,7 +137,8 @@ func (tx *RawTransaction) GetType() int8 {
 	return tx.Type
 }
 
-// GenHash generate unique hash of the transaction. source,sign is out of the hash calculation range
+// GenHash generate unique hash of the transaction. source,sign is out of the hash calculation range.
+// The chain id and the expire height are only hashed if given, so the hash of the transactions without them keeps unchanged
 func (tx *RawTransaction) GenHash() common.Hash {
 	if nil == tx {
 		return common.Hash{}
@@ -174,6 +178,12 @@ func (tx *RawTransaction) GenHash() common.Hash {
 		data:     tx.Data,
 		extra:    tx.ExtraData,
 	}
+	if tx.ChainId != nil {
+		txH.chainId = common.UInt16ToByte(*tx.ChainId)
+	}
+	if tx.ExpireHeight != nil {
+		txH.expireHeight = common.UInt64ToByte(*tx.ExpireHeight)
+	}
 
 	return txH.genHash()
 }
@@ -22,6 +22,7 @@ import (
 	"testing"
 
 	"github.com/zvchain/zvchain/common"
+	tas_middleware_pb "github.com/zvchain/zvchain/middleware/pb"
 	"github.com/zvchain/zvchain/storage/serialize"
 )
 
@@ -144,3 +145,128 @@ func TestGenHash(t *testing.T) {
 	}
 	t.Logf("txhash %v, gaslimit %v, gasprice %v", tx2.GenHash().Hex(), tx2.GasLimit, tx2.GasPrice)
 }
+
+func TestGenHashWithChainId(t *testing.T) {
+	src := common.BytesToAddress([]byte("0x123"))
+	target := common.BytesToAddress([]byte("0x234"))
+	tx := &RawTransaction{
+		Source:   &src,
+		Target:   &target,
+		Value:    NewBigInt(100),
+		GasLimit: NewBigInt(3000),
+		GasPrice: NewBigInt(500),
+		Nonce:    10,
+	}
+	legacy := tx.GenHash()
+
+	chainId := uint16(1)
+	tx.ChainId = &chainId
+	h1 := tx.GenHash()
+	if h1 == legacy {
+		t.Fatalf("chain id should be covered by the hash")
+	}
+	chainId = 2
+	if tx.GenHash() == h1 {
+		t.Fatalf("different chain ids should give different hashes")
+	}
+	tx.ChainId = nil
+	if tx.GenHash() != legacy {
+		t.Fatalf("hash changed for the transaction without chain id")
+	}
+}
+
+func TestChainIdMarshal(t *testing.T) {
+	src := common.BytesToAddress([]byte("4"))
+	chainId := uint16(7)
+	tx := &RawTransaction{
+		Value:    NewBigInt(100),
+		GasLimit: NewBigInt(200),
+		GasPrice: NewBigInt(200),
+		Source:   &src,
+		ChainId:  &chainId,
+	}
+	bs, err := msgpack.Marshal(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx2 := &RawTransaction{}
+	if err := msgpack.Unmarshal(bs, tx2); err != nil {
+		t.Fatal(err)
+	}
+	if tx2.ChainId == nil || *tx2.ChainId != chainId {
+		t.Fatalf("chain id lost after msgpack:%v", tx2.ChainId)
+	}
+
+	data, err := transactionToPb(tx).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pbTx := &tas_middleware_pb.RawTransaction{}
+	if err := pbTx.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	tx3, err := pbToTransaction(pbTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx3.ChainId == nil || *tx3.ChainId != chainId {
+		t.Fatalf("chain id lost after protobuf:%v", tx3.ChainId)
+	}
+	if tx3.GenHash() != tx.GenHash() {
+		t.Fatalf("gen hash diff after protobuf")
+	}
+
+	outOfRange := uint32(common.MaxUint16) + 1
+	pbTx.ChainId = &outOfRange
+	if _, err := pbToTransaction(pbTx); err == nil {
+		t.Fatalf("chain id out of range should be rejected")
+	}
+}
+
+func TestExpireHeightHashAndMarshal(t *testing.T) {
+	src := common.BytesToAddress([]byte("0x123"))
+	tx := &RawTransaction{
+		Value:    NewBigInt(100),
+		GasLimit: NewBigInt(3000),
+		GasPrice: NewBigInt(500),
+		Source:   &src,
+	}
+	legacy := tx.GenHash()
+	expire := uint64(1000)
+	tx.ExpireHeight = &expire
+	h := tx.GenHash()
+	if h == legacy {
+		t.Fatalf("expire height should be covered by the hash")
+	}
+
+	data, err := transactionToPb(tx).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pbTx := &tas_middleware_pb.RawTransaction{}
+	if err := pbTx.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	tx2, err := pbToTransaction(pbTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx2.ExpireHeight == nil || *tx2.ExpireHeight != expire {
+		t.Fatalf("expire height lost after protobuf:%v", tx2.ExpireHeight)
+	}
+	if tx2.GenHash() != h {
+		t.Fatalf("gen hash diff after protobuf")
+	}
+
+	bs, err := msgpack.Marshal(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx3 := &RawTransaction{}
+	if err := msgpack.Unmarshal(bs, tx3); err != nil {
+		t.Fatal(err)
+	}
+	if tx3.GenHash() != h {
+		t.Fatalf("gen hash diff after msgpack")
+	}
+}
@@ -132,6 +132,10 @@ type TransactionPool interface {
 	// AddTransaction adds new transaction to the transaction pool which will be broadcast
 	AddTransaction(tx *Transaction) (bool, error)
 
+	// AddLocalTransaction adds new transaction submitted locally to the transaction pool which will be broadcast,
+	// and is kept across the node restarts
+	AddLocalTransaction(tx *Transaction) (bool, error)
+
 	// AsyncAddTransaction adds transaction to the transaction pool which won't be broadcast
 	AsyncAddTransaction(tx *Transaction) error
 
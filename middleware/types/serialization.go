@@ -16,6 +16,8 @@
 package types
 
 import (
+	"fmt"
+
 	"github.com/gogo/protobuf/proto"
 	"github.com/sirupsen/logrus"
 	"github.com/zvchain/zvchain/common"
@@ -40,8 +42,7 @@ func UnMarshalTransactions(b []byte) ([]*RawTransaction, error) {
 		return nil, error
 	}
 
-	result := PbToTransactions(ts.Transactions)
-	return result, nil
+	return PbToTransactions(ts.Transactions)
 }
 
 // UnMarshalBlock deserialize from []byte to *Block
@@ -52,8 +53,7 @@ func UnMarshalBlock(bytes []byte) (*Block, error) {
 		MiddleWareLogger.Errorf("[handler]Unmarshal Block error:%s", error.Error())
 		return nil, error
 	}
-	block := PbToBlock(b)
-	return block, nil
+	return PbToBlock(b)
 }
 
 // UnMarshalBlockHeader deserialize from []byte to *BlockHeader
@@ -119,14 +119,15 @@ func byteToHash(b []byte) common.Hash {
 	return common.BytesToHash(b)
 }
 
-func pbToTransaction(t *tas_middleware_pb.RawTransaction) *RawTransaction {
+func pbToTransaction(t *tas_middleware_pb.RawTransaction) (*RawTransaction, error) {
 	if t == nil {
-		return &RawTransaction{}
+		return &RawTransaction{}, nil
 	}
 
 	var (
-		target *common.Address
-		source *common.Address
+		target  *common.Address
+		source  *common.Address
+		chainId *uint16
 	)
 	if t.Target != nil {
 		t := common.BytesToAddress(t.Target)
@@ -136,6 +137,14 @@ func pbToTransaction(t *tas_middleware_pb.RawTransaction) *RawTransaction {
 		t := common.BytesToAddress(t.Source)
 		source = &t
 	}
+	if t.ChainId != nil {
+		// The chain id is a uint16 on the wire of uint32, the larger one can't be signed by the sender
+		if *t.ChainId > common.MaxUint16 {
+			return nil, fmt.Errorf("chain id %v out of range", *t.ChainId)
+		}
+		id := uint16(*t.ChainId)
+		chainId = &id
+	}
 
 	value := new(BigInt).SetBytesWithSign(t.Value)
 	gasLimit := new(BigInt).SetBytesWithSign(t.GasLimit)
@@ -152,20 +161,28 @@ func pbToTransaction(t *tas_middleware_pb.RawTransaction) *RawTransaction {
 		ExtraData: t.ExtraData,
 		Type:      int8(ensureInt32(t.Type)),
 		Sign:      t.Sign,
+		ChainId:   chainId,
+	}
+	if t.ExpireHeight != nil {
+		h := *t.ExpireHeight
+		transaction.ExpireHeight = &h
 	}
-	return transaction
+	return transaction, nil
 }
 
-func PbToTransactions(txs []*tas_middleware_pb.RawTransaction) []*RawTransaction {
+func PbToTransactions(txs []*tas_middleware_pb.RawTransaction) ([]*RawTransaction, error) {
 	result := make([]*RawTransaction, 0)
 	if txs == nil {
-		return result
+		return result, nil
 	}
 	for _, t := range txs {
-		transaction := pbToTransaction(t)
+		transaction, err := pbToTransaction(t)
+		if err != nil {
+			return nil, err
+		}
 		result = append(result, transaction)
 	}
-	return result
+	return result, nil
 }
 
 func PbToBlockHeader(h *tas_middleware_pb.BlockHeader) *BlockHeader {
@@ -195,14 +212,17 @@ func PbToBlockHeader(h *tas_middleware_pb.BlockHeader) *BlockHeader {
 	return &header
 }
 
-func PbToBlock(b *tas_middleware_pb.Block) *Block {
+func PbToBlock(b *tas_middleware_pb.Block) (*Block, error) {
 	if b == nil {
-		return nil
+		return nil, nil
 	}
 	h := PbToBlockHeader(b.Header)
-	txs := PbToTransactions(b.Transactions)
+	txs, err := PbToTransactions(b.Transactions)
+	if err != nil {
+		return nil, err
+	}
 	block := &Block{Header: h, Transactions: txs}
-	return block
+	return block, nil
 }
 
 func transactionToPb(t *RawTransaction) *tas_middleware_pb.RawTransaction {
@@ -210,8 +230,9 @@ func transactionToPb(t *RawTransaction) *tas_middleware_pb.RawTransaction {
 		return nil
 	}
 	var (
-		target []byte
-		source []byte
+		target  []byte
+		source  []byte
+		chainId *uint32
 	)
 	if t.Target != nil {
 		target = t.Target.Bytes()
@@ -219,6 +240,10 @@ func transactionToPb(t *RawTransaction) *tas_middleware_pb.RawTransaction {
 	if t.Source != nil {
 		source = t.Source.Bytes()
 	}
+	if t.ChainId != nil {
+		id := uint32(*t.ChainId)
+		chainId = &id
+	}
 	tp := int32(t.Type)
 	transaction := tas_middleware_pb.RawTransaction{
 		Data:      t.Data,
@@ -231,6 +256,11 @@ func transactionToPb(t *RawTransaction) *tas_middleware_pb.RawTransaction {
 		Type:      &tp,
 		Sign:      t.Sign,
 		Source:    source,
+		ChainId:   chainId,
+	}
+	if t.ExpireHeight != nil {
+		h := *t.ExpireHeight
+		transaction.ExpireHeight = &h
 	}
 	return &transaction
 }
@@ -50,6 +50,9 @@ type txHashing struct {
 	typ      byte
 	data     []byte
 	extra    []byte
+	chainId  []byte // bytes with big-endian, nil if the transaction has no chain id
+
+	expireHeight []byte // bytes with big-endian, nil if the transaction never expires
 }
 
 func (th *txHashing) genHash() common.Hash {
@@ -63,5 +66,11 @@ func (th *txHashing) genHash() common.Hash {
 	buf.writeByte(th.typ)
 	buf.writeBytes(th.data)
 	buf.writeBytes(th.extra)
+	if th.chainId != nil {
+		buf.writeBytes(th.chainId)
+	}
+	if th.expireHeight != nil {
+		buf.writeBytes(th.expireHeight)
+	}
 	return common.BytesToHash(common.Sha256(buf.Bytes()))
 }
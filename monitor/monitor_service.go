@@ -245,10 +245,7 @@ func (ms *MonitorService) UpdateNodeInfo(ni *NodeInfo) {
 		dm["BlockHeight"] = ms.nodeInfo.BlockHeight
 		dm["GroupHeight"] = ms.nodeInfo.GroupHeight
 		dm["TxPoolCount"] = ms.nodeInfo.TxPoolCount
-		dm["CPU"] = ms.resStat.CPU
-		dm["Mem"] = ms.resStat.Mem
-		dm["RcvBps"] = ms.resStat.RcvBps
-		dm["TxBps"] = ms.resStat.TxBps
+		dm["CPU"], dm["Mem"], dm["RcvBps"], dm["TxBps"] = ms.resStat.stats()
 		dm["UpdateTime"] = time.Now().UTC()
 		dm["Instance"] = common.InstanceIndex
 
@@ -21,9 +21,11 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/codeskyblue/go-sh"
+	"github.com/zvchain/zvchain/middleware/metrics"
 )
 
 var spaceRe, _ = regexp.Compile("\\s+")
@@ -48,6 +50,7 @@ type NodeResStat struct {
 	Mem    float64
 	RcvBps float64
 	TxBps  float64
+	lock   sync.RWMutex // Guards the stats updated by the stat loop
 
 	cmTicker   *time.Ticker
 	flowTicker *time.Ticker
@@ -59,9 +62,36 @@ func initNodeResStat() *NodeResStat {
 		flowTicker: time.NewTicker(time.Second * 6),
 	}
 	go ns.startStatLoop()
+	ns.registerMetrics()
 	return ns
 }
 
+// stats returns the latest stats of the cpu, memory, receiving and transmitting rates
+func (ns *NodeResStat) stats() (cpu, mem, rcvBps, txBps float64) {
+	ns.lock.RLock()
+	defer ns.lock.RUnlock()
+	return ns.CPU, ns.Mem, ns.RcvBps, ns.TxBps
+}
+
+func (ns *NodeResStat) registerMetrics() {
+	metrics.NewGaugeFunc("gzv_node_cpu_percent", "CPU usage of the node process", func() float64 {
+		cpu, _, _, _ := ns.stats()
+		return cpu
+	})
+	metrics.NewGaugeFunc("gzv_node_memory_mb", "Memory usage of the node process in MB", func() float64 {
+		_, mem, _, _ := ns.stats()
+		return mem
+	})
+	metrics.NewGaugeFunc("gzv_node_receive_kbps", "Receiving rate of the network interface in kB/s", func() float64 {
+		_, _, rcvBps, _ := ns.stats()
+		return rcvBps
+	})
+	metrics.NewGaugeFunc("gzv_node_transmit_kbps", "Transmitting rate of the network interface in kB/s", func() float64 {
+		_, _, _, txBps := ns.stats()
+		return txBps
+	})
+}
+
 func (ns *NodeResStat) startStatLoop() {
 	for {
 		select {
@@ -96,8 +126,10 @@ func (ns *NodeResStat) statCPUAndMEM() {
 			f, _ := strconv.ParseFloat(mems, 64)
 			mem = f / 1000
 		}
+		ns.lock.Lock()
 		ns.CPU = cpu
 		ns.Mem = mem
+		ns.lock.Unlock()
 	} else {
 
 	}
@@ -118,8 +150,12 @@ func (ns *NodeResStat) statFlow() {
 		if len(arrs) < 8 {
 			return
 		}
-		ns.RcvBps, _ = strconv.ParseFloat(arrs[4], 64)
-		ns.TxBps, _ = strconv.ParseFloat(arrs[5], 64)
+		rcvBps, _ := strconv.ParseFloat(arrs[4], 64)
+		txBps, _ := strconv.ParseFloat(arrs[5], 64)
+		ns.lock.Lock()
+		ns.RcvBps = rcvBps
+		ns.TxBps = txBps
+		ns.lock.Unlock()
 	} else {
 	}
 	return
@@ -16,7 +16,17 @@
 package network
 
 import (
+	"strconv"
 	"sync"
+
+	"github.com/zvchain/zvchain/middleware/metrics"
+)
+
+var (
+	flowSentBytes    = metrics.NewCounterVec("gzv_p2p_sent_bytes_total", "Bytes sent by message code", "meter", "code")
+	flowSentMessages = metrics.NewCounterVec("gzv_p2p_sent_messages_total", "Messages sent by message code", "meter", "code")
+	flowRecvBytes    = metrics.NewCounterVec("gzv_p2p_received_bytes_total", "Bytes received by message code", "meter", "code")
+	flowRecvMessages = metrics.NewCounterVec("gzv_p2p_received_messages_total", "Messages received by message code", "meter", "code")
 )
 
 type FlowMeterItem struct {
@@ -61,6 +71,10 @@ func (fm *FlowMeter) send(code int64, size int64) {
 	item.count++
 	item.size += size
 	fm.sendSize += size
+
+	c := strconv.FormatInt(code, 10)
+	flowSentBytes.With(fm.name, c).Add(uint64(size))
+	flowSentMessages.With(fm.name, c).Inc()
 }
 
 func (fm *FlowMeter) recv(code int64, size int64) {
@@ -74,6 +88,10 @@ func (fm *FlowMeter) recv(code int64, size int64) {
 	item.count++
 	item.size += size
 	fm.recvSize += size
+
+	c := strconv.FormatInt(code, 10)
+	flowRecvBytes.With(fm.name, c).Add(uint64(size))
+	flowRecvMessages.With(fm.name, c).Inc()
 }
 
 func (fm *FlowMeter) reset() {
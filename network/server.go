@@ -16,6 +16,9 @@
 package network
 
 import (
+	"fmt"
+	"net"
+
 	"github.com/golang/protobuf/proto"
 
 	"time"
@@ -108,6 +111,41 @@ func (s *Server) ConnInfo() []Conn {
 	return s.netCore.peerManager.ConnInfo()
 }
 
+// AddPeer adds the node to the kad table and connects to it
+func (s *Server) AddPeer(id string, ip string, port int) error {
+	nID := NewNodeID(id)
+	if nID == nil || !nID.IsValid() {
+		return fmt.Errorf("invalid node id: %v", id)
+	}
+	if *nID == s.Self.ID {
+		return fmt.Errorf("can't add self as peer")
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return fmt.Errorf("invalid ip: %v", ip)
+	}
+	if port <= 0 || port > 65535 {
+		return fmt.Errorf("invalid port: %v", port)
+	}
+	node := NewNode(*nID, addr, port)
+	s.netCore.kad.add(node)
+	s.netCore.ping(node.ID, node.addr())
+	return nil
+}
+
+// RemovePeer disconnects the peer, which may be connected again by the node discovery later
+func (s *Server) RemovePeer(id string) error {
+	nID := NewNodeID(id)
+	if nID == nil || !nID.IsValid() {
+		return fmt.Errorf("invalid node id: %v", id)
+	}
+	if s.netCore.peerManager.peerByID(*nID) == nil {
+		return fmt.Errorf("peer not found: %v", id)
+	}
+	s.netCore.peerManager.disconnect(*nID)
+	return nil
+}
+
 func (s *Server) BuildGroupNet(groupID string, members []string) {
 	nodes := make([]NodeID, 0)
 	for _, id := range members {
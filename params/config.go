@@ -16,6 +16,8 @@
 package params
 
 import (
+	"math"
+
 	"github.com/zvchain/zvchain/common"
 )
 
@@ -32,6 +34,12 @@ type ChainConfig struct {
 
 	// zip003 solves the problem of weight comparison when two blocks have the same proves
 	ZIP003 uint64
+
+	// zip004 adds the chain id to the transaction hash to avoid replaying transactions across chains
+	ZIP004 uint64
+
+	// zip005 allows the transaction to carry an expire height after which it can never be executed
+	ZIP005 uint64
 }
 
 var config = &ChainConfig{
@@ -39,6 +47,10 @@ var config = &ChainConfig{
 	ZIP002: 960388, // effect at : 2019-10-31 14:00:00
 
 	ZIP003: 4945537, // effect at : 2020-3-16 14:00:00
+
+	ZIP004: math.MaxUint64, // not scheduled yet
+
+	ZIP005: math.MaxUint64, // not scheduled yet
 }
 
 func InitChainConfig(chainId uint16) {
@@ -68,3 +80,21 @@ func (cfg *ChainConfig) IsZIP002(h uint64) bool {
 func (cfg *ChainConfig) IsZIP003(h uint64) bool {
 	return isFork(cfg.ZIP003, h)
 }
+
+func (cfg *ChainConfig) IsZIP004(h uint64) bool {
+	return isFork(cfg.ZIP004, h)
+}
+
+func (cfg *ChainConfig) IsZIP005(h uint64) bool {
+	return isFork(cfg.ZIP005, h)
+}
+
+// TxChainId returns the chain id should be set in the transactions executed at the given height,
+// nil if zip004 not activated
+func (cfg *ChainConfig) TxChainId(h uint64) *uint16 {
+	if !cfg.IsZIP004(h) {
+		return nil
+	}
+	id := cfg.ChainId
+	return &id
+}
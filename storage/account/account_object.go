@@ -85,8 +85,9 @@ type accountObject struct {
 	// by StateDB.Commit.
 	dbErr error
 
-	trie Trie // storage trie, which becomes non-nil on first access
-	code Code // contract code, which gets set when code is loaded
+	trie Trie       // storage trie, which becomes non-nil on first access
+	code Code       // contract code, which gets set when code is loaded
+	flat FlatReader // flat state the account loaded from, nil if loaded from the trie
 
 	cachedLock    sync.RWMutex
 	cachedStorage Storage // Storage cache of original entries to dedup rewrites
@@ -198,7 +199,21 @@ func (ao *accountObject) GetData(db AccountDatabase, key []byte) []byte {
 	if exists {
 		return value
 	}
-	// Otherwise load the value from the database
+	// Otherwise load the value from the flat state if the account loaded from it, then the trie
+	if ao.flat != nil {
+		value, err := ao.flat.Storage(ao.address, key)
+		if err == nil {
+			if value != nil {
+				ao.cachedLock.Lock()
+				ao.cachedStorage[string(key)] = value
+				ao.cachedLock.Unlock()
+			}
+			return value
+		}
+		if err != ErrFlatUnavailable {
+			log.CoreLogger.Warnf("read flat storage of %v error:%v", ao.address.AddrPrefixString(), err)
+		}
+	}
 	value, err := ao.getTrie(db).TryGet(key)
 	if err != nil {
 		ao.setError(err)
@@ -66,6 +66,10 @@ type Trie interface {
 	// found in the database, a trie.MissingNodeError is returned.
 	TryDelete(key []byte) error
 
+	// Prove constructs a merkle proof for key. The proof contains the encoded nodes
+	// on the path to the value at key, and proves the absence of key if not found.
+	Prove(key []byte) ([][]byte, error)
+
 	// Commit writes all nodes to the trie's memory database, tracking the internal
 	// and external (for account tries) references.
 	Commit(onleaf trie.LeafCallback) (common.Hash, error)
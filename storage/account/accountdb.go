@@ -69,6 +69,9 @@ type AccountDB struct {
 	validRevisions []revision
 	nextRevisionID int
 
+	diff map[common.Address]*AccountDiff // Nil if not recording the diff
+	flat FlatReader                      // Nil if reading from the trie only
+
 	lock sync.RWMutex
 }
 
@@ -112,6 +115,7 @@ func (adb *AccountDB) Reset(root common.Hash) error {
 		return err
 	}
 	adb.trie = tr
+	adb.flat = nil
 	adb.accountObjects = new(sync.Map)
 	adb.accountObjectsDirty = make(map[common.Address]struct{})
 	adb.thash = common.Hash{}
@@ -337,6 +341,20 @@ func (adb *AccountDB) deleteAccountObject(stateObject *accountObject) {
 
 // Retrieve a account object given by the address. Returns nil if not found.
 func (adb *AccountDB) getAccountObjectFromTrie(addr common.Address) (stateObject *accountObject) {
+	if adb.flat != nil {
+		data, err := adb.flat.Account(addr)
+		if err == nil {
+			if data == nil {
+				return nil
+			}
+			obj := newAccountObject(adb, addr, *data, adb.MarkAccountObjectDirty)
+			obj.flat = adb.flat
+			return obj
+		}
+		if err != ErrFlatUnavailable {
+			log.CoreLogger.Warnf("read flat account %v error:%v", addr.AddrPrefixString(), err)
+		}
+	}
 	enc, err := adb.trie.TryGet(addr[:])
 	if len(enc) == 0 {
 		adb.setError(err)
@@ -490,12 +508,19 @@ func (adb *AccountDB) Finalise(deleteEmptyObjects bool) {
 			continue
 		}
 		accountObject := object.(*accountObject)
-		if accountObject.suicided || (deleteEmptyObjects && accountObject.empty()) {
+		deleted := accountObject.suicided || (deleteEmptyObjects && accountObject.empty())
+		if adb.diff != nil {
+			adb.diffStorage(accountObject)
+		}
+		if deleted {
 			adb.deleteAccountObject(accountObject)
 		} else {
 			accountObject.updateRoot(adb.db)
 			adb.updateAccountObject(accountObject)
 		}
+		if adb.diff != nil {
+			adb.diffAccount(accountObject, deleted)
+		}
 	}
 
 	adb.clearJournalAndRefund()
@@ -523,8 +548,13 @@ func (adb *AccountDB) Commit(deleteEmptyObjects bool) (root common.Hash, err err
 		addr := key.(common.Address)
 		_, isDirty := adb.accountObjectsDirty[addr]
 		accountObject := value.(*accountObject)
+		deleted := accountObject.suicided || (isDirty && deleteEmptyObjects && accountObject.empty())
+		if adb.diff != nil && (deleted || isDirty) {
+			adb.diffStorage(accountObject)
+			defer adb.diffAccount(accountObject, deleted)
+		}
 		switch {
-		case accountObject.suicided || (isDirty && deleteEmptyObjects && accountObject.empty()):
+		case deleted:
 			adb.deleteAccountObject(accountObject)
 		case isDirty:
 			if accountObject.code != nil && accountObject.dirtyCode {
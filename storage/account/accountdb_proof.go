@@ -0,0 +1,103 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package account
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/storage/rlp"
+)
+
+// AccountProof contains the merkle proof of an account and its storage slots.
+// The account proof is verified against the state root, and each storage proof is
+// verified against the StorageRoot of the account
+type AccountProof struct {
+	Address      common.Address
+	AccountProof [][]byte
+	Nonce        uint64
+	Balance      *big.Int
+	StorageRoot  common.Hash
+	CodeHash     common.Hash
+	StorageProof []*StorageProof
+}
+
+// StorageProof contains the merkle proof of a key in the account storage trie.
+// Value is nil if the key doesn't exist
+type StorageProof struct {
+	Key   []byte
+	Value []byte
+	Proof [][]byte
+}
+
+// GetProof returns the merkle proof of the given account and the storage keys of it.
+// The proof is built on the committed state the AccountDB opened with, so the modifications
+// not committed yet are not covered.
+func (adb *AccountDB) GetProof(addr common.Address, storageKeys [][]byte) (*AccountProof, error) {
+	accountProof, err := adb.trie.Prove(addr[:])
+	if err != nil {
+		return nil, err
+	}
+	ret := &AccountProof{
+		Address:      addr,
+		AccountProof: accountProof,
+		Balance:      new(big.Int),
+		StorageProof: make([]*StorageProof, 0, len(storageKeys)),
+	}
+	enc, err := adb.trie.TryGet(addr[:])
+	if err != nil {
+		return nil, err
+	}
+	if len(enc) == 0 {
+		// Account not exists, its storage keys are proven absent by the empty root
+		for _, key := range storageKeys {
+			ret.StorageProof = append(ret.StorageProof, &StorageProof{Key: key, Proof: [][]byte{}})
+		}
+		return ret, nil
+	}
+	var data Account
+	if err := rlp.DecodeBytes(enc, &data); err != nil {
+		return nil, fmt.Errorf("decode account error:%v", err)
+	}
+	ret.Nonce = data.Nonce
+	if data.Balance != nil {
+		ret.Balance.Set(data.Balance)
+	}
+	ret.StorageRoot = data.Root
+	ret.CodeHash = common.BytesToHash(data.CodeHash)
+
+	if len(storageKeys) == 0 {
+		return ret, nil
+	}
+	obj := newAccountObject(adb, addr, data, nil)
+	storageTrie, err := adb.db.OpenStorageTrie(obj.addrHash, data.Root)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range storageKeys {
+		value, err := storageTrie.TryGet(key)
+		if err != nil {
+			return nil, err
+		}
+		proof, err := storageTrie.Prove(key)
+		if err != nil {
+			return nil, err
+		}
+		ret.StorageProof = append(ret.StorageProof, &StorageProof{Key: key, Value: value, Proof: proof})
+	}
+	return ret, nil
+}
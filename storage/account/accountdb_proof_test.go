@@ -0,0 +1,85 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package account
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/storage/rlp"
+	"github.com/zvchain/zvchain/storage/tasdb"
+	"github.com/zvchain/zvchain/storage/trie"
+)
+
+func TestAccountDB_GetProof(t *testing.T) {
+	db, _ := tasdb.NewMemDatabase()
+	defer db.Close()
+	triedb := NewDatabase(db, false)
+	state, _ := NewAccountDB(common.Hash{}, triedb)
+	addr := common.BytesToAddress([]byte("1"))
+	state.SetBalance(addr, big.NewInt(1000))
+	state.SetNonce(addr, 3)
+	state.SetData(addr, []byte("aa"), []byte("v1"))
+	state.SetData(addr, []byte("bb"), []byte("v2"))
+	state.SetBalance(common.BytesToAddress([]byte("2")), big.NewInt(1))
+	root, _ := state.Commit(false)
+	triedb.TrieDB().Commit(0, root, false)
+
+	state, _ = NewAccountDB(root, triedb)
+	proof, err := state.GetProof(addr, [][]byte{[]byte("aa"), []byte("cc")})
+	if err != nil {
+		t.Fatalf("get proof error:%v", err)
+	}
+	if proof.Nonce != 3 || proof.Balance.Cmp(big.NewInt(1000)) != 0 {
+		t.Fatalf("wrong account in proof: nonce %v, balance %v", proof.Nonce, proof.Balance)
+	}
+	enc, err := trie.VerifyProof(root, addr[:], proof.AccountProof)
+	if err != nil {
+		t.Fatalf("verify account proof error:%v", err)
+	}
+	var data Account
+	if err := rlp.DecodeBytes(enc, &data); err != nil {
+		t.Fatalf("decode account error:%v", err)
+	}
+	if data.Root != proof.StorageRoot {
+		t.Fatalf("storage root mismatch")
+	}
+	for _, sp := range proof.StorageProof {
+		value, err := trie.VerifyProof(proof.StorageRoot, sp.Key, sp.Proof)
+		if err != nil {
+			t.Fatalf("verify storage proof of %s error:%v", sp.Key, err)
+		}
+		if !bytes.Equal(value, sp.Value) {
+			t.Fatalf("storage value mismatch of %s: have %s, want %s", sp.Key, value, sp.Value)
+		}
+	}
+	if string(proof.StorageProof[0].Value) != "v1" || proof.StorageProof[1].Value != nil {
+		t.Fatalf("wrong storage values in proof")
+	}
+
+	// Absent account
+	absent := common.BytesToAddress([]byte("3"))
+	proof, err = state.GetProof(absent, nil)
+	if err != nil {
+		t.Fatalf("get proof error:%v", err)
+	}
+	enc, err = trie.VerifyProof(root, absent[:], proof.AccountProof)
+	if err != nil || enc != nil {
+		t.Fatalf("absent account should be proven, value %x, err %v", enc, err)
+	}
+}
@@ -27,6 +27,9 @@ import (
 )
 
 type VisitAccountCallback func(stat *TraverseStat)
+
+// VisitStorageCallback is called with each storage entry of the account, the traversal stops if error returned
+type VisitStorageCallback func(addr common.Address, key []byte, value []byte) error
 type SubTreeKeyProvider func(address common.Address) [][]byte
 
 type TraverseStat struct {
@@ -38,6 +41,7 @@ type TraverseStat struct {
 	NodeCount uint64
 	KeySize   uint64
 	CodeSize  uint64
+	Code      []byte `json:"-"`
 	Cost      time.Duration
 }
 
@@ -48,6 +52,7 @@ func (vs *TraverseStat) String() string {
 
 type TraverseConfig struct {
 	VisitAccountCb      VisitAccountCallback
+	VisitStorageCb      VisitStorageCallback // Called concurrently, including the entries of the duplicate roots if VisitedRoots not set
 	ResolveNodeCb       trie.ResolveNodeCallback
 	CheckHash           bool
 	SubTreeKeysProvider SubTreeKeyProvider       // Provides concerned keys for the specified address, and only traverse the given keys for the address
@@ -67,6 +72,13 @@ func (cfg *TraverseConfig) OnVisitAccount(stat *TraverseStat) {
 	}
 }
 
+func (cfg *TraverseConfig) OnVisitStorage(addr common.Address, key []byte, value []byte) error {
+	if cfg.VisitStorageCb != nil {
+		return cfg.VisitStorageCb(addr, key, value)
+	}
+	return nil
+}
+
 func (cfg *TraverseConfig) subTreeKeys(address common.Address) [][]byte {
 	if cfg.SubTreeKeysProvider != nil {
 		return cfg.SubTreeKeysProvider(address)
@@ -106,7 +118,7 @@ func (adb *AccountDB) Traverse(config *TraverseConfig) (bool, error) {
 			atomic.AddUint64(&vs.DataCount, 1)
 			atomic.AddUint64(&vs.DataSize, uint64(len(v)))
 			atomic.AddUint64(&vs.KeySize, uint64(len(k)))
-			return nil
+			return config.OnVisitStorage(vs.Addr, k, v)
 		}
 
 		resolveCb := func(hash common.Hash, data []byte) {
@@ -146,6 +158,7 @@ func (adb *AccountDB) Traverse(config *TraverseConfig) (bool, error) {
 				return fmt.Errorf("get code %v err %v", codeHash.Hex(), err)
 			}
 			vs.CodeSize = uint64(len(code))
+			vs.Code = code
 			config.OnResolve(codeHash, code)
 		}
 		vs.Cost = time.Since(begin)
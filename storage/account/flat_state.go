@@ -0,0 +1,604 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package account
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/storage/rlp"
+	"github.com/zvchain/zvchain/storage/tasdb"
+)
+
+// The flat state keeps the accounts and storage of a state root in a flat key-value database, so the
+// reads don't need to walk the tries. The database, called the disk layer, stores:
+//
+//	'a' + address -> rlp encoded account
+//	's' + address + key -> storage value
+//	flatRootKey -> the state root of the disk layer
+//	flatGeneratingKey -> the state root being generated, the disk layer is incomplete if exists
+//
+// The changes of the latest blocks are kept in memory as diff layers on top of the disk layer, and the
+// diff layers far below the top are flattened into the disk layer.
+const (
+	flatAccountPrefix = 'a'
+	flatStoragePrefix = 's'
+)
+
+var (
+	flatRootKey       = []byte("root")
+	flatGeneratingKey = []byte("generating")
+
+	// ErrFlatUnavailable is returned if the flat state of the root is not available, the trie should be read instead
+	ErrFlatUnavailable = errors.New("flat state unavailable")
+
+	errFlatGenerationAborted = errors.New("flat state generation aborted")
+)
+
+// FlatReader reads the accounts and storage at a state root from the flat state
+type FlatReader interface {
+	// Root returns the state root of the reader
+	Root() common.Hash
+
+	// Account returns the account of the address, nil if not exists
+	Account(addr common.Address) (*Account, error)
+
+	// Storage returns the storage value of the address and key, nil if not exists
+	Storage(addr common.Address, key []byte) ([]byte, error)
+}
+
+// flatDiff is the changes of the accounts from the parent root to the root
+type flatDiff struct {
+	root       common.Hash
+	parent     common.Hash
+	accounts   map[common.Address]*Account // Nil for the deleted account
+	destructed map[common.Address]struct{} // Accounts deleted, whose storage is wiped
+	storage    map[common.Address]map[string][]byte
+}
+
+func newFlatDiff(root, parent common.Hash, diffs []*AccountDiff) *flatDiff {
+	d := &flatDiff{
+		root:       root,
+		parent:     parent,
+		accounts:   make(map[common.Address]*Account),
+		destructed: make(map[common.Address]struct{}),
+		storage:    make(map[common.Address]map[string][]byte),
+	}
+	for _, ad := range diffs {
+		if ad.Deleted {
+			d.accounts[ad.Address] = nil
+			d.destructed[ad.Address] = struct{}{}
+			continue
+		}
+		after := copyAccount(ad.After)
+		d.accounts[ad.Address] = &after
+		if len(ad.Storage) == 0 {
+			continue
+		}
+		values := make(map[string][]byte, len(ad.Storage))
+		for _, sd := range ad.Storage {
+			values[string(sd.Key)] = common.CopyBytes(sd.After)
+		}
+		d.storage[ad.Address] = values
+	}
+	return d
+}
+
+// FlatTree manages the disk layer and the diff layers of the flat state. It's safe for concurrent use
+type FlatTree struct {
+	db         *tasdb.PrefixedDatabase // The iterators return the keys without the iteration prefix
+	diskRoot   common.Hash
+	generating bool // Whether the disk layer is being generated and not readable
+	broken     bool // Whether the disk layer is incomplete as the generation failed, no diff layers kept until regenerated
+	diffs      map[common.Hash]*flatDiff
+	lock       sync.RWMutex
+
+	genLock sync.Mutex // Held by the running generation
+	abort   int32      // Set to abort the running generation
+	closed  int32
+}
+
+// NewFlatTree opens the flat state stored in the database
+func NewFlatTree(db *tasdb.PrefixedDatabase) *FlatTree {
+	t := &FlatTree{db: db, diffs: make(map[common.Hash]*flatDiff)}
+	if bs, err := db.Get(flatGeneratingKey); err == nil {
+		// The generation interrupted
+		t.diskRoot, t.broken = common.BytesToHash(bs), true
+	} else if bs, err := db.Get(flatRootKey); err == nil {
+		t.diskRoot = common.BytesToHash(bs)
+	} else {
+		// Nothing stored, needs to be generated
+		t.broken = true
+	}
+	return t
+}
+
+func flatAccountKey(addr common.Address) []byte {
+	return append([]byte{flatAccountPrefix}, addr.Bytes()...)
+}
+
+func flatStorageKey(addr common.Address, key []byte) []byte {
+	buf := make([]byte, 0, 1+common.AddressLength+len(key))
+	buf = append(buf, flatStoragePrefix)
+	buf = append(buf, addr.Bytes()...)
+	return append(buf, key...)
+}
+
+// DiskRoot returns the state root of the disk layer and whether it's readable
+func (t *FlatTree) DiskRoot() (common.Hash, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.diskRoot, t.readable()
+}
+
+// Generating returns whether the disk layer is being generated
+func (t *FlatTree) Generating() bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.generating
+}
+
+// readable returns whether the disk layer is complete, the lock should be held
+func (t *FlatTree) readable() bool {
+	return !t.generating && !t.broken
+}
+
+// Reader returns the reader of the given state root, nil if the root is not known by the flat state
+func (t *FlatTree) Reader(root common.Hash) FlatReader {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	if t.broken {
+		return nil
+	}
+	if _, ok := t.diffs[root]; !ok && root != t.diskRoot {
+		return nil
+	}
+	return &flatReader{tree: t, root: root}
+}
+
+func (t *FlatTree) account(root common.Hash, addr common.Address) (*Account, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	for h := root; h != t.diskRoot; {
+		d, ok := t.diffs[h]
+		if !ok {
+			return nil, ErrFlatUnavailable
+		}
+		if acc, ok := d.accounts[addr]; ok {
+			if acc == nil {
+				return nil, nil
+			}
+			cpy := copyAccount(*acc)
+			return &cpy, nil
+		}
+		h = d.parent
+	}
+	if !t.readable() {
+		return nil, ErrFlatUnavailable
+	}
+	enc, err := t.db.Get(flatAccountKey(addr))
+	if err == tasdb.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var data Account
+	if err := rlp.DecodeBytes(enc, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (t *FlatTree) storage(root common.Hash, addr common.Address, key []byte) ([]byte, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	for h := root; h != t.diskRoot; {
+		d, ok := t.diffs[h]
+		if !ok {
+			return nil, ErrFlatUnavailable
+		}
+		if value, ok := d.storage[addr][string(key)]; ok {
+			return value, nil
+		}
+		if _, ok := d.destructed[addr]; ok {
+			return nil, nil
+		}
+		h = d.parent
+	}
+	if !t.readable() {
+		return nil, ErrFlatUnavailable
+	}
+	value, err := t.db.Get(flatStorageKey(addr, key))
+	if err == tasdb.ErrNotFound {
+		return nil, nil
+	}
+	return value, err
+}
+
+// Update adds the diff layer of the root on top of the parent root. It does nothing if the root is known.
+// ErrFlatUnavailable is returned if the disk layer is broken, and the flat state should be regenerated
+func (t *FlatTree) Update(root, parent common.Hash, diffs []*AccountDiff) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.broken {
+		return ErrFlatUnavailable
+	}
+	if _, ok := t.diffs[root]; ok || root == t.diskRoot {
+		return nil
+	}
+	if _, ok := t.diffs[parent]; !ok && parent != t.diskRoot {
+		return fmt.Errorf("parent root %v of the flat state not found", parent.Hex())
+	}
+	t.diffs[root] = newFlatDiff(root, parent, diffs)
+	return nil
+}
+
+// Cap keeps at most the given number of diff layers below the root, including the root itself, and
+// flattens the rest into the disk layer. The diff layers not descended from the new disk layer are dropped.
+// Nothing is flattened while the disk layer is being generated or broken
+func (t *FlatTree) Cap(root common.Hash, layers int) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !t.readable() {
+		return nil
+	}
+	path := make([]*flatDiff, 0)
+	for h := root; h != t.diskRoot; {
+		d, ok := t.diffs[h]
+		if !ok {
+			return ErrFlatUnavailable
+		}
+		path = append(path, d)
+		h = d.parent
+	}
+	if len(path) <= layers {
+		return nil
+	}
+	for i := len(path) - 1; i >= layers; i-- {
+		if err := t.flatten(path[i]); err != nil {
+			return err
+		}
+	}
+	t.dropOrphans()
+	return nil
+}
+
+// flatten writes the diff layer on top of the disk layer into the database
+func (t *FlatTree) flatten(d *flatDiff) error {
+	batch := t.db.NewBatch()
+	for addr := range d.destructed {
+		if err := t.wipeStorage(batch, addr); err != nil {
+			return err
+		}
+	}
+	for addr, acc := range d.accounts {
+		if acc == nil {
+			if err := batch.Delete(flatAccountKey(addr)); err != nil {
+				return err
+			}
+			continue
+		}
+		enc, err := rlp.EncodeToBytes(acc)
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(flatAccountKey(addr), enc); err != nil {
+			return err
+		}
+	}
+	for addr, values := range d.storage {
+		if _, ok := d.destructed[addr]; ok {
+			continue
+		}
+		for key, value := range values {
+			var err error
+			if value == nil {
+				err = batch.Delete(flatStorageKey(addr, []byte(key)))
+			} else {
+				err = batch.Put(flatStorageKey(addr, []byte(key)), value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if err := batch.Put(flatRootKey, d.root.Bytes()); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	delete(t.diffs, d.root)
+	t.diskRoot = d.root
+	return nil
+}
+
+func (t *FlatTree) wipeStorage(batch tasdb.Batch, addr common.Address) error {
+	iter := t.db.NewIteratorWithPrefix(flatStorageKey(addr, nil))
+	defer iter.Release()
+	for iter.Next() {
+		if err := batch.Delete(flatStorageKey(addr, iter.Key())); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+// dropOrphans removes the diff layers not descended from the disk layer
+func (t *FlatTree) dropOrphans() {
+	valid := map[common.Hash]bool{t.diskRoot: true}
+	var check func(h common.Hash) bool
+	check = func(h common.Hash) bool {
+		if ok, checked := valid[h]; checked {
+			return ok
+		}
+		d, ok := t.diffs[h]
+		ok = ok && check(d.parent)
+		valid[h] = ok
+		return ok
+	}
+	for root := range t.diffs {
+		if !check(root) {
+			delete(t.diffs, root)
+		}
+	}
+}
+
+// Generate rebuilds the disk layer from the state trie of the given root, and drops the diff layers not
+// descended from the root. The running generation is aborted if a new one started.
+// The disk layer is broken if the generation fails, and all the diff layers are dropped until regenerated
+func (t *FlatTree) Generate(root common.Hash, db AccountDatabase) error {
+	atomic.StoreInt32(&t.abort, 1)
+	t.genLock.Lock()
+	defer t.genLock.Unlock()
+	if atomic.LoadInt32(&t.closed) == 1 {
+		return errFlatGenerationAborted
+	}
+	atomic.StoreInt32(&t.abort, 0)
+
+	state, err := NewAccountDB(root, db)
+	if err != nil {
+		return err
+	}
+	t.lock.Lock()
+	t.diskRoot, t.generating, t.broken = root, true, false
+	delete(t.diffs, root)
+	t.dropOrphans()
+	t.lock.Unlock()
+
+	err = t.generate(root, state)
+	t.lock.Lock()
+	t.generating = false
+	if err != nil {
+		t.broken = true
+		t.diffs = make(map[common.Hash]*flatDiff)
+	}
+	t.lock.Unlock()
+	return err
+}
+
+// generate writes the disk layer of the root from the state
+func (t *FlatTree) generate(root common.Hash, state *AccountDB) error {
+	if err := t.db.Put(flatGeneratingKey, root.Bytes()); err != nil {
+		return err
+	}
+
+	// Remove all the items of the old disk layer
+	var err error
+	batch := t.db.NewBatch()
+	for _, prefix := range []byte{flatAccountPrefix, flatStoragePrefix} {
+		iter := t.db.NewIteratorWithPrefix([]byte{prefix})
+		for iter.Next() {
+			if err = batch.Delete(append([]byte{prefix}, iter.Key()...)); err == nil && batch.ValueSize() >= tasdb.IdealBatchSize {
+				err = batch.Write()
+				batch.Reset()
+			}
+			if err == nil && atomic.LoadInt32(&t.abort) == 1 {
+				err = errFlatGenerationAborted
+			}
+			if err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = iter.Error()
+		}
+		iter.Release()
+		if err != nil {
+			return err
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	batch.Reset()
+
+	var (
+		lock   sync.Mutex
+		genErr error
+	)
+	put := func(key, value []byte) error {
+		lock.Lock()
+		defer lock.Unlock()
+		if genErr != nil {
+			return genErr
+		}
+		if atomic.LoadInt32(&t.abort) == 1 {
+			genErr = errFlatGenerationAborted
+			return genErr
+		}
+		if genErr = batch.Put(key, value); genErr == nil && batch.ValueSize() >= tasdb.IdealBatchSize {
+			genErr = batch.Write()
+			batch.Reset()
+		}
+		return genErr
+	}
+	config := &TraverseConfig{
+		VisitAccountCb: func(stat *TraverseStat) {
+			enc, err := rlp.EncodeToBytes(&stat.Account)
+			if err != nil {
+				lock.Lock()
+				genErr = err
+				lock.Unlock()
+				return
+			}
+			put(flatAccountKey(stat.Addr), enc)
+		},
+		VisitStorageCb: func(addr common.Address, key []byte, value []byte) error {
+			return put(flatStorageKey(addr, key), common.CopyBytes(value))
+		},
+	}
+	if ok, err := state.Traverse(config); !ok {
+		if genErr != nil {
+			return genErr
+		}
+		return fmt.Errorf("traverse state error:%v", err)
+	}
+	if genErr != nil {
+		return genErr
+	}
+	if err := batch.Put(flatRootKey, root.Bytes()); err != nil {
+		return err
+	}
+	if err := batch.Delete(flatGeneratingKey); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+// Verify checks the flat state of the given root against the state trie. All the accounts and storage in
+// the disk layer are checked if the root is the one of the disk layer
+func (t *FlatTree) Verify(root common.Hash, db AccountDatabase) error {
+	reader := t.Reader(root)
+	if reader == nil {
+		return ErrFlatUnavailable
+	}
+	state, err := NewAccountDB(root, db)
+	if err != nil {
+		return err
+	}
+	var (
+		lock               sync.Mutex
+		verifyErr          error
+		accounts, storages uint64
+	)
+	setErr := func(err error) error {
+		lock.Lock()
+		defer lock.Unlock()
+		if verifyErr == nil {
+			verifyErr = err
+		}
+		return verifyErr
+	}
+	config := &TraverseConfig{
+		VisitAccountCb: func(stat *TraverseStat) {
+			atomic.AddUint64(&accounts, 1)
+			acc, err := reader.Account(stat.Addr)
+			if err != nil {
+				setErr(err)
+				return
+			}
+			want, _ := rlp.EncodeToBytes(&stat.Account)
+			var got []byte
+			if acc != nil {
+				got, _ = rlp.EncodeToBytes(acc)
+			}
+			if !bytes.Equal(want, got) {
+				setErr(fmt.Errorf("account %v mismatches", stat.Addr.AddrPrefixString()))
+			}
+		},
+		VisitStorageCb: func(addr common.Address, key []byte, value []byte) error {
+			atomic.AddUint64(&storages, 1)
+			got, err := reader.Storage(addr, key)
+			if err != nil {
+				return setErr(err)
+			}
+			if !bytes.Equal(got, value) {
+				return setErr(fmt.Errorf("storage %x of account %v mismatches", key, addr.AddrPrefixString()))
+			}
+			return nil
+		},
+	}
+	if ok, err := state.Traverse(config); !ok {
+		if verifyErr != nil {
+			return verifyErr
+		}
+		return fmt.Errorf("traverse state error:%v", err)
+	}
+	if verifyErr != nil {
+		return verifyErr
+	}
+	if diskRoot, ready := t.DiskRoot(); !ready || diskRoot != root {
+		return nil
+	}
+	// Check no more items stored than the trie
+	for prefix, expect := range map[byte]uint64{flatAccountPrefix: accounts, flatStoragePrefix: storages} {
+		count := uint64(0)
+		iter := t.db.NewIteratorWithPrefix([]byte{prefix})
+		for iter.Next() {
+			count++
+		}
+		err := iter.Error()
+		iter.Release()
+		if err != nil {
+			return err
+		}
+		if count != expect {
+			return fmt.Errorf("flat state has %v items of prefix %c, expect %v", count, prefix, expect)
+		}
+	}
+	return nil
+}
+
+// Close aborts the running generation, and no more generation started afterwards
+func (t *FlatTree) Close() {
+	atomic.StoreInt32(&t.closed, 1)
+	atomic.StoreInt32(&t.abort, 1)
+	t.genLock.Lock()
+	t.genLock.Unlock()
+}
+
+// UseFlat reads the accounts and storage from the flat state before the trie. It should be called on
+// the account db just opened, with the reader of the same root
+func (adb *AccountDB) UseFlat(r FlatReader) {
+	adb.flat = r
+}
+
+type flatReader struct {
+	tree *FlatTree
+	root common.Hash
+}
+
+func (r *flatReader) Root() common.Hash {
+	return r.root
+}
+
+func (r *flatReader) Account(addr common.Address) (*Account, error) {
+	return r.tree.account(r.root, addr)
+}
+
+func (r *flatReader) Storage(addr common.Address, key []byte) ([]byte, error) {
+	return r.tree.storage(r.root, addr, key)
+}
@@ -0,0 +1,198 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package account
+
+import (
+	"bytes"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/storage/tasdb"
+)
+
+func TestFlatTree(t *testing.T) {
+	dir := "test_flat_state"
+	os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+	ds, err := tasdb.NewDataSource(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flatDb, err := ds.NewPrefixDatabase("fs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer flatDb.Close()
+
+	db, _ := tasdb.NewMemDatabase()
+	sdb := NewDatabase(db, false)
+	a, b, c := common.BytesToAddress([]byte{1}), common.BytesToAddress([]byte{2}), common.BytesToAddress([]byte{3})
+
+	state, _ := NewAccountDB(common.Hash{}, sdb)
+	state.SetBalance(a, big.NewInt(10))
+	state.SetData(a, []byte("k1"), []byte("v1"))
+	state.SetData(a, []byte("k2"), []byte("v2"))
+	state.SetBalance(b, big.NewInt(5))
+	state.SetData(b, []byte("k1"), []byte("b1"))
+	root0, _ := state.Commit(true)
+
+	tree := NewFlatTree(flatDb)
+	if _, ready := tree.DiskRoot(); ready {
+		t.Fatalf("expect the empty flat state not ready")
+	}
+	if err := tree.Generate(root0, sdb); err != nil {
+		t.Fatalf("generate error:%v", err)
+	}
+	if err := tree.Verify(root0, sdb); err != nil {
+		t.Fatalf("verify after generation error:%v", err)
+	}
+
+	// Apply the changes as a diff layer
+	state, _ = NewAccountDB(root0, sdb)
+	state.UseFlat(tree.Reader(root0))
+	state.RecordDiff()
+	if state.GetBalance(a).Int64() != 10 || !bytes.Equal(state.GetData(a, []byte("k1")), []byte("v1")) {
+		t.Fatalf("unexpected values read from the flat state")
+	}
+	state.SubBalance(a, big.NewInt(3))
+	state.SetData(a, []byte("k1"), []byte("v1x"))
+	state.RemoveData(a, []byte("k2"))
+	state.Suicide(b)
+	state.SetBalance(c, big.NewInt(7))
+	root1, _ := state.Commit(true)
+	if err := tree.Update(root1, root0, state.Diff()); err != nil {
+		t.Fatalf("update error:%v", err)
+	}
+	if err := tree.Update(root1, common.Hash{1}, nil); err != nil {
+		t.Errorf("expect the known root ignored, got %v", err)
+	}
+	if err := tree.Update(common.Hash{2}, common.Hash{1}, nil); err == nil {
+		t.Errorf("expect error for the unknown parent")
+	}
+
+	check := func(root common.Hash) {
+		reader := tree.Reader(root)
+		if reader == nil {
+			t.Fatalf("no reader of root %v", root.Hex())
+		}
+		state, _ := NewAccountDB(root, sdb)
+		state.UseFlat(reader)
+		if state.GetBalance(a).Int64() != 7 || state.GetBalance(c).Int64() != 7 || state.Exist(b) {
+			t.Errorf("unexpected accounts read from the flat state")
+		}
+		if !bytes.Equal(state.GetData(a, []byte("k1")), []byte("v1x")) || state.GetData(a, []byte("k2")) != nil {
+			t.Errorf("unexpected storage read from the flat state")
+		}
+		if v, err := reader.Storage(b, []byte("k1")); err != nil || v != nil {
+			t.Errorf("expect the storage of the deleted account wiped, got %x %v", v, err)
+		}
+		if err := tree.Verify(root, sdb); err != nil {
+			t.Errorf("verify error:%v", err)
+		}
+	}
+	check(root1)
+	// The old root is still readable until flattened
+	if acc, err := tree.Reader(root0).Account(b); err != nil || acc == nil {
+		t.Errorf("expect the account at the old root, got %v %v", acc, err)
+	}
+
+	// A sibling of root1 is dropped after flattening
+	state, _ = NewAccountDB(root0, sdb)
+	state.RecordDiff()
+	state.SetBalance(c, big.NewInt(1))
+	sibling, _ := state.Commit(true)
+	if err := tree.Update(sibling, root0, state.Diff()); err != nil {
+		t.Fatalf("update sibling error:%v", err)
+	}
+	if err := tree.Cap(root1, 0); err != nil {
+		t.Fatalf("cap error:%v", err)
+	}
+	if root, ready := tree.DiskRoot(); !ready || root != root1 {
+		t.Fatalf("expect the disk layer at root1")
+	}
+	if tree.Reader(sibling) != nil || tree.Reader(root0) != nil {
+		t.Errorf("expect the stale layers dropped")
+	}
+	check(root1)
+
+	// Reopen
+	tree = NewFlatTree(flatDb)
+	if root, ready := tree.DiskRoot(); !ready || root != root1 {
+		t.Fatalf("expect the disk layer at root1 after reopen")
+	}
+	check(root1)
+
+	// Regenerate over the existing disk layer, the items not in the trie are removed
+	if err := tree.Generate(root0, sdb); err != nil {
+		t.Fatalf("regenerate error:%v", err)
+	}
+	if err := tree.Verify(root0, sdb); err != nil {
+		t.Fatalf("verify after regeneration error:%v", err)
+	}
+	reader := tree.Reader(root0)
+	if acc, err := reader.Account(c); err != nil || acc != nil {
+		t.Errorf("expect the stale account removed, got %v %v", acc, err)
+	}
+	if v, err := reader.Storage(a, []byte("k2")); err != nil || !bytes.Equal(v, []byte("v2")) {
+		t.Errorf("unexpected storage after regeneration, got %x %v", v, err)
+	}
+	if err := tree.Generate(root1, sdb); err != nil {
+		t.Fatalf("regenerate error:%v", err)
+	}
+	check(root1)
+
+	// A failed generation breaks the disk layer and drops the diff layers
+	state, _ = NewAccountDB(root1, sdb)
+	state.RecordDiff()
+	state.SetBalance(c, big.NewInt(2))
+	root2, _ := state.Commit(true)
+	if err := tree.Update(root2, root1, state.Diff()); err != nil {
+		t.Fatalf("update error:%v", err)
+	}
+	brokenDir := dir + "_broken"
+	os.RemoveAll(brokenDir)
+	defer os.RemoveAll(brokenDir)
+	brokenDs, err := tasdb.NewDataSource(brokenDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	brokenDb, _ := brokenDs.NewPrefixDatabase("fs")
+	tree.db = brokenDb
+	brokenDb.Close()
+	if err := tree.Generate(root1, sdb); err == nil {
+		t.Fatalf("expect generation error on the closed database")
+	}
+	if _, ready := tree.DiskRoot(); ready || tree.Generating() {
+		t.Errorf("expect the disk layer broken after the failed generation")
+	}
+	if tree.Reader(root2) != nil || len(tree.diffs) != 0 {
+		t.Errorf("expect the diff layers dropped after the failed generation")
+	}
+	if err := tree.Update(common.Hash{3}, root2, nil); err != ErrFlatUnavailable {
+		t.Errorf("expect no diff layers added on the broken disk layer, got %v", err)
+	}
+
+	// Regenerate to recover
+	tree.db = flatDb
+	if err := tree.Generate(root2, sdb); err != nil {
+		t.Fatalf("regenerate error:%v", err)
+	}
+	if root, ready := tree.DiskRoot(); !ready || root != root2 {
+		t.Errorf("expect the disk layer at root2 after regeneration")
+	}
+}
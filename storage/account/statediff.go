@@ -0,0 +1,168 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package account
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/storage/rlp"
+)
+
+// StorageDiff is the change of one storage key, the value is empty if not exists
+type StorageDiff struct {
+	Key    []byte
+	Before []byte
+	After  []byte
+}
+
+// AccountDiff is the change of one account since the diff recording started
+type AccountDiff struct {
+	Address common.Address
+	Created bool // The account not exists before
+	Deleted bool // The account not exists after
+	Before  Account
+	After   Account
+	Storage []*StorageDiff
+
+	storage map[string]*StorageDiff
+}
+
+func (d *AccountDiff) changed() bool {
+	if d.Created && d.Deleted {
+		return false
+	}
+	if d.Created != d.Deleted || len(d.Storage) > 0 {
+		return true
+	}
+	return d.Before.Nonce != d.After.Nonce || d.Before.Balance.Cmp(d.After.Balance) != 0 ||
+		!bytes.Equal(d.Before.CodeHash, d.After.CodeHash)
+}
+
+func emptyAccount() Account {
+	return Account{Balance: new(big.Int), CodeHash: emptyCodeHash[:]}
+}
+
+func copyAccount(data Account) Account {
+	data.Balance = new(big.Int).Set(data.Balance)
+	data.CodeHash = common.CopyBytes(data.CodeHash)
+	return data
+}
+
+// RecordDiff starts recording the changes of the accounts finalised or committed afterwards.
+// It should be called on the account db just opened, before any change made
+func (adb *AccountDB) RecordDiff() {
+	adb.diff = make(map[common.Address]*AccountDiff)
+}
+
+// Diff returns the changed accounts sorted by address, together with the changed storage keys
+// sorted by key. Changes not finalised yet are not included. Nil if not recording
+func (adb *AccountDB) Diff() []*AccountDiff {
+	if adb.diff == nil {
+		return nil
+	}
+	diffs := make([]*AccountDiff, 0, len(adb.diff))
+	for _, d := range adb.diff {
+		ret := &AccountDiff{
+			Address: d.Address,
+			Created: d.Created,
+			Deleted: d.Deleted,
+			Before:  copyAccount(d.Before),
+			After:   copyAccount(d.After),
+			Storage: make([]*StorageDiff, 0),
+		}
+		for _, sd := range d.storage {
+			if !bytes.Equal(sd.Before, sd.After) {
+				ret.Storage = append(ret.Storage, sd)
+			}
+		}
+		if !ret.changed() {
+			continue
+		}
+		sort.Slice(ret.Storage, func(i, j int) bool {
+			return bytes.Compare(ret.Storage[i].Key, ret.Storage[j].Key) < 0
+		})
+		diffs = append(diffs, ret)
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return bytes.Compare(diffs[i].Address[:], diffs[j].Address[:]) < 0
+	})
+	return diffs
+}
+
+// diffEntry returns the diff of the address, and creates it with the value in the account trie
+// if not exists. The trie is not updated with the account until it is first finalised, so the
+// value is the one before the recording started
+func (adb *AccountDB) diffEntry(addr common.Address) *AccountDiff {
+	if d, ok := adb.diff[addr]; ok {
+		return d
+	}
+	d := &AccountDiff{Address: addr, Before: emptyAccount(), storage: make(map[string]*StorageDiff)}
+	enc, _ := adb.trie.TryGet(addr[:])
+	if len(enc) == 0 {
+		d.Created = true
+	} else if err := rlp.DecodeBytes(enc, &d.Before); err != nil {
+		adb.setError(err)
+	}
+	if d.Before.Balance == nil {
+		d.Before.Balance = new(big.Int)
+	}
+	adb.diff[addr] = d
+	return d
+}
+
+// diffStorage records the dirty storage of the object, it must be called before the storage is
+// written to the trie
+func (adb *AccountDB) diffStorage(object *accountObject) {
+	d := adb.diffEntry(object.address)
+	var origin Trie
+	for key, value := range object.dirtyStorage {
+		if sd, ok := d.storage[key]; ok {
+			sd.After = common.CopyBytes(value)
+			continue
+		}
+		sd := &StorageDiff{Key: []byte(key), After: common.CopyBytes(value)}
+		if !d.Created && d.Before.Root != emptyData {
+			if origin == nil {
+				tr, err := adb.db.OpenStorageTrie(object.addrHash, d.Before.Root)
+				if err != nil {
+					adb.setError(err)
+					continue
+				}
+				origin = tr
+			}
+			before, err := origin.TryGet([]byte(key))
+			if err != nil {
+				adb.setError(err)
+			}
+			sd.Before = before
+		}
+		d.storage[key] = sd
+	}
+}
+
+// diffAccount records the value of the object after written to the trie
+func (adb *AccountDB) diffAccount(object *accountObject, deleted bool) {
+	d := adb.diffEntry(object.address)
+	d.Deleted = deleted
+	if deleted {
+		d.After = emptyAccount()
+	} else {
+		d.After = copyAccount(object.data)
+	}
+}
@@ -0,0 +1,117 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package account
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/storage/rlp"
+	"github.com/zvchain/zvchain/storage/tasdb"
+)
+
+func TestAccountDB_Diff(t *testing.T) {
+	db, _ := tasdb.NewMemDatabase()
+	sdb := NewDatabase(db, false)
+	state, _ := NewAccountDB(common.Hash{}, sdb)
+
+	a, b, c, d := common.BytesToAddress([]byte{1}), common.BytesToAddress([]byte{2}), common.BytesToAddress([]byte{3}), common.BytesToAddress([]byte{4})
+	state.SetBalance(a, big.NewInt(10))
+	state.SetNonce(a, 1)
+	state.SetData(a, []byte("k1"), []byte("v1"))
+	state.SetData(a, []byte("k2"), []byte("v2"))
+	state.SetBalance(b, big.NewInt(5))
+	root, err := state.Commit(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, _ = NewAccountDB(root, sdb)
+	state.RecordDiff()
+	state.SubBalance(a, big.NewInt(3))
+	state.SetData(a, []byte("k1"), []byte("v1x"))
+	state.RemoveData(a, []byte("k2"))
+	state.SetData(a, []byte("k3"), []byte("v3"))
+	snapshot := state.Snapshot()
+	state.SetData(a, []byte("k4"), []byte("v4"))
+	state.RevertToSnapshot(snapshot)
+	state.SetNonce(b, 1)
+	state.IntermediateRoot(true)
+
+	// Changes after the first finalising keep the value before
+	state.AddBalance(b, big.NewInt(1))
+	state.SetData(a, []byte("k3"), []byte("v3x"))
+	state.AddBalance(c, big.NewInt(7))
+	state.AddBalance(d, big.NewInt(0))
+	state.SetBalance(b, big.NewInt(6))
+	state.IntermediateRoot(true)
+
+	diffs := state.Diff()
+	if len(diffs) != 3 {
+		t.Fatalf("expect 3 accounts changed, got %v", len(diffs))
+	}
+	da, db2, dc := diffs[0], diffs[1], diffs[2]
+	if da.Address != a || db2.Address != b || dc.Address != c {
+		t.Fatalf("unexpected addresses %v %v %v", da.Address, db2.Address, dc.Address)
+	}
+	if da.Created || da.Deleted || da.Before.Balance.Int64() != 10 || da.After.Balance.Int64() != 7 || da.After.Nonce != 1 {
+		t.Errorf("unexpected diff of a %+v", da)
+	}
+	expect := []*StorageDiff{
+		{Key: []byte("k1"), Before: []byte("v1"), After: []byte("v1x")},
+		{Key: []byte("k2"), Before: []byte("v2")},
+		{Key: []byte("k3"), After: []byte("v3x")},
+	}
+	if len(da.Storage) != len(expect) {
+		t.Fatalf("expect %v storage keys changed, got %v", len(expect), len(da.Storage))
+	}
+	for i, sd := range da.Storage {
+		if !bytes.Equal(sd.Key, expect[i].Key) || !bytes.Equal(sd.Before, expect[i].Before) || !bytes.Equal(sd.After, expect[i].After) {
+			t.Errorf("unexpected storage diff %s: %s -> %s", sd.Key, sd.Before, sd.After)
+		}
+	}
+	if db2.Before.Nonce != 0 || db2.After.Nonce != 1 || db2.Before.Balance.Int64() != 5 || db2.After.Balance.Int64() != 6 || len(db2.Storage) != 0 {
+		t.Errorf("unexpected diff of b %+v", db2)
+	}
+	if !dc.Created || dc.Before.Balance.Sign() != 0 || dc.After.Balance.Int64() != 7 {
+		t.Errorf("unexpected diff of c %+v", dc)
+	}
+
+	// The diff can be persisted
+	bs, err := rlp.EncodeToBytes(diffs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []*AccountDiff
+	if err := rlp.DecodeBytes(bs, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 3 || decoded[0].After.Balance.Int64() != 7 || !decoded[2].Created || len(decoded[0].Storage) != 3 {
+		t.Errorf("unexpected decoded diff %+v", decoded)
+	}
+}
+
+func TestAccountDB_DiffNotRecording(t *testing.T) {
+	db, _ := tasdb.NewMemDatabase()
+	state, _ := NewAccountDB(common.Hash{}, NewDatabase(db, false))
+	state.SetBalance(common.BytesToAddress([]byte{1}), big.NewInt(1))
+	state.IntermediateRoot(true)
+	if state.Diff() != nil {
+		t.Errorf("expect no diff if not recording")
+	}
+}
@@ -0,0 +1,112 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tasdb
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Convert copies all the key/value pairs of the database in src into a new database in dest created
+// by the given engine, and then verifies the copy entry by entry. The progress is called with the
+// number of entries copied after each batch written. It returns the number of entries copied.
+func Convert(src, dest, engine string, progress func(copied int)) (int, error) {
+	srcEngine := DetectEngine(src)
+	if srcEngine == "" {
+		return 0, fmt.Errorf("no database found in %v", src)
+	}
+	if created := DetectEngine(dest); created != "" {
+		return 0, fmt.Errorf("database already exists in %v", dest)
+	}
+	srcDB, err := OpenStore(srcEngine, src, &Options{ReadOnly: true})
+	if err != nil {
+		return 0, fmt.Errorf("open %v error:%v", src, err)
+	}
+	defer srcDB.Close()
+	destDB, err := OpenStore(engine, dest, nil)
+	if err != nil {
+		return 0, fmt.Errorf("open %v error:%v", dest, err)
+	}
+	defer destDB.Close()
+
+	copied, err := copyStore(srcDB, destDB, progress)
+	if err != nil {
+		return copied, err
+	}
+	if err := compareStore(srcDB, destDB); err != nil {
+		return copied, fmt.Errorf("verify error:%v", err)
+	}
+	return copied, nil
+}
+
+func copyStore(src, dest Database, progress func(copied int)) (int, error) {
+	iter := src.NewIterator()
+	defer iter.Release()
+
+	batch := dest.NewBatch()
+	copied := 0
+	for iter.Next() {
+		if err := batch.Put(iter.Key(), iter.Value()); err != nil {
+			return copied, err
+		}
+		copied++
+		if batch.ValueSize() >= IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return copied, err
+			}
+			batch.Reset()
+			if progress != nil {
+				progress(copied)
+			}
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return copied, err
+	}
+	if err := batch.Write(); err != nil {
+		return copied, err
+	}
+	if progress != nil {
+		progress(copied)
+	}
+	return copied, nil
+}
+
+// compareStore checks the two databases hold the same key/value pairs
+func compareStore(a, b Database) error {
+	iterA, iterB := a.NewIterator(), b.NewIterator()
+	defer iterA.Release()
+	defer iterB.Release()
+	for {
+		nextA, nextB := iterA.Next(), iterB.Next()
+		if !nextA || !nextB {
+			if nextA || nextB {
+				return fmt.Errorf("number of entries differs")
+			}
+			break
+		}
+		if !bytes.Equal(iterA.Key(), iterB.Key()) {
+			return fmt.Errorf("key differs: %x, %x", iterA.Key(), iterB.Key())
+		}
+		if !bytes.Equal(iterA.Value(), iterB.Value()) {
+			return fmt.Errorf("value of key %x differs", iterA.Key())
+		}
+	}
+	if err := iterA.Error(); err != nil {
+		return err
+	}
+	return iterB.Error()
+}
@@ -14,13 +14,14 @@
 //   along with this program.  If not, see <https://www.gnu.org/licenses/>.
 
 /*
-	Package tasdb provides level db operations
+	Package tasdb provides the database operations built on the pluggable storage engines
 */
 package tasdb
 
 import (
 	"bytes"
 	"os"
+	"path/filepath"
 	"runtime/debug"
 	"sync"
 
@@ -30,7 +31,7 @@ import (
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/errors"
-	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/filter"
 	"github.com/syndtr/goleveldb/leveldb/opt"
 	"github.com/syndtr/goleveldb/leveldb/util"
 	"github.com/zvchain/zvchain/common"
@@ -39,6 +40,9 @@ import (
 const (
 	ConfigSec   = "chain"
 	DefaultFile = "database"
+
+	// LevelDBEngine is the name of the goleveldb storage engine
+	LevelDBEngine = "leveldb"
 )
 
 var (
@@ -46,7 +50,7 @@ var (
 )
 
 type PrefixedDatabase struct {
-	db     *LDBDatabase
+	db     KeyValueStore
 	prefix string
 }
 
@@ -56,19 +60,65 @@ type databaseConfig struct {
 	handler  int
 }
 
-func getInstance(file string, options *opt.Options) (*LDBDatabase, error) {
-	var (
-		instanceInner *LDBDatabase
-		err           error
-	)
+func init() {
+	RegisterEngine(&Engine{
+		Name: LevelDBEngine,
+		Open: func(file string, options *Options) (KeyValueStore, error) {
+			return NewLDBDatabase(file, levelDBOptions(options))
+		},
+		Created: func(file string) bool {
+			// Pebble writes the CURRENT file too
+			_, err := os.Stat(filepath.Join(file, "CURRENT"))
+			return err == nil && !pebbleCreated(file)
+		},
+	})
+}
 
-	instanceInner, err = NewLDBDatabase(file, options)
+// levelDBOptions converts the options to the goleveldb ones
+func levelDBOptions(options *Options) *opt.Options {
+	if options == nil {
+		return nil
+	}
+	o := &opt.Options{
+		BlockCacheCapacity:            options.BlockCacheCapacity,
+		WriteBuffer:                   options.WriteBuffer,
+		OpenFilesCacheCapacity:        options.OpenFilesCacheCapacity,
+		BlockSize:                     options.BlockSize,
+		CompactionTableSize:           options.CompactionTableSize,
+		CompactionTableSizeMultiplier: options.CompactionTableSizeMultiplier,
+		CompactionTotalSize:           options.CompactionTotalSize,
+		ReadOnly:                      options.ReadOnly,
+	}
+	if options.BloomFilterBits > 0 {
+		o.Filter = filter.NewBloomFilter(options.BloomFilterBits)
+	}
+	return o
+}
 
-	return instanceInner, err
+// Compact compacts the key range [start, limit) of the logical database, the nil limit
+// means the end of the logical database
+func (db *PrefixedDatabase) Compact(start, limit []byte) error {
+	rangeStart := generateKey(start, db.prefix)
+	var rangeLimit []byte
+	if limit != nil {
+		rangeLimit = generateKey(limit, db.prefix)
+	} else {
+		rangeLimit = prefixUpperBound([]byte(db.prefix))
+	}
+	return db.db.Compact(rangeStart, rangeLimit)
 }
 
-func (db *PrefixedDatabase) GetDB() *leveldb.DB {
-	return db.db.db
+// prefixUpperBound returns the smallest key greater than all keys with the given prefix,
+// nil if there is no such key
+func prefixUpperBound(prefix []byte) []byte {
+	limit := common.CopyBytes(prefix)
+	for i := len(limit) - 1; i >= 0; i-- {
+		if limit[i] < 0xff {
+			limit[i]++
+			return limit[:i+1]
+		}
+	}
+	return nil
 }
 
 // Close close db connection
@@ -92,19 +142,11 @@ func (db *PrefixedDatabase) Delete(key []byte) error {
 	return db.db.Delete(generateKey(key, db.prefix))
 }
 
-func (db *PrefixedDatabase) newIterator(prefix []byte) iterator.Iterator {
-	iter := db.db.NewIteratorWithPrefix([]byte(db.prefix))
-	return &prefixIter{
-		prefix: []byte(db.prefix),
-		iter:   iter,
-	}
-}
-
-func (db *PrefixedDatabase) NewIterator() iterator.Iterator {
+func (db *PrefixedDatabase) NewIterator() Iterator {
 	return db.NewIteratorWithPrefix(nil)
 }
 
-func (db *PrefixedDatabase) NewIteratorWithPrefix(prefix []byte) iterator.Iterator {
+func (db *PrefixedDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
 	iterPrefix := generateKey(prefix, db.prefix)
 	iter := db.db.NewIteratorWithPrefix(iterPrefix)
 	return &prefixIter{
@@ -114,7 +156,7 @@ func (db *PrefixedDatabase) NewIteratorWithPrefix(prefix []byte) iterator.Iterat
 }
 
 func (db *PrefixedDatabase) NewBatch() Batch {
-	return &prefixBatch{db: db.db.db, b: new(leveldb.Batch), prefix: db.prefix}
+	return &prefixBatch{b: db.db.NewBatch(), prefix: db.prefix}
 }
 
 func (db *PrefixedDatabase) AddKv(batch Batch, k, v []byte) error {
@@ -134,6 +176,11 @@ func (db *PrefixedDatabase) addKVToBatch(b Batch, k, v []byte) error {
 
 var dbStats = &leveldb.DBStats{}
 
+// levelDBStater is implemented by the leveldb engine which provides the stats
+type levelDBStater interface {
+	Stats(s *leveldb.DBStats) error
+}
+
 func (db *PrefixedDatabase) LogStats(logger *logrus.Logger) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -147,7 +194,11 @@ func (db *PrefixedDatabase) LogStats(logger *logrus.Logger) {
 	lastWrite := dbStats.IOWrite / uint64(byte2MB)
 	lastRead := dbStats.IORead / uint64(byte2MB)
 
-	err := db.db.db.Stats(dbStats)
+	stater, ok := db.db.(levelDBStater)
+	if !ok {
+		return
+	}
+	err := stater.Stats(dbStats)
 	if err != nil {
 		logger.Info("failed to get leveldb stats", err)
 	} else {
@@ -183,7 +234,7 @@ func (db *PrefixedDatabase) addDeleteToBatch(b Batch, k []byte) error {
 
 type prefixIter struct {
 	prefix []byte
-	iter   iterator.Iterator
+	iter   Iterator
 }
 
 func (iter *prefixIter) First() bool {
@@ -213,10 +264,6 @@ func (iter *prefixIter) Release() {
 	iter.iter.Release()
 }
 
-func (iter *prefixIter) SetReleaser(releaser util.Releaser) {
-	iter.iter.SetReleaser(releaser)
-}
-
 func (iter *prefixIter) Valid() bool {
 	return iter.iter.Valid()
 }
@@ -235,35 +282,28 @@ func (iter *prefixIter) Value() []byte {
 }
 
 type prefixBatch struct {
-	db     *leveldb.DB
-	b      *leveldb.Batch
-	size   int
+	b      Batch
 	prefix string
 }
 
 func (b *prefixBatch) Delete(key []byte) error {
-	b.b.Delete(generateKey(key, b.prefix))
-	b.size++
-	return nil
+	return b.b.Delete(generateKey(key, b.prefix))
 }
 
 func (b *prefixBatch) Put(key, value []byte) error {
-	b.b.Put(generateKey(key, b.prefix), value)
-	b.size += len(value)
-	return nil
+	return b.b.Put(generateKey(key, b.prefix), value)
 }
 
 func (b *prefixBatch) Write() error {
-	return b.db.Write(b.b, nil)
+	return b.b.Write()
 }
 
 func (b *prefixBatch) ValueSize() int {
-	return b.size
+	return b.b.ValueSize()
 }
 
 func (b *prefixBatch) Reset() {
 	b.b.Reset()
-	b.size = 0
 }
 
 // generateKey generate a prefixed key
@@ -366,6 +406,9 @@ func (ldb *LDBDatabase) Get(key []byte) ([]byte, error) {
 	}
 
 	dat, err := ldb.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -381,7 +424,7 @@ func (ldb *LDBDatabase) Delete(key []byte) error {
 	return ldb.db.Delete(key, nil)
 }
 
-func (ldb *LDBDatabase) NewIterator() iterator.Iterator {
+func (ldb *LDBDatabase) NewIterator() Iterator {
 	if !ldb.inited {
 		return nil
 	}
@@ -389,10 +432,26 @@ func (ldb *LDBDatabase) NewIterator() iterator.Iterator {
 }
 
 // NewIteratorWithPrefix returns a iterator to iterate over subset of database content with a particular prefix.
-func (ldb *LDBDatabase) NewIteratorWithPrefix(prefix []byte) iterator.Iterator {
+func (ldb *LDBDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
 	return ldb.db.NewIterator(util.BytesPrefix(prefix), nil)
 }
 
+// Compact compacts the key range [start, limit) of the database
+func (ldb *LDBDatabase) Compact(start, limit []byte) error {
+	if !ldb.inited {
+		return ErrLDBInit
+	}
+	return ldb.db.CompactRange(util.Range{Start: start, Limit: limit})
+}
+
+// Stats returns the stats of the leveldb
+func (ldb *LDBDatabase) Stats(s *leveldb.DBStats) error {
+	if !ldb.inited {
+		return ErrLDBInit
+	}
+	return ldb.db.Stats(s)
+}
+
 func (ldb *LDBDatabase) Close() {
 	ldb.quitLock.Lock()
 	defer ldb.quitLock.Unlock()
@@ -481,7 +540,7 @@ func (db *MemDatabase) Get(key []byte) ([]byte, error) {
 	if entry, ok := db.db[string(key)]; ok {
 		return common.CopyBytes(entry), nil
 	}
-	return nil, errors.ErrNotFound
+	return nil, ErrNotFound
 }
 
 func (db *MemDatabase) Keys() [][]byte {
@@ -495,11 +554,11 @@ func (db *MemDatabase) Keys() [][]byte {
 	return keys
 }
 
-func (db *MemDatabase) NewIterator() iterator.Iterator {
+func (db *MemDatabase) NewIterator() Iterator {
 	panic("Not support")
 }
 
-func (db *MemDatabase) NewIteratorWithPrefix(prefix []byte) iterator.Iterator {
+func (db *MemDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
 	panic("Not support")
 }
 
@@ -623,11 +682,11 @@ func (db *LRUMemDatabase) NewBatch() Batch {
 	return &LruMemBatch{db: db}
 }
 
-func (db *LRUMemDatabase) NewIterator() iterator.Iterator {
+func (db *LRUMemDatabase) NewIterator() Iterator {
 	panic("Not support")
 }
 
-func (db *LRUMemDatabase) NewIteratorWithPrefix(prefix []byte) iterator.Iterator {
+func (db *LRUMemDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
 	panic("Not support")
 }
 
@@ -15,19 +15,28 @@
 
 package tasdb
 
-import "github.com/syndtr/goleveldb/leveldb/opt"
-
 type TasDataSource struct {
-	db *LDBDatabase
+	db     KeyValueStore
+	engine string
+}
+
+// NewDataSource create database instance by file with the configured storage engine
+func NewDataSource(file string, options *Options) (*TasDataSource, error) {
+	return NewDataSourceWithEngine(DefaultEngine(), file, options)
 }
 
-// NewDataSource create levedb instance by file
-func NewDataSource(file string, options *opt.Options) (*TasDataSource, error) {
-	db, err := getInstance(file, options)
+// NewDataSourceWithEngine create database instance by file with the given storage engine
+func NewDataSourceWithEngine(engine string, file string, options *Options) (*TasDataSource, error) {
+	db, err := OpenStore(engine, file, options)
 	if err != nil {
 		return nil, err
 	}
-	return &TasDataSource{db: db}, nil
+	return &TasDataSource{db: db, engine: engine}, nil
+}
+
+// Engine returns the storage engine of the data source
+func (ds *TasDataSource) Engine() string {
+	return ds.engine
 }
 
 // NewPrefixDatabase create logical database by prefix
@@ -0,0 +1,105 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tasdb
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/zvchain/zvchain/common"
+)
+
+const (
+	KiB = 1024
+	MiB = 1024 * KiB
+)
+
+// Options are the tunings of the storage engines, each engine ignores the ones not applicable to it.
+// The sizes are in bytes and the zero values mean the defaults of the engines.
+type Options struct {
+	BlockCacheCapacity            int
+	WriteBuffer                   int
+	OpenFilesCacheCapacity        int
+	BlockSize                     int
+	CompactionTableSize           int
+	CompactionTableSizeMultiplier float64
+	CompactionTotalSize           int
+	BloomFilterBits               int // bits per key of the bloom filter, 0 means no filter
+	ReadOnly                      bool
+}
+
+// Engine is a storage engine the databases can be created by
+type Engine struct {
+	Name string
+
+	// Open opens the database in the given directory, which is created if not exists
+	Open func(file string, options *Options) (KeyValueStore, error)
+
+	// Created reports whether the given directory holds a database created by the engine
+	Created func(file string) bool
+}
+
+var engines = make(map[string]*Engine)
+
+// RegisterEngine registers the storage engine which can be selected by the name
+func RegisterEngine(e *Engine) {
+	if _, ok := engines[e.Name]; ok {
+		panic(fmt.Sprintf("db engine %v registered twice", e.Name))
+	}
+	engines[e.Name] = e
+}
+
+// EngineNames returns the names of the registered storage engines
+func EngineNames() []string {
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultEngine returns the storage engine configured by db_engine in the chain section, leveldb by default
+func DefaultEngine() string {
+	if common.GlobalConf == nil {
+		return LevelDBEngine
+	}
+	return common.GlobalConf.GetString(ConfigSec, "db_engine", LevelDBEngine)
+}
+
+// DetectEngine returns the engine created the database in the given directory,
+// or empty if there is no database
+func DetectEngine(file string) string {
+	for _, name := range EngineNames() {
+		if engines[name].Created(file) {
+			return name
+		}
+	}
+	return ""
+}
+
+// OpenStore opens the database in the given directory by the given engine. It fails if the directory
+// holds a database created by another engine, which should be converted first.
+func OpenStore(engine, file string, options *Options) (KeyValueStore, error) {
+	e, ok := engines[engine]
+	if !ok {
+		return nil, fmt.Errorf("unknown db engine %v, should be one of %v", engine, EngineNames())
+	}
+	if created := DetectEngine(file); created != "" && created != engine {
+		return nil, fmt.Errorf("database %v is created by the %v engine rather than %v, convert it by gzv db convert", file, created, engine)
+	}
+	return e.Open(file, options)
+}
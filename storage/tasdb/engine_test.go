@@ -0,0 +1,210 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tasdb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "tasdb_engine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func iterKeys(iter Iterator, step func() bool) []string {
+	keys := make([]string, 0)
+	for step() {
+		keys = append(keys, string(iter.Key()))
+	}
+	return keys
+}
+
+func testEngine(t *testing.T, engine string) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+
+	ds, err := NewDataSourceWithEngine(engine, filepath.Join(dir, "db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, _ := ds.NewPrefixDatabase("p")
+	other, _ := ds.NewPrefixDatabase("q")
+	defer db.Close()
+
+	for _, k := range []string{"a1", "a2", "b1", "b2", "c"} {
+		if err := db.Put([]byte(k), []byte("v"+k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other.Put([]byte("a1"), []byte("other"))
+	if v, err := db.Get([]byte("a1")); err != nil || string(v) != "va1" {
+		t.Errorf("unexpected get %s %v", v, err)
+	}
+	if _, err := db.Get([]byte("x")); err != ErrNotFound {
+		t.Errorf("expect not found, got %v", err)
+	}
+	if ok, _ := db.Has([]byte("c")); !ok {
+		t.Errorf("expect key c exists")
+	}
+	db.Delete([]byte("c"))
+	if ok, _ := db.Has([]byte("c")); ok {
+		t.Errorf("expect key c deleted")
+	}
+
+	batch := db.NewBatch()
+	batch.Put([]byte("b3"), []byte("vb3"))
+	batch.Delete([]byte("a2"))
+	if err := batch.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	iter := db.NewIterator()
+	if keys := fmt.Sprint(iterKeys(iter, iter.Next)); keys != "[a1 b1 b2 b3]" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+	if keys := fmt.Sprint(iterKeys(iter, iter.Prev)); keys != "[b3 b2 b1 a1]" {
+		t.Errorf("unexpected reversed keys %v", keys)
+	}
+	iter.Release()
+
+	// The keys are relative to the prefix of the iterator
+	iter = db.NewIteratorWithPrefix([]byte("b"))
+	if !iter.Last() || string(iter.Key()) != "3" || !iter.Prev() || string(iter.Key()) != "2" {
+		t.Errorf("unexpected last entries of prefix b")
+	}
+	if !iter.Seek([]byte("15")) || string(iter.Key()) != "2" || !bytes.Equal(iter.Value(), []byte("vb2")) {
+		t.Errorf("unexpected seek result")
+	}
+	if !iter.First() || string(iter.Key()) != "1" || iter.Prev() || iter.Valid() {
+		t.Errorf("unexpected first entry of prefix b")
+	}
+	if iter.Seek([]byte("c")) {
+		t.Errorf("expect seek out of the prefix fails")
+	}
+	iter.Release()
+
+	if err := db.Compact(nil, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEngine_LevelDB(t *testing.T) {
+	testEngine(t, LevelDBEngine)
+}
+
+func TestEngine_Pebble(t *testing.T) {
+	testEngine(t, PebbleEngine)
+}
+
+// The iterators of the engines move the same way at the ends and see the same snapshot
+func TestEngine_IteratorMoves(t *testing.T) {
+	for _, engine := range EngineNames() {
+		dir := newTestDir(t)
+		db, err := OpenStore(engine, dir, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range []string{"a", "b", "c"} {
+			db.Put([]byte(k), []byte(k))
+		}
+		iter := db.NewIterator()
+		db.Put([]byte("d"), []byte("d"))
+		db.Delete([]byte("a"))
+		var moves []string
+		for _, move := range []func() bool{iter.Prev, iter.Next, iter.Prev, iter.Next, iter.Last, iter.Next, iter.Prev} {
+			if move() {
+				moves = append(moves, string(iter.Key()))
+			} else {
+				moves = append(moves, "-")
+			}
+		}
+		if fmt.Sprint(moves) != "[- a - a c - c]" {
+			t.Errorf("unexpected moves of %v iterator %v", engine, moves)
+		}
+		iter.Release()
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestEngine_Mismatch(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+
+	store, err := OpenStore(PebbleEngine, dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	if DetectEngine(dir) != PebbleEngine {
+		t.Errorf("expect pebble engine detected")
+	}
+	if _, err := OpenStore(LevelDBEngine, dir, nil); err == nil {
+		t.Errorf("expect opening by another engine fails")
+	}
+	if _, err := OpenStore("unknown", dir, nil); err == nil {
+		t.Errorf("expect unknown engine fails")
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src")
+	store, err := OpenStore(LevelDBEngine, src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		store.Put([]byte(fmt.Sprintf("key%04d", i)), bytes.Repeat([]byte{byte(i)}, 200))
+	}
+	store.Put([]byte("empty"), []byte{})
+	store.Close()
+
+	dest := filepath.Join(dir, "dest")
+	copied, err := Convert(src, dest, PebbleEngine, nil)
+	if err != nil || copied != 1001 {
+		t.Fatalf("convert error %v %v", copied, err)
+	}
+	if _, err := Convert(src, dest, PebbleEngine, nil); err == nil {
+		t.Errorf("expect converting into an existing database fails")
+	}
+
+	// Convert back and compare with the source
+	back := filepath.Join(dir, "back")
+	if _, err := Convert(dest, back, LevelDBEngine, nil); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := OpenStore(LevelDBEngine, src, nil)
+	b, _ := OpenStore(LevelDBEngine, back, nil)
+	defer a.Close()
+	defer b.Close()
+	if err := compareStore(a, b); err != nil {
+		t.Error(err)
+	}
+	if v, err := b.Get([]byte("empty")); err != nil || len(v) != 0 {
+		t.Errorf("unexpected empty value %v %v", v, err)
+	}
+}
@@ -15,10 +15,13 @@
 
 package tasdb
 
-import "github.com/syndtr/goleveldb/leveldb/iterator"
+import "errors"
 
 const IdealBatchSize = 100 * 1024
 
+// ErrNotFound is returned by the databases if the key is not found
+var ErrNotFound = errors.New("not found")
+
 type Putter interface {
 	Put(key []byte, value []byte) error
 }
@@ -50,6 +53,38 @@ type Database interface {
 	Has(key []byte) (bool, error)
 	Close()
 	NewBatch() Batch
-	NewIterator() iterator.Iterator
-	NewIteratorWithPrefix(prefix []byte) iterator.Iterator
+	NewIterator() Iterator
+	NewIteratorWithPrefix(prefix []byte) Iterator
+}
+
+// Iterator iterates over the key/value pairs of a database in the key order. A new iterator is
+// positioned before the first entry, so Next moves it to the first one. The exhausted iterator
+// moves back to the last one by Prev.
+type Iterator interface {
+	First() bool
+	Last() bool
+	Seek(key []byte) bool
+	Next() bool
+	Prev() bool
+
+	// Valid returns whether the iterator is positioned at an entry
+	Valid() bool
+	Key() []byte
+	Value() []byte
+	Error() error
+
+	// Release releases the resources held by the iterator, which can't be used any more
+	Release()
+}
+
+// KeyValueStore is the database implemented by a storage engine which the data sources are built on
+type KeyValueStore interface {
+	Database
+
+	// Path returns the directory of the database
+	Path() string
+
+	// Compact compacts the underlying storage for the key range [start, limit),
+	// the nil start or limit means the start or end of the whole key space
+	Compact(start, limit []byte) error
 }
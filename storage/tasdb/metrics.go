@@ -0,0 +1,147 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tasdb
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/zvchain/zvchain/middleware/metrics"
+)
+
+// statsCacheTime is how long the stats are reused, so that the metrics collected in one scrape
+// share a single read of the stats
+const statsCacheTime = time.Second
+
+// Stats returns the leveldb stats of the underlying database, which fails on the other engines
+func (db *PrefixedDatabase) Stats() (*leveldb.DBStats, error) {
+	stater, ok := db.db.(levelDBStater)
+	if !ok {
+		return nil, fmt.Errorf("stats not supported by the db engine")
+	}
+	stats := &leveldb.DBStats{}
+	if err := stater.Stats(stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+type statsReader struct {
+	db   *PrefixedDatabase
+	lock sync.Mutex
+	at   time.Time
+	last *leveldb.DBStats
+}
+
+func (r *statsReader) read() *leveldb.DBStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.last != nil && time.Since(r.at) < statsCacheTime {
+		return r.last
+	}
+	stats, err := r.db.Stats()
+	if err != nil {
+		return nil
+	}
+	r.last, r.at = stats, time.Now()
+	return stats
+}
+
+// RegisterMetrics registers the leveldb stats of the underlying database as the metrics named
+// with the given prefix, including the sizes and compactions of each level.
+// The stats accumulated since the database opened are registered as counters
+func (db *PrefixedDatabase) RegisterMetrics(prefix string) {
+	r := &statsReader{db: db}
+	single := func(name string, typ metrics.Type, help string, fn func(s *leveldb.DBStats) float64) {
+		metrics.NewCollector(prefix+name, help, typ, func() []*metrics.Sample {
+			s := r.read()
+			if s == nil {
+				return nil
+			}
+			return []*metrics.Sample{{Name: prefix + name, Value: fn(s)}}
+		})
+	}
+	levels := func(name string, typ metrics.Type, help string, fn func(s *leveldb.DBStats, level int) (float64, bool)) {
+		metrics.NewCollector(prefix+name, help, typ, func() []*metrics.Sample {
+			s := r.read()
+			if s == nil {
+				return nil
+			}
+			samples := make([]*metrics.Sample, 0, len(s.LevelSizes))
+			for level := range s.LevelSizes {
+				if v, ok := fn(s, level); ok {
+					samples = append(samples, &metrics.Sample{Name: prefix + name, Labels: []metrics.Label{{Name: "level", Value: strconv.Itoa(level)}}, Value: v})
+				}
+			}
+			return samples
+		})
+	}
+
+	single("_write_delay_total", metrics.TypeCounter, "Number of the writes delayed by the compaction", func(s *leveldb.DBStats) float64 {
+		return float64(s.WriteDelayCount)
+	})
+	single("_write_delay_seconds_total", metrics.TypeCounter, "Duration of the writes delayed by the compaction", func(s *leveldb.DBStats) float64 {
+		return s.WriteDelayDuration.Seconds()
+	})
+	single("_write_paused", metrics.TypeGauge, "Whether the writes are paused by the compaction", func(s *leveldb.DBStats) float64 {
+		if s.WritePaused {
+			return 1
+		}
+		return 0
+	})
+	single("_io_read_bytes_total", metrics.TypeCounter, "Bytes read from the disk", func(s *leveldb.DBStats) float64 {
+		return float64(s.IORead)
+	})
+	single("_io_write_bytes_total", metrics.TypeCounter, "Bytes written to the disk", func(s *leveldb.DBStats) float64 {
+		return float64(s.IOWrite)
+	})
+	single("_block_cache_bytes", metrics.TypeGauge, "Size of the block cache", func(s *leveldb.DBStats) float64 {
+		return float64(s.BlockCacheSize)
+	})
+	single("_opened_tables", metrics.TypeGauge, "Number of the opened tables", func(s *leveldb.DBStats) float64 {
+		return float64(s.OpenedTablesCount)
+	})
+	levels("_level_size_bytes", metrics.TypeGauge, "Size of the tables in each level", func(s *leveldb.DBStats, level int) (float64, bool) {
+		return float64(s.LevelSizes[level]), true
+	})
+	levels("_level_tables", metrics.TypeGauge, "Number of the tables in each level", func(s *leveldb.DBStats, level int) (float64, bool) {
+		if level >= len(s.LevelTablesCounts) {
+			return 0, false
+		}
+		return float64(s.LevelTablesCounts[level]), true
+	})
+	levels("_compaction_read_bytes_total", metrics.TypeCounter, "Bytes read by the compactions of each level", func(s *leveldb.DBStats, level int) (float64, bool) {
+		if level >= len(s.LevelRead) {
+			return 0, false
+		}
+		return float64(s.LevelRead[level]), true
+	})
+	levels("_compaction_write_bytes_total", metrics.TypeCounter, "Bytes written by the compactions of each level", func(s *leveldb.DBStats, level int) (float64, bool) {
+		if level >= len(s.LevelWrite) {
+			return 0, false
+		}
+		return float64(s.LevelWrite[level]), true
+	})
+	levels("_compaction_seconds_total", metrics.TypeCounter, "Duration of the compactions of each level", func(s *leveldb.DBStats, level int) (float64, bool) {
+		if level >= len(s.LevelDurations) {
+			return 0, false
+		}
+		return s.LevelDurations[level].Seconds(), true
+	})
+}
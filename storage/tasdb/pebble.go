@@ -0,0 +1,325 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tasdb
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/bloom"
+	"github.com/zvchain/zvchain/common"
+)
+
+const (
+	// PebbleEngine is the name of the pebble storage engine. It's an LSM store like leveldb, with
+	// less write amplification from the concurrent compactions, the sized levels and the flushes
+	// of large memtables
+	PebbleEngine = "pebble"
+
+	// pebbleLevels is the count of the levels of the LSM tree
+	pebbleLevels = 7
+)
+
+func init() {
+	RegisterEngine(&Engine{
+		Name: PebbleEngine,
+		Open: func(file string, options *Options) (KeyValueStore, error) {
+			return NewPebbleDatabase(file, options)
+		},
+		Created: pebbleCreated,
+	})
+}
+
+// pebbleCreated checks the OPTIONS file, which pebble writes and leveldb doesn't
+func pebbleCreated(file string) bool {
+	matches, err := filepath.Glob(filepath.Join(file, "OPTIONS-*"))
+	return err == nil && len(matches) > 0
+}
+
+// PebbleDatabase is the database stored by pebble in the given directory
+type PebbleDatabase struct {
+	db   *pebble.DB
+	path string
+}
+
+// NewPebbleDatabase opens the pebble database in the given directory
+func NewPebbleDatabase(file string, options *Options) (*PebbleDatabase, error) {
+	o := pebbleOptions(options)
+	if o.Cache != nil {
+		// The database holds its own reference
+		defer o.Cache.Unref()
+	}
+	if !o.ReadOnly {
+		if err := os.MkdirAll(file, 0700); err != nil {
+			return nil, err
+		}
+	}
+	db, err := pebble.Open(file, o)
+	if err != nil {
+		return nil, err
+	}
+	return &PebbleDatabase{db: db, path: file}, nil
+}
+
+func pebbleOptions(options *Options) *pebble.Options {
+	o := &pebble.Options{}
+	if options == nil {
+		return o
+	}
+	if options.BlockCacheCapacity > 0 {
+		o.Cache = pebble.NewCache(int64(options.BlockCacheCapacity))
+	}
+	if options.WriteBuffer > 0 {
+		o.MemTableSize = uint64(options.WriteBuffer)
+	}
+	o.MaxOpenFiles = options.OpenFilesCacheCapacity
+	o.LBaseMaxBytes = int64(options.CompactionTotalSize)
+	o.ReadOnly = options.ReadOnly
+
+	// The table size grows by the multiplier level by level as leveldb does
+	tableSize := float64(options.CompactionTableSize)
+	for i := 0; i < pebbleLevels; i++ {
+		level := pebble.LevelOptions{
+			BlockSize:      options.BlockSize,
+			TargetFileSize: int64(tableSize),
+		}
+		if options.BloomFilterBits > 0 {
+			level.FilterPolicy = bloom.FilterPolicy(options.BloomFilterBits)
+		}
+		o.Levels = append(o.Levels, level)
+		if options.CompactionTableSizeMultiplier > 0 {
+			tableSize *= options.CompactionTableSizeMultiplier
+		}
+	}
+	return o
+}
+
+// Path returns the directory of the database
+func (pdb *PebbleDatabase) Path() string {
+	return pdb.path
+}
+
+func (pdb *PebbleDatabase) Get(key []byte) ([]byte, error) {
+	value, closer, err := pdb.db.Get(key)
+	if err == pebble.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	// The value is only valid until closed
+	ret := make([]byte, len(value))
+	copy(ret, value)
+	return ret, nil
+}
+
+func (pdb *PebbleDatabase) Has(key []byte) (bool, error) {
+	_, closer, err := pdb.db.Get(key)
+	if err == pebble.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	closer.Close()
+	return true, nil
+}
+
+// Put writes the entry without syncing the log, same as leveldb does
+func (pdb *PebbleDatabase) Put(key []byte, value []byte) error {
+	return pdb.db.Set(key, value, pebble.NoSync)
+}
+
+func (pdb *PebbleDatabase) Delete(key []byte) error {
+	return pdb.db.Delete(key, pebble.NoSync)
+}
+
+func (pdb *PebbleDatabase) NewIterator() Iterator {
+	return pdb.NewIteratorWithPrefix(nil)
+}
+
+// NewIteratorWithPrefix returns a iterator to iterate over subset of database content with a particular prefix.
+func (pdb *PebbleDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
+	iter, err := pdb.db.NewIter(&pebble.IterOptions{
+		LowerBound: common.CopyBytes(prefix),
+		UpperBound: prefixUpperBound(prefix),
+	})
+	return &pebbleIterator{iter: iter, err: err}
+}
+
+func (pdb *PebbleDatabase) NewBatch() Batch {
+	return &pebbleBatch{b: pdb.db.NewBatch()}
+}
+
+// Compact compacts the key range [start, limit), the nil limit is replaced by the one after the last key
+func (pdb *PebbleDatabase) Compact(start, limit []byte) error {
+	if start == nil {
+		start = []byte{}
+	}
+	if limit == nil {
+		iter, err := pdb.db.NewIter(nil)
+		if err != nil {
+			return err
+		}
+		if iter.Last() {
+			limit = append(common.CopyBytes(iter.Key()), 0)
+		}
+		if err := iter.Close(); err != nil {
+			return err
+		}
+	}
+	// Nothing to compact in the empty range
+	if limit == nil || bytes.Compare(start, limit) >= 0 {
+		return nil
+	}
+	return pdb.db.Compact(start, limit, true)
+}
+
+func (pdb *PebbleDatabase) Close() {
+	pdb.db.Close()
+}
+
+type pebbleBatch struct {
+	b    *pebble.Batch
+	size int
+}
+
+func (b *pebbleBatch) Put(key, value []byte) error {
+	b.size += len(value)
+	return b.b.Set(key, value, nil)
+}
+
+func (b *pebbleBatch) Delete(key []byte) error {
+	b.size++
+	return b.b.Delete(key, nil)
+}
+
+func (b *pebbleBatch) Write() error {
+	return b.b.Commit(pebble.NoSync)
+}
+
+func (b *pebbleBatch) ValueSize() int {
+	return b.size
+}
+
+func (b *pebbleBatch) Reset() {
+	b.b.Reset()
+	b.size = 0
+}
+
+const (
+	iterBeforeFirst = iota
+	iterAtEntry
+	iterAfterLast
+)
+
+// pebbleIterator adapts the pebble iterator, which has to be positioned by First or Last before moving,
+// to the leveldb behaviors: the new iterator is before the first entry, and the exhausted one moves back
+// to the first or last entry by turning around.
+type pebbleIterator struct {
+	iter     *pebble.Iterator
+	pos      int
+	err      error
+	released bool
+}
+
+// set records the position after a move, end is the position when moved out of the entries
+func (it *pebbleIterator) set(ok bool, end int) bool {
+	if ok {
+		it.pos = iterAtEntry
+	} else {
+		it.pos = end
+	}
+	return ok
+}
+
+func (it *pebbleIterator) usable() bool {
+	return it.iter != nil && !it.released
+}
+
+func (it *pebbleIterator) First() bool {
+	return it.usable() && it.set(it.iter.First(), iterAfterLast)
+}
+
+func (it *pebbleIterator) Last() bool {
+	return it.usable() && it.set(it.iter.Last(), iterBeforeFirst)
+}
+
+// Seek moves the iterator to the first entry with the key not less than the given one
+func (it *pebbleIterator) Seek(key []byte) bool {
+	return it.usable() && it.set(it.iter.SeekGE(key), iterAfterLast)
+}
+
+func (it *pebbleIterator) Next() bool {
+	if !it.usable() {
+		return false
+	}
+	switch it.pos {
+	case iterBeforeFirst:
+		return it.First()
+	case iterAfterLast:
+		return false
+	}
+	return it.set(it.iter.Next(), iterAfterLast)
+}
+
+func (it *pebbleIterator) Prev() bool {
+	if !it.usable() {
+		return false
+	}
+	switch it.pos {
+	case iterAfterLast:
+		return it.Last()
+	case iterBeforeFirst:
+		return false
+	}
+	return it.set(it.iter.Prev(), iterBeforeFirst)
+}
+
+func (it *pebbleIterator) Valid() bool {
+	return it.usable() && it.pos == iterAtEntry && it.iter.Valid()
+}
+
+func (it *pebbleIterator) Key() []byte {
+	if !it.Valid() {
+		return nil
+	}
+	return it.iter.Key()
+}
+
+func (it *pebbleIterator) Value() []byte {
+	if !it.Valid() {
+		return nil
+	}
+	return it.iter.Value()
+}
+
+func (it *pebbleIterator) Error() error {
+	if it.err != nil || it.iter == nil {
+		return it.err
+	}
+	return it.iter.Error()
+}
+
+func (it *pebbleIterator) Release() {
+	if it.usable() {
+		it.err = it.iter.Close()
+	}
+	it.released = true
+}
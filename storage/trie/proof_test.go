@@ -0,0 +1,128 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/zvchain/zvchain/common"
+)
+
+func newProofTestTrie(n int) (*Trie, map[string][]byte) {
+	trie := newTrieFromDB("test_proof", common.Hash{})
+	vals := make(map[string][]byte)
+	for i := 0; i < n; i++ {
+		k := common.Sha256([]byte(fmt.Sprintf("key%d", i)))
+		v := []byte(fmt.Sprintf("value%d", i))
+		trie.TryUpdate(k, v)
+		vals[string(k)] = v
+	}
+	// Short keys make embedded nodes
+	trie.TryUpdate([]byte("1"), []byte("a"))
+	vals["1"] = []byte("a")
+	trie.TryUpdate([]byte("12"), []byte("b"))
+	vals["12"] = []byte("b")
+	return trie, vals
+}
+
+func TestProve(t *testing.T) {
+	trie, vals := newProofTestTrie(500)
+	root := trie.Hash()
+	for k, v := range vals {
+		proof, err := trie.Prove([]byte(k))
+		if err != nil {
+			t.Fatalf("prove error for key %x: %v", k, err)
+		}
+		val, err := VerifyProof(root, []byte(k), proof)
+		if err != nil {
+			t.Fatalf("verify proof error for key %x: %v", k, err)
+		}
+		if !bytes.Equal(val, v) {
+			t.Fatalf("verified value mismatch for key %x: have %x, want %x", k, val, v)
+		}
+	}
+}
+
+func TestProveCommitted(t *testing.T) {
+	trie, vals := newProofTestTrie(100)
+	root, err := trie.Commit(nil)
+	if err != nil {
+		t.Fatalf("commit error:%v", err)
+	}
+	trie.db.Commit(0, root, false)
+	reopened, err := NewTrie(root, trie.db)
+	if err != nil {
+		t.Fatalf("reopen trie error:%v", err)
+	}
+	for k, v := range vals {
+		proof, err := reopened.Prove([]byte(k))
+		if err != nil {
+			t.Fatalf("prove error for key %x: %v", k, err)
+		}
+		val, err := VerifyProof(root, []byte(k), proof)
+		if err != nil || !bytes.Equal(val, v) {
+			t.Fatalf("verify proof failed for key %x: %v", k, err)
+		}
+	}
+}
+
+func TestProveAbsent(t *testing.T) {
+	trie, _ := newProofTestTrie(100)
+	root := trie.Hash()
+	key := common.Sha256([]byte("not exist"))
+	proof, err := trie.Prove(key)
+	if err != nil {
+		t.Fatalf("prove error:%v", err)
+	}
+	val, err := VerifyProof(root, key, proof)
+	if err != nil {
+		t.Fatalf("verify absent proof error:%v", err)
+	}
+	if val != nil {
+		t.Fatalf("absent key should have nil value, have %x", val)
+	}
+}
+
+func TestVerifyBadProof(t *testing.T) {
+	trie, vals := newProofTestTrie(100)
+	root := trie.Hash()
+	for k := range vals {
+		proof, _ := trie.Prove([]byte(k))
+		if len(proof) == 0 {
+			t.Fatalf("empty proof for key %x", k)
+		}
+		// Drop the last node
+		if _, err := VerifyProof(root, []byte(k), proof[:len(proof)-1]); err == nil && len(proof) > 1 {
+			t.Fatalf("expected error for incomplete proof of key %x", k)
+		}
+		// Modify a node
+		bad := make([][]byte, len(proof))
+		copy(bad, proof)
+		node := common.CopyBytes(bad[len(bad)-1])
+		node[len(node)-1] ^= 0x1
+		bad[len(bad)-1] = node
+		if val, err := VerifyProof(root, []byte(k), bad); err == nil && bytes.Equal(val, vals[k]) {
+			t.Fatalf("expected error for modified proof of key %x", k)
+		}
+		// Wrong root
+		if _, err := VerifyProof(common.BytesToHash([]byte("wrong")), []byte(k), proof); err == nil {
+			t.Fatalf("expected error for wrong root of key %x", k)
+		}
+	}
+}
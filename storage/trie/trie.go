@@ -20,9 +20,9 @@ package trie
 import (
 	"bytes"
 	"fmt"
-	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/zvchain/zvchain/common"
 	"github.com/zvchain/zvchain/log"
+	"github.com/zvchain/zvchain/storage/tasdb"
 )
 
 var (
@@ -412,7 +412,7 @@ func (t *Trie) resolveHash(n hashNode, prefix []byte) (node, error) {
 	hash := common.BytesToHash(n)
 	if node, _, err := t.db.node(hash, t.cachegen); node != nil {
 		return node, nil
-	} else if err == leveldb.ErrNotFound {
+	} else if err == tasdb.ErrNotFound {
 		return nil, &MissingNodeError{NodeHash: hash, Path: prefix}
 	} else {
 		return nil, err
@@ -423,7 +423,7 @@ func (t *Trie) resolveHashAndGetRawBytes(n hashNode, prefix []byte) (node, []byt
 	hash := common.BytesToHash(n)
 	if node, bs, err := t.db.node(hash, t.cachegen); node != nil {
 		return node, bs, nil
-	} else if err == leveldb.ErrNotFound {
+	} else if err == tasdb.ErrNotFound {
 		return nil, nil, &MissingNodeError{NodeHash: hash, Path: prefix}
 	} else {
 		return nil, nil, err
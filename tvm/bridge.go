@@ -41,9 +41,12 @@ func Transfer(toAddress *C.char, value *C.char) bool {
 	to := common.StringToAddress(toAddressStr)
 
 	if !controller.AccountDB.CanTransfer(*contractAddr, transValue) {
+		controller.traceTransfer(*contractAddr, to, transValue, false)
 		return false
 	}
 	controller.AccountDB.Transfer(*contractAddr, to, transValue)
+	controller.transferees = append(controller.transferees, to)
+	controller.traceTransfer(*contractAddr, to, transValue, true)
 	return true
 
 }
@@ -63,7 +66,9 @@ func GetBalance(addressC *C.char) *C.char {
 func GetData(key *C.char, keyLen C.int, value **C.char, valueLen *C.int) {
 	//hash := common.StringToHash(C.GoString(hashC))
 	address := *controller.VM.ContractAddress
-	state := controller.AccountDB.GetData(address, C.GoBytes(unsafe.Pointer(key), keyLen))
+	k := C.GoBytes(unsafe.Pointer(key), keyLen)
+	state := controller.AccountDB.GetData(address, k)
+	controller.traceStorageRead(address, k, state)
 	if state == nil {
 		*value = nil
 		*valueLen = -1
@@ -79,6 +84,7 @@ func SetData(key *C.char, kenLen C.int, value *C.char, valueLen C.int) {
 	k := C.GoBytes(unsafe.Pointer(key), kenLen)
 	v := C.GoBytes(unsafe.Pointer(value), valueLen)
 	controller.AccountDB.SetData(address, k, v)
+	controller.traceStorageWrite(address, k, v)
 }
 
 //export BlockHash
@@ -107,7 +113,10 @@ func TxGasLimit() C.ulonglong {
 
 //export ContractCall
 func ContractCall(addressC *C.char, funName *C.char, jsonParms *C.char, cResult unsafe.Pointer) {
-	goResult := CallContract(C.GoString(addressC), C.GoString(funName), C.GoString(jsonParms))
+	address, function, params := C.GoString(addressC), C.GoString(funName), C.GoString(jsonParms)
+	controller.traceContractCall(address, function, params)
+	goResult := CallContract(address, function, params)
+	controller.traceExit(goResult, nil, uint64(controller.VM.Gas()))
 	ccResult := (*C.struct__tvm_execute_result_t)(cResult)
 	ccResult.result_type = C.int(goResult.ResultType)
 	ccResult.error_code = C.int(goResult.ErrorCode)
@@ -120,7 +129,8 @@ func ContractCall(addressC *C.char, funName *C.char, jsonParms *C.char, cResult
 func EventCall(eventName *C.char, data *C.char, dataLen C.int) {
 
 	var log types.Log
-	log.Topic = common.BytesToHash(common.Sha256([]byte(C.GoString(eventName))))
+	name := C.GoString(eventName)
+	log.Topic = common.BytesToHash(common.Sha256([]byte(name)))
 	log.Index = uint(len(controller.VM.Logs))
 	log.Data = C.GoBytes(unsafe.Pointer(data), dataLen)
 	log.TxHash = controller.Transaction.GetHash()
@@ -130,6 +140,7 @@ func EventCall(eventName *C.char, data *C.char, dataLen C.int) {
 	// log.BlockHash = controller.BlockHeader.Hash
 
 	controller.VM.Logs = append(controller.VM.Logs, &log)
+	controller.traceEvent(log.Address, name, log.Data)
 }
 
 //export RemoveData
@@ -137,4 +148,5 @@ func RemoveData(key *C.char, kenLen C.int) {
 	address := *controller.VM.ContractAddress
 	k := C.GoBytes(unsafe.Pointer(key), kenLen)
 	controller.AccountDB.RemoveData(address, k)
+	controller.traceStorageWrite(address, k, nil)
 }
@@ -37,6 +37,9 @@ type Controller struct {
 	VMStack     []*TVM
 	GasLeft     uint64
 	mm          MinerManager
+
+	transferees []common.Address // Recipients of the transfers made by the contracts
+	tracer      Tracer
 }
 
 // MinerManager MinerManager is the interface of the miner manager
@@ -65,6 +68,8 @@ func NewController(accountDB types.AccountDB,
 	controller.VMStack = make([]*TVM, 0)
 	controller.GasLeft = transaction.GetGasLimit() - gasUsed
 	controller.mm = manager
+	controller.transferees = nil
+	controller.tracer = activeTracer
 	return controller
 }
 
@@ -84,6 +89,13 @@ func transactionErrorWith(result *ExecuteResult) *types.TransactionError {
 
 // Deploy Deploy a contract instance
 func (con *Controller) Deploy(contract *Contract) (*ExecuteResult, []*types.Log, *types.TransactionError) {
+	con.traceDeploy(contract)
+	result, logs, err := con.deploy(contract)
+	con.traceExit(result, err, con.GasLeft)
+	return result, logs, err
+}
+
+func (con *Controller) deploy(contract *Contract) (*ExecuteResult, []*types.Log, *types.TransactionError) {
 	var blockHeight uint64 = 0
 	if con.BlockHeader != nil {
 		blockHeight = con.BlockHeader.Height
@@ -107,6 +119,13 @@ func (con *Controller) Deploy(contract *Contract) (*ExecuteResult, []*types.Log,
 
 // ExecuteAbiEval Execute the contract with abi and returns result
 func (con *Controller) ExecuteAbiEval(sender *common.Address, contract *Contract, abiJSON string) (*ExecuteResult, []*types.Log, *types.TransactionError) {
+	con.traceAbiCall(sender, contract.ContractAddress, abiJSON)
+	result, logs, err := con.executeAbiEval(sender, contract, abiJSON)
+	con.traceExit(result, err, con.GasLeft)
+	return result, logs, err
+}
+
+func (con *Controller) executeAbiEval(sender *common.Address, contract *Contract, abiJSON string) (*ExecuteResult, []*types.Log, *types.TransactionError) {
 	var blockHeight uint64 = 0
 	if con.BlockHeader != nil {
 		blockHeight = con.BlockHeader.Height
@@ -148,6 +167,11 @@ func (con *Controller) GetGasLeft() uint64 {
 	return con.GasLeft
 }
 
+// Transferees returns the recipients of the transfers made by the contracts during the execution
+func (con *Controller) Transferees() []common.Address {
+	return con.transferees
+}
+
 func BytesToBigInt(bs []byte) *big.Int {
 	res := &big.Int{}
 	isNeg := false
@@ -0,0 +1,238 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tvm
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+)
+
+// Types of the call frames
+const (
+	CallTypeCreate = "create" // Contract deployment
+	CallTypeCall   = "call"   // Contract call from the transaction or another contract
+)
+
+// Tracer receives the events during the contract execution
+type Tracer interface {
+	// CaptureEnter is called when the contract is deployed or called
+	CaptureEnter(typ string, from, to common.Address, function, input string, gas uint64)
+	// CaptureExit is called when the deployment or call returns
+	CaptureExit(output string, err *types.TransactionError, gasLeft uint64)
+	CaptureStorageRead(addr common.Address, key, value []byte)
+	// CaptureStorageWrite is called when the data is set or removed, the value is nil for removing
+	CaptureStorageWrite(addr common.Address, key, value []byte)
+	CaptureTransfer(from, to common.Address, value *big.Int, success bool)
+	CaptureEvent(addr common.Address, name string, data []byte)
+}
+
+// activeTracer is attached to the controller created, nil if tracing disabled.
+// Like the controller, it should be used with the block executing locked
+var activeTracer Tracer
+
+// SetTracer sets the tracer of the contract executions afterwards, nil to disable
+func SetTracer(t Tracer) {
+	activeTracer = t
+}
+
+// StorageAccess is one read or write of the contract storage
+type StorageAccess struct {
+	Address common.Address
+	Key     []byte
+	Value   []byte // Nil if not exists or removed
+}
+
+// TransferRecord is the transfer made by the contract
+type TransferRecord struct {
+	From    common.Address
+	To      common.Address
+	Value   *big.Int
+	Success bool
+}
+
+// EventRecord is the event emitted by the contract
+type EventRecord struct {
+	Address common.Address
+	Name    string
+	Data    []byte
+}
+
+// CallFrame is one deployment or call of the contract together with the nested calls made by it
+type CallFrame struct {
+	Type      string
+	From      common.Address
+	To        common.Address
+	Function  string
+	Input     string
+	Gas       uint64 // Gas available when entering
+	GasUsed   uint64
+	Output    string
+	Error     *types.TransactionError
+	Reads     []*StorageAccess
+	Writes    []*StorageAccess
+	Transfers []*TransferRecord
+	Events    []*EventRecord
+	Calls     []*CallFrame
+}
+
+// CallTracer records the call tree of one transaction
+type CallTracer struct {
+	root  *CallFrame
+	stack []*CallFrame
+}
+
+// NewCallTracer creates a call tracer
+func NewCallTracer() *CallTracer {
+	return &CallTracer{stack: make([]*CallFrame, 0)}
+}
+
+// Root returns the outermost frame, nil if no contract executed
+func (t *CallTracer) Root() *CallFrame {
+	return t.root
+}
+
+func (t *CallTracer) current() *CallFrame {
+	if len(t.stack) == 0 {
+		return nil
+	}
+	return t.stack[len(t.stack)-1]
+}
+
+func (t *CallTracer) CaptureEnter(typ string, from, to common.Address, function, input string, gas uint64) {
+	frame := &CallFrame{Type: typ, From: from, To: to, Function: function, Input: input, Gas: gas}
+	if parent := t.current(); parent != nil {
+		parent.Calls = append(parent.Calls, frame)
+	} else if t.root == nil {
+		t.root = frame
+	}
+	t.stack = append(t.stack, frame)
+}
+
+func (t *CallTracer) CaptureExit(output string, err *types.TransactionError, gasLeft uint64) {
+	frame := t.current()
+	if frame == nil {
+		return
+	}
+	if frame.Gas > gasLeft {
+		frame.GasUsed = frame.Gas - gasLeft
+	}
+	frame.Output = output
+	frame.Error = err
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *CallTracer) CaptureStorageRead(addr common.Address, key, value []byte) {
+	if frame := t.current(); frame != nil {
+		frame.Reads = append(frame.Reads, &StorageAccess{Address: addr, Key: key, Value: value})
+	}
+}
+
+func (t *CallTracer) CaptureStorageWrite(addr common.Address, key, value []byte) {
+	if frame := t.current(); frame != nil {
+		frame.Writes = append(frame.Writes, &StorageAccess{Address: addr, Key: key, Value: value})
+	}
+}
+
+func (t *CallTracer) CaptureTransfer(from, to common.Address, value *big.Int, success bool) {
+	if frame := t.current(); frame != nil {
+		frame.Transfers = append(frame.Transfers, &TransferRecord{From: from, To: to, Value: new(big.Int).Set(value), Success: success})
+	}
+}
+
+func (t *CallTracer) CaptureEvent(addr common.Address, name string, data []byte) {
+	if frame := t.current(); frame != nil {
+		frame.Events = append(frame.Events, &EventRecord{Address: addr, Name: name, Data: data})
+	}
+}
+
+// The functions below are called by the controller and the bridge, and do nothing if not tracing
+
+// traceDeploy is called before the contract deployed by the transaction
+func (con *Controller) traceDeploy(contract *Contract) {
+	if con.tracer == nil {
+		return
+	}
+	var from common.Address
+	if op := con.Transaction.Operator(); op != nil {
+		from = *op
+	}
+	con.tracer.CaptureEnter(CallTypeCreate, from, *contract.ContractAddress, "", "", con.GasLeft)
+}
+
+func (con *Controller) traceExit(result *ExecuteResult, err *types.TransactionError, gasLeft uint64) {
+	if con.tracer == nil {
+		return
+	}
+	output := ""
+	if result != nil {
+		output = result.Content
+		if err == nil {
+			err = transactionErrorWith(result)
+		}
+	}
+	con.tracer.CaptureExit(output, err, gasLeft)
+}
+
+// traceAbiCall is called before the transaction calls the contract with the abi
+func (con *Controller) traceAbiCall(from, to *common.Address, abiJSON string) {
+	if con.tracer == nil {
+		return
+	}
+	var abi struct {
+		FuncName string `json:"func_name"`
+	}
+	json.Unmarshal([]byte(abiJSON), &abi)
+	con.tracer.CaptureEnter(CallTypeCall, *from, *to, abi.FuncName, abiJSON, con.GasLeft)
+}
+
+// traceContractCall is called before the contract calls another one
+func (con *Controller) traceContractCall(toAddr, function, params string) {
+	if con.tracer == nil {
+		return
+	}
+	var to common.Address
+	if common.ValidateAddress(toAddr) {
+		to = common.StringToAddress(toAddr)
+	}
+	con.tracer.CaptureEnter(CallTypeCall, *con.VM.ContractAddress, to, function, params, uint64(con.VM.Gas()))
+}
+
+func (con *Controller) traceStorageRead(addr common.Address, key, value []byte) {
+	if con.tracer != nil {
+		con.tracer.CaptureStorageRead(addr, key, value)
+	}
+}
+
+func (con *Controller) traceStorageWrite(addr common.Address, key, value []byte) {
+	if con.tracer != nil {
+		con.tracer.CaptureStorageWrite(addr, key, value)
+	}
+}
+
+func (con *Controller) traceTransfer(from, to common.Address, value *big.Int, success bool) {
+	if con.tracer != nil {
+		con.tracer.CaptureTransfer(from, to, value, success)
+	}
+}
+
+func (con *Controller) traceEvent(addr common.Address, name string, data []byte) {
+	if con.tracer != nil {
+		con.tracer.CaptureEvent(addr, name, data)
+	}
+}
@@ -0,0 +1,58 @@
+//   Copyright (C) 2018 ZVChain
+//
+//   This program is free software: you can redistribute it and/or modify
+//   it under the terms of the GNU General Public License as published by
+//   the Free Software Foundation, either version 3 of the License, or
+//   (at your option) any later version.
+//
+//   This program is distributed in the hope that it will be useful,
+//   but WITHOUT ANY WARRANTY; without even the implied warranty of
+//   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//   GNU General Public License for more details.
+//
+//   You should have received a copy of the GNU General Public License
+//   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tvm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/zvchain/zvchain/common"
+	"github.com/zvchain/zvchain/middleware/types"
+)
+
+func TestCallTracer(t *testing.T) {
+	sender := common.BytesToAddress([]byte{1})
+	contractA := common.BytesToAddress([]byte{2})
+	contractB := common.BytesToAddress([]byte{3})
+
+	tracer := NewCallTracer()
+	tracer.CaptureEnter(CallTypeCall, sender, contractA, "foo", `{"func_name":"foo","args":[]}`, 1000)
+	tracer.CaptureStorageRead(contractA, []byte("k"), []byte("v"))
+	tracer.CaptureEnter(CallTypeCall, contractA, contractB, "bar", "[]", 800)
+	tracer.CaptureStorageWrite(contractB, []byte("k"), nil)
+	tracer.CaptureTransfer(contractB, sender, big.NewInt(10), true)
+	tracer.CaptureExit("ok", nil, 600)
+	tracer.CaptureEvent(contractA, "done", []byte("data"))
+	tracer.CaptureExit("", types.NewTransactionError(types.TVMExecutedError, "failed"), 300)
+
+	root := tracer.Root()
+	if root == nil || root.To != contractA || root.GasUsed != 700 || root.Error == nil {
+		t.Fatalf("unexpected root frame %+v", root)
+	}
+	if len(root.Reads) != 1 || len(root.Events) != 1 || len(root.Writes) != 0 || len(root.Calls) != 1 {
+		t.Errorf("unexpected records of the root frame %+v", root)
+	}
+	sub := root.Calls[0]
+	if sub.From != contractA || sub.To != contractB || sub.GasUsed != 200 || sub.Output != "ok" || sub.Error != nil {
+		t.Errorf("unexpected sub frame %+v", sub)
+	}
+	if len(sub.Writes) != 1 || sub.Writes[0].Value != nil || len(sub.Transfers) != 1 || sub.Transfers[0].Value.Int64() != 10 {
+		t.Errorf("unexpected records of the sub frame %+v", sub)
+	}
+	// Events out of any frame are ignored
+	tracer.CaptureEvent(contractA, "ignored", nil)
+	tracer.CaptureExit("", nil, 0)
+}